@@ -25,6 +25,10 @@ DOCKER_PLATFORMS="linux/amd64,linux/arm64"
 GH_REPO="4throckcloud/obs-agent"
 GH_TOKEN_FILE="/home/ubuntu/production/obs-stack/secrets/ghcr_token"
 
+# Ed25519 key that signs manifest.json (PEM). The matching public key is
+# built into the binary (integrity.ManifestPublicKey) to verify it.
+MANIFEST_KEY_FILE="/home/ubuntu/production/obs-stack/secrets/manifest_ed25519.pem"
+
 # Binary definitions for download (GitHub Release zips + manifest)
 # format: filename os arch
 BUILDS=(
@@ -60,6 +64,20 @@ load_gh_token() {
     [[ -n "$GH_TOKEN" ]] || die "GitHub token is empty"
 }
 
+# Base64 raw public key for the binary's ldflags
+manifest_pubkey() {
+    [[ -f "$MANIFEST_KEY_FILE" ]] || die "Manifest signing key not found: $MANIFEST_KEY_FILE"
+    # DER SubjectPublicKeyInfo for Ed25519 is a 12-byte prefix + 32-byte key
+    openssl pkey -in "$MANIFEST_KEY_FILE" -pubout -outform DER | tail -c 32 | base64
+}
+
+# Detached signature: manifest.json → manifest.json.sig (raw 64 bytes)
+sign_manifest() {
+    local manifest="$1"
+    openssl pkeyutl -sign -inkey "$MANIFEST_KEY_FILE" -rawin -in "$manifest" -out "${manifest}.sig" \
+        || die "Signing $manifest failed"
+}
+
 # Zip name: obs-agent-windows-amd64.exe → obs-agent-windows-amd64.zip
 zip_name() {
     local filename="$1"
@@ -171,7 +189,7 @@ do_build() {
 
     # Build all platforms via Docker (includes linux-arm64)
     echo "→ Building binaries..."
-    (cd "$OBS_STACK_DIR" && docker compose run --rm obs-agent-builder make -C build build-all VERSION="v${version}")
+    (cd "$OBS_STACK_DIR" && docker compose run --rm obs-agent-builder make -C build build-all VERSION="v${version}" MANIFEST_PUBKEY="$(manifest_pubkey)")
 
     # Verify all builds exist
     for entry in "${BUILDS[@]}"; do
@@ -213,6 +231,7 @@ do_build() {
     # Generate manifest
     echo "→ Generating manifest..."
     generate_manifest "$version" "staging" "v${version}" > "$DIST_DIR/manifest.json"
+    sign_manifest "$DIST_DIR/manifest.json"
 
     # Create GitHub prerelease with zip assets + manifest
     load_gh_token
@@ -223,7 +242,8 @@ do_build() {
         --title "v${version}" \
         --notes "Staging release v${version}" \
         "${zip_assets[@]}" \
-        "$DIST_DIR/manifest.json"
+        "$DIST_DIR/manifest.json" \
+        "$DIST_DIR/manifest.json.sig"
     echo "  ✓ GitHub prerelease created (with manifest)"
 
     # Docker multi-arch image build + push
@@ -264,10 +284,12 @@ do_promote() {
     load_gh_token
     echo "→ Updating manifest to stable channel..."
     generate_manifest "$version" "stable" "latest" > "$DIST_DIR/manifest.json"
+    sign_manifest "$DIST_DIR/manifest.json"
     gh release upload "v${version}" \
         --repo "$GH_REPO" \
         --clobber \
-        "$DIST_DIR/manifest.json"
+        "$DIST_DIR/manifest.json" \
+        "$DIST_DIR/manifest.json.sig"
     echo "  ✓ Stable manifest uploaded"
 
     # Promote GitHub prerelease → latest release
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/4throck/obs-agent/internal/status"
+	"github.com/4throck/obs-agent/internal/tunnel"
+	"github.com/4throck/obs-agent/internal/ui"
+	"github.com/4throck/obs-agent/internal/updater"
+)
+
+// autoUpdater installs updates announced by the relay (-auto-update).
+// After a successful install it stops the runner; main then releases the
+// lock and status server and calls restart.
+type autoUpdater struct {
+	runner *agentRunner
+
+	mu        sync.Mutex
+	busy      bool
+	installed string // executable to re-exec once main has cleaned up
+}
+
+// notify handles an update_available notice. Non-blocking; one update at
+// a time, and a failed attempt is retried on the next notice.
+func (u *autoUpdater) notify(n tunnel.UpdateNotice) {
+	version := n.Version
+	if version == Version {
+		return
+	}
+	u.mu.Lock()
+	if u.busy || u.installed != "" {
+		u.mu.Unlock()
+		return
+	}
+	u.busy = true
+	u.mu.Unlock()
+
+	go func() {
+		log.Printf("[update] Downloading %s from %s", version, n.DownloadURL)
+		exe, err := updater.Apply(context.Background(), n.DownloadURL, version, n.SHA256, "")
+
+		u.mu.Lock()
+		u.busy = false
+		if err == nil {
+			u.installed = exe
+		}
+		u.mu.Unlock()
+
+		if err != nil {
+			log.Printf("[update] Update to %s failed: %v — keeping %s", version, err, Version)
+			return
+		}
+		log.Printf("[update] Installed %s — restarting", version)
+		u.runner.stop()
+	}()
+}
+
+// offer shows the update wizard for an update the relay announced and
+// installs it if the user accepts.
+func (u *autoUpdater) offer(wr ui.WizardRunner, info status.UpdateInfo) {
+	res, err := wr.RunUpdateAvailableWizard(info.Version, info.DownloadURL)
+	if err != nil {
+		log.Printf("[update] Could not offer %s: %v", info.Version, err)
+		return
+	}
+	if res.InstallUpdate {
+		u.notify(tunnel.UpdateNotice{Version: info.Version, DownloadURL: info.DownloadURL, SHA256: info.SHA256})
+	}
+}
+
+// pending reports whether an update was installed and awaits a restart.
+func (u *autoUpdater) pending() bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.installed != ""
+}
+
+// restart re-execs the installed binary, if any. Returns only when there
+// was nothing to restart or the restart failed.
+func (u *autoUpdater) restart() {
+	u.mu.Lock()
+	exe := u.installed
+	u.mu.Unlock()
+	if exe == "" {
+		return
+	}
+	if err := updater.Restart(exe); err != nil {
+		log.Printf("[update] Restart failed: %v — start the agent again to run the new version", err)
+		return
+	}
+	if runtime.GOOS == "windows" {
+		os.Exit(0) // the new process is running; Unix exec never returns
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/instance"
+	"github.com/4throck/obs-agent/internal/integrity"
+	"github.com/4throck/obs-agent/internal/service"
+	"github.com/4throck/obs-agent/internal/tunnel"
+)
+
+// checkRelayAuth presents the token to the relay and waits for its
+// verdict. Skipped while an agent is running here, since a second session
+// with the same token would compete with it.
+func checkRelayAuth(env *doctorEnv) doctorResult {
+	if running, pid, err := instance.Probe(binaryDirectory(), agentInstance); err == nil && running {
+		return doctorResult{Status: doctorPass, Detail: fmt.Sprintf("skipped — the running agent (PID %s) holds the relay session; see obs-agent -status", pid)}
+	}
+	if !tokenRegex.MatchString(env.cfg.Token) {
+		return doctorResult{Status: doctorFail, Detail: "not checked — no valid token"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	err := tunnel.ValidateToken(ctx, env.cfg.RelayURL, env.cfg.Token, tunnel.ConnectOptions{Pin: env.cfg.RelayPin})
+	var rejected *tunnel.ErrTokenRejected
+	if errors.As(err, &rejected) {
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      "relay rejected the token",
+			Remediation: "Run obs-agent -setup to authorize this machine again",
+		}
+	}
+	if err != nil {
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      fmt.Sprintf("token check with the relay failed: %v", err),
+			Remediation: "Check the internet connection and that outbound HTTPS (port 443) is allowed",
+		}
+	}
+	return doctorResult{Status: doctorPass, Detail: "relay accepted the token"}
+}
+
+func checkService(env *doctorEnv) doctorResult {
+	st := service.Status()
+	if !st.Installed {
+		return doctorResult{
+			Status:      doctorWarn,
+			Detail:      "startup service not installed — the agent won't start at boot",
+			Remediation: "Run obs-agent -install",
+		}
+	}
+	detail := fmt.Sprintf("installed (%s)", st.Path)
+	if st.Mode != "" {
+		detail = fmt.Sprintf("installed as %s (%s)", st.Mode, st.Path)
+	}
+	if !st.Running {
+		return doctorResult{
+			Status:      doctorWarn,
+			Detail:      detail + " but not running",
+			Remediation: "Check the service manager's log for why the agent stopped",
+		}
+	}
+	return doctorResult{Status: doctorPass, Detail: detail + " and running"}
+}
+
+// checkLock only probes the instance lock; it never holds it, so this is
+// safe next to a running agent.
+func checkLock(env *doctorEnv) doctorResult {
+	dir := binaryDirectory()
+	running, pid, err := instance.Probe(dir, agentInstance)
+	if err != nil {
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      fmt.Sprintf("cannot create the instance lock in %s: %v", dir, err),
+			Remediation: "Make the agent's directory writable by the user that runs it",
+		}
+	}
+	if running {
+		if pid == "" {
+			return doctorResult{Status: doctorPass, Detail: "held by a running agent"}
+		}
+		return doctorResult{Status: doctorPass, Detail: fmt.Sprintf("held by a running agent (PID %s)", pid)}
+	}
+	return doctorResult{Status: doctorPass, Detail: "available — no agent running"}
+}
+
+// checkLogFile opens the log file the same way setupFileLogging does.
+func checkLogFile(env *doctorEnv) doctorResult {
+	dir := binaryDirectory()
+	if dir == "." {
+		return doctorResult{Status: doctorWarn, Detail: "could not resolve the binary directory — logging to the console only"}
+	}
+	path := filepath.Join(dir, instance.FileName(agentInstance, ".log"))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return doctorResult{
+			Status:      doctorWarn,
+			Detail:      fmt.Sprintf("cannot write %s: %v — logging to the console only", path, err),
+			Remediation: "Make the agent's directory writable by the user that runs it",
+		}
+	}
+	f.Close()
+	return doctorResult{Status: doctorPass, Detail: fmt.Sprintf("%s is writable", path)}
+}
+
+func checkIntegrity(env *doctorEnv) doctorResult {
+	if !integrity.HasPublicKey() {
+		return doctorResult{
+			Status:      doctorWarn,
+			Detail:      "this build has no release public key, so manifest signatures cannot be checked",
+			Remediation: "Use a release binary, or build with make -C build MANIFEST_PUBKEY=<base64 key>",
+		}
+	}
+	result, err := integrity.Verify(integrity.Options{Strict: env.strictIntegrity})
+	if errors.Is(err, integrity.ErrSignature) {
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      fmt.Sprintf("release manifest not trusted: %v", err),
+			Remediation: "Download the agent again from the official release page",
+		}
+	}
+	if err != nil {
+		return doctorResult{
+			Status:      doctorWarn,
+			Detail:      fmt.Sprintf("could not check: %v", err),
+			Remediation: "Run obs-agent -verify -manifest manifest.json on air-gapped machines",
+		}
+	}
+	if !result.Match {
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      fmt.Sprintf("SHA256 %s does not match the %s manifest — binary may be modified or outdated", result.Actual, result.Version),
+			Remediation: "Download the agent again from the official release page",
+		}
+	}
+	detail := fmt.Sprintf("SHA256 matches the %s manifest", result.Version)
+	if result.Source == integrity.SourceCached {
+		detail += fmt.Sprintf(" (cached, %s old)", result.CacheAge.Round(time.Minute))
+	}
+	return doctorResult{Status: doctorPass, Detail: detail}
+}
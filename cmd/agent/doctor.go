@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/agent"
+	"github.com/4throck/obs-agent/internal/branding"
+	"github.com/4throck/obs-agent/internal/crypto"
+	"github.com/4throck/obs-agent/internal/obs"
+	"github.com/4throck/obs-agent/internal/tunnel"
+)
+
+// Doctor check statuses
+const (
+	doctorPass = "pass"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+// doctorResult is the outcome of a single doctor check.
+type doctorResult struct {
+	Check       string `json:"check"`
+	Status      string `json:"status"`
+	Detail      string `json:"detail"`
+	Remediation string `json:"remediation,omitempty"`
+}
+
+// doctorReport is the machine-readable output of -doctor -json. Pass is
+// false when any check failed; Warn is set when any check warned.
+type doctorReport struct {
+	Pass   bool           `json:"pass"`
+	Warn   bool           `json:"warn"`
+	Checks []doctorResult `json:"checks"`
+}
+
+// doctorEnv is the resolved configuration the checks run against.
+type doctorEnv struct {
+	cfg        *agent.Config
+	configPath string
+	configOpts agent.ConfigOptions
+	configErr  error
+	// strictIntegrity is -strict-integrity
+	strictIntegrity bool
+}
+
+// doctorCheck is a single named diagnostic. The human-readable and JSON
+// outputs are both rendered from the same list.
+type doctorCheck struct {
+	name string
+	run  func(env *doctorEnv) doctorResult
+}
+
+var doctorChecks = []doctorCheck{
+	{"config", checkConfig},
+	{"token", checkToken},
+	{"obs", checkOBS},
+	{"relay", checkRelay},
+}
+
+// diagnoseChecks is the -diagnose superset of doctorChecks: it also checks
+// the token with the relay and the local install (service, instance lock,
+// log file, binary integrity). None of them take the instance lock.
+var diagnoseChecks = append(append([]doctorCheck(nil), doctorChecks...),
+	doctorCheck{"auth", checkRelayAuth},
+	doctorCheck{"service", checkService},
+	doctorCheck{"lock", checkLock},
+	doctorCheck{"log", checkLogFile},
+	doctorCheck{"integrity", checkIntegrity},
+)
+
+// runDoctor resolves the effective config the same way a normal start would,
+// runs checks, prints the report, and exits 1 if any check failed or 2 if
+// any warned.
+func runDoctor(cfg *agent.Config, configFile string, opts agent.ConfigOptions, strictIntegrity bool, checks []doctorCheck, asJSON bool) {
+	env := &doctorEnv{cfg: cfg, configPath: configFile, configOpts: opts, strictIntegrity: strictIntegrity}
+	if env.configPath == "" {
+		env.configPath = defaultConfigFile()
+	}
+	if env.configPath != "" {
+		// Same key material location as a normal start (see main)
+		crypto.SetFallbackDir(filepath.Dir(env.configPath))
+		loaded, err := agent.LoadConfig(env.configPath, env.configOpts)
+		if err != nil {
+			env.configErr = err
+		} else {
+			if !isFlagSet("token") && loaded.Token != "" {
+				cfg.Token = loaded.Token
+			}
+			if !isFlagSet("obs-port") && loaded.OBSPort != 0 {
+				cfg.OBSPort = loaded.OBSPort
+			}
+			if !isFlagSet("obs-pass") && loaded.OBSPass != "" {
+				cfg.OBSPass = loaded.OBSPass
+			}
+		}
+	}
+	if cfg.Token == "" {
+		cfg.Token = os.Getenv("OBS_AGENT_TOKEN")
+	}
+	if cfg.OBSPass == "" {
+		cfg.OBSPass = os.Getenv("OBS_PASSWORD")
+	}
+
+	report := runDoctorChecks(env, checks)
+
+	if asJSON {
+		out, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(out))
+	} else {
+		printDoctorReport(report)
+	}
+
+	if !report.Pass {
+		os.Exit(1)
+	}
+	if report.Warn {
+		os.Exit(2)
+	}
+}
+
+// runDoctorChecks runs each check in order and builds the report.
+func runDoctorChecks(env *doctorEnv, checks []doctorCheck) doctorReport {
+	report := doctorReport{Pass: true, Checks: make([]doctorResult, 0, len(checks))}
+	for _, c := range checks {
+		r := c.run(env)
+		r.Check = c.name
+		switch r.Status {
+		case doctorFail:
+			report.Pass = false
+		case doctorWarn:
+			report.Warn = true
+		}
+		report.Checks = append(report.Checks, r)
+	}
+	return report
+}
+
+// printDoctorReport renders the report for humans.
+func printDoctorReport(report doctorReport) {
+	for _, r := range report.Checks {
+		label := branding.Green("PASS")
+		switch r.Status {
+		case doctorWarn:
+			label = branding.Yellow("WARN")
+		case doctorFail:
+			label = branding.Red("FAIL")
+		}
+		fmt.Printf("  [%s] %-9s %s\n", label, r.Check, r.Detail)
+		if r.Status != doctorPass && r.Remediation != "" {
+			fmt.Printf("         %s %s\n", branding.Dim("→"), r.Remediation)
+		}
+	}
+	fmt.Println()
+	switch {
+	case !report.Pass:
+		fmt.Println("One or more checks failed.")
+	case report.Warn:
+		fmt.Println("All checks passed, with warnings.")
+	default:
+		fmt.Println("All checks passed.")
+	}
+}
+
+func checkConfig(env *doctorEnv) doctorResult {
+	if env.configPath == "" {
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      "could not resolve the binary directory",
+			Remediation: "Pass an explicit config path with -config",
+		}
+	}
+	if env.configErr != nil {
+		if os.IsNotExist(env.configErr) {
+			if env.cfg.Token != "" {
+				return doctorResult{Status: doctorPass, Detail: "no config file — using flags/environment"}
+			}
+			return doctorResult{
+				Status:      doctorFail,
+				Detail:      fmt.Sprintf("no config file at %s", env.configPath),
+				Remediation: "Run obs-agent -setup to create one",
+			}
+		}
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      fmt.Sprintf("could not load %s: %v", env.configPath, env.configErr),
+			Remediation: "Run obs-agent -setup to recreate the config on this machine",
+		}
+	}
+	return doctorResult{Status: doctorPass, Detail: fmt.Sprintf("loaded %s", env.configPath)}
+}
+
+func checkToken(env *doctorEnv) doctorResult {
+	token := env.cfg.Token
+	if token == "" {
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      "no token configured",
+			Remediation: "Run obs-agent -setup, or pass -token / OBS_AGENT_TOKEN",
+		}
+	}
+	if !tokenRegex.MatchString(token) {
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      "token is not 64 hex characters",
+			Remediation: "Copy the token again from your 4thRock dashboard",
+		}
+	}
+	// SECURITY: Never print the full token
+	return doctorResult{Status: doctorPass, Detail: fmt.Sprintf("token %s...%s (valid format)", token[:4], token[60:])}
+}
+
+func checkOBS(env *doctorEnv) doctorResult {
+	addr := fmt.Sprintf("%s:%d", env.cfg.OBSHost, env.cfg.OBSPort)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	conn, info, err := obs.ConnectInfo(ctx, addr, env.cfg.OBSPass)
+	if err != nil {
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      fmt.Sprintf("could not connect to OBS at %s: %v", addr, err),
+			Remediation: fmt.Sprintf("Start OBS, enable Tools → WebSocket Server Settings on port %d, and check the password", env.cfg.OBSPort),
+		}
+	}
+	obs.CloseConn(conn)
+	if err := obs.CheckMinVersion(info.WebSocketVersion, env.cfg.MinOBSVersion); err != nil {
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      fmt.Sprintf("OBS at %s: %v", addr, err),
+			Remediation: "Update OBS Studio, or lower -min-obs-version",
+		}
+	}
+	return doctorResult{Status: doctorPass, Detail: fmt.Sprintf("connected and authenticated to OBS at %s (obs-websocket %s, RPC v%d)", addr, info.WebSocketVersion, info.RPCVersion)}
+}
+
+// checkRelay verifies the relay is reachable with TLS 1.3. It does not send
+// the token, so it is safe to run while another agent holds the session.
+func checkRelay(env *doctorEnv) doctorResult {
+	u, err := url.Parse(env.cfg.RelayURL)
+	if err != nil {
+		return doctorResult{Status: doctorFail, Detail: fmt.Sprintf("invalid relay URL: %v", err)}
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "443")
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS13}
+	if env.cfg.RelayPin != nil {
+		tlsConfig.VerifyPeerCertificate = env.cfg.RelayPin.VerifyPeerCertificate
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", host, tlsConfig)
+	if errors.Is(err, tunnel.ErrCertPinMismatch) {
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      fmt.Sprintf("relay %s: %v", host, err),
+			Remediation: "Check -relay-pin-cert is current; if it is, the connection is being intercepted",
+		}
+	}
+	if err != nil {
+		return doctorResult{
+			Status:      doctorFail,
+			Detail:      fmt.Sprintf("could not reach relay %s: %v", host, err),
+			Remediation: "Check the internet connection and that outbound HTTPS (port 443) is allowed",
+		}
+	}
+	conn.Close()
+	detail := fmt.Sprintf("TLS 1.3 handshake with %s succeeded", host)
+	if env.cfg.RelayPin != nil {
+		detail += " (certificate pin matched)"
+	}
+	return doctorResult{Status: doctorPass, Detail: detail}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/4throck/obs-agent/internal/agent"
+	"github.com/4throck/obs-agent/internal/obstest"
+)
+
+func TestDoctorReportJSON(t *testing.T) {
+	const (
+		token    = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+		password = "hunter2"
+	)
+	fakeOBS, err := obstest.NewServer(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fakeOBS.Close()
+	host, portStr, _ := net.SplitHostPort(fakeOBS.Addr())
+	port, _ := strconv.Atoi(portStr)
+
+	checks := []doctorCheck{
+		{"config", checkConfig},
+		{"token", checkToken},
+		{"obs", checkOBS},
+	}
+	tests := []struct {
+		name       string
+		cfg        agent.Config
+		configErr  error
+		wantPass   bool
+		wantStatus []string // per check
+	}{
+		{
+			name:       "healthy",
+			cfg:        agent.Config{Token: token, OBSHost: host, OBSPort: port, OBSPass: password},
+			wantPass:   true,
+			wantStatus: []string{doctorPass, doctorPass, doctorPass},
+		},
+		{
+			name:       "flags only",
+			cfg:        agent.Config{Token: token, OBSHost: host, OBSPort: port, OBSPass: password},
+			configErr:  os.ErrNotExist,
+			wantPass:   true,
+			wantStatus: []string{doctorPass, doctorPass, doctorPass},
+		},
+		{
+			name:       "nothing configured",
+			cfg:        agent.Config{OBSHost: host, OBSPort: port, OBSPass: password},
+			configErr:  os.ErrNotExist,
+			wantStatus: []string{doctorFail, doctorFail, doctorPass},
+		},
+		{
+			name:       "unreadable config",
+			cfg:        agent.Config{Token: token, OBSHost: host, OBSPort: port, OBSPass: password},
+			configErr:  errors.New("decrypt failed"),
+			wantStatus: []string{doctorFail, doctorPass, doctorPass},
+		},
+		{
+			name:       "bad token and OBS password",
+			cfg:        agent.Config{Token: "abc", OBSHost: host, OBSPort: port, OBSPass: "wrong"},
+			wantStatus: []string{doctorPass, doctorFail, doctorFail},
+		},
+		{
+			name:       "OBS older than minimum",
+			cfg:        agent.Config{Token: token, OBSHost: host, OBSPort: port, OBSPass: password, MinOBSVersion: "5.4.0"},
+			wantStatus: []string{doctorPass, doctorPass, doctorFail},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			env := &doctorEnv{cfg: &cfg, configPath: "obs-agent.enc", configErr: tt.configErr}
+			out, err := json.Marshal(runDoctorChecks(env, checks))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var report struct {
+				Pass   bool `json:"pass"`
+				Warn   bool `json:"warn"`
+				Checks []struct {
+					Check       string `json:"check"`
+					Status      string `json:"status"`
+					Detail      string `json:"detail"`
+					Remediation string `json:"remediation"`
+				} `json:"checks"`
+			}
+			if err := json.Unmarshal(out, &report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Pass != tt.wantPass || report.Warn {
+				t.Errorf("pass %v warn %v, want pass %v: %s", report.Pass, report.Warn, tt.wantPass, out)
+			}
+			if len(report.Checks) != len(checks) {
+				t.Fatalf("%d checks in %s", len(report.Checks), out)
+			}
+			for i, c := range report.Checks {
+				if c.Check != checks[i].name || c.Status != tt.wantStatus[i] {
+					t.Errorf("check %d = %s/%s (%s), want %s/%s", i, c.Check, c.Status, c.Detail, checks[i].name, tt.wantStatus[i])
+				}
+				if c.Status == doctorFail && c.Remediation == "" {
+					t.Errorf("%s failed without a remediation", c.Check)
+				}
+			}
+		})
+	}
+}
+
+func TestDoctorReportWarn(t *testing.T) {
+	result := func(status string) func(*doctorEnv) doctorResult {
+		return func(*doctorEnv) doctorResult { return doctorResult{Status: status} }
+	}
+	tests := []struct {
+		statuses []string
+		wantPass bool
+		wantWarn bool
+	}{
+		{[]string{doctorPass, doctorPass}, true, false},
+		{[]string{doctorPass, doctorWarn}, true, true},
+		{[]string{doctorWarn, doctorFail}, false, true},
+		{[]string{doctorFail, doctorPass}, false, false},
+	}
+	for _, tt := range tests {
+		var checks []doctorCheck
+		for i, s := range tt.statuses {
+			checks = append(checks, doctorCheck{strconv.Itoa(i), result(s)})
+		}
+		report := runDoctorChecks(&doctorEnv{}, checks)
+		if report.Pass != tt.wantPass || report.Warn != tt.wantWarn {
+			t.Errorf("%v: pass %v warn %v, want %v %v", tt.statuses, report.Pass, report.Warn, tt.wantPass, tt.wantWarn)
+		}
+	}
+}
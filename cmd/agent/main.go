@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -14,6 +15,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,11 +24,21 @@ import (
 
 	"github.com/4throck/obs-agent/internal/agent"
 	"github.com/4throck/obs-agent/internal/branding"
+	"github.com/4throck/obs-agent/internal/crypto"
 	"github.com/4throck/obs-agent/internal/device"
+	"github.com/4throck/obs-agent/internal/eventlog"
 	"github.com/4throck/obs-agent/internal/instance"
 	"github.com/4throck/obs-agent/internal/integrity"
+	"github.com/4throck/obs-agent/internal/keyring"
+	"github.com/4throck/obs-agent/internal/logging"
+	"github.com/4throck/obs-agent/internal/obs"
+	"github.com/4throck/obs-agent/internal/obsdetect"
+	"github.com/4throck/obs-agent/internal/obslaunch"
+	"github.com/4throck/obs-agent/internal/sdnotify"
 	"github.com/4throck/obs-agent/internal/service"
 	"github.com/4throck/obs-agent/internal/status"
+	"github.com/4throck/obs-agent/internal/tray"
+	"github.com/4throck/obs-agent/internal/tui"
 	"github.com/4throck/obs-agent/internal/tunnel"
 	"github.com/4throck/obs-agent/internal/ui"
 	"github.com/gorilla/websocket"
@@ -40,6 +52,17 @@ var tokenRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
 // wizard is the UI implementation used for setup and fatal errors
 var wizard ui.UI
 
+// agentInstance is the -instance name. It picks the lock, log, control
+// token and config files and the preferred status port, so several agents
+// can run side by side.
+var agentInstance = instance.DefaultName
+
+// statusBind is the -status-bind host the status server listens on.
+var statusBind = "127.0.0.1"
+
+// logRing keeps the recent log tail for GET /api/logs
+var logRing = logging.NewRing(logging.DefaultRingLines)
+
 func main() {
 	// Relay URL is hardcoded — not configurable by users
 	const relayURL = "wss://4throck.cloud/ws/agent"
@@ -48,30 +71,211 @@ func main() {
 	obsHost := detectOBSHost()
 
 	var (
-		token   string
-		obsPort int
-		obsPass string
+		token          string
+		obsPort        int
+		obsPass        string
 		configFile     string
 		showVersion    bool
 		setup          bool
 		verify         bool
 		queryStatus    bool
+		restartAgent   bool
+		confirmDestr   bool
 		installService bool
 		uninstallSvc   bool
+		installMode    string
+		minOBSVersion  string
+		serviceStatus  bool
+		maxBatch       int
+		noTray         bool
+		tuiMode        bool
+		rateRead       string
+		rateWrite      string
+		rateOverrides  string
+		relayFallback  stringList
+		doctor         bool
+		diagnose       bool
+		jsonOutput     bool
+		jsonLogs       bool
+		rotateKey      bool
+		resetToken     bool
+		exportConfig   string
+		importConfig   string
+		exportPass     string
+		autoUpdate     bool
+		noKeyring      bool
+		fixPerms       bool
+		strictIntegr   bool
+		manifestPath   string
+		manifestCache  string
+		preflight      bool
+		profile        string
+		relayReadLimit int64
+		relayPinCert   string
+		obsReconnect   time.Duration
+		drainTimeout   time.Duration
+		eventLogPath   string
+		scanPorts      string
+		scanSubnet     string
+		statusAllow    string
+		statusBindFlag string
+		scanTimeout    time.Duration
+		connDebounce   time.Duration
+		clockSkew      time.Duration
+		nonceCache     int
+		monOwnConn     bool
+		nonInteract    bool
+		saveConfig     bool
+		setupOnly      bool
+		wizardTimeout  time.Duration
+		instanceName   string
 	)
 
 	flag.StringVar(&token, "token", "", "Agent authentication token")
 	flag.IntVar(&obsPort, "obs-port", 4455, "Local OBS WebSocket port")
 	flag.StringVar(&obsPass, "obs-pass", "", "Local OBS WebSocket password")
 	flag.StringVar(&configFile, "config", "", "Config file path (optional, overrides flags)")
+	flag.StringVar(&profile, "profile", agent.DefaultProfile, "Named credential profile inside the config file")
+	flag.StringVar(&instanceName, "instance", instance.DefaultName, "Run as a named instance with its own lock, log, config file and status port, so several agents can run on one machine")
 	flag.BoolVar(&showVersion, "version", false, "Show version")
 	flag.BoolVar(&setup, "setup", false, "Run interactive setup wizard")
+	flag.BoolVar(&nonInteract, "non-interactive", false, "Never prompt: validate -token/-obs-port/-obs-pass and test OBS; errors are JSON on stderr")
+	flag.BoolVar(&saveConfig, "save", false, "With -non-interactive, write the validated settings to the config file")
+	flag.BoolVar(&setupOnly, "setup-only", false, "With -non-interactive, exit after validating (and saving) instead of running")
 	flag.BoolVar(&verify, "verify", false, "Verify binary integrity against manifest")
 	flag.BoolVar(&queryStatus, "status", false, "Query running agent status")
+	flag.BoolVar(&restartAgent, "restart", false, "Ask the running agent to reconnect")
 	flag.BoolVar(&installService, "install", false, "Install as startup service")
 	flag.BoolVar(&uninstallSvc, "uninstall", false, "Uninstall startup service")
+	flag.BoolVar(&serviceStatus, "service-status", false, "Show whether the startup service is installed and running (JSON with -json)")
+	flag.StringVar(&installMode, "install-mode", string(service.DefaultMode), "How -install registers the agent on Windows: service (starts at boot) or task (Scheduled Task at logon)")
+	flag.BoolVar(&doctor, "doctor", false, "Check config, OBS and relay connectivity, then exit")
+	flag.BoolVar(&diagnose, "diagnose", false, "Run the -doctor checks plus relay token, service, lock, log file and integrity checks, then exit (0 pass, 1 fail, 2 warn)")
+	flag.BoolVar(&jsonOutput, "json", false, "Machine-readable JSON output (with -doctor, -diagnose or -service-status)")
+	flag.BoolVar(&jsonLogs, "json-logs", false, "Emit logs as one JSON object per line on stdout")
+	flag.BoolVar(&autoUpdate, "auto-update", false, "Install verified updates announced by the relay and restart")
+	flag.BoolVar(&noTray, "no-tray", false, "Don't show the tray / menu-bar icon")
+	flag.BoolVar(&tuiMode, "tui", false, "Show a live full-screen status view in the terminal (q quits, r reconfigures)")
+	flag.BoolVar(&noKeyring, "no-keyring", false, "Keep the agent token in the config file instead of the OS keyring")
+	flag.BoolVar(&fixPerms, "fix-permissions", false, "Restrict the config file to the current user (chmod 600 / ACL) on every load and save")
+	flag.BoolVar(&rotateKey, "rotate-key", false, "Re-encrypt the config under a fresh storage key, then exit")
+	flag.StringVar(&exportConfig, "export-config", "", "Write the config, encrypted with a passphrase instead of this machine's key, to this file, then exit")
+	flag.StringVar(&importConfig, "import-config", "", "Import a config written by -export-config on another machine, then exit")
+	flag.StringVar(&exportPass, "export-passphrase", "", "Passphrase for -export-config / -import-config (prompted when empty). Other users can read the command line: prefer the prompt or OBS_AGENT_EXPORT_PASSPHRASE")
+	flag.BoolVar(&resetToken, "reset-token", false, "Clear the stored agent token (keeping the OBS settings), then exit")
+	flag.StringVar(&manifestPath, "manifest", "", "Local manifest.json for -verify (air-gapped machines)")
+	flag.StringVar(&manifestCache, "manifest-cache", "", "Directory caching the release manifest for integrity checks (used for 24h without fetching)")
+	flag.BoolVar(&strictIntegr, "strict-integrity", false, "Require a signed release manifest (fail instead of falling back to TLS trust)")
+	flag.BoolVar(&preflight, "preflight", false, "Check the token with the relay before connecting to OBS")
+	flag.BoolVar(&confirmDestr, "confirm-destructive", false, "Ask locally before RemoveScene/RemoveSceneItem/RemoveInput/StopStream")
+	flag.StringVar(&minOBSVersion, "min-obs-version", obs.DefaultMinVersion, "Oldest obs-websocket version to accept")
+	flag.DurationVar(&obsReconnect, "obs-reconnect-window", tunnel.DefaultOBSReconnectWindow, "How long to wait for OBS during a scene-collection switch (0 disables OBS-only reconnects)")
+	flag.DurationVar(&drainTimeout, "drain-timeout", tunnel.DefaultDrainTimeout, "How long to wait for OBS to answer in-flight requests when stopping (0 closes immediately)")
+	flag.StringVar(&scanPorts, "obs-scan-ports", "4455,4454,4456", "Comma-separated localhost ports the setup wizard probes for OBS")
+	flag.StringVar(&scanSubnet, "obs-scan-subnet", "", "List OBS WebSocket servers in this IPv4 CIDR (\"auto\" = this machine's /24), then exit")
+	flag.DurationVar(&scanTimeout, "obs-scan-timeout", 2*time.Second, "How long the OBS port probe waits for OBS to answer")
+	flag.DurationVar(&wizardTimeout, "wizard-timeout", ui.DefaultWizardTimeout, "How long the browser setup wizard waits for the user before the agent gives up and exits (0 waits forever)")
+	flag.StringVar(&statusAllow, "status-allowlist", status.DefaultAllowlist, "Comma-separated CIDR ranges allowed to call the local status server")
+	flag.StringVar(&statusBindFlag, "status-bind", statusBind, "IP address the local status server listens on (0.0.0.0 or :: for other machines; widen -status-allowlist too)")
+	flag.StringVar(&eventLogPath, "event-log", "", "Append every OBS event to this file as JSON lines (served at /api/events)")
+	flag.DurationVar(&connDebounce, "connected-debounce", status.DefaultConnectedDebounce, "How long a connection must stay up before the connected notification fires")
+	flag.DurationVar(&clockSkew, "clock-skew", tunnel.DefaultClockSkew, "How far relay message timestamps may be from this machine's clock")
+	flag.BoolVar(&monOwnConn, "monitor-own-connection", false, "Give the source monitor its own OBS connections instead of sharing the agent's")
+	flag.IntVar(&nonceCache, "nonce-cache-size", tunnel.DefaultNonceCacheSize, "How many relay message nonces are remembered for replay protection")
+	flag.IntVar(&maxBatch, "max-batch-requests", tunnel.DefaultMaxBatchRequests, "Maximum requests in one RequestBatch from the relay")
+	flag.StringVar(&rateRead, "rate-limit-read", tunnel.DefaultReadRateLimit, "Per-type rate limit for Get* requests from the relay, RATE[:BURST] per second (0 = unlimited)")
+	flag.StringVar(&rateWrite, "rate-limit-write", tunnel.DefaultWriteRateLimit, "Per-type rate limit for all other requests from the relay, RATE[:BURST] per second (0 = unlimited)")
+	flag.StringVar(&rateOverrides, "rate-limit", "", "Per-type overrides, e.g. SetCurrentProgramScene=2:4,GetStats=1")
+	flag.Var(&relayFallback, "relay-fallback", "Backup relay URL (wss://) tried when the primary is unreachable; repeat for more")
+	flag.Int64Var(&relayReadLimit, "relay-read-limit", tunnel.DefaultReadLimit, "Maximum relay message size in bytes")
+	flag.StringVar(&relayPinCert, "relay-pin-cert", "", "Advanced: pin the relay TLS key (PEM file or sha256//<base64>)")
 	flag.Parse()
 
+	if err := agent.ValidateProfileName(profile); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -profile: %v\n", err)
+		os.Exit(1)
+	}
+	if err := instance.ValidateName(instanceName); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -instance: %v\n", err)
+		os.Exit(1)
+	}
+	agentInstance = instanceName
+	configOpts := agent.ConfigOptions{Profile: profile, FixPermissions: fixPerms}
+	if agentInstance != instance.DefaultName {
+		configOpts.Instance = agentInstance
+	}
+	// The token goes to the OS keyring (Credential Manager, Keychain,
+	// Secret Service) when one is available; -no-keyring opts out
+	if !noKeyring {
+		configOpts.Keyring = keyring.System()
+	}
+	if clockSkew <= 0 {
+		fmt.Fprintln(os.Stderr, "Invalid -clock-skew: must be positive")
+		os.Exit(1)
+	}
+	if nonceCache <= 0 {
+		fmt.Fprintln(os.Stderr, "Invalid -nonce-cache-size: must be positive")
+		os.Exit(1)
+	}
+	rateLimits, err := parseRateLimits(rateRead, rateWrite, rateOverrides)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid rate limit: %v\n", err)
+		os.Exit(1)
+	}
+	if obsScanPorts, err = parsePortList(scanPorts); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -obs-scan-ports: %v\n", err)
+		os.Exit(1)
+	}
+	if scanTimeout <= 0 {
+		fmt.Fprintln(os.Stderr, "Invalid -obs-scan-timeout: must be positive")
+		os.Exit(1)
+	}
+	obsScanTimeout = scanTimeout
+	if wizardTimeout < 0 {
+		fmt.Fprintln(os.Stderr, "Invalid -wizard-timeout: must not be negative")
+		os.Exit(1)
+	}
+	statusAllowlist, err := status.ParseAllowlist(statusAllow)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -status-allowlist: %v\n", err)
+		os.Exit(1)
+	}
+	if net.ParseIP(statusBindFlag) == nil {
+		fmt.Fprintf(os.Stderr, "Invalid -status-bind: %q is not an IP address\n", statusBindFlag)
+		os.Exit(1)
+	}
+	statusBind = statusBindFlag
+	if err := obs.ValidateMinVersion(minOBSVersion); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -min-obs-version: %v\n", err)
+		os.Exit(1)
+	}
+	for _, u := range relayFallback {
+		if !strings.HasPrefix(u, "wss://") {
+			fmt.Fprintf(os.Stderr, "Invalid -relay-fallback %q: must be a wss:// URL\n", u)
+			os.Exit(1)
+		}
+	}
+	if (saveConfig || setupOnly) && !nonInteract {
+		fmt.Fprintln(os.Stderr, "-save and -setup-only require -non-interactive")
+		os.Exit(1)
+	}
+	if nonInteract && (setup || confirmDestr) {
+		fmt.Fprintln(os.Stderr, "-non-interactive cannot be combined with -setup or -confirm-destructive")
+		os.Exit(1)
+	}
+
+	// Started by the Windows service manager: there is no desktop, and
+	// Stop/Shutdown from the SCM take the same path as SIGTERM
+	var svcCtl *service.Control
+	if service.IsWindowsService() {
+		ui.DisableGui()
+		c, err := service.StartControl()
+		if err != nil {
+			log.Fatalf("[agent] Service control: %v", err)
+		}
+		svcCtl = c
+	}
+
 	// 1. -version → print version, exit
 	if showVersion {
 		fmt.Printf("obs-agent %s\n", Version)
@@ -80,7 +284,7 @@ func main() {
 
 	// 2. -verify → verbose integrity check, exit
 	if verify {
-		runVerify()
+		runVerify(manifestPath, manifestCache, strictIntegr)
 		return
 	}
 
@@ -90,15 +294,68 @@ func main() {
 		return
 	}
 
-	// 4. Select UI implementation: WebUI (branded browser wizard) wrapping native OS dialogs > CLI fallback
-	if ui.IsGuiAvailable() {
-		wizard = ui.NewWebUI(ui.NewGuiUI())
+	// 3a. -restart → ask running agent to reconnect, exit
+	if restartAgent {
+		runRestartRequest()
+		return
+	}
+
+	// Parse the relay pin up front so a bad pin fails fast (and -doctor uses it)
+	var relayPin *tunnel.CertPin
+	if relayPinCert != "" {
+		pin, err := tunnel.ParseCertPin(relayPinCert)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -relay-pin-cert: %v\n", err)
+			os.Exit(1)
+		}
+		relayPin = pin
+	}
+
+	// 3b. -doctor / -diagnose → run diagnostics against the effective config, exit
+	if doctor || diagnose {
+		checks := doctorChecks
+		if diagnose {
+			checks = diagnoseChecks
+		}
+		runDoctor(&agent.Config{
+			RelayURL: relayURL,
+			Token:    token,
+			OBSHost:  obsHost,
+			OBSPort:  obsPort,
+			OBSPass:  obsPass,
+			Version:  Version,
+			RelayPin: relayPin,
+
+			MinOBSVersion: minOBSVersion,
+		}, configFile, configOpts, strictIntegr, checks, jsonOutput)
+		return
+	}
+
+	// 3c. -obs-scan-subnet → list OBS servers on the network, exit
+	if scanSubnet != "" {
+		runSubnetScan(scanSubnet, jsonOutput)
+	}
+
+	// 3d. -service-status → report the startup registration, exit
+	if serviceStatus {
+		runServiceStatus(jsonOutput)
+		return
+	}
+
+	// 4. Select UI implementation: WebUI (branded browser wizard) wrapping native OS dialogs > CLI fallback.
+	// -non-interactive has none, so nothing can prompt
+	if nonInteract {
+		ui.DisableGui()
+	} else if ui.IsGuiAvailable() {
+		webUI := ui.NewWebUI(ui.NewGuiUI())
+		webUI.SetWizardTimeout(wizardTimeout)
+		wizard = webUI
 	} else {
 		wizard = ui.NewCliUI()
 	}
 
 	// 5. Set up file logging (next to the binary)
-	setupFileLogging()
+	setupFileLogging(jsonLogs, tuiMode, agentInstance)
 
 	// 6. Print branded banner
 	branding.PrintBanner(Version, runtime.GOOS, runtime.GOARCH, os.Stderr)
@@ -110,17 +367,41 @@ func main() {
 
 	// 8. -install → install service, exit
 	if installService {
+		if agentInstance != instance.DefaultName {
+			fmt.Fprintln(os.Stderr, "Install failed: the startup service runs the default instance only (drop -instance)")
+			os.Exit(1)
+		}
+		mode, err := service.ParseMode(installMode)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -install-mode: %v\n", err)
+			os.Exit(1)
+		}
 		exe, _ := os.Executable()
 		exe, _ = filepath.EvalSymlinks(exe)
 		cfgPath := configFile
 		if cfgPath == "" {
 			cfgPath = defaultConfigFile()
 		}
-		if err := service.Install(exe, cfgPath); err != nil {
+		if runtime.GOOS == "windows" && mode == service.ModeService && cfgPath != "" {
+			// The service runs as LocalSystem, which cannot open this user's
+			// DPAPI key or Credential Manager
+			crypto.SetFallbackDir(filepath.Dir(cfgPath))
+			if err := agent.ShareWithService(cfgPath, configOpts); err != nil {
+				fmt.Fprintf(os.Stderr, "Install failed: cannot share the config with the service: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if err := service.Install(exe, cfgPath, mode); err != nil {
 			fmt.Fprintf(os.Stderr, "Install failed: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Println("Startup service installed. The agent will start automatically on login.")
+		if runtime.GOOS == "windows" && mode == service.ModeService {
+			fmt.Println("Windows service installed. The agent starts at boot and restarts if it fails.")
+		} else if runtime.GOOS == "freebsd" || runtime.GOOS == "openbsd" {
+			fmt.Println("rc.d service installed. The agent will start automatically at boot.")
+		} else {
+			fmt.Println("Startup service installed. The agent will start automatically on login.")
+		}
 		return
 	}
 
@@ -135,7 +416,7 @@ func main() {
 	}
 
 	// 10. Acquire instance lock (fatal if another running)
-	lock, err := instance.Acquire(binaryDir)
+	lock, err := instance.Acquire(binaryDir, agentInstance)
 	if err != nil {
 		fatalWait(fmt.Sprintf("[agent] %v", err))
 	}
@@ -144,12 +425,36 @@ func main() {
 	defaultConfigPath := defaultConfigFile()
 
 	cfg := &agent.Config{
-		RelayURL: relayURL,
-		Token:    token,
-		OBSHost:  obsHost,
-		OBSPort:  obsPort,
-		OBSPass:  obsPass,
-		Version:  Version,
+		RelayURL:       relayURL,
+		Token:          token,
+		OBSHost:        obsHost,
+		OBSPort:        obsPort,
+		OBSPass:        obsPass,
+		Version:        Version,
+		RelayReadLimit: relayReadLimit,
+		RelayPin:       relayPin,
+		ValidateToken:  preflight,
+
+		ClockSkew:        clockSkew,
+		NonceCacheSize:   nonceCache,
+		MaxBatchRequests: maxBatch,
+		MinOBSVersion:    minOBSVersion,
+
+		MonitorOwnConnection: monOwnConn,
+	}
+	if len(relayFallback) > 0 {
+		cfg.RelayURLs = append([]string{relayURL}, relayFallback...)
+	}
+	// Flag 0 means "disabled"; the agent config uses negative for that
+	if obsReconnect <= 0 {
+		cfg.OBSReconnectWindow = -1
+	} else {
+		cfg.OBSReconnectWindow = obsReconnect
+	}
+	if drainTimeout <= 0 {
+		cfg.DrainTimeout = -1
+	} else {
+		cfg.DrainTimeout = drainTimeout
 	}
 
 	// 11. Try loading config from explicit path or default location
@@ -166,17 +471,80 @@ func main() {
 			}
 		}
 	}
-	var configLoaded bool
+	// Saves (wizard, token rotation, dashboard preferences) go to the
+	// -config file when one was given; a legacy config is migrated to the
+	// default path
+	savePath := configSavePath(configFile, defaultConfigPath)
+
+	// Config key derivation falls back to a generated ID in the config dir
+	// when the OS machine ID is unavailable (e.g. containers)
+	if configPath != "" {
+		crypto.SetFallbackDir(filepath.Dir(configPath))
+	}
+
+	// 11a. -rotate-key → re-encrypt config under new key material, exit
+	// (runs under the instance lock so a live agent can't save concurrently)
+	if rotateKey {
+		code := runRotateKey(configPath, configOpts)
+		lock.Release()
+		os.Exit(code)
+	}
+
+	// Likewise -reset-token → clear the token so the next start re-authorizes.
+	// Holding the lock proves no agent is running; releasing it removes the
+	// lock file.
+	if resetToken {
+		code := runResetToken(configPath, configOpts)
+		lock.Release()
+		os.Exit(code)
+	}
+
+	// -export-config / -import-config → move the config between machines
+	if exportConfig != "" {
+		code := runExportConfig(configPath, exportConfig, exportPass, configOpts)
+		lock.Release()
+		os.Exit(code)
+	}
+	if importConfig != "" {
+		importPath := configFile
+		if importPath == "" {
+			importPath = defaultConfigPath
+		}
+		code := runImportConfig(importConfig, importPath, exportPass, configOpts)
+		lock.Release()
+		os.Exit(code)
+	}
+
+	var migratedFrom string // legacy plaintext config migrated this run
 	if configPath != "" {
-		loaded, err := agent.LoadConfig(configPath)
+		loaded, err := agent.LoadConfig(configPath, configOpts)
+		if errors.Is(err, agent.ErrConfigPermissions) {
+			// Refuse rather than fall through to setup and overwrite it
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			lock.Release()
+			os.Exit(1)
+		}
+		if errors.Is(err, agent.ErrConfigTampered) && !setup {
+			// Don't silently start over; -setup replaces the file
+			lock.Release()
+			fatalWait(fmt.Sprintf("[agent] %s: %v\nRun obs-agent -setup to create a new config.", configPath, err))
+		}
 		if err != nil {
 			if configFile != "" {
 				// Explicit config file specified but failed — warn
 				log.Printf("[agent] Warning: could not load config file: %v", err)
 			}
+			// Setup will save a fresh config; move an undecodable one out
+			// of its way (SaveConfig refuses to overwrite its profiles)
+			if configPath == defaultConfigPath {
+				if aside, aerr := agent.SetAsideUnreadableConfig(configPath); aerr != nil {
+					log.Printf("[agent] Warning: could not move the unreadable config aside: %v", aerr)
+				} else if aside != "" {
+					log.Printf("[agent] Config could not be decoded (%v); kept it as %s and starting setup", err, aside)
+				}
+			}
 			// Default config not found is fine — will prompt for setup
 		} else {
-			configLoaded = true
 			// relay_url and obs_host are never loaded from config — hardcoded in binary
 			if !isFlagSet("token") && loaded.Token != "" {
 				cfg.Token = loaded.Token
@@ -187,11 +555,18 @@ func main() {
 			if !isFlagSet("obs-pass") && loaded.OBSPass != "" {
 				cfg.OBSPass = loaded.OBSPass
 			}
-			// Migrate legacy JSON config to encrypted format
-			if configPath != defaultConfigPath && configLoaded {
-				if err := agent.SaveConfig(defaultConfigPath, cfg); err == nil {
+			cfg.AllowedExtraRequests = loaded.AllowedExtraRequests
+			cfg.Notifications = loaded.Notifications
+			cfg.AgentName = loaded.AgentName
+			cfg.OBSLaunchPath = loaded.OBSLaunchPath
+			cfg.OBSLaunchArgs = loaded.OBSLaunchArgs
+			// Migrate legacy JSON config to encrypted format; an explicit
+			// -config file is kept where it is and saved in place
+			if configFile == "" && configPath != defaultConfigPath {
+				if err := agent.SaveConfig(defaultConfigPath, cfg, configOpts); err == nil {
 					log.Printf("[agent] Migrated config to encrypted format: %s", defaultConfigPath)
 					os.Remove(configPath) // delete old plaintext JSON
+					migratedFrom = configPath
 				}
 			}
 		}
@@ -204,10 +579,89 @@ func main() {
 	if cfg.OBSPass == "" {
 		cfg.OBSPass = os.Getenv("OBS_PASSWORD")
 	}
+	if !isFlagSet("relay-read-limit") {
+		if v := os.Getenv("OBS_AGENT_RELAY_READ_LIMIT"); v != "" {
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+				cfg.RelayReadLimit = n
+			} else {
+				log.Printf("[agent] Ignoring invalid OBS_AGENT_RELAY_READ_LIMIT=%q", v)
+			}
+		}
+	}
+
+	registerSecrets(cfg)
+
+	// 11b. -non-interactive → validate (and with -save, write) the config
+	// without the wizard; -setup-only exits here
+	if nonInteract {
+		if err := runNonInteractiveSetup(cfg, savePath, saveConfig, configOpts); err != nil {
+			lock.Release()
+			exitProvision(err)
+		}
+		if setupOnly {
+			lock.Release()
+			return
+		}
+	}
+
+	if cfg.RelayPin != nil {
+		log.Printf("[agent] Relay certificate pinned: %s", cfg.RelayPin)
+	}
+
+	// Extra request types come from the local config only — never from the relay
+	if len(cfg.AllowedExtraRequests) > 0 {
+		if added := tunnel.AddAllowedRequests(cfg.AllowedExtraRequests); len(added) > 0 {
+			log.Printf("[agent] Extra OBS request types allowed by local config: %s", strings.Join(added, ", "))
+		}
+	}
 
 	// 12. Start status server early — the WebUI wizard runs on it (no separate server)
 	statusSrv := status.New(Version, cfg.OBSHost, cfg.OBSPort, cfg.RelayURL)
+	statusSrv.SetConnectedDebounce(connDebounce)
+	statusSrv.SetAllowlist(statusAllowlist)
+	if !net.ParseIP(statusBind).IsLoopback() && status.OnlyLoopback(statusAllowlist) {
+		log.Printf("[status] Listening on %s, but -status-allowlist only admits loopback — other machines will get 403", statusBind)
+	}
+	statusSrv.SetLogSource(logRing)
+
+	// -event-log: OBS event history on disk, readable at /api/events
+	var eventLog *eventlog.Log
+	if eventLogPath != "" {
+		eventLog, err = eventlog.Open(eventLogPath)
+		if err != nil {
+			lock.Release()
+			fatalWait(fmt.Sprintf("[agent] -event-log: %v", err))
+		}
+		statusSrv.SetEventSource(eventLog)
+		log.Printf("[agent] Logging OBS events to %s", eventLogPath)
+	}
+	statusSrv.SetPreferredAddr(statusListenAddr())
 	statusSrv.Start()
+	tokenPath := filepath.Join(binaryDir, instance.FileName(agentInstance, ".token"))
+	if err := statusSrv.WriteTokenFile(tokenPath); err != nil {
+		log.Printf("[status] Could not write control token: %v (quit/reconfigure need it)", err)
+	} else {
+		log.Printf("[status] Control token written to %s", tokenPath)
+	}
+	portPath := filepath.Join(binaryDir, instance.FileName(agentInstance, ".port"))
+	if err := statusSrv.WritePortFile(portPath); err != nil {
+		log.Printf("[status] Could not write status port: %v (-status tries the preferred port)", err)
+	}
+
+	// systemd watchdog (Type=notify unit): keep pinging while the connect
+	// loop's heartbeat is fresh, so a wedged bridge gets restarted
+	if interval := sdnotify.WatchdogInterval(); interval > 0 {
+		go watchdogLoop(statusSrv, interval/2)
+	}
+
+	// Report the startup registration in /api/status; it changes only when
+	// someone runs -install/-uninstall, so a slow refresh is enough
+	go func() {
+		for {
+			statusSrv.SetService(serviceInfo(service.Status()))
+			time.Sleep(time.Minute)
+		}
+	}()
 
 	// Wire WebUI to use the status server for wizard endpoints
 	if webUI, ok := wizard.(*ui.WebUI); ok {
@@ -230,7 +684,19 @@ func main() {
 		}
 		wizardRan = true
 		detected := autoDetectOBS()
-		runWizardSetup(wizard, cfg, defaultConfigPath, detected, setup)
+		if err := runWizardSetup(wizard, cfg, savePath, configOpts, detected, setup); err != nil {
+			statusSrv.Stop()
+			lock.Release()
+			wizardFailed(err)
+		}
+	}
+
+	// A migrated legacy config gets a screen explaining what changed
+	if migratedFrom != "" && !wizardRan {
+		if runner, ok := wizard.(ui.WizardRunner); ok {
+			wizardRan = true
+			runUpdateWizard(runner, cfg, migratedFrom, savePath, configOpts)
+		}
 	}
 
 	// 14. Validate token
@@ -247,43 +713,160 @@ func main() {
 
 	// SECURITY: Never log the token or OBS password
 	log.Printf("[agent] Relay: %s", cfg.RelayURL)
+	for _, u := range relayFallback {
+		log.Printf("[agent] Fallback relay: %s", u)
+	}
 	log.Printf("[agent] OBS target: %s:%d", cfg.OBSHost, cfg.OBSPort)
 	log.Printf("[agent] Token: %s...%s (verified format)", cfg.Token[:4], cfg.Token[60:])
+	if cfg.AgentName != "" {
+		log.Printf("[agent] Running as %q", cfg.AgentName)
+	}
+	statusSrv.SetAgentName(cfg.AgentName)
 
 	// 15. Silent integrity check (background goroutine)
 	go func() {
-		result, err := integrity.Verify("")
+		result, err := verifyBinary(manifestCache, strictIntegr)
+		if errors.Is(err, integrity.ErrSignature) {
+			log.Printf("[integrity] WARNING: %v — manifest not trusted", err)
+			return
+		}
 		if err != nil {
 			log.Printf("[integrity] Skipped: %v", err)
 			return
 		}
+		source := "live"
+		if result.Source == integrity.SourceCached {
+			source = fmt.Sprintf("cached, %s old", result.CacheAge.Round(time.Minute))
+		}
 		if result.Match {
-			log.Printf("[integrity] Binary verified (SHA256 matches %s manifest)", result.Version)
+			log.Printf("[integrity] Binary verified (SHA256 matches %s manifest, %s)", result.Version, source)
 		} else {
 			log.Printf("[integrity] WARNING: SHA256 mismatch — binary may be modified or outdated")
 		}
 	}()
 
-	// 16. Create agent, update status server with final config (may have changed during setup)
-	statusSrv.UpdateConfig(cfg.OBSHost, cfg.OBSPort, cfg.RelayURL)
-	a := agent.New(cfg)
-	a.StatusServer = statusSrv
+	// 16. Create the agent runner; callbacks target the runner so they survive restarts
+	runner := newAgentRunner(statusSrv, cfg)
+	runner.eventLog = eventLog
+	// OBS runs on the host when the agent is in a container: never launch it
+	if obslaunch.InContainer() {
+		if cfg.OBSLaunchPath != "" {
+			log.Println("[agent] Running in a container — ignoring the OBS launch path")
+		}
+	} else {
+		runner.obsLauncher = obslaunch.New()
+		if cfg.OBSLaunchPath != "" {
+			log.Printf("[agent] Will launch OBS from %s when it is not running", cfg.OBSLaunchPath)
+		}
+	}
+	var dash *tui.TUI // -tui view, created below; nil-safe
+	if confirmDestr {
+		log.Printf("[agent] Destructive requests require local approval (auto-deny after %v)", tunnel.DefaultApprovalTimeout)
+		runner.approvals = tunnel.NewApprovalQueue(func(ctx context.Context, title, message string) bool {
+			ui.Notify("4thRock OBS Agent", "Approval needed for an OBS action")
+			dash.Suspend()
+			defer dash.Resume()
+			return wizard.ConfirmContext(ctx, title, message)
+		}, 0)
+	}
+
+	// Bridged message totals for /api/status (kept across reconnects)
+	statusSrv.SetMessageCounter(func() status.MessageCounts {
+		toOBS, toRelay, rejected := runner.stats.Counts()
+		return status.MessageCounts{ToOBS: toOBS, ToRelay: toRelay, Rejected: rejected}
+	})
+
+	// Per-request-type rate limits on commands from the relay
+	runner.limiter = tunnel.NewRateLimiter(rateLimits)
+	runner.limiter.SetOnLimited(func(types []string) {
+		statusSrv.IncRateLimited()
+	})
 
-	// Wire status server callbacks
-	var reconfigureRequested bool
-	var reconfigureMu sync.Mutex
+	// Relay-announced updates go to /api/update-info. -auto-update installs
+	// them (verified against the manifest); otherwise the browser wizard
+	// offers them
+	updates := &autoUpdater{runner: runner}
+	runner.onUpdateAvailable = func(n tunnel.UpdateNotice) {
+		if n.Version == Version {
+			return
+		}
+		statusSrv.SetUpdateInfo(n.Version, n.DownloadURL, n.SHA256)
+		if autoUpdate {
+			updates.notify(n)
+		}
+	}
+	if autoUpdate {
+		log.Println("[update] Auto-update enabled")
+	} else if wr, ok := wizard.(ui.WizardRunner); ok {
+		statusSrv.SetUpdateHandler(func(info status.UpdateInfo) {
+			updates.offer(wr, info)
+		})
+	}
+
+	// Relay token rotation: save the new token so restarts and reconnects use it
+	runner.onTokenRotated = saveRotatedToken(savePath, configOpts)
 
 	statusSrv.SetQuitHandler(func() {
 		log.Println("[status] Quit requested via dashboard")
-		a.Stop()
+		runner.stop()
 	})
 
 	statusSrv.SetReconfigureHandler(func() {
 		log.Println("[status] Reconfigure requested via dashboard")
-		reconfigureMu.Lock()
-		reconfigureRequested = true
-		reconfigureMu.Unlock()
-		a.Stop()
+		runner.requestReconfigure()
+	})
+
+	statusSrv.SetRestartHandler(func() {
+		log.Println("[status] Restart requested")
+		runner.requestRestart()
+	})
+
+	// Tray icon mirroring the agent status, with the dashboard actions
+	var tr *tray.Tray
+	if tray.Supported && !noTray && ui.IsGuiAvailable() {
+		tr = tray.New(tray.Options{
+			Version:   Version,
+			OBSTarget: fmt.Sprintf("%s:%d", cfg.OBSHost, cfg.OBSPort),
+			OnOpenDashboard: func() {
+				_ = device.OpenBrowser(statusSrv.DashboardURL())
+			},
+			OnReconfigure: func() {
+				log.Println("[agent] Reconfigure requested via tray")
+				runner.requestReconfigure()
+			},
+			OnQuit: func() {
+				log.Println("[agent] Quit requested via tray")
+				runner.stop()
+			},
+		})
+		statusSrv.SetStatusHandler(func(st string) {
+			tr.SetStatus(tray.StatusFor(st))
+		})
+	}
+
+	// Desktop notification preferences: from the config, editable by the
+	// dashboard through /api/notifications and saved back to the config
+	if cfg.Notifications != nil {
+		statusSrv.SetNotificationPrefs(*cfg.Notifications)
+	}
+	statusSrv.SetNotificationPrefsHandler(func(p status.NotificationPrefs) error {
+		if savePath == "" {
+			return fmt.Errorf("no config file to save to")
+		}
+		err := runner.updateConfig(func(cfg *agent.Config) error {
+			c := *cfg
+			c.Notifications = &p
+			if err := agent.SaveConfig(savePath, &c, configOpts); err != nil {
+				return err
+			}
+			cfg.Notifications = &p
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		log.Printf("[status] Notification preferences saved to %s", savePath)
+		return nil
 	})
 
 	// Desktop notification debouncing (30s per event type)
@@ -291,6 +874,9 @@ func main() {
 	notifyLast := map[string]time.Time{}
 
 	statusSrv.SetStateChangeHandler(func(event, message string) {
+		if !statusSrv.NotificationPrefs().Allows(event, time.Now()) {
+			return
+		}
 		notifyMu.Lock()
 		last, ok := notifyLast[event]
 		now := time.Now()
@@ -307,61 +893,132 @@ func main() {
 	// Skip if wizard already opened a tab — the merged page transitions
 	// from setup to status inline without needing a second tab.
 	if !wizardRan && ui.IsGuiAvailable() && statusSrv.Port() > 0 {
-		_ = device.OpenBrowser(fmt.Sprintf("https://agent.4throck.cloud/status?port=%d", statusSrv.Port()))
+		_ = device.OpenBrowser(statusSrv.DashboardURL())
 	}
 
 	// 17. Signal handler (release lock, stop status, stop agent)
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 
+	var svcStop <-chan struct{} // nil (never ready) unless running as a service
+	if svcCtl != nil {
+		svcStop = svcCtl.StopRequested()
+	}
+
 	go func() {
-		<-sigCh
+		select {
+		case <-sigCh:
+		case <-svcStop:
+		}
 		log.Println("[agent] Shutting down...")
-		a.Stop()
+		sdnotify.Stopping()
+		runner.stop()
 	}()
 
-	// 18. a.Start() (blocking reconnection loop)
-	if err := a.Start(); err != nil {
-		// Token rejected — auto-trigger device auth to get a new valid token
-		if _, ok := err.(*tunnel.ErrTokenRejected); ok {
-			log.Println("[agent] Token rejected — starting device authorization...")
-			handleTokenRejected(wizard, cfg, defaultConfigPath, statusSrv, lock)
-			return
-		}
+	// -tui: full-screen status view; only ever enabled by the flag so
+	// containers and service logs never get escape sequences
+	if tuiMode {
+		dash = tui.New(tui.Options{
+			Version:  Version,
+			Snapshot: statusSrv.Snapshot,
+			Logs:     logRing.Lines,
+			OnQuit: func() {
+				log.Println("[agent] Quit requested via terminal")
+				runner.stop()
+			},
+			OnReconfigure: func() {
+				log.Println("[agent] Reconfigure requested via terminal")
+				runner.requestReconfigure()
+			},
+		})
+		go dash.Run()
+	}
 
-		// Check if this was a reconfigure request
-		reconfigureMu.Lock()
-		reconfig := reconfigureRequested
-		reconfigureMu.Unlock()
+	// 18. runner.run() (blocking reconnection loop). Token rejection and
+	// reconfigure requests update cfg and loop back on the same status server.
+	// The terminal view steps aside while a wizard may prompt on the console.
+	runAgent := func() {
+		for {
+			err := runner.run()
+
+			// Token rejected — auto-trigger device auth to get a new valid token
+			// (-non-interactive can't prompt, so it exits instead)
+			if _, ok := err.(*tunnel.ErrTokenRejected); ok {
+				if nonInteract {
+					dash.Stop()
+					statusSrv.Stop()
+					lock.Release()
+					exitProvision(&provisionError{"token_rejected", "the relay rejected the token"})
+				}
+				log.Println("[agent] Token rejected — starting device authorization...")
+				dash.Suspend()
+				statusSrv.SuspendHeartbeat()
+				c := runner.config()
+				handleTokenRejected(wizard, &c, savePath, configOpts, statusSrv, lock)
+				runner.setConfig(c)
+				statusSrv.ResumeHeartbeat()
+				dash.Resume()
+				continue
+			}
 
-		if reconfig {
-			log.Println("[agent] Restarting for reconfiguration...")
-			handleReconfigure(wizard, cfg, defaultConfigPath, statusSrv, lock, a)
-			return
+			if runner.takeReconfigure() {
+				if nonInteract {
+					log.Println("[agent] Reconfigure ignored in -non-interactive mode — reconnecting with the same settings")
+					continue
+				}
+				log.Println("[agent] Restarting for reconfiguration...")
+				dash.Suspend()
+				statusSrv.SuspendHeartbeat()
+				c := runner.config()
+				handleReconfigure(wizard, &c, savePath, configOpts, statusSrv, lock)
+				runner.setConfig(c)
+				statusSrv.ResumeHeartbeat()
+				dash.Resume()
+				continue
+			}
+
+			if err != nil {
+				dash.Stop()
+				eventLog.Close()
+				statusSrv.Stop()
+				lock.Release()
+				fatalWait(fmt.Sprintf("[agent] Fatal: %v", err))
+			}
+			break
 		}
 
+		dash.Stop()
+		eventLog.Close()
 		statusSrv.Stop()
 		lock.Release()
-		fatalWait(fmt.Sprintf("[agent] Fatal: %v", err))
+		if svcCtl != nil {
+			// A service can't re-exec itself; exiting non-zero makes the
+			// SCM's recovery action start the updated binary
+			if updates.pending() {
+				svcCtl.Stopped(1)
+			} else {
+				svcCtl.Stopped(0)
+			}
+			return
+		}
+		updates.restart()
 	}
 
-	// Check if agent stopped due to reconfigure
-	reconfigureMu.Lock()
-	reconfig := reconfigureRequested
-	reconfigureMu.Unlock()
-
-	if reconfig {
-		log.Println("[agent] Restarting for reconfiguration...")
-		handleReconfigure(wizard, cfg, defaultConfigPath, statusSrv, lock, a)
+	// 19. With a tray icon (CGO builds on Windows/macOS) the tray owns the
+	// main thread and the agent loop runs beside it
+	if tr == nil {
+		runAgent()
 		return
 	}
-
-	statusSrv.Stop()
-	lock.Release()
+	go func() {
+		runAgent()
+		tr.Stop()
+	}()
+	tr.Run()
 }
 
-// handleReconfigure runs the OBS wizard to reconfigure, then restarts the agent.
-func handleReconfigure(w ui.UI, cfg *agent.Config, savePath string, statusSrv *status.Server, lock *instance.Lock, oldAgent *agent.Agent) {
+// handleReconfigure runs the OBS wizard and updates cfg. The caller restarts the agent.
+func handleReconfigure(w ui.UI, cfg *agent.Config, savePath string, opts agent.ConfigOptions, statusSrv *status.Server, lock *instance.Lock) {
 	detected := autoDetectOBS()
 
 	if runner, ok := w.(ui.WizardRunner); ok {
@@ -370,16 +1027,21 @@ func handleReconfigure(w ui.UI, cfg *agent.Config, savePath string, statusSrv *s
 			Version:       Version,
 			DefaultHost:   cfg.OBSHost,
 			DefaultPort:   cfg.OBSPort,
-			OBSDetected:   detected != nil,
 			SavePath:      savePath,
 			ExistingToken: cfg.Token,
+			ConfigOptions: opts,
+			MinOBSVersion: cfg.MinOBSVersion,
+
+			DefaultLaunchPath: cfg.OBSLaunchPath,
+			DefaultLaunchArgs: cfg.OBSLaunchArgs,
 		}
-		if detected != nil {
-			wizCfg.DefaultHost = detected.Host
-			wizCfg.DefaultPort = detected.Port
-		}
+		detected.applyTo(&wizCfg)
 
 		result, err := runner.RunOBSWizard(wizCfg)
+		if errors.Is(err, ui.ErrWizardTimeout) {
+			log.Printf("[agent] Reconfiguration abandoned — keeping %s:%d", cfg.OBSHost, cfg.OBSPort)
+			return
+		}
 		if err != nil {
 			log.Printf("[agent] Reconfiguration wizard failed: %v", err)
 			statusSrv.Stop()
@@ -391,52 +1053,39 @@ func handleReconfigure(w ui.UI, cfg *agent.Config, savePath string, statusSrv *s
 		// OBSHost is hardcoded — only take port/pass/token from wizard
 		cfg.OBSPort = result.OBSPort
 		cfg.OBSPass = result.OBSPass
-		if result.Token != "" {
+		cfg.OBSLaunchPath = result.OBSLaunchPath
+		cfg.OBSLaunchArgs = result.OBSLaunchArgs
+		if result.Token != "" && result.Token != cfg.Token {
 			cfg.Token = result.Token
+			cfg.AgentName = result.AgentName
 		}
 	} else {
 		// CLI fallback
 		collectOBSSettings(w, cfg, detected)
-		autoSaveConfig(w, savePath, cfg)
+		autoSaveConfig(w, savePath, cfg, opts)
 	}
 
-	// Restart agent with new config on the same status server
-	log.Printf("[agent] Restarting with new OBS target: %s:%d", cfg.OBSHost, cfg.OBSPort)
-	statusSrv.UpdateConfig(cfg.OBSHost, cfg.OBSPort, cfg.RelayURL)
-
-	newAgent := agent.New(cfg)
-	newAgent.StatusServer = statusSrv
-
 	// No need to open a new browser tab — the wizard page transitions
 	// to status view inline after reconfiguration completes.
-
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-sigCh
-		log.Println("[agent] Shutting down...")
-		newAgent.Stop()
-	}()
-
-	if err := newAgent.Start(); err != nil {
-		statusSrv.Stop()
-		lock.Release()
-		fatalWait(fmt.Sprintf("[agent] Fatal: %v", err))
-	}
-
-	statusSrv.Stop()
-	lock.Release()
+	log.Printf("[agent] Restarting with new OBS target: %s:%d", cfg.OBSHost, cfg.OBSPort)
 }
 
-// handleTokenRejected clears the bad token, runs device auth to get a new one, and restarts.
-func handleTokenRejected(w ui.UI, cfg *agent.Config, savePath string, statusSrv *status.Server, lock *instance.Lock) {
+// handleTokenRejected clears the bad token and runs device auth to get a new one.
+// The caller restarts the agent.
+func handleTokenRejected(w ui.UI, cfg *agent.Config, savePath string, opts agent.ConfigOptions, statusSrv *status.Server, lock *instance.Lock) {
 	// Clear the rejected token and delete old config
 	cfg.Token = ""
+	cfg.AgentName = ""
 	os.Remove(savePath)
 
 	// Run device auth to get a new valid token
 	detected := autoDetectOBS()
-	runWizardSetup(w, cfg, savePath, detected, false)
+	if err := runWizardSetup(w, cfg, savePath, opts, detected, false); err != nil {
+		statusSrv.Stop()
+		lock.Release()
+		wizardFailed(err)
+		return
+	}
 
 	if cfg.Token == "" || !tokenRegex.MatchString(cfg.Token) {
 		statusSrv.Stop()
@@ -445,50 +1094,32 @@ func handleTokenRejected(w ui.UI, cfg *agent.Config, savePath string, statusSrv
 		return
 	}
 
-	log.Printf("[agent] Re-authenticated successfully, restarting...")
-	statusSrv.UpdateConfig(cfg.OBSHost, cfg.OBSPort, cfg.RelayURL)
-
-	newAgent := agent.New(cfg)
-	newAgent.StatusServer = statusSrv
+	statusSrv.SetAgentName(cfg.AgentName)
 
 	// No need to open a new browser tab — the wizard page transitions
 	// to status view inline after re-authentication completes.
-
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-sigCh
-		log.Println("[agent] Shutting down...")
-		newAgent.Stop()
-	}()
-
-	if err := newAgent.Start(); err != nil {
-		statusSrv.Stop()
-		lock.Release()
-		fatalWait(fmt.Sprintf("[agent] Fatal: %v", err))
-	}
-
-	statusSrv.Stop()
-	lock.Release()
+	log.Printf("[agent] Re-authenticated successfully, restarting...")
 }
 
 // runWizardSetup runs the appropriate wizard flow for initial setup.
 // If the wizard implements WizardRunner (WebUI), it uses the branded browser wizard.
-// Otherwise it falls back to the CLI/GUI dialog flow.
-func runWizardSetup(w ui.UI, cfg *agent.Config, savePath string, detected *obsDetectResult, forceSetup bool) {
+// Otherwise it falls back to the CLI/GUI dialog flow. It returns the
+// browser wizard's error, e.g. ui.ErrWizardTimeout.
+func runWizardSetup(w ui.UI, cfg *agent.Config, savePath string, opts agent.ConfigOptions, detected *obsDetectResult, forceSetup bool) error {
 	if runner, ok := w.(ui.WizardRunner); ok {
 		wizCfg := ui.WizardConfig{
-			RelayURL:    cfg.RelayURL,
-			Version:     Version,
-			DefaultHost: cfg.OBSHost,
-			DefaultPort: cfg.OBSPort,
-			OBSDetected: detected != nil,
-			SavePath:    savePath,
-		}
-		if detected != nil {
-			wizCfg.DefaultHost = detected.Host
-			wizCfg.DefaultPort = detected.Port
+			RelayURL:      cfg.RelayURL,
+			Version:       Version,
+			DefaultHost:   cfg.OBSHost,
+			DefaultPort:   cfg.OBSPort,
+			SavePath:      savePath,
+			ConfigOptions: opts,
+			MinOBSVersion: cfg.MinOBSVersion,
+
+			DefaultLaunchPath: cfg.OBSLaunchPath,
+			DefaultLaunchArgs: cfg.OBSLaunchArgs,
 		}
+		detected.applyTo(&wizCfg)
 
 		var result *ui.WizardResult
 		var err error
@@ -503,22 +1134,75 @@ func runWizardSetup(w ui.UI, cfg *agent.Config, savePath string, detected *obsDe
 		}
 
 		if err != nil {
-			fatalWait(fmt.Sprintf("[agent] Setup wizard failed: %v", err))
+			return err
 		}
 
 		cfg.Token = result.Token
 		// OBSHost is hardcoded — only take port/pass from wizard
 		cfg.OBSPort = result.OBSPort
 		cfg.OBSPass = result.OBSPass
-		return
+		cfg.OBSLaunchPath = result.OBSLaunchPath
+		cfg.OBSLaunchArgs = result.OBSLaunchArgs
+		if result.AgentName != "" {
+			cfg.AgentName = result.AgentName
+		}
+		return nil
 	}
 
 	// CLI fallback — manual token entry
-	runSetup(w, cfg, savePath, detected)
+	runSetup(w, cfg, savePath, opts, detected)
+	return nil
+}
+
+// wizardFailed exits after the setup wizard failed; the caller has
+// released the status server and instance lock. A timed-out wizard gets
+// no error dialog, since nobody is at the machine to close it.
+func wizardFailed(err error) {
+	if errors.Is(err, ui.ErrWizardTimeout) {
+		log.Println("[agent] Setup was not finished in time — start the agent again (or run obs-agent -setup) to complete it")
+		os.Exit(1)
+	}
+	fatalWait(fmt.Sprintf("[agent] Setup wizard failed: %v", err))
+}
+
+// runUpdateWizard shows the migration from the legacy config at
+// legacyPath to savePath. The config is already saved; the wizard only
+// explains the change and tests the OBS settings, so failures are logged.
+func runUpdateWizard(runner ui.WizardRunner, cfg *agent.Config, legacyPath, savePath string, opts agent.ConfigOptions) {
+	_, err := runner.RunUpdateWizard(ui.WizardConfig{
+		RelayURL:      cfg.RelayURL,
+		Version:       Version,
+		DefaultHost:   cfg.OBSHost,
+		DefaultPort:   cfg.OBSPort,
+		SavePath:      savePath,
+		ExistingToken: cfg.Token,
+		ConfigOptions: opts,
+		MinOBSVersion: cfg.MinOBSVersion,
+
+		ExistingOBSPass: cfg.OBSPass,
+		LegacyPath:      legacyPath,
+
+		DefaultLaunchPath: cfg.OBSLaunchPath,
+		DefaultLaunchArgs: cfg.OBSLaunchArgs,
+	})
+	if err != nil {
+		log.Printf("[agent] Migration wizard failed: %v", err)
+	}
+}
+
+// verifyBinary checks the running binary against the release manifest,
+// cached in manifestCache when set.
+func verifyBinary(manifestCache string, strict bool) (*integrity.Result, error) {
+	return integrity.Verify(integrity.Options{CacheDir: manifestCache, Strict: strict})
 }
 
 // runVerify performs a verbose integrity check and exits.
-func runVerify() {
+func runVerify(manifestPath, manifestCache string, strict bool) {
+	if !integrity.HasPublicKey() {
+		fmt.Fprintln(os.Stderr, "Verification unavailable: this build has no release public key, so manifest signatures cannot be checked.")
+		fmt.Fprintln(os.Stderr, "Use a release binary, or build with make -C build MANIFEST_PUBKEY=<base64 key>.")
+		os.Exit(1)
+	}
 	fmt.Println("Computing binary SHA256...")
 	hash, err := integrity.SelfHash()
 	if err != nil {
@@ -527,13 +1211,39 @@ func runVerify() {
 	}
 	fmt.Printf("Local SHA256: %s\n\n", hash)
 
-	fmt.Printf("Fetching manifest from %s...\n", integrity.DefaultManifestURL)
-	result, err := integrity.Verify("")
+	var result *integrity.Result
+	if manifestPath != "" {
+		fmt.Printf("Reading manifest from %s...\n", manifestPath)
+		result, err = integrity.VerifyLocal(manifestPath, strict)
+	} else {
+		if manifestCache != "" {
+			fmt.Printf("Reading manifest from %s or %s...\n", manifestCache, integrity.DefaultManifestURL)
+		} else {
+			fmt.Printf("Fetching manifest from %s...\n", integrity.DefaultManifestURL)
+		}
+		result, err = verifyBinary(manifestCache, strict)
+	}
+	if errors.Is(err, integrity.ErrSignature) {
+		fmt.Fprintf(os.Stderr, "\nResult: FAIL — %v (the manifest may have been tampered with)\n", err)
+		os.Exit(1)
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
 		os.Exit(1)
 	}
+	if result.Signature == integrity.SignatureVerified {
+		fmt.Println("Manifest signature: OK (Ed25519)")
+	} else {
+		fmt.Println("Manifest signature: UNAVAILABLE — trusting HTTPS only (use -strict-integrity to refuse)")
+	}
 
+	if result.Source == integrity.SourceCached && manifestCache != "" && result.CacheAge < integrity.CacheMaxAge {
+		fmt.Printf("Manifest source:  cached copy (%s old)\n", result.CacheAge.Round(time.Minute))
+	} else if result.Source == integrity.SourceCached {
+		fmt.Printf("Manifest source:  cached copy (%s old) — network fetch failed\n", result.CacheAge.Round(time.Minute))
+	} else {
+		fmt.Printf("Manifest source:  %s\n", result.Source)
+	}
 	fmt.Printf("Manifest version: %s\n", result.Version)
 	fmt.Printf("Expected SHA256:  %s\n", result.Expected)
 	fmt.Printf("Actual SHA256:    %s\n", result.Actual)
@@ -545,12 +1255,37 @@ func runVerify() {
 	}
 }
 
+// statusAddr is the status server address of this -instance: the port
+// the running agent recorded in its .port file, else the preferred one.
+// An unspecified -status-bind (0.0.0.0, ::) is dialled as loopback.
+func statusAddr() string {
+	host := statusBind
+	if net.ParseIP(host).IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	port := instance.Port(agentInstance, status.DefaultPort)
+	// The file outlives the agent: only trust it while the instance runs
+	dir := binaryDirectory()
+	if running, _, err := instance.Probe(dir, agentInstance); err == nil && running {
+		if p, err := status.ReadPortFile(filepath.Join(dir, instance.FileName(agentInstance, ".port"))); err == nil {
+			port = p
+		}
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// statusListenAddr is where this -instance's status server listens.
+func statusListenAddr() string {
+	return net.JoinHostPort(statusBind, strconv.Itoa(instance.Port(agentInstance, status.DefaultPort)))
+}
+
 // runStatusQuery fetches status from a running agent and pretty-prints it.
 func runStatusQuery() {
+	addr := statusAddr()
 	client := &http.Client{Timeout: 3 * time.Second}
-	resp, err := client.Get("http://" + status.DefaultAddr + "/api/status")
+	resp, err := client.Get("http://" + addr + "/api/status")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "No agent running (could not connect to %s)\n", status.DefaultAddr)
+		fmt.Fprintf(os.Stderr, "No agent running (could not connect to %s)\n", addr)
 		os.Exit(1)
 	}
 	defer resp.Body.Close()
@@ -565,6 +1300,109 @@ func runStatusQuery() {
 	fmt.Println(string(out))
 }
 
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// parseRateLimits builds the relay rate limits from the -rate-limit* flags.
+func parseRateLimits(read, write, overrides string) (tunnel.RateLimits, error) {
+	var l tunnel.RateLimits
+	var err error
+	if l.Read, err = tunnel.ParseRateLimit(read); err != nil {
+		return l, fmt.Errorf("-rate-limit-read: %w", err)
+	}
+	if l.Write, err = tunnel.ParseRateLimit(write); err != nil {
+		return l, fmt.Errorf("-rate-limit-write: %w", err)
+	}
+	if l.PerType, err = tunnel.ParseRateLimitOverrides(tunnel.DefaultRateLimitOverrides); err != nil {
+		return l, err
+	}
+	user, err := tunnel.ParseRateLimitOverrides(overrides)
+	if err != nil {
+		return l, fmt.Errorf("-rate-limit: %w", err)
+	}
+	for typ, limit := range user {
+		l.PerType[typ] = limit
+	}
+	return l, nil
+}
+
+// runServiceStatus prints the startup registration and exits non-zero
+// when it is not installed.
+func runServiceStatus(jsonOut bool) {
+	st := service.Status()
+	if jsonOut {
+		out, _ := json.MarshalIndent(st, "", "  ")
+		fmt.Println(string(out))
+	} else if !st.Installed {
+		fmt.Println("Startup service: not installed (run obs-agent -install)")
+	} else {
+		state := "stopped"
+		if st.Running {
+			state = "running"
+		}
+		fmt.Printf("Startup service: installed (%s), %s\n", st.Mode, state)
+		fmt.Printf("  %s\n", st.Path)
+	}
+	if !st.Installed {
+		os.Exit(1)
+	}
+}
+
+// serviceInfo converts a service.State for the status server.
+func serviceInfo(st service.State) status.ServiceInfo {
+	return status.ServiceInfo{
+		Installed: st.Installed,
+		Running:   st.Running,
+		Mode:      string(st.Mode),
+		Path:      st.Path,
+		Managed:   st.Managed,
+	}
+}
+
+// runRestartRequest asks a running agent to drop and re-establish its
+// connections. Authenticates with the control token next to the binary.
+func runRestartRequest() {
+	token, err := status.ReadTokenFile(filepath.Join(binaryDirectory(), instance.FileName(agentInstance, ".token")))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not read control token (%v) — is the agent running from this directory?\n", err)
+		os.Exit(1)
+	}
+
+	addr := statusAddr()
+	req, _ := http.NewRequest("POST", "http://"+addr+"/api/restart", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "No agent running (could not connect to %s)\n", addr)
+		os.Exit(1)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		fmt.Fprintln(os.Stderr, "Restart refused: control token does not match the running agent")
+		os.Exit(1)
+	}
+
+	var data struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil || !data.OK {
+		fmt.Fprintf(os.Stderr, "Restart failed: %s\n", data.Error)
+		os.Exit(1)
+	}
+	fmt.Println("Restart requested — the agent is reconnecting.")
+}
+
 func isFlagSet(name string) bool {
 	found := false
 	flag.Visit(func(f *flag.Flag) {
@@ -597,92 +1435,334 @@ func binaryDirectory() string {
 	return filepath.Dir(exe)
 }
 
-// defaultConfigFile returns the config file path next to the binary
+// defaultConfigFile returns the config file path next to the binary:
+// obs-agent.dat, or obs-agent.<instance>.dat for a named -instance
 func defaultConfigFile() string {
 	dir := binaryDirectory()
 	if dir == "." {
 		return ""
 	}
+	if agentInstance != instance.DefaultName {
+		return filepath.Join(dir, "obs-agent."+agentInstance+".dat")
+	}
 	return filepath.Join(dir, "obs-agent.dat")
 }
 
-// legacyConfigFile returns the old JSON config path (for migration)
+// legacyConfigFile returns the old JSON config path (for migration). Named
+// instances are newer than it and have none.
 func legacyConfigFile() string {
 	dir := binaryDirectory()
-	if dir == "." {
+	if dir == "." || agentInstance != instance.DefaultName {
 		return ""
 	}
 	return filepath.Join(dir, "obs-agent.json")
 }
 
-// setupFileLogging opens obs-agent.log next to the binary for persistent logging.
+// setupFileLogging opens the log of the named instance (obs-agent.log, or
+// obs-agent-<instance>.log) next to the binary for persistent logging.
 // On Windows (GUI mode), log only to file. On other OS, log to both stderr and file.
-func setupFileLogging() {
+// Every mode also tees into logRing so the status API can serve the tail.
+//
+// With jsonLogs every sink gets one JSON record per line, and the console
+// copy goes to stdout so it can be piped (obs-agent -json-logs | jq .).
+// With noConsole (-tui) nothing is written to the console; the dashboard
+// shows the tail from logRing instead.
+func setupFileLogging(jsonLogs, noConsole bool, name string) {
+	var console io.Writer = os.Stderr
+	if jsonLogs {
+		console = os.Stdout
+	}
+	if noConsole {
+		console = io.Discard
+	}
+	setOutput := func(w ...io.Writer) {
+		out := io.MultiWriter(w...)
+		if jsonLogs {
+			log.SetFlags(0)
+			out = logging.NewJSONWriter(out)
+		}
+		log.SetOutput(out)
+	}
+	setOutput(console, logRing)
+
 	dir := binaryDirectory()
 	if dir == "." {
 		return
 	}
-	logPath := filepath.Join(dir, "obs-agent.log")
+	logPath := filepath.Join(dir, instance.FileName(name, ".log"))
 	f, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
 	if err != nil {
 		return
 	}
 	if runtime.GOOS == "windows" {
 		// Windows GUI mode — no console, log to file only
-		log.SetOutput(f)
+		setOutput(f, logRing)
 	} else {
-		log.SetOutput(io.MultiWriter(os.Stderr, f))
+		setOutput(console, f, logRing)
+	}
+}
+
+// runRotateKey re-encrypts the config at path and returns the exit code.
+func runRotateKey(path string, opts agent.ConfigOptions) int {
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "No config file found — nothing to rotate.")
+		return 1
+	}
+	if err := agent.RotateConfigKey(path, opts); err != nil {
+		fmt.Fprintf(os.Stderr, "Key rotation failed: %v\n", err)
+		return 1
+	}
+	log.Printf("[agent] Config re-encrypted under a new storage key: %s", path)
+	fmt.Println("Config re-encrypted under a new storage key.")
+	return 0
+}
+
+// runResetToken clears the token in the config at path and returns the
+// exit code.
+func runResetToken(path string, opts agent.ConfigOptions) int {
+	err := agent.ResetToken(path, opts)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintln(os.Stderr, "No config found — there is no token to clear.")
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Token reset failed: %v\n", err)
+		return 1
+	}
+	log.Printf("[agent] Agent token cleared from %s", path)
+	fmt.Println("Token cleared. Run obs-agent to re-authorize.")
+	return 0
+}
+
+// runExportConfig writes the config at path to out under a passphrase and
+// returns the exit code.
+func runExportConfig(path, out, passphrase string, opts agent.ConfigOptions) int {
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "No config file found — nothing to export.")
+		return 1
+	}
+	if passphrase == "" {
+		passphrase = os.Getenv("OBS_AGENT_EXPORT_PASSPHRASE")
+	}
+	if passphrase == "" {
+		var ok bool
+		if passphrase, ok = promptPassphrase("Export config", true); !ok {
+			return 1
+		}
+	}
+	err := agent.ExportConfig(path, out, passphrase, opts)
+	if errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintln(os.Stderr, "No config file found — nothing to export.")
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Export failed: %v\n", err)
+		return 1
+	}
+	log.Printf("[agent] Config exported to %s", out)
+	fmt.Printf("Config exported to %s. Import it on the new machine with:\n  obs-agent -import-config %s\n", out, filepath.Base(out))
+	return 0
+}
+
+// runImportConfig saves the export in in to the config at path and
+// returns the exit code.
+func runImportConfig(in, path, passphrase string, opts agent.ConfigOptions) int {
+	if passphrase == "" {
+		passphrase = os.Getenv("OBS_AGENT_EXPORT_PASSPHRASE")
+	}
+	if passphrase == "" {
+		var ok bool
+		if passphrase, ok = promptPassphrase("Import config", false); !ok {
+			return 1
+		}
+	}
+	profiles, err := agent.ImportConfig(in, path, passphrase, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Import failed: %v\n", err)
+		return 1
+	}
+	if len(profiles) == 0 {
+		fmt.Fprintln(os.Stderr, "The export holds no configured profiles — nothing imported.")
+		return 1
 	}
+	log.Printf("[agent] Config imported from %s into %s (profiles: %s)", in, path, strings.Join(profiles, ", "))
+	fmt.Printf("Config imported (profiles: %s). Run obs-agent to connect.\n", strings.Join(profiles, ", "))
+	return 0
+}
+
+// promptPassphrase asks for the -export-config / -import-config
+// passphrase, twice when confirm is set.
+func promptPassphrase(title string, confirm bool) (string, bool) {
+	if wizard == nil {
+		fmt.Fprintln(os.Stderr, "Set OBS_AGENT_EXPORT_PASSPHRASE to pass the passphrase (no prompt with -non-interactive).")
+		return "", false
+	}
+	pass, ok := wizard.Password(title, "Passphrase")
+	if !ok || pass == "" {
+		fmt.Fprintln(os.Stderr, "No passphrase given.")
+		return "", false
+	}
+	if confirm {
+		again, ok := wizard.Password(title, "Repeat passphrase")
+		if !ok || again != pass {
+			fmt.Fprintln(os.Stderr, "Passphrases do not match.")
+			return "", false
+		}
+	}
+	return pass, true
+}
+
+// registerSecrets tells the log redactor about the token and OBS password
+// so they never appear in served or structured logs.
+func registerSecrets(cfg *agent.Config) {
+	logging.AddSecret(cfg.Token)
+	logging.AddSecret(cfg.OBSPass)
 }
 
 // obsDetectResult holds auto-detected OBS WebSocket connection info
 type obsDetectResult struct {
 	Host string
 	Port int
+
+	// Running is set when OBS answered on Port; otherwise Port comes from
+	// OBS's config alone
+	Running bool
+	// Password is the server password from OBS's config ("" if none or
+	// unknown). Never log it
+	Password string
+	// Disabled is set when OBS's config has the WebSocket server off
+	Disabled bool
+	// SettingsPath is the OBS file Password and Disabled came from
+	SettingsPath string
 }
 
-// autoDetectOBS scans common OBS WebSocket ports on localhost.
-// Returns detected host/port or nil if OBS is not found.
-func autoDetectOBS() *obsDetectResult {
-	ports := []int{4455, 4454, 4456}
-	for _, port := range ports {
-		addr := fmt.Sprintf("localhost:%d", port)
-		// Quick TCP check first
-		conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
-		if err != nil {
-			continue
-		}
-		conn.Close()
+// applyTo sets the wizard's OBS defaults from what was detected.
+func (d *obsDetectResult) applyTo(wizCfg *ui.WizardConfig) {
+	if d == nil {
+		return
+	}
+	wizCfg.DefaultHost = d.Host
+	wizCfg.DefaultPort = d.Port
+	wizCfg.OBSDetected = d.Running
+	wizCfg.DetectedOBSPass = d.Password
+	wizCfg.OBSServerDisabled = d.Disabled
+	wizCfg.OBSSettingsPath = d.SettingsPath
+}
 
-		// Try WebSocket handshake and read Hello (op 0)
-		dialer := &websocket.Dialer{HandshakeTimeout: 2 * time.Second}
-		ws, _, err := dialer.Dial(fmt.Sprintf("ws://%s", addr), nil)
-		if err != nil {
-			continue
-		}
+// OBS auto-detect scan (-obs-scan-ports, -obs-scan-timeout).
+var (
+	obsScanPorts   = []int{4455, 4454, 4456}
+	obsScanTimeout = 2 * time.Second
+)
 
-		ws.SetReadDeadline(time.Now().Add(2 * time.Second))
-		_, data, err := ws.ReadMessage()
-		ws.Close()
-		if err != nil {
-			continue
+// parsePortList parses a comma-separated list of TCP ports.
+func parsePortList(s string) ([]int, error) {
+	var ports []int
+	for _, f := range strings.Split(s, ",") {
+		port, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("%q is not a port", strings.TrimSpace(f))
 		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
 
-		var msg struct {
-			Op int `json:"op"`
-			D  struct {
-				ObsWebSocketVersion string `json:"obsWebSocketVersion"`
-			} `json:"d"`
-		}
-		if err := json.Unmarshal(data, &msg); err != nil {
-			continue
+// autoDetectOBS probes the scan ports on localhost concurrently and
+// returns the first that answers with an OBS WebSocket Hello. The port and
+// password in OBS's own config (see obsdetect) are scanned first and fill
+// in the password; when nothing answers they are returned on their own,
+// and nil only when there is no OBS config either.
+func autoDetectOBS() *obsDetectResult {
+	settings, err := obsdetect.Read()
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("[agent] Could not read OBS's WebSocket settings: %v", err)
+	}
+	ports := obsScanPorts
+	if settings != nil && !slices.Contains(ports, settings.Port) {
+		ports = append([]int{settings.Port}, ports...)
+	}
+
+	type hello struct {
+		port    int
+		version string
+	}
+	found := make(chan hello, len(ports))
+	var wg sync.WaitGroup
+	for _, port := range ports {
+		wg.Add(1)
+		go func(port int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), obsScanTimeout)
+			defer cancel()
+			if version := probeOBS(ctx, fmt.Sprintf("localhost:%d", port)); version != "" {
+				found <- hello{port, version}
+			}
+		}(port)
+	}
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	h, ok := <-found
+	if !ok {
+		if settings == nil {
+			return nil
 		}
-		if msg.Op == 0 && msg.D.ObsWebSocketVersion != "" {
-			log.Printf("[agent] Auto-detected OBS WebSocket v%s on port %d", msg.D.ObsWebSocketVersion, port)
-			return &obsDetectResult{Host: "localhost", Port: port}
+		if !settings.Enabled {
+			log.Printf("[agent] The WebSocket server is disabled in OBS (%s) — enable it under Tools → WebSocket Server Settings", settings.Path)
 		}
+		return &obsDetectResult{Host: "localhost", Port: settings.Port, Password: settings.Password, Disabled: !settings.Enabled, SettingsPath: settings.Path}
 	}
-	return nil
+	log.Printf("[agent] Auto-detected OBS WebSocket v%s on port %d", h.version, h.port)
+	result := &obsDetectResult{Host: "localhost", Port: h.port, Running: true}
+	if settings != nil && settings.Port == h.port && settings.Password != "" {
+		log.Printf("[agent] Using the WebSocket password from %s", settings.Path)
+		result.Password = settings.Password
+		result.SettingsPath = settings.Path
+	}
+	return result
+}
+
+// probeOBS returns the obs-websocket version if an OBS WebSocket server at
+// addr (host:port) sends its Hello before ctx is done, else "".
+func probeOBS(ctx context.Context, addr string) string {
+	// Quick TCP check first
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return ""
+	}
+	conn.Close()
+
+	// Try WebSocket handshake and read Hello (op 0)
+	ws, _, err := websocket.DefaultDialer.DialContext(ctx, fmt.Sprintf("ws://%s", addr), nil)
+	if err != nil {
+		return ""
+	}
+	defer ws.Close()
+
+	deadline, _ := ctx.Deadline()
+	ws.SetReadDeadline(deadline)
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return ""
+	}
+
+	var msg struct {
+		Op int `json:"op"`
+		D  struct {
+			ObsWebSocketVersion string `json:"obsWebSocketVersion"`
+		} `json:"d"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return ""
+	}
+	if msg.Op == 0 && msg.D.ObsWebSocketVersion != "" {
+		return msg.D.ObsWebSocketVersion
+	}
+	return ""
 }
 
 // detectOBSHost returns the hardcoded OBS host.
@@ -695,6 +1775,20 @@ func detectOBSHost() string {
 	return "localhost"
 }
 
+// watchdogLoop sends WATCHDOG=1 every interval for as long as the agent
+// loop keeps beating. The heartbeat is read in-process rather than over
+// HTTP, so neither -status-allowlist nor a busy status server can starve
+// the watchdog; a tunnel that hangs no longer feeds it.
+func watchdogLoop(statusSrv *status.Server, interval time.Duration) {
+	for range time.Tick(interval) {
+		if silent := statusSrv.HeartbeatSilence(); silent > 0 {
+			log.Printf("[agent] Watchdog: agent loop silent for %s — not notifying systemd", silent.Round(time.Second))
+			continue
+		}
+		sdnotify.Watchdog()
+	}
+}
+
 // fatalWait shows an error via GUI dialog or stderr, then exits.
 func fatalWait(msg string) {
 	log.Println(msg)
@@ -723,7 +1817,7 @@ func relayToHTTPS(relayURL string) string {
 }
 
 // runDeviceAuth performs the browser-based device authorization flow (CLI fallback).
-func runDeviceAuth(w ui.UI, cfg *agent.Config, savePath string, detected *obsDetectResult) error {
+func runDeviceAuth(w ui.UI, cfg *agent.Config, savePath string, opts agent.ConfigOptions, detected *obsDetectResult) error {
 	ctx := context.Background()
 	baseURL := relayToHTTPS(cfg.RelayURL)
 
@@ -744,6 +1838,9 @@ func runDeviceAuth(w ui.UI, cfg *agent.Config, savePath string, detected *obsDet
 	flow := &device.Flow{
 		BaseURL: baseURL,
 		Version: Version,
+		OnRetry: func(attempt int, wait time.Duration, err error) {
+			log.Printf("[agent] Device authorization unavailable (%v) — retrying in %v", err, wait)
+		},
 	}
 
 	// Request device code
@@ -757,6 +1854,7 @@ func runDeviceAuth(w ui.UI, cfg *agent.Config, savePath string, detected *obsDet
 	if code.Status == "already_authorized" && code.Token != "" {
 		log.Printf("[agent] Machine already authorized as %q — reconnecting", code.AgentName)
 		cfg.Token = code.Token
+		cfg.AgentName = code.AgentName
 		w.Info("Already Authorized", fmt.Sprintf("This machine is already authorized as %q.\nReconnecting...", code.AgentName))
 	} else {
 		// Open browser
@@ -765,16 +1863,20 @@ func runDeviceAuth(w ui.UI, cfg *agent.Config, savePath string, detected *obsDet
 			log.Printf("[agent] Could not open browser: %v", browserErr)
 		}
 
-		// Show user the code and URL
-		msg := fmt.Sprintf(
-			"A browser window should open.\n\nIf not, go to:\n%s\n\nVerification code: %s\n\nWaiting for approval...",
-			verifyURL, code.UserCode,
-		)
 		log.Printf("[agent] Verification URL: %s", verifyURL)
 		log.Printf("[agent] User code: %s", code.UserCode)
 
-		// Non-blocking info display (CLI prints to stderr, GUI shows dialog)
-		go w.Info("Sign In to Authorize", msg)
+		// Show user the code and URL. Headless CLI gets a scannable QR code;
+		// otherwise a non-blocking info display (GUI shows dialog)
+		if cli, ok := w.(*ui.CliUI); ok {
+			cli.DisplayAuthPrompt(verifyURL, code.UserCode)
+		} else {
+			msg := fmt.Sprintf(
+				"A browser window should open.\n\nIf not, go to:\n%s\n\nVerification code: %s\n\nWaiting for approval...",
+				verifyURL, code.UserCode,
+			)
+			go w.Info("Sign In to Authorize", msg)
+		}
 
 		// Poll for approval
 		token, err := flow.PollForToken(ctx, code.DeviceCode, code.Interval)
@@ -783,6 +1885,7 @@ func runDeviceAuth(w ui.UI, cfg *agent.Config, savePath string, detected *obsDet
 		}
 
 		cfg.Token = token
+		cfg.AgentName = agentName
 		log.Println("[agent] Device authorized successfully!")
 	}
 
@@ -790,13 +1893,13 @@ func runDeviceAuth(w ui.UI, cfg *agent.Config, savePath string, detected *obsDet
 	collectOBSSettings(w, cfg, detected)
 
 	// Auto-save config
-	autoSaveConfig(w, savePath, cfg)
+	autoSaveConfig(w, savePath, cfg, opts)
 
 	return nil
 }
 
 // runSetup runs the interactive setup wizard using the provided UI (CLI fallback).
-func runSetup(w ui.UI, cfg *agent.Config, savePath string, detected *obsDetectResult) {
+func runSetup(w ui.UI, cfg *agent.Config, savePath string, opts agent.ConfigOptions, detected *obsDetectResult) {
 	// Token
 	if cfg.Token == "" {
 		for {
@@ -811,6 +1914,7 @@ func runSetup(w ui.UI, cfg *agent.Config, savePath string, detected *obsDetectRe
 			val = strings.TrimSpace(val)
 			if tokenRegex.MatchString(val) {
 				cfg.Token = val
+				cfg.AgentName = ""
 				break
 			}
 			w.Error("Invalid Token", "Token must be exactly 64 hex characters. Try again.")
@@ -821,20 +1925,27 @@ func runSetup(w ui.UI, cfg *agent.Config, savePath string, detected *obsDetectRe
 	collectOBSSettings(w, cfg, detected)
 
 	// Auto-save config
-	autoSaveConfig(w, savePath, cfg)
+	autoSaveConfig(w, savePath, cfg, opts)
 }
 
 // collectOBSSettings shows a form dialog for OBS port and password.
 // OBS host is hardcoded and not configurable.
 func collectOBSSettings(w ui.UI, cfg *agent.Config, detected *obsDetectResult) {
 	defaultPort := cfg.OBSPort
+	passLabel := "OBS WebSocket password (blank if none)"
 	if detected != nil {
 		defaultPort = detected.Port
+		if detected.Disabled {
+			w.Info("OBS WebSocket Disabled", "The WebSocket server is turned off in OBS.\nEnable it under Tools → WebSocket Server Settings → Enable WebSocket server.")
+		}
+		if detected.Password != "" {
+			passLabel = "OBS WebSocket password (blank to use the one from OBS's settings)"
+		}
 	}
 
 	fields := []ui.FormField{
 		{Label: "OBS WebSocket port", Key: "port", Default: strconv.Itoa(defaultPort)},
-		{Label: "OBS WebSocket password (blank if none)", Key: "password", Password: true},
+		{Label: passLabel, Key: "password", Password: true},
 	}
 
 	values, ok := w.Form("OBS Connection", fields)
@@ -852,17 +1963,45 @@ func collectOBSSettings(w ui.UI, cfg *agent.Config, detected *obsDetectResult) {
 
 	if pw := strings.TrimSpace(values["password"]); pw != "" {
 		cfg.OBSPass = pw
+	} else if detected != nil && detected.Password != "" {
+		cfg.OBSPass = detected.Password
 	}
 }
 
 // autoSaveConfig saves the config file without prompting for confirmation.
-func autoSaveConfig(w ui.UI, savePath string, cfg *agent.Config) {
+func autoSaveConfig(w ui.UI, savePath string, cfg *agent.Config, opts agent.ConfigOptions) {
+	registerSecrets(cfg)
 	if savePath == "" {
 		return
 	}
-	if err := agent.SaveConfig(savePath, cfg); err != nil {
+	if err := agent.SaveConfig(savePath, cfg, opts); err != nil {
 		w.Error("Save Failed", fmt.Sprintf("Could not save config: %v", err))
 	} else {
 		log.Printf("[agent] Config saved to %s", savePath)
 	}
 }
+
+// configSavePath returns the file config changes are written to: the
+// -config file when given, else the default config path.
+func configSavePath(configFile, defaultPath string) string {
+	if configFile != "" {
+		return configFile
+	}
+	return defaultPath
+}
+
+// saveRotatedToken returns the runner's onTokenRotated hook, which writes
+// a relay-rotated token to savePath so restarts and reconnects use it.
+func saveRotatedToken(savePath string, opts agent.ConfigOptions) func(*agent.Config) error {
+	return func(c *agent.Config) error {
+		registerSecrets(c)
+		if savePath == "" {
+			return nil
+		}
+		if err := agent.SaveConfig(savePath, c, opts); err != nil {
+			return err
+		}
+		log.Printf("[agent] Rotated token saved to %s", savePath)
+		return nil
+	}
+}
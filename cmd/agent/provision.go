@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/agent"
+	"github.com/4throck/obs-agent/internal/obs"
+	"github.com/gorilla/websocket"
+)
+
+// obsAuthFailed is the close code OBS sends for a wrong password.
+const obsAuthFailed = 4009
+
+// provisionError is a -non-interactive failure. Code is stable for
+// scripts; Message is for people.
+type provisionError struct {
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e *provisionError) Error() string { return e.Message }
+
+// runNonInteractiveSetup checks cfg the way the wizard would (token format,
+// OBS reachable with the given password) and, with save, writes the
+// encrypted config to savePath with opts. It never prompts.
+func runNonInteractiveSetup(cfg *agent.Config, savePath string, save bool, opts agent.ConfigOptions) error {
+	if cfg.Token == "" {
+		return &provisionError{"missing_token", "a token is required: pass -token, set OBS_AGENT_TOKEN or provide a config file"}
+	}
+	if !tokenRegex.MatchString(cfg.Token) {
+		return &provisionError{"invalid_token", "token must be 64 hex characters"}
+	}
+	if cfg.OBSPort <= 0 || cfg.OBSPort > 65535 {
+		return &provisionError{"invalid_obs_port", fmt.Sprintf("-obs-port %d is out of range", cfg.OBSPort)}
+	}
+
+	addr := fmt.Sprintf("%s:%d", cfg.OBSHost, cfg.OBSPort)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, info, err := obs.ConnectInfo(ctx, addr, cfg.OBSPass)
+	if err != nil {
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) && closeErr.Code == obsAuthFailed {
+			return &provisionError{"obs_auth_failed", fmt.Sprintf("OBS at %s rejected the password — check -obs-pass", addr)}
+		}
+		var verErr *obs.ErrUnsupportedVersion
+		if errors.As(err, &verErr) || errors.Is(err, obs.ErrWebSocketV4) {
+			return &provisionError{"obs_version_unsupported", fmt.Sprintf("OBS at %s: %v", addr, err)}
+		}
+		return &provisionError{"obs_unreachable", fmt.Sprintf("could not connect to OBS at %s: %v", addr, err)}
+	}
+	conn.Close()
+	if err := obs.CheckMinVersion(info.WebSocketVersion, cfg.MinOBSVersion); err != nil {
+		return &provisionError{"obs_version_unsupported", fmt.Sprintf("OBS at %s: %v", addr, err)}
+	}
+	log.Printf("[agent] OBS reachable at %s (obs-websocket %s)", addr, info.WebSocketVersion)
+
+	if save {
+		if err := agent.SaveConfig(savePath, cfg, opts); err != nil {
+			return &provisionError{"save_failed", fmt.Sprintf("could not save config to %s: %v", savePath, err)}
+		}
+		log.Printf("[agent] Config saved to %s", savePath)
+	}
+	return nil
+}
+
+// exitProvision prints err to stderr as one JSON object and exits 1.
+func exitProvision(err error) {
+	var perr *provisionError
+	if !errors.As(err, &perr) {
+		perr = &provisionError{"error", err.Error()}
+	}
+	log.Printf("[agent] Non-interactive setup failed: %s", perr.Message)
+	json.NewEncoder(os.Stderr).Encode(perr)
+	os.Exit(1)
+}
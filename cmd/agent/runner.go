@@ -0,0 +1,167 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/4throck/obs-agent/internal/agent"
+	"github.com/4throck/obs-agent/internal/eventlog"
+	"github.com/4throck/obs-agent/internal/obslaunch"
+	"github.com/4throck/obs-agent/internal/status"
+	"github.com/4throck/obs-agent/internal/tunnel"
+)
+
+// agentRunner runs successive agent.Agent instances on one status server.
+// Status callbacks and the signal handler target the runner rather than a
+// specific agent, so they keep working across restarts and reconfigures.
+// Neither the instance lock nor the status server is touched here.
+type agentRunner struct {
+	statusSrv *status.Server
+	approvals *tunnel.ApprovalQueue
+	limiter   *tunnel.RateLimiter
+	stats     *tunnel.BridgeStats
+	eventLog  *eventlog.Log
+	// obsLauncher starts OBS when it is not running (nil in containers);
+	// shared so its respawn guard spans restarts
+	obsLauncher *obslaunch.Launcher
+	// onTokenRotated persists a relay-rotated token (see agent.OnTokenRotated)
+	onTokenRotated func(cfg *agent.Config) error
+	// onUpdateAvailable handles relay update notices (-auto-update)
+	onUpdateAvailable func(tunnel.UpdateNotice)
+
+	// cfg is the config each agent starts from. The relay rotates the
+	// token from the bridge goroutine while dashboard handlers read and
+	// save it, so it is only touched under cfgMu (see config and
+	// updateConfig); every agent gets its own copy.
+	cfg   *agent.Config
+	cfgMu sync.Mutex
+
+	mu          sync.Mutex
+	current     *agent.Agent
+	stopped     bool
+	restart     bool
+	reconfigure bool
+}
+
+func newAgentRunner(statusSrv *status.Server, cfg *agent.Config) *agentRunner {
+	return &agentRunner{statusSrv: statusSrv, cfg: cfg, stats: &tunnel.BridgeStats{}}
+}
+
+// config returns a copy of the current config.
+func (r *agentRunner) config() agent.Config {
+	r.cfgMu.Lock()
+	defer r.cfgMu.Unlock()
+	return *r.cfg
+}
+
+// setConfig replaces the config with cfg, as edited by a wizard while no
+// agent ran. Notification preferences the dashboard saved meanwhile are
+// kept.
+func (r *agentRunner) setConfig(cfg agent.Config) {
+	r.cfgMu.Lock()
+	defer r.cfgMu.Unlock()
+	cfg.Notifications = r.cfg.Notifications
+	*r.cfg = cfg
+}
+
+// updateConfig calls fn with the config locked, for changes made while an
+// agent may be running.
+func (r *agentRunner) updateConfig(fn func(cfg *agent.Config) error) error {
+	r.cfgMu.Lock()
+	defer r.cfgMu.Unlock()
+	return fn(r.cfg)
+}
+
+// tokenRotated records a token the relay rotated and persists the config
+// through onTokenRotated.
+func (r *agentRunner) tokenRotated(token string) error {
+	r.cfgMu.Lock()
+	r.cfg.Token = token
+	cfg := *r.cfg
+	r.cfgMu.Unlock()
+	if r.onTokenRotated == nil {
+		return nil
+	}
+	return r.onTokenRotated(&cfg)
+}
+
+// run starts an agent for the config and blocks until it stops. Restart
+// requests start a fresh agent with the same config instead of returning.
+func (r *agentRunner) run() error {
+	for {
+		r.mu.Lock()
+		if r.stopped {
+			r.mu.Unlock()
+			return nil
+		}
+		cfg := r.config()
+		a := agent.New(&cfg)
+		a.StatusServer = r.statusSrv
+		a.Approvals = r.approvals
+		a.RateLimiter = r.limiter
+		a.Stats = r.stats
+		a.EventLog = r.eventLog
+		a.OBSLauncher = r.obsLauncher
+		a.OnTokenRotated = func(c *agent.Config) error { return r.tokenRotated(c.Token) }
+		a.OnUpdateAvailable = r.onUpdateAvailable
+		r.current = a
+		r.restart = false
+		r.mu.Unlock()
+
+		r.statusSrv.UpdateConfig(cfg.OBSHost, cfg.OBSPort, cfg.RelayURL)
+		err := a.Start()
+
+		r.mu.Lock()
+		restart := r.restart && !r.stopped
+		r.current = nil
+		r.mu.Unlock()
+
+		if restart {
+			log.Println("[agent] Restarting connection...")
+			continue
+		}
+		return err
+	}
+}
+
+// stop stops the current agent for good (quit / signal).
+func (r *agentRunner) stop() {
+	r.mu.Lock()
+	r.stopped = true
+	a := r.current
+	r.mu.Unlock()
+	if a != nil {
+		a.Stop()
+	}
+}
+
+// requestRestart drops the current connection and starts a fresh agent.
+func (r *agentRunner) requestRestart() {
+	r.mu.Lock()
+	r.restart = true
+	a := r.current
+	r.mu.Unlock()
+	if a != nil {
+		a.Stop()
+	}
+}
+
+// requestReconfigure stops the current agent so main can run the OBS wizard.
+func (r *agentRunner) requestReconfigure() {
+	r.mu.Lock()
+	r.reconfigure = true
+	a := r.current
+	r.mu.Unlock()
+	if a != nil {
+		a.Stop()
+	}
+}
+
+// takeReconfigure reports (and clears) a pending reconfigure request.
+func (r *agentRunner) takeReconfigure() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reconfig := r.reconfigure
+	r.reconfigure = false
+	return reconfig
+}
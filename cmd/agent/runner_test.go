@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/4throck/obs-agent/internal/agent"
+	"github.com/4throck/obs-agent/internal/keyring"
+	"github.com/4throck/obs-agent/internal/status"
+)
+
+// A rotation from the bridge goroutine must not race the dashboard saving
+// notification preferences (run with -race).
+func TestRunnerConfigUpdates(t *testing.T) {
+	oldToken := strings.Repeat("a", 64)
+	newToken := strings.Repeat("b", 64)
+	r := newAgentRunner(nil, &agent.Config{Token: oldToken})
+
+	var saved []string
+	var savedMu sync.Mutex
+	r.onTokenRotated = func(cfg *agent.Config) error {
+		savedMu.Lock()
+		saved = append(saved, cfg.Token)
+		savedMu.Unlock()
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		r.tokenRotated(newToken)
+	}()
+	go func() {
+		defer wg.Done()
+		r.updateConfig(func(cfg *agent.Config) error {
+			c := *cfg
+			c.Notifications = &status.NotificationPrefs{}
+			cfg.Notifications = c.Notifications
+			return nil
+		})
+	}()
+	wg.Wait()
+
+	cfg := r.config()
+	if cfg.Token != newToken || cfg.Notifications == nil {
+		t.Errorf("config after updates: token %q, notifications %v", cfg.Token, cfg.Notifications)
+	}
+	if len(saved) != 1 || saved[0] != newToken {
+		t.Errorf("saved tokens %q, want the rotated one", saved)
+	}
+
+	// A wizard's edits replace the config but keep the dashboard's prefs
+	edited := cfg
+	edited.Notifications = nil
+	edited.OBSPort = 4456
+	r.setConfig(edited)
+	if cfg := r.config(); cfg.OBSPort != 4456 || cfg.Notifications == nil {
+		t.Errorf("after setConfig: port %d, notifications %v", cfg.OBSPort, cfg.Notifications)
+	}
+}
+
+// With -config, a rotated token is saved to that file, not the default one.
+func TestRunnerSavesRotatedToken(t *testing.T) {
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "studio.enc")
+	defaultPath := filepath.Join(dir, "obs-agent.enc")
+	opts := agent.ConfigOptions{Keyring: keyring.NewMemory()}
+	newToken := strings.Repeat("b", 64)
+
+	r := newAgentRunner(nil, &agent.Config{Token: strings.Repeat("a", 64), OBSPort: 4455})
+	r.onTokenRotated = saveRotatedToken(configSavePath(configFile, defaultPath), opts)
+	r.tokenRotated(newToken)
+
+	cfg, err := agent.LoadConfig(configFile, opts)
+	if err != nil {
+		t.Fatalf("-config file: %v", err)
+	}
+	if cfg.Token != newToken {
+		t.Errorf("-config file has token %q, want the rotated one", cfg.Token)
+	}
+	if _, err := os.Stat(defaultPath); !os.IsNotExist(err) {
+		t.Errorf("default config written (%v)", err)
+	}
+}
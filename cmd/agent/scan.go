@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// subnetScanWorkers bounds the concurrent probes of -obs-scan-subnet.
+	subnetScanWorkers = 64
+	// subnetScanLimit bounds the whole scan.
+	subnetScanLimit = 30 * time.Second
+	// maxScanHosts refuses networks larger than a /20.
+	maxScanHosts = 4096
+)
+
+// obsCandidate is an OBS WebSocket server found by -obs-scan-subnet.
+type obsCandidate struct {
+	Address string `json:"address"`
+	Version string `json:"obs_ws_version"`
+}
+
+// runSubnetScan handles -obs-scan-subnet: it lists the OBS WebSocket
+// servers answering on the scan ports in cidr ("auto" = the /24 of each of
+// this machine's IPv4 networks) and exits 0 if any were found, else 1.
+func runSubnetScan(cidr string, jsonOut bool) {
+	networks, err := scanNetworks(cidr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -obs-scan-subnet: %v\n", err)
+		os.Exit(1)
+	}
+	var names []string
+	for _, n := range networks {
+		names = append(names, n.String())
+	}
+	if !jsonOut {
+		fmt.Printf("Scanning %s (ports %s) for OBS WebSocket servers...\n", strings.Join(names, ", "), portList(obsScanPorts))
+	}
+
+	found, probed, total := scanSubnet(networks, obsScanPorts, obsScanTimeout)
+
+	if jsonOut {
+		if found == nil {
+			found = []obsCandidate{}
+		}
+		json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
+			"networks":     names,
+			"found":        found,
+			"complete":     probed == total,
+			"hosts_probed": probed,
+			"hosts_total":  total,
+		})
+	} else {
+		if probed < total {
+			fmt.Printf("Scan incomplete (%d of %d hosts probed before the %s limit); scan a smaller range to cover the rest.\n", probed, total, subnetScanLimit)
+		}
+		if len(found) == 0 {
+			fmt.Println("No OBS WebSocket servers found.")
+		} else {
+			fmt.Printf("Found %d OBS WebSocket server(s):\n", len(found))
+			for _, c := range found {
+				fmt.Printf("  %-21s obs-websocket %s\n", c.Address, c.Version)
+			}
+			fmt.Println("\nThe agent connects to OBS on its own machine: run it on the PC that runs OBS.")
+		}
+	}
+	if len(found) == 0 {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// scanNetworks parses an IPv4 CIDR, or "auto" for this machine's networks
+// (each narrowed to the /24 around the interface address).
+func scanNetworks(cidr string) ([]*net.IPNet, error) {
+	if cidr != "auto" {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		if n.IP.To4() == nil {
+			return nil, fmt.Errorf("%s is not an IPv4 network", cidr)
+		}
+		if ones, bits := n.Mask.Size(); 1<<(bits-ones) > maxScanHosts {
+			return nil, fmt.Errorf("%s is larger than a /20", cidr)
+		}
+		return []*net.IPNet{n}, nil
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var networks []*net.IPNet
+	seen := make(map[string]bool)
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok || ipn.IP.IsLoopback() || ipn.IP.To4() == nil {
+			continue
+		}
+		n := &net.IPNet{IP: ipn.IP.To4().Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}
+		if ones, _ := ipn.Mask.Size(); ones > 24 {
+			n = &net.IPNet{IP: ipn.IP.To4().Mask(ipn.Mask), Mask: ipn.Mask}
+		}
+		if !seen[n.String()] {
+			seen[n.String()] = true
+			networks = append(networks, n)
+		}
+	}
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("no IPv4 network found on this machine — pass a CIDR such as 192.168.1.0/24")
+	}
+	return networks, nil
+}
+
+// scanSubnet probes every host and port with at most subnetScanWorkers
+// probes at a time, each bounded by timeout and all by subnetScanLimit.
+// Results are sorted by address. probed counts the hosts whose ports were
+// all probed before the limit, out of total.
+func scanSubnet(networks []*net.IPNet, ports []int, timeout time.Duration) (found []obsCandidate, probed, total int) {
+	ctx, cancel := context.WithTimeout(context.Background(), subnetScanLimit)
+	defer cancel()
+
+	for _, n := range networks {
+		total += len(hostIPs(n))
+	}
+
+	type target struct{ host, addr string }
+	targets := make(chan target)
+	go func() {
+		defer close(targets)
+		for _, n := range networks {
+			for _, ip := range hostIPs(n) {
+				for _, port := range ports {
+					t := target{host: ip.String(), addr: net.JoinHostPort(ip.String(), strconv.Itoa(port))}
+					select {
+					case targets <- t:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	done := make(map[string]int) // host → ports probed
+	var wg sync.WaitGroup
+	for i := 0; i < subnetScanWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range targets {
+				probeCtx, probeCancel := context.WithTimeout(ctx, timeout)
+				version := probeOBS(probeCtx, t.addr)
+				probeCancel()
+				mu.Lock()
+				if version != "" {
+					found = append(found, obsCandidate{Address: t.addr, Version: version})
+				}
+				// A probe cut short by the scan limit did not cover its host
+				if ctx.Err() == nil {
+					if done[t.host]++; done[t.host] == len(ports) {
+						probed++
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(found, func(i, j int) bool {
+		a, _, _ := net.SplitHostPort(found[i].Address)
+		b, _, _ := net.SplitHostPort(found[j].Address)
+		if c := bytes.Compare(net.ParseIP(a).To4(), net.ParseIP(b).To4()); c != 0 {
+			return c < 0
+		}
+		return found[i].Address < found[j].Address
+	})
+	return found, probed, total
+}
+
+// hostIPs lists the host addresses of n, leaving out the network and
+// broadcast addresses where the network has them.
+func hostIPs(n *net.IPNet) []net.IP {
+	base := n.IP.To4()
+	ones, bits := n.Mask.Size()
+	size := 1 << (bits - ones)
+	first, last := 0, size-1
+	if size > 2 {
+		first, last = 1, size-2
+	}
+	ips := make([]net.IP, 0, last-first+1)
+	start := uint32(base[0])<<24 | uint32(base[1])<<16 | uint32(base[2])<<8 | uint32(base[3])
+	for i := first; i <= last; i++ {
+		v := start + uint32(i)
+		ips = append(ips, net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v)))
+	}
+	return ips
+}
+
+// portList formats ports as a comma-separated list.
+func portList(ports []int) string {
+	s := make([]string, len(ports))
+	for i, p := range ports {
+		s[i] = strconv.Itoa(p)
+	}
+	return strings.Join(s, ",")
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/4throck/obs-agent/internal/instance"
+	"github.com/4throck/obs-agent/internal/status"
+)
+
+// -status and -restart both dial statusAddr: a running instance's
+// recorded port wins over the port its name maps to.
+func TestStatusAddr(t *testing.T) {
+	defer func(name string) { agentInstance = name }(agentInstance)
+	agentInstance = "statusaddrtest"
+	dir := binaryDirectory()
+	portFile := filepath.Join(dir, instance.FileName(agentInstance, ".port"))
+	if err := os.WriteFile(portFile, []byte("49152\n"), 0600); err != nil {
+		t.Skipf("binary directory not writable: %v", err)
+	}
+	defer os.Remove(portFile)
+
+	want := net.JoinHostPort("127.0.0.1", strconv.Itoa(instance.Port(agentInstance, status.DefaultPort)))
+	if got := statusAddr(); got != want {
+		t.Errorf("stopped instance: statusAddr() = %s, want %s (stale port file ignored)", got, want)
+	}
+
+	lock, err := instance.Acquire(dir, agentInstance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Release()
+	if got, want := statusAddr(), "127.0.0.1:49152"; got != want {
+		t.Errorf("running instance: statusAddr() = %s, want %s", got, want)
+	}
+}
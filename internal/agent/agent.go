@@ -2,14 +2,19 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"github.com/4throck/obs-agent/internal/eventlog"
 	"github.com/4throck/obs-agent/internal/obs"
+	"github.com/4throck/obs-agent/internal/obslaunch"
+	"github.com/4throck/obs-agent/internal/sdnotify"
 	"github.com/4throck/obs-agent/internal/status"
 	"github.com/4throck/obs-agent/internal/tunnel"
+	"github.com/gorilla/websocket"
 )
 
 // Agent manages the lifecycle of the OBS agent
@@ -19,6 +24,34 @@ type Agent struct {
 	cancel       context.CancelFunc
 	wg           sync.WaitGroup
 	StatusServer *status.Server
+	// Approvals, when set, gates destructive requests on local confirmation.
+	Approvals *tunnel.ApprovalQueue
+	// RateLimiter, when set, drops relay requests over their type's rate.
+	RateLimiter *tunnel.RateLimiter
+	// Stats, when set, counts bridged messages across reconnects.
+	Stats *tunnel.BridgeStats
+	// EventLog, when set, records every OBS event bridged to the relay.
+	EventLog *eventlog.Log
+	// OnTokenRotated, when set, persists the config after the relay rotates
+	// the agent token. cfg.Token already holds the new token.
+	OnTokenRotated func(cfg *Config) error
+	// OnUpdateAvailable, when set, is called (must not block) when the relay
+	// announces a new version. Nil leaves the notice informational.
+	OnUpdateAvailable func(tunnel.UpdateNotice)
+	// Reconnect tunes the backoff between connection attempts.
+	Reconnect ReconnectConfig
+	// OBSLauncher, when set, starts cfg.OBSLaunchPath if OBS is not
+	// running. Share one across agents so its respawn guard holds.
+	OBSLauncher *obslaunch.Launcher
+
+	tokenMu sync.Mutex // guards cfg.Token (rotated from the bridge goroutine)
+
+	// preflighted is set once the token passed cfg.ValidateToken's check
+	preflighted bool
+
+	// relayIndex is the cfg.relayURLs() entry that last connected; each
+	// attempt starts there rather than at the primary
+	relayIndex int
 }
 
 // New creates a new Agent instance
@@ -44,6 +77,8 @@ func (a *Agent) Start() error {
 		default:
 		}
 
+		a.heartbeat()
+		started := time.Now()
 		err := a.run()
 		if err == nil {
 			// Clean shutdown
@@ -56,6 +91,12 @@ func (a *Agent) Start() error {
 			return nil
 		}
 
+		// A connection that stayed up long enough was healthy; treat this
+		// failure as the first of a new outage rather than continuing the
+		// previous one's backoff
+		if time.Since(started) >= a.Reconnect.successThreshold() {
+			attempt = 0
+		}
 		attempt++
 		a.setStatus("reconnecting")
 		a.setOBS(false)
@@ -69,10 +110,38 @@ func (a *Agent) Start() error {
 			return err
 		}
 
-		delay := backoff(attempt)
+		// OBS is not running (e.g. the agent started at boot before it):
+		// connect the moment its port opens rather than after a backoff
+		if errors.Is(err, errOBSNotRunning) {
+			obsAddr := fmt.Sprintf("%s:%d", a.cfg.OBSHost, a.cfg.OBSPort)
+			if a.launchOBS(obsAddr) {
+				attempt = 0
+				continue
+			}
+			a.setStatus("waiting_for_obs")
+			a.setError("waiting for OBS to start")
+			log.Printf("[agent] OBS is not running — waiting for it on %s", obsAddr)
+			if !a.waitForOBS(a.ctx, obsAddr) {
+				return nil
+			}
+			log.Println("[agent] OBS port is open — connecting")
+			attempt = 0
+			continue
+		}
+
+		delay := reconnectDelay(attempt)
+		if errors.Is(err, tunnel.ErrCertPinMismatch) {
+			log.Printf("[agent] SECURITY: %v — connection aborted", tunnel.ErrCertPinMismatch)
+		}
 		log.Printf("[agent] Connection lost: %v — reconnecting in %v (attempt %d)", err, delay, attempt)
-		a.setError(err.Error())
+		var tooLarge *tunnel.ErrMessageTooLarge
+		if errors.As(err, &tooLarge) {
+			a.setError(tooLarge.Error())
+		} else {
+			a.setError(err.Error())
+		}
 
+		a.heartbeat()
 		select {
 		case <-time.After(delay):
 		case <-a.ctx.Done():
@@ -87,31 +156,69 @@ func (a *Agent) Start() error {
 // 3. Wait for session handshake (derive session key)
 // 4. Bridge with signed envelopes + OBS protocol validation
 func (a *Agent) run() error {
+	// Optional token preflight: a rejected token fails here instead of
+	// after a full OBS connect. Once per Start — reconnects skip it.
+	if a.cfg.ValidateToken && !a.preflighted {
+		a.setStatus("validating_token")
+		log.Println("[agent] Validating token with relay")
+		err := tunnel.ValidateToken(a.ctx, a.cfg.relayURLs()[a.relayIndex], a.token(), tunnel.ConnectOptions{
+			ReadLimit: a.cfg.RelayReadLimit,
+			Pin:       a.cfg.RelayPin,
+		})
+		if _, ok := err.(*tunnel.ErrTokenRejected); ok {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("token preflight failed: %w", err)
+		}
+		a.preflighted = true
+		log.Println("[agent] Token accepted by relay")
+	}
+
 	// Connect to local OBS
 	a.setStatus("connecting_obs")
 	log.Printf("[agent] Connecting to local OBS at %s:%d", a.cfg.OBSHost, a.cfg.OBSPort)
 	obsAddr := fmt.Sprintf("%s:%d", a.cfg.OBSHost, a.cfg.OBSPort)
-	obsConn, err := obs.Connect(a.ctx, obsAddr, a.cfg.OBSPass)
+	obsConn, obsInfo, err := obs.ConnectInfo(a.ctx, obsAddr, a.cfg.OBSPass)
+	if err == nil {
+		if err = obs.CheckMinVersion(obsInfo.WebSocketVersion, a.cfg.MinOBSVersion); err != nil {
+			obs.CloseConn(obsConn)
+		}
+	}
 	if err != nil {
+		var verErr *obs.ErrUnsupportedVersion
+		if errors.As(err, &verErr) || errors.Is(err, obs.ErrWebSocketV4) {
+			log.Printf("[agent] ERROR: %v", err)
+		} else if a.ctx.Err() == nil && !obsListening(obsAddr) {
+			return fmt.Errorf("%w: %w", errOBSNotRunning, err)
+		}
 		return fmt.Errorf("OBS connection failed: %w", err)
 	}
-	defer obsConn.Close()
-	log.Println("[agent] Connected to local OBS")
+	defer obs.CloseConn(obsConn)
+	log.Printf("[agent] Connected to local OBS (obs-websocket %s, RPC v%d)", obsInfo.WebSocketVersion, obsInfo.RPCVersion)
+	if a.StatusServer != nil {
+		a.StatusServer.SetOBSVersion(obsInfo.WebSocketVersion, obsInfo.RPCVersion)
+	}
+	obsVersion := a.queryOBSVersion(obsAddr, obsInfo)
 	a.setOBS(true)
 
 	// Connect to relay
 	a.setStatus("connecting_relay")
-	log.Printf("[agent] Connecting to relay at %s", a.cfg.RelayURL)
-	relayConn, err := tunnel.Connect(a.ctx, a.cfg.RelayURL, a.cfg.Token, a.cfg.Version)
+	token := a.token()
+	relayConn, info, err := a.connectRelay(token, obsVersion)
 	if err != nil {
 		return fmt.Errorf("relay connection failed: %w", err)
 	}
-	defer relayConn.Close()
-	log.Println("[agent] Connected to relay")
+	defer tunnel.CloseRelay(relayConn)
 	a.setRelay(true)
 
 	// Wait for session handshake — relay sends nonce, we derive session key
-	sessionKey, err := tunnel.WaitForSession(relayConn, a.cfg.Token)
+	sess, err := tunnel.WaitForSession(relayConn, token, info, tunnel.SessionOptions{
+		ClockSkew:         a.cfg.ClockSkew,
+		NonceCacheSize:    a.cfg.NonceCacheSize,
+		OnTokenRotate:     a.rotateToken,
+		OnUpdateAvailable: a.OnUpdateAvailable,
+	})
 	if err != nil {
 		// Pass through special errors — main loop handles them
 		if _, ok := err.(*tunnel.ErrTokenRejected); ok {
@@ -120,11 +227,118 @@ func (a *Agent) run() error {
 		return fmt.Errorf("session handshake failed: %w", err)
 	}
 
-	// Bridge messages with signed envelope protocol
+	// Bridge messages with signed envelope protocol; /api/stats covers this session
+	if a.StatusServer != nil {
+		a.StatusServer.ResetStats()
+	}
 	a.setStatus("connected")
-	a.setError("")
+	if err := sess.ClockError(); err != nil {
+		a.setError(err.Error())
+	} else {
+		a.setError("")
+	}
 	log.Println("[agent] Bridge active — relaying signed messages")
-	return tunnel.EnvelopeBridge(a.ctx, obsConn, relayConn, sessionKey, obsAddr, a.cfg.OBSPass)
+	return tunnel.EnvelopeBridge(a.ctx, obsConn, relayConn, sess, obsAddr, a.cfg.OBSPass, tunnel.BridgeOptions{
+		OBSReconnectWindow: a.cfg.OBSReconnectWindow,
+		DrainTimeout:       a.cfg.DrainTimeout,
+		Approvals:          a.Approvals,
+		RateLimiter:        a.RateLimiter,
+		Stats:              a.Stats,
+		Metrics:            a.bridgeMetrics(),
+		OnSnapshot:         a.setOBSSnapshot,
+		OnEvent:            a.EventLog.Append,
+		OnPong:             a.heartbeat,
+		OnOBSLost:          func() { a.setOBS(false) },
+		OnOBSRestored:      func(info obs.Info) { a.obsRestored(obsAddr, info) },
+
+		MonitorOwnConnection: a.cfg.MonitorOwnConnection,
+		MaxBatchRequests:     a.cfg.MaxBatchRequests,
+		MinOBSVersion:        a.cfg.MinOBSVersion,
+	})
+}
+
+// heartbeat tells the status server the connect loop is still moving
+// (see status.Server.Heartbeat).
+func (a *Agent) heartbeat() {
+	if a.StatusServer != nil {
+		a.StatusServer.Heartbeat()
+	}
+}
+
+// bridgeMetrics records the bridge's measurements in the status server.
+func (a *Agent) bridgeMetrics() tunnel.BridgeMetrics {
+	if a.StatusServer == nil {
+		return tunnel.BridgeMetrics{}
+	}
+	return tunnel.BridgeMetrics{
+		RelayRTT:    a.StatusServer.RecordRelayRTT,
+		OBSResponse: a.StatusServer.RecordOBSResponse,
+		Message:     a.StatusServer.RecordMessage,
+	}
+}
+
+// connectRelay tries each relay once, starting with the one that last
+// connected, and remembers which succeeded. When all fail it returns the
+// last error and Start backs off before the next round.
+func (a *Agent) connectRelay(token string, obsVersion *obs.Version) (*websocket.Conn, tunnel.ConnInfo, error) {
+	urls := a.cfg.relayURLs()
+	if a.relayIndex >= len(urls) {
+		a.relayIndex = 0
+	}
+	var lastErr error
+	for i := range urls {
+		idx := (a.relayIndex + i) % len(urls)
+		log.Printf("[agent] Connecting to relay at %s", urls[idx])
+		conn, info, err := tunnel.Connect(a.ctx, urls[idx], token, a.cfg.Version, tunnel.ConnectOptions{
+			ReadLimit: a.cfg.RelayReadLimit,
+			Pin:       a.cfg.RelayPin,
+			OBS:       obsVersion,
+		})
+		if err == nil {
+			if idx != a.relayIndex {
+				log.Printf("[agent] Failed over to relay %s", urls[idx])
+			}
+			a.relayIndex = idx
+			log.Println("[agent] Connected to relay")
+			if a.StatusServer != nil {
+				a.StatusServer.SetCurrentRelay(urls[idx])
+			}
+			return conn, info, nil
+		}
+		lastErr = err
+		if a.ctx.Err() != nil {
+			break
+		}
+		if len(urls) > 1 {
+			log.Printf("[agent] Relay %s unreachable: %v", urls[idx], err)
+		}
+	}
+	return nil, tunnel.ConnInfo{}, lastErr
+}
+
+// token returns the current agent token.
+func (a *Agent) token() string {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+	return a.cfg.Token
+}
+
+// rotateToken adopts a token pushed by the relay. The running session is
+// unaffected; the next connection uses the new token.
+func (a *Agent) rotateToken(newToken string) {
+	a.tokenMu.Lock()
+	a.cfg.Token = newToken
+	a.tokenMu.Unlock()
+
+	if a.OnTokenRotated != nil {
+		if err := a.OnTokenRotated(a.cfg); err != nil {
+			// Still use the new token in memory; the next rotation or setup saves it
+			log.Printf("[agent] Could not save rotated token: %v", err)
+		}
+	}
+	if a.StatusServer != nil {
+		a.StatusServer.SetTokenRotated(time.Now())
+	}
 }
 
 // Stop gracefully shuts down the agent
@@ -140,6 +354,12 @@ func (a *Agent) setStatus(s string) {
 	if a.StatusServer != nil {
 		a.StatusServer.SetStatus(s)
 	}
+	// Mirror to systemd (no-op unless run as a Type=notify unit); the first
+	// established bridge completes start-up
+	sdnotify.Status(s)
+	if s == "connected" {
+		sdnotify.Ready()
+	}
 }
 
 func (a *Agent) setError(e string) {
@@ -148,9 +368,58 @@ func (a *Agent) setError(e string) {
 	}
 }
 
+// setOBS reports the OBS connection state; disconnecting also clears the
+// OBS versions, which the next connect reports afresh.
 func (a *Agent) setOBS(connected bool) {
 	if a.StatusServer != nil {
 		a.StatusServer.SetOBSConnected(connected)
+		if !connected {
+			a.StatusServer.SetOBSVersion("", 0)
+			a.StatusServer.SetOBSInfo("", "")
+		}
+	}
+}
+
+// queryOBSVersion asks OBS for its version and platform over a one-shot
+// connection (the bridge's is not read until the bridge starts) and
+// records them. Without an answer only the handshake's versions are known.
+func (a *Agent) queryOBSVersion(addr string, info obs.Info) *obs.Version {
+	ctx, cancel := context.WithTimeout(a.ctx, 5*time.Second)
+	defer cancel()
+
+	client, err := obs.Dial(ctx, addr, a.cfg.OBSPass, 0)
+	if err != nil {
+		log.Printf("[agent] OBS GetVersion failed: %v", err)
+		return &obs.Version{OBSWebSocketVersion: info.WebSocketVersion, RPCVersion: info.RPCVersion}
+	}
+	defer client.Close()
+	v, err := client.GetVersion(ctx)
+	if err != nil {
+		log.Printf("[agent] OBS GetVersion failed: %v", err)
+		return &obs.Version{OBSWebSocketVersion: info.WebSocketVersion, RPCVersion: info.RPCVersion}
+	}
+	v.RPCVersion = info.RPCVersion // negotiated, not the latest OBS supports
+	log.Printf("[agent] OBS Studio %s on %s", v.OBSVersion, v.Platform)
+	if a.StatusServer != nil {
+		a.StatusServer.SetOBSInfo(v.OBSVersion, v.Platform)
+	}
+	return v
+}
+
+// obsRestored records an OBS connection the bridge re-established on its
+// own, without a new relay session.
+func (a *Agent) obsRestored(addr string, info obs.Info) {
+	log.Printf("[agent] OBS is back (obs-websocket %s, RPC v%d)", info.WebSocketVersion, info.RPCVersion)
+	a.setOBS(true)
+	if a.StatusServer != nil {
+		a.StatusServer.SetOBSVersion(info.WebSocketVersion, info.RPCVersion)
+	}
+	go a.queryOBSVersion(addr, info) // OBS may have been upgraded
+}
+
+func (a *Agent) setOBSSnapshot(data []byte) {
+	if a.StatusServer != nil {
+		a.StatusServer.SetOBSSnapshot(data)
 	}
 }
 
@@ -2,18 +2,107 @@ package agent
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/4throck/obs-agent/internal/crypto"
+	"github.com/4throck/obs-agent/internal/keyring"
+	"github.com/4throck/obs-agent/internal/status"
+	"github.com/4throck/obs-agent/internal/tunnel"
 )
 
 // configHeader identifies the encrypted config format on disk.
-// Files starting with this header are machine-locked encrypted blobs.
-const configHeader = "OBSAGENT2\n"
+// Files starting with this header are machine-locked encrypted blobs:
+// the header, one base64 line, a "kid=<hex>" line naming the integrity
+// key, then an "hmac=<hex>" line authenticating everything before it
+// (see fileMAC).
+const configHeader = "OBSAGENT3\n"
+
+// configHeaderV2 is the previous format, without the file HMAC. It is
+// still read; the next save rewrites the file as configHeader.
+const configHeaderV2 = "OBSAGENT2\n"
+
+// configFormat is recorded inside the encrypted payload of every
+// configHeader file. A configHeaderV2 file carrying it was relabelled to
+// skip the HMAC check and is refused.
+const configFormat = 3
+
+// macLinePrefix starts the line holding the file HMAC; keyIDLinePrefix
+// the line identifying the key it was made with.
+const (
+	macLinePrefix   = "hmac="
+	keyIDLinePrefix = "kid="
+)
+
+// ErrConfigTampered is returned by LoadConfig when the config file's HMAC
+// is missing or does not match: the file was edited or corrupted on disk.
+var ErrConfigTampered = errors.New("config file failed its integrity check (tampered or corrupted)")
+
+// ErrConfigKeyChanged is returned by LoadConfig when the config was
+// written under other key material: copied from another machine, or this
+// machine's ID or credential store key changed since.
+var ErrConfigKeyChanged = errors.New("config file was written with a different machine key (copied from another machine, or the machine ID changed)")
+
+// ErrConfigUnreadable is returned by SaveConfig when the existing file
+// cannot be decoded: writing would discard every other profile in it. See
+// SetAsideUnreadableConfig.
+var ErrConfigUnreadable = errors.New("existing config file cannot be decoded")
+
+// ErrConfigPermissions is returned by LoadConfig for a config file that
+// other users can read or that belongs to another user.
+var ErrConfigPermissions = errors.New("unsafe config file permissions")
+
+// ConfigOptions says how the config file functions treat the file. The
+// zero value reads and writes the default profile with the token kept in
+// the encrypted file.
+type ConfigOptions struct {
+	// Profile selects the profile LoadConfig, SaveConfig and ResetToken
+	// use ("" = DefaultProfile).
+	Profile string
+
+	// Instance gives the keyring tokens of a named agent instance
+	// (-instance) their own accounts, so instances with separate config
+	// files do not overwrite each other's tokens. "" is the default
+	// instance.
+	Instance string
+
+	// Keyring, when set, makes SaveConfig store the token there instead of
+	// in the config file, and LoadConfig move a file-stored token into it.
+	Keyring keyring.Keyring
+
+	// FixPermissions makes LoadConfig repair a loose config file before
+	// checking it, and on Windows SaveConfig give it a protected
+	// owner-only DACL after every write (on Unix every save already
+	// chmods it 600).
+	FixPermissions bool
+}
+
+// profile returns the selected profile.
+func (o ConfigOptions) profile() string {
+	if o.Profile == "" {
+		return DefaultProfile
+	}
+	return o.Profile
+}
+
+// keyring returns Keyring, or nil once the config is shared with the
+// Windows service (ShareWithService): that runs as another account, with
+// its own keyring.
+func (o ConfigOptions) keyring() keyring.Keyring {
+	if crypto.MachineScope() {
+		return nil
+	}
+	return o.Keyring
+}
 
 // Config holds agent configuration (runtime only, never serialized directly)
 type Config struct {
@@ -23,6 +112,72 @@ type Config struct {
 	OBSPort  int
 	OBSPass  string
 	Version  string
+
+	// AllowedExtraRequests extends the OBS request whitelist with custom
+	// request types (e.g. from OBS plugins). Local config only.
+	AllowedExtraRequests []string
+
+	// RelayReadLimit caps relay message size in bytes (0 = tunnel default).
+	RelayReadLimit int64
+
+	// RelayPin, when set, pins the relay's TLS public key. Runtime only.
+	RelayPin *tunnel.CertPin
+
+	// OBSReconnectWindow bounds the OBS-only reconnect during a scene
+	// collection switch (0 = tunnel default, negative disables it and the
+	// OBS-only reconnect after other OBS drops).
+	OBSReconnectWindow time.Duration
+
+	// DrainTimeout bounds the wait for in-flight OBS responses when the
+	// agent stops (0 = tunnel default, negative disables).
+	DrainTimeout time.Duration
+
+	// ValidateToken checks the token against the relay before the first
+	// OBS connection (see tunnel.ValidateToken). Runtime only.
+	ValidateToken bool
+
+	// RelayURLs lists relays to try in order when one is unreachable.
+	// Empty means just RelayURL. Runtime only.
+	RelayURLs []string
+
+	// Notifications holds the desktop notification preferences (nil =
+	// status.DefaultNotificationPrefs). Local config only.
+	Notifications *status.NotificationPrefs
+
+	// AgentName is the display name the agent was authorized under (set
+	// by device authorization; empty for pasted tokens). Local config only.
+	AgentName string
+
+	// OBSLaunchPath, when set, is started (with OBSLaunchArgs) if OBS is
+	// not running (see Agent.OBSLauncher). Local config only.
+	OBSLaunchPath string
+	OBSLaunchArgs []string
+
+	// MonitorOwnConnection keeps the monitor on OBS connections of its
+	// own rather than sharing the bridge's. Runtime only.
+	MonitorOwnConnection bool
+
+	// ClockSkew and NonceCacheSize tune the relay session's replay
+	// protection (see tunnel.SessionOptions; 0 = tunnel defaults).
+	// Runtime only.
+	ClockSkew      time.Duration
+	NonceCacheSize int
+
+	// MaxBatchRequests caps a RequestBatch from the relay (0 =
+	// tunnel.DefaultMaxBatchRequests). Runtime only.
+	MaxBatchRequests int
+
+	// MinOBSVersion is the oldest obs-websocket version accepted (""
+	// = obs.DefaultMinVersion). Runtime only.
+	MinOBSVersion string
+}
+
+// relayURLs returns the relays to try: RelayURLs, or RelayURL alone.
+func (c *Config) relayURLs() []string {
+	if len(c.RelayURLs) > 0 {
+		return c.RelayURLs
+	}
+	return []string{c.RelayURL}
 }
 
 // configData is the internal structure encrypted on disk.
@@ -32,6 +187,55 @@ type configData struct {
 	Token   string `json:"token"`
 	OBSPort int    `json:"obs_port"`
 	OBSPass string `json:"obs_pass,omitempty"`
+
+	AllowedExtraRequests []string `json:"allowed_extra_requests,omitempty"`
+
+	// TokenInKeyring means Token was stripped and lives in the OS keyring.
+	TokenInKeyring bool `json:"token_in_keyring,omitempty"`
+
+	Notifications *status.NotificationPrefs `json:"notifications,omitempty"`
+
+	AgentName string `json:"agent_name,omitempty"`
+
+	OBSLaunchPath string   `json:"obs_launch_path,omitempty"`
+	OBSLaunchArgs []string `json:"obs_launch_args,omitempty"`
+
+	// Format is configFormat in configHeader files (top level only).
+	Format int `json:"format,omitempty"`
+
+	// Profiles holds the named profiles other than DefaultProfile, whose
+	// settings are the top-level fields above (so pre-profile files load
+	// unchanged). Entries never have Profiles of their own.
+	Profiles map[string]*configData `json:"profiles,omitempty"`
+}
+
+// newConfigData returns the stored form of cfg's settings.
+func newConfigData(cfg *Config) *configData {
+	return &configData{
+		Token:                cfg.Token,
+		OBSPort:              cfg.OBSPort,
+		OBSPass:              cfg.OBSPass,
+		AllowedExtraRequests: cfg.AllowedExtraRequests,
+		Notifications:        cfg.Notifications,
+		AgentName:            cfg.AgentName,
+		OBSLaunchPath:        cfg.OBSLaunchPath,
+		OBSLaunchArgs:        cfg.OBSLaunchArgs,
+	}
+}
+
+// config returns the settings stored in cd (Token as stored, so empty when
+// it lives in the keyring).
+func (cd *configData) config() *Config {
+	return &Config{
+		Token:                cd.Token,
+		OBSPort:              cd.OBSPort,
+		OBSPass:              cd.OBSPass,
+		AllowedExtraRequests: cd.AllowedExtraRequests,
+		Notifications:        cd.Notifications,
+		AgentName:            cd.AgentName,
+		OBSLaunchPath:        cd.OBSLaunchPath,
+		OBSLaunchArgs:        cd.OBSLaunchArgs,
+	}
 }
 
 // legacyConfigFile is the old plaintext JSON format (migration only)
@@ -41,32 +245,148 @@ type legacyConfigFile struct {
 	OBSHost    string `json:"obs_host"`
 	OBSPort    int    `json:"obs_port"`
 	OBSPassEnc string `json:"obs_pass_enc,omitempty"`
+
+	AllowedExtraRequests []string `json:"allowed_extra_requests,omitempty"`
+}
+
+// LoadConfig reads and decrypts the profile selected in opts from a
+// config file. Handles both the new encrypted format and legacy plaintext JSON.
+func LoadConfig(path string, opts ConfigOptions) (*Config, error) {
+	return LoadProfile(path, opts.profile(), opts)
 }
 
-// LoadConfig reads and decrypts a config file.
-// Handles both the new encrypted format and legacy plaintext JSON.
-func LoadConfig(path string) (*Config, error) {
+// LoadProfile is LoadConfig for a named profile. A profile missing from
+// the file is reported as os.ErrNotExist, like a missing file.
+func LoadProfile(path, profile string, opts ConfigOptions) (*Config, error) {
+	if err := ValidateProfileName(profile); err != nil {
+		return nil, err
+	}
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
 	// New encrypted format
-	if bytes.HasPrefix(data, []byte(configHeader)) {
-		return loadEncrypted(data)
+	if isEncryptedConfig(data) {
+		if opts.FixPermissions {
+			if err := fixConfigPermissions(path); err != nil {
+				return nil, fmt.Errorf("fix config permissions: %w", err)
+			}
+		}
+		if err := checkConfigPermissions(path); err != nil {
+			return nil, err
+		}
+		file, err := decodeConfigFile(data)
+		if err != nil {
+			return nil, err
+		}
+		cd := file.profile(profile)
+		if cd == nil {
+			return nil, fmt.Errorf("profile %q not in %s: %w", profile, path, os.ErrNotExist)
+		}
+		cfg := cd.config()
+		if cd.TokenInKeyring {
+			if err := loadKeyringToken(cfg, profile, opts); err != nil {
+				return nil, err
+			}
+		} else if kr := opts.keyring(); kr != nil && cfg.Token != "" {
+			// Move the token out of the file; on failure it simply stays there
+			if err := SaveProfile(path, profile, cfg, opts); err != nil {
+				log.Printf("[agent] Could not move token to the OS keyring: %v", err)
+			} else {
+				log.Println("[agent] Moved agent token from the config file to the OS keyring")
+			}
+		}
+		return cfg, nil
 	}
 
-	// Legacy plaintext JSON (auto-migrates on next save)
+	// Legacy plaintext JSON (auto-migrates on next save; default profile only)
 	trimmed := bytes.TrimSpace(data)
 	if len(trimmed) > 0 && trimmed[0] == '{' {
+		if profile != DefaultProfile {
+			return nil, fmt.Errorf("profile %q not in %s: %w", profile, path, os.ErrNotExist)
+		}
+		// Older versions wrote these with looser modes; don't block the migration
+		if err := checkConfigPermissions(path); err != nil {
+			log.Printf("[agent] Warning: %v", err)
+		}
 		return loadLegacy(data)
 	}
 
 	return nil, fmt.Errorf("unrecognized config format")
 }
 
-func loadEncrypted(data []byte) (*Config, error) {
-	payload := data[len(configHeader):]
+// loadKeyringToken fills cfg.Token from the keyring. A config written with
+// the keyring still needs it after -no-keyring, so the system keyring is
+// used when none was set.
+func loadKeyringToken(cfg *Config, profile string, opts ConfigOptions) error {
+	kr := opts.keyring()
+	if kr == nil {
+		kr = keyring.System()
+	}
+	if kr == nil {
+		return fmt.Errorf("config token is stored in the OS keyring, which is unavailable")
+	}
+	token, err := kr.Get(opts.keyringAccount(profile))
+	if errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("config token is missing from the OS keyring — run setup again")
+	}
+	if err != nil {
+		return fmt.Errorf("read token from OS keyring: %w", err)
+	}
+	cfg.Token = token
+	return nil
+}
+
+// isEncryptedConfig reports whether data is an encrypted config file in
+// the current or previous format.
+func isEncryptedConfig(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(configHeader)) || bytes.HasPrefix(data, []byte(configHeaderV2))
+}
+
+// isLegacyConfig reports whether data is a plaintext JSON config from
+// before encryption (or an empty file).
+func isLegacyConfig(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) == 0 || (data[0] == '{' && json.Valid(data))
+}
+
+// SetAsideUnreadableConfig renames the config at path to
+// path+".unreadable" when it is an encrypted config that no longer decodes
+// (e.g. after the machine ID changed), so setup can write a new one. The
+// old profiles are kept in case the key material comes back. Returns the
+// new name, or "" when nothing was moved.
+func SetAsideUnreadableConfig(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !isEncryptedConfig(data) {
+		return "", nil
+	}
+	if _, err := decodeConfigFile(data); err == nil {
+		return "", nil
+	}
+	aside := path + ".unreadable"
+	if err := os.Rename(path, aside); err != nil {
+		return "", err
+	}
+	return aside, nil
+}
+
+// decodeConfigFile decrypts an encrypted config file (all profiles). The
+// file HMAC is checked first, so a modified file is reported as
+// ErrConfigTampered rather than as a decryption failure, and a file from
+// other key material as ErrConfigKeyChanged.
+func decodeConfigFile(data []byte) (*configData, error) {
+	var payload []byte
+	v2 := bytes.HasPrefix(data, []byte(configHeaderV2))
+	if v2 {
+		payload = data[len(configHeaderV2):]
+	} else {
+		signed, err := verifyFileMAC(data)
+		if err != nil {
+			return nil, err
+		}
+		payload, _, _ = bytes.Cut(signed[len(configHeader):], []byte("\n"))
+	}
 	encoded := strings.TrimSpace(string(payload))
 
 	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
@@ -81,19 +401,81 @@ func loadEncrypted(data []byte) (*Config, error) {
 
 	plaintext, err := crypto.DecryptBytes(key, ciphertext)
 	if err != nil {
-		return nil, fmt.Errorf("config decryption failed (wrong machine?): %w", err)
+		// Configs written before the OS credential store was used are
+		// encrypted with the machine-ID key; the next save re-encrypts them
+		legacyKey, lerr := crypto.DeriveStorageKeyLegacy()
+		if lerr != nil || bytes.Equal(legacyKey, key) {
+			return nil, fmt.Errorf("%w: decryption failed: %v", ErrConfigKeyChanged, err)
+		}
+		if plaintext, err = crypto.DecryptBytes(legacyKey, ciphertext); err != nil {
+			return nil, fmt.Errorf("%w: decryption failed: %v", ErrConfigKeyChanged, err)
+		}
 	}
 
 	var cd configData
 	if err := json.Unmarshal(plaintext, &cd); err != nil {
 		return nil, fmt.Errorf("config parse failed: %w", err)
 	}
+	// SECURITY: Format sits inside the authenticated ciphertext, so it
+	// can't be stripped to make a relabelled file pass as a real v2 one
+	if v2 && cd.Format >= configFormat {
+		return nil, fmt.Errorf("%w: format 3 config relabelled as format 2", ErrConfigTampered)
+	}
 
-	return &Config{
-		Token:   cd.Token,
-		OBSPort: cd.OBSPort,
-		OBSPass: cd.OBSPass,
-	}, nil
+	return &cd, nil
+}
+
+// verifyFileMAC checks the trailing hmac= line of a configHeader file and
+// returns the bytes it covers (header, base64 line and kid= line). On a
+// mismatch the kid= line tells a key change apart from an edited file.
+func verifyFileMAC(data []byte) ([]byte, error) {
+	i := bytes.LastIndex(data, []byte("\n"+macLinePrefix))
+	if i < 0 {
+		return nil, fmt.Errorf("%w: no HMAC line", ErrConfigTampered)
+	}
+	signed := data[:i+1]
+	want, err := hex.DecodeString(strings.TrimSpace(string(data[i+1+len(macLinePrefix):])))
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed HMAC line", ErrConfigTampered)
+	}
+
+	key, err := crypto.DeriveFileIntegrityKey()
+	if err != nil {
+		return nil, fmt.Errorf("cannot derive integrity key: %w", err)
+	}
+	if !hmac.Equal(fileMAC(key, signed), want) {
+		if kid, ok := fileKeyID(signed); ok && kid != keyID(key) {
+			return nil, ErrConfigKeyChanged
+		}
+		return nil, fmt.Errorf("%w: HMAC mismatch", ErrConfigTampered)
+	}
+	return signed, nil
+}
+
+// fileMAC is HMAC-SHA256 over the literal file bytes before the hmac= line.
+func fileMAC(key, signed []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write(signed)
+	return m.Sum(nil)
+}
+
+// keyID names an integrity key without revealing it: the first 8 bytes
+// of an HMAC under it, in hex.
+func keyID(key []byte) string {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte("obs-agent-config-key-id"))
+	return hex.EncodeToString(m.Sum(nil)[:8])
+}
+
+// fileKeyID returns the kid= line of the signed part of a file, if any
+// (files written before it was added have none).
+func fileKeyID(signed []byte) (string, bool) {
+	for _, line := range strings.Split(string(signed), "\n") {
+		if kid, ok := strings.CutPrefix(line, keyIDLinePrefix); ok {
+			return kid, true
+		}
+	}
+	return "", false
 }
 
 func loadLegacy(data []byte) (*Config, error) {
@@ -103,8 +485,9 @@ func loadLegacy(data []byte) (*Config, error) {
 	}
 
 	cfg := &Config{
-		Token:   lf.Token,
-		OBSPort: lf.OBSPort,
+		Token:                lf.Token,
+		OBSPort:              lf.OBSPort,
+		AllowedExtraRequests: lf.AllowedExtraRequests,
 	}
 
 	// Decrypt OBS password using old token-based key
@@ -120,36 +503,266 @@ func loadLegacy(data []byte) (*Config, error) {
 	return cfg, nil
 }
 
-// SaveConfig encrypts and saves config as an opaque machine-locked blob.
-// The relay URL is never stored — it is hardcoded in the binary.
-func SaveConfig(path string, cfg *Config) error {
-	cd := configData{
-		Token:   cfg.Token,
-		OBSPort: cfg.OBSPort,
-		OBSPass: cfg.OBSPass,
+// SaveConfig encrypts and saves config as an opaque machine-locked blob,
+// into the profile selected in opts; other profiles are kept.
+// The relay URL is never stored — it is hardcoded in the binary. With
+// opts.Keyring set the token goes there and is left out of the file.
+func SaveConfig(path string, cfg *Config, opts ConfigOptions) error {
+	return SaveProfile(path, opts.profile(), cfg, opts)
+}
+
+// SaveProfile is SaveConfig for a named profile.
+func SaveProfile(path, profile string, cfg *Config, opts ConfigOptions) error {
+	if err := ValidateProfileName(profile); err != nil {
+		return err
 	}
 
-	plaintext, err := json.Marshal(cd)
-	if err != nil {
+	cd := newConfigData(cfg)
+
+	if kr := opts.keyring(); kr != nil && cfg.Token != "" {
+		if err := kr.Set(opts.keyringAccount(profile), cfg.Token); err != nil {
+			log.Printf("[agent] OS keyring unavailable, keeping token in the config file: %v", err)
+		} else {
+			cd.Token = ""
+			cd.TokenInKeyring = true
+		}
+	}
+
+	// Keep the other profiles. Only a missing or legacy plaintext file is
+	// replaced outright; one that no longer decodes is refused rather
+	// than silently losing its other profiles.
+	file := &configData{}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
 		return err
+	case isEncryptedConfig(data):
+		existing, err := decodeConfigFile(data)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrConfigUnreadable, err)
+		}
+		file = existing
+	case !isLegacyConfig(data):
+		return fmt.Errorf("%w: not a config file", ErrConfigUnreadable)
 	}
+	file.setProfile(profile, cd)
 
+	return writeConfigFile(path, file, opts)
+}
+
+// writeConfigFile encrypts cd (all profiles) and writes it to path.
+func writeConfigFile(path string, cd *configData, opts ConfigOptions) error {
 	key, err := crypto.DeriveStorageKey()
 	if err != nil {
 		return fmt.Errorf("cannot derive key: %w", err)
 	}
-
-	ciphertext, err := crypto.EncryptBytes(key, plaintext)
+	macKey, err := crypto.DeriveFileIntegrityKey()
+	if err != nil {
+		return fmt.Errorf("cannot derive integrity key: %w", err)
+	}
+	data, err := encodeConfigFile(cd, key, macKey)
 	if err != nil {
 		return err
 	}
 
-	encoded := base64.StdEncoding.EncodeToString(ciphertext)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return err
+	}
+	// os.WriteFile keeps the mode of an existing file
+	if err := restrictOnSave(path, opts.FixPermissions); err != nil {
+		return fmt.Errorf("fix config permissions: %w", err)
+	}
+	return nil
+}
+
+// encodeConfigFile builds the configHeader file for cd with the given
+// storage and integrity keys.
+func encodeConfigFile(cd *configData, key, macKey []byte) ([]byte, error) {
+	versioned := *cd
+	versioned.Format = configFormat
+	plaintext, err := json.Marshal(&versioned)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := crypto.EncryptBytes(key, plaintext)
+	if err != nil {
+		return nil, err
+	}
 
 	var buf bytes.Buffer
 	buf.WriteString(configHeader)
-	buf.WriteString(encoded)
+	buf.WriteString(base64.StdEncoding.EncodeToString(ciphertext))
 	buf.WriteByte('\n')
+	buf.WriteString(keyIDLinePrefix + keyID(macKey) + "\n")
+	buf.WriteString(macLinePrefix + hex.EncodeToString(fileMAC(macKey, buf.Bytes())) + "\n")
+	return buf.Bytes(), nil
+}
 
-	return os.WriteFile(path, buf.Bytes(), 0600)
+// ResetToken clears the agent token of the profile selected in opts in
+// the config at path, keeping the OBS settings, so the next start
+// re-authorizes. A token kept in the OS keyring is deleted from it as well.
+func ResetToken(path string, opts ConfigOptions) error {
+	profile := opts.profile()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !isEncryptedConfig(data) {
+		// Legacy plaintext: the save migrates it, minus the token
+		cfg, err := LoadProfile(path, profile, opts)
+		if err != nil {
+			return fmt.Errorf("cannot read current config: %w", err)
+		}
+		cfg.Token = ""
+		return SaveProfile(path, profile, cfg, opts)
+	}
+	file, err := decodeConfigFile(data)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt current config: %w", err)
+	}
+	cd := file.profile(profile)
+	if cd == nil {
+		return fmt.Errorf("profile %q not in %s: %w", profile, path, os.ErrNotExist)
+	}
+
+	if cd.TokenInKeyring {
+		kr := opts.keyring()
+		if kr == nil {
+			kr = keyring.System()
+		}
+		if kr != nil {
+			if err := kr.Delete(opts.keyringAccount(profile)); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+				log.Printf("[agent] Could not remove the token from the OS keyring: %v", err)
+			}
+		}
+	}
+	cd.Token = ""
+	cd.TokenInKeyring = false
+	return writeConfigFile(path, file, opts)
+}
+
+// ShareWithService makes the config at path readable by the Windows
+// service, which runs as LocalSystem rather than as the user who set it
+// up: the storage key is re-wrapped for the machine (see
+// crypto.ShareStorageKeyWithMachine) and tokens kept in this user's
+// Credential Manager are moved into the encrypted file. The keyring is not
+// used for the config after that. A missing config only shares the key.
+func ShareWithService(path string, opts ConfigOptions) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		keyPath, err := crypto.ShareStorageKeyWithMachine()
+		if err != nil {
+			return err
+		}
+		return fixConfigPermissions(keyPath)
+	}
+	if err != nil {
+		return err
+	}
+	if !isEncryptedConfig(data) {
+		// Legacy plaintext: save it encrypted first
+		cfg, err := LoadProfile(path, DefaultProfile, opts)
+		if err != nil {
+			return fmt.Errorf("cannot read current config: %w", err)
+		}
+		if err := SaveProfile(path, DefaultProfile, cfg, opts); err != nil {
+			return err
+		}
+		if data, err = os.ReadFile(path); err != nil {
+			return err
+		}
+	}
+	file, err := decodeConfigFile(data)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt current config: %w", err)
+	}
+
+	var moved []string
+	for _, name := range file.profileNames() {
+		cd := file.profile(name)
+		if !cd.TokenInKeyring {
+			continue
+		}
+		cfg := &Config{}
+		if err := loadKeyringToken(cfg, name, opts); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+		cd.Token = cfg.Token
+		cd.TokenInKeyring = false
+		moved = append(moved, name)
+	}
+
+	keyPath, err := crypto.ShareStorageKeyWithMachine()
+	if err != nil {
+		return fmt.Errorf("share storage key: %w", err)
+	}
+	if err := writeConfigFile(path, file, opts); err != nil {
+		return err
+	}
+	// Any account can unwrap a machine-scope key: only the file
+	// permissions (this user and SYSTEM) keep others out now
+	for _, p := range []string{path, keyPath} {
+		if err := fixConfigPermissions(p); err != nil {
+			return fmt.Errorf("restrict %s: %w", p, err)
+		}
+	}
+
+	if kr := keyring.System(); kr != nil {
+		for _, name := range moved {
+			if err := kr.Delete(opts.keyringAccount(name)); err != nil && !errors.Is(err, keyring.ErrNotFound) {
+				log.Printf("[agent] Could not remove the token from the OS keyring: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// RotateConfigKey re-encrypts the config at path (every profile) under
+// fresh storage key material (see crypto.RotateStorageKey). The config is
+// decrypted with the current key first; if anything fails afterwards the
+// old key material and file are restored, so the config is never left
+// unreadable.
+func RotateConfigKey(path string, opts ConfigOptions) error {
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !isEncryptedConfig(original) {
+		// Legacy plaintext: re-encrypting is an ordinary save
+		cfg, err := LoadProfile(path, DefaultProfile, opts)
+		if err != nil {
+			return fmt.Errorf("cannot read current config: %w", err)
+		}
+		return SaveProfile(path, DefaultProfile, cfg, opts)
+	}
+	cd, err := decodeConfigFile(original)
+	if err != nil {
+		return fmt.Errorf("cannot decrypt current config: %w", err)
+	}
+
+	rollback, err := crypto.RotateStorageKey()
+	if err != nil {
+		return err
+	}
+	restore := func() {
+		rollback()
+		os.WriteFile(path, original, 0600)
+	}
+
+	if err := writeConfigFile(path, cd, opts); err != nil {
+		restore()
+		return fmt.Errorf("re-encrypt failed (old key restored): %w", err)
+	}
+	// Prove the new file opens before discarding the old material
+	data, err := os.ReadFile(path)
+	if err == nil {
+		_, err = decodeConfigFile(data)
+	}
+	if err != nil {
+		restore()
+		return fmt.Errorf("re-encrypted config did not verify (old key restored): %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,202 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/4throck/obs-agent/internal/crypto"
+	"github.com/4throck/obs-agent/internal/keyring"
+)
+
+func TestSaveProfileKeepsUnreadableConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string // "" = no file
+		wantErr  error
+	}{
+		{"missing file", "", nil},
+		{"empty file", "\n", nil},
+		{"legacy plaintext", `{"token":"old","obs_port":4455}`, nil},
+		{"undecodable encrypted", configHeader + "bm90IGEgcmVhbCBjb25maWc=\nhmac=00\n", ErrConfigUnreadable},
+		{"garbage", "not a config", ErrConfigUnreadable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "obs-agent.enc")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := SaveProfile(path, DefaultProfile, &Config{OBSPort: 4455}, ConfigOptions{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SaveProfile error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if data, _ := os.ReadFile(path); string(data) != tt.existing {
+					t.Error("unreadable config was overwritten")
+				}
+			}
+		})
+	}
+}
+
+func TestSaveProfileKeepsOtherProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "obs-agent.enc")
+	if err := SaveProfile(path, "studio", &Config{OBSPort: 4456}, ConfigOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveProfile(path, DefaultProfile, &Config{OBSPort: 4455}, ConfigOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadProfile(path, "studio", ConfigOptions{})
+	if err != nil {
+		t.Fatalf("studio profile lost: %v", err)
+	}
+	if cfg.OBSPort != 4456 {
+		t.Errorf("studio OBSPort = %d, want 4456", cfg.OBSPort)
+	}
+}
+
+func TestSetAsideUnreadableConfig(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.enc")
+	if err := SaveProfile(good, DefaultProfile, &Config{OBSPort: 4455}, ConfigOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if aside, err := SetAsideUnreadableConfig(good); err != nil || aside != "" {
+		t.Fatalf("readable config moved: %q, %v", aside, err)
+	}
+
+	bad := filepath.Join(dir, "bad.enc")
+	if err := os.WriteFile(bad, []byte(configHeader+"Zm9v\nhmac=00\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	aside, err := SetAsideUnreadableConfig(bad)
+	if err != nil || aside != bad+".unreadable" {
+		t.Fatalf("SetAsideUnreadableConfig = %q, %v", aside, err)
+	}
+	if _, err := os.Stat(bad); !os.IsNotExist(err) {
+		t.Error("unreadable config still in place")
+	}
+	if err := SaveProfile(bad, DefaultProfile, &Config{OBSPort: 4455}, ConfigOptions{}); err != nil {
+		t.Errorf("save after setting aside: %v", err)
+	}
+}
+
+func TestDecodeConfigFile(t *testing.T) {
+	key, err := crypto.DeriveStorageKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	macKey, err := crypto.DeriveFileIntegrityKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd := &configData{Token: "tok", OBSPort: 4455}
+	v3, err := encodeConfigFile(cd, key, macKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherMachine, err := encodeConfigFile(cd, key, []byte("another machine's integrity key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A genuine format 2 file: no HMAC and no format field
+	plain, _ := json.Marshal(cd)
+	ciphertext, err := crypto.EncryptBytes(key, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2 := []byte(configHeaderV2 + base64.StdEncoding.EncodeToString(ciphertext) + "\n")
+
+	lines := strings.SplitAfter(string(v3), "\n")
+	relabelled := []byte(configHeaderV2 + lines[1])
+	noKeyID := []byte(lines[0] + lines[1] + lines[3])
+	flipped := bytes.Clone(v3)
+	flipped[len(configHeader)+4] ^= 1
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{"format 3", v3, nil},
+		{"format 2", v2, nil},
+		{"format 3 relabelled as 2", relabelled, ErrConfigTampered},
+		{"edited ciphertext", flipped, ErrConfigTampered},
+		{"no HMAC line", []byte(lines[0] + lines[1]), ErrConfigTampered},
+		{"HMAC mismatch without kid", noKeyID, ErrConfigTampered},
+		{"other machine key", otherMachine, ErrConfigKeyChanged},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeConfigFile(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("decodeConfigFile error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.Token != "tok" || got.OBSPort != 4455) {
+				t.Errorf("decoded %+v", got)
+			}
+		})
+	}
+}
+
+func TestConfigOptionsKeyring(t *testing.T) {
+	const token = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name        string
+		opts        ConfigOptions
+		wantAccount string
+	}{
+		{"default profile", ConfigOptions{}, keyringAccount},
+		{"named profile", ConfigOptions{Profile: "studio"}, keyringAccount + ":studio"},
+		{"named instance", ConfigOptions{Instance: "cam2"}, keyringAccount + "@cam2"},
+		{"instance and profile", ConfigOptions{Instance: "cam2", Profile: "studio"}, keyringAccount + "@cam2:studio"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kr := keyring.NewMemory()
+			opts := tt.opts
+			opts.Keyring = kr
+			path := filepath.Join(t.TempDir(), "obs-agent.enc")
+
+			if err := SaveConfig(path, &Config{Token: token, OBSPort: 4455}, opts); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := kr.Get(tt.wantAccount); err != nil || got != token {
+				t.Fatalf("keyring %s = %q, %v; want the token", tt.wantAccount, got, err)
+			}
+			if data, _ := os.ReadFile(path); bytes.Contains(data, []byte(token)) {
+				t.Error("token written to the config file")
+			}
+
+			cfg, err := LoadConfig(path, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Token != token {
+				t.Errorf("loaded token %q", cfg.Token)
+			}
+
+			if err := ResetToken(path, opts); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := kr.Get(tt.wantAccount); !errors.Is(err, keyring.ErrNotFound) {
+				t.Errorf("token still in keyring after ResetToken: %v", err)
+			}
+			if cfg, err := LoadConfig(path, opts); err != nil || cfg.Token != "" || cfg.OBSPort != 4455 {
+				t.Errorf("after ResetToken: %+v, %v", cfg, err)
+			}
+		})
+	}
+}
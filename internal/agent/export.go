@@ -0,0 +1,126 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/4throck/obs-agent/internal/crypto"
+)
+
+// exportHeader starts a portable config export (see ExportConfig). The
+// line after it is base64(salt + nonce + XChaCha20-Poly1305 ciphertext)
+// of the config JSON, under a key derived from the export passphrase.
+const exportHeader = "OBSAGENT-EXPORT1\n"
+
+// ErrExportPassphrase is returned by ImportConfig when the export does
+// not decrypt — a wrong passphrase or a damaged file.
+var ErrExportPassphrase = errors.New("wrong passphrase or damaged export file")
+
+// ExportConfig writes every profile of the config at path to out,
+// encrypted under passphrase instead of this machine's key, so it can be
+// imported on another machine. Tokens kept in the OS keyring are included.
+func ExportConfig(path, out, passphrase string, opts ConfigOptions) error {
+	if passphrase == "" {
+		return fmt.Errorf("an export passphrase is required")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file *configData
+	if isEncryptedConfig(data) {
+		if file, err = decodeConfigFile(data); err != nil {
+			return fmt.Errorf("cannot decrypt current config: %w", err)
+		}
+	} else {
+		cfg, err := LoadProfile(path, DefaultProfile, opts)
+		if err != nil {
+			return fmt.Errorf("cannot read current config: %w", err)
+		}
+		file = newConfigData(cfg)
+	}
+	for _, name := range file.profileNames() {
+		cd := file.profile(name)
+		if !cd.TokenInKeyring {
+			continue
+		}
+		cfg := &Config{}
+		if err := loadKeyringToken(cfg, name, opts); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+		cd.Token = cfg.Token
+		cd.TokenInKeyring = false
+	}
+
+	plaintext, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	salt, err := crypto.NewPassphraseSalt()
+	if err != nil {
+		return err
+	}
+	ciphertext, err := crypto.EncryptBytes(crypto.DerivePassphraseKey(passphrase, salt), plaintext)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(exportHeader)
+	buf.WriteString(base64.StdEncoding.EncodeToString(append(salt, ciphertext...)))
+	buf.WriteByte('\n')
+	return os.WriteFile(out, buf.Bytes(), 0600)
+}
+
+// ImportConfig decrypts an export made by ExportConfig with passphrase and
+// saves its profiles into the config at path under this machine's key
+// (tokens go to the OS keyring as usual). Profiles not in the export are
+// kept, and empty ones in the export are skipped. It returns the names of
+// the profiles imported.
+func ImportConfig(in, path, passphrase string, opts ConfigOptions) ([]string, error) {
+	data, err := os.ReadFile(in)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, []byte(exportHeader)) {
+		return nil, fmt.Errorf("%s is not an obs-agent config export", in)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data[len(exportHeader):])))
+	if err != nil || len(raw) < crypto.PassphraseSaltSize {
+		return nil, ErrExportPassphrase
+	}
+	salt, ciphertext := raw[:crypto.PassphraseSaltSize], raw[crypto.PassphraseSaltSize:]
+	plaintext, err := crypto.DecryptBytes(crypto.DerivePassphraseKey(passphrase, salt), ciphertext)
+	if err != nil {
+		return nil, ErrExportPassphrase
+	}
+	var file configData
+	if err := json.Unmarshal(plaintext, &file); err != nil {
+		return nil, fmt.Errorf("export parse failed: %w", err)
+	}
+
+	names := file.profileNames()
+	for _, name := range names {
+		if err := ValidateProfileName(name); err != nil {
+			return nil, err
+		}
+	}
+	var imported []string
+	for _, name := range names {
+		cd := file.profile(name)
+		if cd.Token == "" && cd.OBSPort == 0 && cd.OBSPass == "" {
+			continue // never set up (e.g. an unused default profile)
+		}
+		if err := SaveProfile(path, name, cd.config(), opts); err != nil {
+			return imported, fmt.Errorf("save profile %q: %w", name, err)
+		}
+		imported = append(imported, name)
+	}
+	return imported, nil
+}
@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/4throck/obs-agent/internal/status"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	profiles := map[string]*Config{
+		DefaultProfile: {
+			Token:                "tok_default",
+			OBSPort:              4455,
+			OBSPass:              "secret",
+			AllowedExtraRequests: []string{"ObsNDI_GetStatus"},
+			Notifications: &status.NotificationPrefs{
+				Enabled:    true,
+				Events:     []string{"stream_stopped"},
+				QuietHours: &status.QuietHours{Start: "22:00", End: "07:00"},
+			},
+			AgentName:     "Studio PC",
+			OBSLaunchPath: "/usr/bin/obs",
+			OBSLaunchArgs: []string{"--minimize-to-tray"},
+		},
+		"backup": {Token: "tok_backup", OBSPort: 4456},
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.enc")
+	for name, cfg := range profiles {
+		if err := SaveProfile(src, name, cfg, ConfigOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	export := filepath.Join(dir, "export.obsagent")
+	if err := ExportConfig(src, export, "correct horse", ConfigOptions{}); err != nil {
+		t.Fatalf("ExportConfig: %v", err)
+	}
+
+	dst := filepath.Join(dir, "dst.enc")
+	if _, err := ImportConfig(export, dst, "wrong", ConfigOptions{}); !errors.Is(err, ErrExportPassphrase) {
+		t.Fatalf("import with wrong passphrase: %v, want ErrExportPassphrase", err)
+	}
+	imported, err := ImportConfig(export, dst, "correct horse", ConfigOptions{})
+	if err != nil {
+		t.Fatalf("ImportConfig: %v", err)
+	}
+	if len(imported) != len(profiles) {
+		t.Errorf("imported %v, want %d profiles", imported, len(profiles))
+	}
+	for name, want := range profiles {
+		got, err := LoadProfile(dst, name, ConfigOptions{})
+		if err != nil {
+			t.Fatalf("load %q: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("profile %q after round trip = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestExportLegacyConfig(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "config.json")
+	legacy := `{"token":"tok_legacy","obs_port":4460,"allowed_extra_requests":["ObsNDI_GetStatus"]}`
+	if err := os.WriteFile(src, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	export := filepath.Join(dir, "export.obsagent")
+	if err := ExportConfig(src, export, "pass", ConfigOptions{}); err != nil {
+		t.Fatalf("ExportConfig: %v", err)
+	}
+	dst := filepath.Join(dir, "dst.enc")
+	if _, err := ImportConfig(export, dst, "pass", ConfigOptions{}); err != nil {
+		t.Fatalf("ImportConfig: %v", err)
+	}
+	got, err := LoadProfile(dst, DefaultProfile, ConfigOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Config{Token: "tok_legacy", OBSPort: 4460, AllowedExtraRequests: []string{"ObsNDI_GetStatus"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imported legacy config = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+
+package agent
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkConfigPermissions rejects a config file other users can read or
+// that belongs to another user: it holds the agent token and OBS password.
+// A root-owned file is accepted too (e.g. created with sudo for a service
+// that runs as root): only root could have written it.
+func checkConfigPermissions(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && !trustedOwner(int(st.Uid), os.Geteuid()) {
+		return fmt.Errorf("%w: %s is owned by uid %d, not the current user (uid %d) — "+
+			"run: chown %d %s", ErrConfigPermissions, path, st.Uid, os.Geteuid(), os.Geteuid(), path)
+	}
+	if mode := info.Mode().Perm(); mode&0077 != 0 {
+		return fmt.Errorf("%w: %s is accessible by other users (mode %04o) — "+
+			"run: chmod 600 %s (or start once with -fix-permissions)", ErrConfigPermissions, path, mode, path)
+	}
+	return nil
+}
+
+// trustedOwner reports whether a config owned by uid may be loaded by a
+// process running as euid.
+func trustedOwner(uid, euid int) bool {
+	return uid == euid || uid == 0
+}
+
+// restrictOnSave runs after every config write. On Unix the file is always
+// made 0600, whatever -fix-permissions says.
+func restrictOnSave(path string, fix bool) error {
+	return fixConfigPermissions(path)
+}
+
+// fixConfigPermissions restricts the config file to its owner. os.WriteFile
+// keeps the mode of an existing file, so 0600 alone is not enough.
+func fixConfigPermissions(path string) error {
+	return os.Chmod(path, 0600)
+}
@@ -0,0 +1,93 @@
+//go:build !windows
+
+package agent
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckConfigPermissionsMode(t *testing.T) {
+	tests := []struct {
+		mode    os.FileMode
+		wantErr bool
+	}{
+		{0600, false},
+		{0400, false},
+		{0640, true},
+		{0644, true},
+		{0606, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "obs-agent.enc")
+		if err := os.WriteFile(path, []byte("x"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(path, tt.mode); err != nil {
+			t.Fatal(err)
+		}
+		err := checkConfigPermissions(path)
+		if tt.wantErr != errors.Is(err, ErrConfigPermissions) {
+			t.Errorf("mode %04o: checkConfigPermissions = %v, wantErr %v", tt.mode, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTrustedOwner(t *testing.T) {
+	tests := []struct {
+		uid, euid int
+		want      bool
+	}{
+		{1000, 1000, true},
+		{0, 1000, true}, // created with sudo for a service user
+		{0, 0, true},
+		{1001, 1000, false},
+		{1000, 0, false}, // root does not trust a user's file
+	}
+	for _, tt := range tests {
+		if got := trustedOwner(tt.uid, tt.euid); got != tt.want {
+			t.Errorf("trustedOwner(%d, %d) = %v, want %v", tt.uid, tt.euid, got, tt.want)
+		}
+	}
+}
+
+func TestCheckConfigPermissionsOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("needs root to chown")
+	}
+	path := filepath.Join(t.TempDir(), "obs-agent.enc")
+	if err := os.WriteFile(path, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(path, 12345, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkConfigPermissions(path); !errors.Is(err, ErrConfigPermissions) {
+		t.Errorf("foreign owner: checkConfigPermissions = %v, want ErrConfigPermissions", err)
+	}
+	if err := os.Chown(path, 0, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkConfigPermissions(path); err != nil {
+		t.Errorf("root owner: %v", err)
+	}
+}
+
+func TestSaveConfigRestrictsMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "obs-agent.enc")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveProfile(path, DefaultProfile, &Config{OBSPort: 4455}, ConfigOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("mode after save = %04o, want 0600", mode)
+	}
+}
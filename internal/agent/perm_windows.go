@@ -0,0 +1,99 @@
+//go:build windows
+
+package agent
+
+import (
+	"fmt"
+	"log"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// checkConfigPermissions warns when the config file's DACL grants access
+// to anyone besides the current user, SYSTEM and Administrators. Windows
+// ACLs are often inherited from the profile folder, so this never rejects.
+func checkConfigPermissions(path string) error {
+	user, err := currentUserSID()
+	if err != nil {
+		return nil
+	}
+	sd, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		return nil
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil || dacl == nil {
+		// A NULL DACL grants everyone full access
+		log.Printf("[agent] Warning: config %s has no access control list — start once with -fix-permissions", path)
+		return nil
+	}
+
+	for i := uint32(0); i < uint32(dacl.AceCount); i++ {
+		var ace *windows.ACCESS_ALLOWED_ACE
+		if err := windows.GetAce(dacl, i, &ace); err != nil {
+			continue
+		}
+		if ace.Header.AceType != windows.ACCESS_ALLOWED_ACE_TYPE {
+			continue
+		}
+		sid := (*windows.SID)(unsafe.Pointer(&ace.SidStart))
+		if sid.Equals(user) || sid.IsWellKnown(windows.WinLocalSystemSid) || sid.IsWellKnown(windows.WinBuiltinAdministratorsSid) {
+			continue
+		}
+		log.Printf("[agent] Warning: config %s is readable by %s — start once with -fix-permissions", path, sid.String())
+		return nil
+	}
+	return nil
+}
+
+// restrictOnSave runs after every config write. Rewriting the DACL drops
+// inherited entries an administrator may rely on, so on Windows it is
+// left to -fix-permissions.
+func restrictOnSave(path string, fix bool) error {
+	if fix {
+		return fixConfigPermissions(path)
+	}
+	return nil
+}
+
+// fixConfigPermissions replaces the config file's DACL with a protected one
+// (no inheritance) granting full control to the current user and SYSTEM.
+func fixConfigPermissions(path string) error {
+	user, err := currentUserSID()
+	if err != nil {
+		return err
+	}
+	system, err := windows.CreateWellKnownSid(windows.WinLocalSystemSid)
+	if err != nil {
+		return err
+	}
+
+	grant := func(sid *windows.SID) windows.EXPLICIT_ACCESS {
+		return windows.EXPLICIT_ACCESS{
+			AccessPermissions: windows.GENERIC_ALL,
+			AccessMode:        windows.GRANT_ACCESS,
+			Inheritance:       windows.NO_INHERITANCE,
+			Trustee: windows.TRUSTEE{
+				TrusteeForm:  windows.TRUSTEE_IS_SID,
+				TrusteeType:  windows.TRUSTEE_IS_USER,
+				TrusteeValue: windows.TrusteeValueFromSID(sid),
+			},
+		}
+	}
+	acl, err := windows.ACLFromEntries([]windows.EXPLICIT_ACCESS{grant(user), grant(system)}, nil)
+	if err != nil {
+		return fmt.Errorf("build ACL: %w", err)
+	}
+	return windows.SetNamedSecurityInfo(path, windows.SE_FILE_OBJECT,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION,
+		nil, nil, acl, nil)
+}
+
+func currentUserSID() (*windows.SID, error) {
+	tu, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		return nil, err
+	}
+	return tu.User.Sid, nil
+}
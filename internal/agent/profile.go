@@ -0,0 +1,72 @@
+package agent
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// DefaultProfile is the profile used when none is selected. Its settings
+// are the config file's top-level fields.
+const DefaultProfile = "default"
+
+// profileName limits profile names to what is safe in keyring account
+// names and on the command line.
+var profileName = regexp.MustCompile(`^[A-Za-z0-9-]{1,32}$`)
+
+// keyringAccount names the default profile's token in the OS keyring.
+const keyringAccount = "agent-token"
+
+// ValidateProfileName checks that name is 1–32 letters, digits or hyphens.
+func ValidateProfileName(name string) error {
+	if !profileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use 1-32 letters, digits or hyphens", name)
+	}
+	return nil
+}
+
+// keyringAccount names profile's token in the keyring. The default
+// profile keeps the pre-profile account name so existing keyring entries
+// still resolve.
+func (o ConfigOptions) keyringAccount(profile string) string {
+	account := keyringAccount
+	if o.Instance != "" {
+		account += "@" + o.Instance
+	}
+	if profile == DefaultProfile {
+		return account
+	}
+	return account + ":" + profile
+}
+
+// profile returns the named profile's settings, or nil if absent.
+func (cd *configData) profile(name string) *configData {
+	if name == DefaultProfile {
+		return cd
+	}
+	return cd.Profiles[name]
+}
+
+// setProfile stores p as the named profile, keeping the others.
+func (cd *configData) setProfile(name string, p *configData) {
+	if name == DefaultProfile {
+		profiles := cd.Profiles
+		*cd = *p
+		cd.Profiles = profiles
+		return
+	}
+	if cd.Profiles == nil {
+		cd.Profiles = make(map[string]*configData)
+	}
+	cd.Profiles[name] = p
+}
+
+// profileNames lists DefaultProfile, then the other profiles by name.
+func (cd *configData) profileNames() []string {
+	names := make([]string, 0, len(cd.Profiles))
+	for name := range cd.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{DefaultProfile}, names...)
+}
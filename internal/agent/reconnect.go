@@ -1,28 +1,116 @@
 package agent
 
 import (
-	"math"
-	"math/rand"
+	"context"
+	"errors"
+	"log"
+	"net"
 	"time"
+
+	"github.com/4throck/obs-agent/internal/backoff"
+	"github.com/4throck/obs-agent/internal/obslaunch"
 )
 
 const (
 	baseDelay   = 1 * time.Second
 	maxDelay    = 60 * time.Second
 	maxAttempts = 0 // 0 = unlimited
+
+	// obsWatchInterval is how often a closed OBS port is probed while
+	// waiting for OBS to start.
+	obsWatchInterval = 2 * time.Second
+
+	// obsLaunchTimeout is how long a launched OBS has to open its port.
+	obsLaunchTimeout = 60 * time.Second
+
+	// DefaultSuccessThreshold is how long a connection must stay up before
+	// the next failure restarts the backoff from the first attempt.
+	DefaultSuccessThreshold = 60 * time.Second
 )
 
-// backoff calculates exponential backoff with jitter
-func backoff(attempt int) time.Duration {
-	// Exponential: 1s, 2s, 4s, 8s, 16s, 32s, 60s (capped)
-	delay := float64(baseDelay) * math.Pow(2, float64(attempt))
-	if delay > float64(maxDelay) {
-		delay = float64(maxDelay)
+// ReconnectConfig tunes the reconnect loop in Agent.Start.
+type ReconnectConfig struct {
+	// SuccessThreshold resets the attempt counter when a connection lasted
+	// at least this long (0 = DefaultSuccessThreshold).
+	SuccessThreshold time.Duration
+}
+
+func (rc ReconnectConfig) successThreshold() time.Duration {
+	if rc.SuccessThreshold <= 0 {
+		return DefaultSuccessThreshold
 	}
+	return rc.SuccessThreshold
+}
+
+// reconnectDelay is the wait before reconnect attempt n:
+// 1s, 2s, 4s, 8s, 16s, 32s, 60s (capped), with jitter.
+func reconnectDelay(attempt int) time.Duration {
+	return backoff.Delay(attempt, baseDelay, maxDelay)
+}
 
-	// Add jitter: +-25%
-	jitter := delay * 0.25 * (rand.Float64()*2 - 1)
-	delay += jitter
+// errOBSNotRunning marks a run that failed because nothing listens on the
+// OBS port: Start then waits for OBS to start instead of backing off.
+var errOBSNotRunning = errors.New("OBS is not running")
 
-	return time.Duration(delay)
+// obsListening reports whether anything accepts TCP connections at addr.
+func obsListening(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// waitForOBS probes addr every obsWatchInterval until the OBS port opens
+// (true) or ctx is done (false). Waiting counts as progress for the
+// heartbeat, however long OBS stays closed.
+func (a *Agent) waitForOBS(ctx context.Context, addr string) bool {
+	ticker := time.NewTicker(obsWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+		a.heartbeat()
+		if obsListening(addr) {
+			return true
+		}
+	}
+}
+
+// launchOBS starts cfg.OBSLaunchPath when configured and waits up to
+// obsLaunchTimeout for OBS to open its port at addr. It reports whether
+// the port opened; otherwise Start waits for OBS as if none were set.
+func (a *Agent) launchOBS(addr string) bool {
+	if a.OBSLauncher == nil || a.cfg.OBSLaunchPath == "" {
+		return false
+	}
+	err := a.OBSLauncher.Launch(a.cfg.OBSLaunchPath, a.cfg.OBSLaunchArgs)
+	if errors.Is(err, obslaunch.ErrTooManyLaunches) {
+		log.Printf("[agent] Not launching OBS: %v", err)
+		return false
+	}
+	if a.StatusServer != nil {
+		a.StatusServer.RecordOBSLaunch(err)
+	}
+	if err != nil {
+		log.Printf("[agent] Could not launch OBS: %v", err)
+		return false
+	}
+
+	a.setStatus("launching_obs")
+	log.Printf("[agent] Launched %s — waiting up to %v for OBS on %s", a.cfg.OBSLaunchPath, obsLaunchTimeout, addr)
+	ctx, cancel := context.WithTimeout(a.ctx, obsLaunchTimeout)
+	defer cancel()
+	if !a.waitForOBS(ctx, addr) {
+		if a.ctx.Err() == nil {
+			log.Printf("[agent] OBS did not open %s within %v — is its WebSocket server enabled?", addr, obsLaunchTimeout)
+		}
+		return false
+	}
+	log.Println("[agent] OBS port is open — connecting")
+	return true
 }
@@ -0,0 +1,24 @@
+// Package backoff computes retry delays shared by the agent's relay
+// reconnect loop and the monitor's OBS connection.
+package backoff
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Delay returns base·2^attempt, capped at max, with ±25% jitter so many
+// agents (or loops) do not retry in lockstep.
+func Delay(attempt int, base, max time.Duration) time.Duration {
+	delay := float64(base) * math.Pow(2, float64(attempt))
+	if delay > float64(max) {
+		delay = float64(max)
+	}
+
+	// Add jitter: +-25%
+	jitter := delay * 0.25 * (rand.Float64()*2 - 1)
+	delay += jitter
+
+	return time.Duration(delay)
+}
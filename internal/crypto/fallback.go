@@ -0,0 +1,87 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FallbackIDFileName is the generated machine identifier used when the OS
+// does not expose one (e.g. a container without /etc/machine-id).
+const FallbackIDFileName = "obs-agent.machine-id"
+
+var (
+	fallbackMu  sync.Mutex
+	fallbackDir string
+)
+
+// SetFallbackDir sets the directory holding locally stored key material:
+// the generated machine ID file and, on Windows, the DPAPI-wrapped storage
+// key. Should be the config directory. Empty disables both.
+func SetFallbackDir(dir string) {
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	fallbackDir = dir
+}
+
+// keyDir returns the directory set by SetFallbackDir.
+func keyDir() string {
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+	return fallbackDir
+}
+
+// fallbackMachineID returns the persisted random machine ID, creating it
+// on first use. The file is written 0600 and must stay next to the config:
+// deleting it makes the encrypted config unreadable.
+func fallbackMachineID() (string, error) {
+	fallbackMu.Lock()
+	defer fallbackMu.Unlock()
+
+	if fallbackDir == "" {
+		return "", fmt.Errorf("no fallback directory configured")
+	}
+	path := filepath.Join(fallbackDir, FallbackIDFileName)
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		id := strings.TrimSpace(string(data))
+		if id == "" {
+			return "", fmt.Errorf("fallback machine ID file %s is empty", path)
+		}
+		return id, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("read fallback machine ID: %w", err)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate fallback machine ID: %w", err)
+	}
+	id := hex.EncodeToString(b)
+
+	// O_EXCL so two agents racing on first run cannot overwrite each other
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", fmt.Errorf("create fallback machine ID: %w", err)
+	}
+	if _, err := f.WriteString(id + "\n"); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", fmt.Errorf("write fallback machine ID: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("write fallback machine ID: %w", err)
+	}
+
+	log.Printf("[agent] OS machine ID unavailable — generated %s", path)
+	return id, nil
+}
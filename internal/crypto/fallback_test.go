@@ -0,0 +1,54 @@
+package crypto
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFallbackMachineID(t *testing.T) {
+	defer SetFallbackDir(keyDir())
+	dir := t.TempDir()
+	path := filepath.Join(dir, FallbackIDFileName)
+
+	SetFallbackDir("")
+	if _, err := fallbackMachineID(); err == nil {
+		t.Error("generated an ID without a fallback directory")
+	}
+
+	// First run: no ID anywhere, so one is generated and persisted
+	SetFallbackDir(dir)
+	id, err := fallbackMachineID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(id) != 64 {
+		t.Errorf("generated ID %q, want 32 random bytes in hex", id)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("ID file mode %v, want 0600", info.Mode().Perm())
+	}
+
+	// Later runs read the same ID back
+	SetFallbackDir(dir)
+	again, err := fallbackMachineID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != id {
+		t.Errorf("second run got ID %q, want %q", again, id)
+	}
+
+	// An emptied file is an error, not a new identity
+	if err := os.WriteFile(path, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fallbackMachineID(); err == nil {
+		t.Error("empty ID file accepted")
+	}
+}
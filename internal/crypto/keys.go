@@ -2,7 +2,9 @@ package crypto
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"regexp"
@@ -32,10 +34,26 @@ func DeriveKey(token string) ([]byte, error) {
 	return key, nil
 }
 
-// DeriveStorageKey derives a 32-byte key from the machine ID alone.
-// Used for encrypting the entire config file at rest. The config is
-// machine-locked — cannot be copied to another machine and decrypted.
+// DeriveStorageKey returns the 32-byte key used for encrypting the entire
+// config file at rest. It prefers the OS credential store
+// (DeriveStorageKeySecure) and falls back to the machine-ID HKDF key
+// (DeriveStorageKeyLegacy) when that is unavailable. Either way the config
+// is machine-locked — cannot be copied to another machine and decrypted.
 func DeriveStorageKey() ([]byte, error) {
+	key, err := DeriveStorageKeySecure()
+	if err == nil {
+		return key, nil
+	}
+	if !errors.Is(err, errSecureUnsupported) {
+		log.Printf("[agent] OS credential store unavailable (%v) — using machine-ID key", err)
+	}
+	return DeriveStorageKeyLegacy()
+}
+
+// DeriveStorageKeyLegacy derives a 32-byte key from the machine ID alone.
+// Any process that can read the machine ID can derive it; configs written
+// before the credential store was used are encrypted with this key.
+func DeriveStorageKeyLegacy() ([]byte, error) {
 	machineID, err := getMachineID()
 	if err != nil {
 		return nil, fmt.Errorf("machine ID required for key derivation: %w", err)
@@ -50,9 +68,42 @@ func DeriveStorageKey() ([]byte, error) {
 	return key, nil
 }
 
-// getMachineID returns a stable machine identifier.
-// Returns error if unavailable — callers must handle explicitly.
+// DeriveFileIntegrityKey derives the 32-byte HMAC key that authenticates
+// the config file on disk. It is independent of the storage key, so the
+// file can be checked before any decryption is attempted.
+func DeriveFileIntegrityKey() ([]byte, error) {
+	machineID, err := getMachineID()
+	if err != nil {
+		return nil, fmt.Errorf("machine ID required for key derivation: %w", err)
+	}
+
+	hkdfReader := hkdf.New(sha256.New, []byte(machineID), nil, []byte("obs-agent-file-integrity-v1"))
+
+	key := make([]byte, 32)
+	if _, err := hkdfReader.Read(key); err != nil {
+		return nil, fmt.Errorf("HKDF key derivation failed: %w", err)
+	}
+	return key, nil
+}
+
+// getMachineID returns a stable machine identifier. The OS identifier is
+// preferred; when it is unavailable a generated ID persisted in the
+// fallback directory is used instead (see SetFallbackDir).
+// Returns error if neither is available — callers must handle explicitly.
 func getMachineID() (string, error) {
+	id, err := osMachineID()
+	if err == nil {
+		return id, nil
+	}
+	fid, ferr := fallbackMachineID()
+	if ferr != nil {
+		return "", fmt.Errorf("%w (fallback: %v)", err, ferr)
+	}
+	return fid, nil
+}
+
+// osMachineID returns the identifier exposed by the operating system.
+func osMachineID() (string, error) {
 	switch runtime.GOOS {
 	case "linux":
 		return getLinuxMachineID()
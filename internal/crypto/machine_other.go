@@ -0,0 +1,14 @@
+//go:build !windows
+
+package crypto
+
+import "errors"
+
+// ShareStorageKeyWithMachine is only needed for the Windows service, whose
+// account differs from the user's.
+func ShareStorageKeyWithMachine() (string, error) {
+	return "", errors.New("machine-scope storage keys are only used on Windows")
+}
+
+// MachineScope is always false on this platform.
+func MachineScope() bool { return false }
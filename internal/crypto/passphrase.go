@@ -0,0 +1,35 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// PassphraseSaltSize is the length of the random salt stored with data
+// encrypted under a passphrase.
+const PassphraseSaltSize = 16
+
+// Argon2id parameters for passphrase keys (RFC 9106 second recommendation).
+const (
+	passphraseTime    = 3
+	passphraseMemory  = 64 * 1024 // KiB
+	passphraseThreads = 4
+)
+
+// NewPassphraseSalt returns a random salt for DerivePassphraseKey.
+func NewPassphraseSalt() ([]byte, error) {
+	salt := make([]byte, PassphraseSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return salt, nil
+}
+
+// DerivePassphraseKey derives a 32-byte encryption key from a user
+// passphrase using Argon2id. Unlike DeriveStorageKey it involves nothing
+// machine-specific, so data encrypted with it opens on any machine.
+func DerivePassphraseKey(passphrase string, salt []byte) []byte {
+	return argon2.IDKey([]byte(passphrase), salt, passphraseTime, passphraseMemory, passphraseThreads, 32)
+}
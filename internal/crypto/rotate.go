@@ -0,0 +1,80 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrRotateUnsupported is returned by RotateStorageKey when the storage
+// key has no material of its own to replace.
+var ErrRotateUnsupported = errors.New("rotation not supported on this platform")
+
+// RotateStorageKey discards the current storage key material — the
+// credential store key and, when the OS has no machine ID, the generated
+// fallback ID — so the next DeriveStorageKey creates fresh material.
+// The caller must re-save the config with the new key; if that fails,
+// rollback restores the old material.
+//
+// With neither (Linux with /etc/machine-id) the key is fully determined by
+// the machine ID, nothing can change, and ErrRotateUnsupported is returned.
+func RotateStorageKey() (rollback func(), err error) {
+	var undo []func()
+	rollback = func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+
+	rotated := false
+	restore, err := rotateSecureKey()
+	switch {
+	case err == nil:
+		undo = append(undo, restore)
+		rotated = true
+	case !errors.Is(err, errSecureUnsupported):
+		return nil, fmt.Errorf("rotate credential store key: %w", err)
+	}
+
+	if _, err := osMachineID(); err != nil {
+		restore, err := rotateFallbackID()
+		if err != nil {
+			rollback()
+			return nil, fmt.Errorf("rotate fallback machine ID: %w", err)
+		}
+		undo = append(undo, restore)
+		rotated = true
+	}
+	if !rotated {
+		return nil, ErrRotateUnsupported
+	}
+	return rollback, nil
+}
+
+// rotateFallbackID removes the generated machine ID file.
+func rotateFallbackID() (func(), error) {
+	dir := keyDir()
+	if dir == "" {
+		return nil, fmt.Errorf("no fallback directory configured")
+	}
+	return removeWithRestore(filepath.Join(dir, FallbackIDFileName))
+}
+
+// removeWithRestore deletes path and returns a func that puts the old
+// contents back. A missing file is not an error.
+func removeWithRestore(path string) (func(), error) {
+	old, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return func() {}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+	return func() {
+		os.WriteFile(path, old, 0600)
+	}, nil
+}
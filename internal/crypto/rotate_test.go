@@ -0,0 +1,48 @@
+package crypto
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotateStorageKeyUnsupported(t *testing.T) {
+	if _, err := DeriveStorageKeySecure(); !errors.Is(err, errSecureUnsupported) {
+		t.Skip("platform has a credential store key")
+	}
+	if _, err := osMachineID(); err != nil {
+		t.Skip("no OS machine ID")
+	}
+
+	rollback, err := RotateStorageKey()
+	if !errors.Is(err, ErrRotateUnsupported) {
+		t.Fatalf("RotateStorageKey() error = %v, want ErrRotateUnsupported", err)
+	}
+	if rollback != nil {
+		t.Error("rollback returned although nothing rotated")
+	}
+}
+
+func TestRemoveWithRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FallbackIDFileName)
+	if err := os.WriteFile(path, []byte("old-id"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	restore, err := removeWithRestore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("file still present after remove: %v", err)
+	}
+	restore()
+	if data, err := os.ReadFile(path); err != nil || string(data) != "old-id" {
+		t.Fatalf("restore = %q, %v", data, err)
+	}
+
+	if _, err := removeWithRestore(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("missing file: %v", err)
+	}
+}
@@ -0,0 +1,7 @@
+package crypto
+
+import "errors"
+
+// errSecureUnsupported is returned by DeriveStorageKeySecure on platforms
+// without a supported credential store.
+var errSecureUnsupported = errors.New("no OS credential store on this platform")
@@ -0,0 +1,82 @@
+//go:build darwin
+
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Keychain item holding the storage key.
+const (
+	keychainService = "cloud.4throck.obs-agent"
+	keychainAccount = "storage-key"
+)
+
+// DeriveStorageKeySecure returns a random 32-byte storage key kept in the
+// login Keychain (service cloud.4throck.obs-agent), creating it on first
+// use. Uses the security(1) tool, like getDarwinMachineID uses ioreg, so
+// the binary stays cgo-free.
+func DeriveStorageKeySecure() ([]byte, error) {
+	out, err := exec.Command("security", "find-generic-password",
+		"-s", keychainService, "-a", keychainAccount, "-w").Output()
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(out)))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("keychain item %s is malformed", keychainService)
+		}
+		return key, nil
+	}
+	// Exit status 44 = item not found; anything else means no usable Keychain
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 44 {
+		return nil, fmt.Errorf("keychain lookup failed: %w", err)
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate storage key: %w", err)
+	}
+	// The value is briefly visible in this process's argv; security(1) has
+	// no stdin mode for -w. It never touches disk outside the Keychain.
+	if err := exec.Command("security", "add-generic-password",
+		"-s", keychainService, "-a", keychainAccount,
+		"-l", "4thRock OBS Agent storage key",
+		"-w", hex.EncodeToString(key)).Run(); err != nil {
+		return nil, fmt.Errorf("keychain store failed: %w", err)
+	}
+	return key, nil
+}
+
+// rotateSecureKey deletes the Keychain item so the next call creates a new
+// one. The rollback deletes any replacement and re-adds the old value.
+func rotateSecureKey() (func(), error) {
+	out, err := exec.Command("security", "find-generic-password",
+		"-s", keychainService, "-a", keychainAccount, "-w").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 44 {
+			return func() {}, nil // nothing stored yet
+		}
+		return nil, fmt.Errorf("keychain lookup failed: %w", err)
+	}
+	old := strings.TrimSpace(string(out))
+
+	deleteItem := func() error {
+		return exec.Command("security", "delete-generic-password",
+			"-s", keychainService, "-a", keychainAccount).Run()
+	}
+	if err := deleteItem(); err != nil {
+		return nil, fmt.Errorf("keychain delete failed: %w", err)
+	}
+	return func() {
+		deleteItem()
+		exec.Command("security", "add-generic-password",
+			"-s", keychainService, "-a", keychainAccount,
+			"-l", "4thRock OBS Agent storage key", "-w", old).Run()
+	}, nil
+}
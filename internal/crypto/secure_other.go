@@ -0,0 +1,13 @@
+//go:build !windows && !darwin
+
+package crypto
+
+// DeriveStorageKeySecure is not available on this platform; DeriveStorageKey
+// uses the machine-ID key instead.
+func DeriveStorageKeySecure() ([]byte, error) {
+	return nil, errSecureUnsupported
+}
+
+func rotateSecureKey() (func(), error) {
+	return nil, errSecureUnsupported
+}
@@ -0,0 +1,180 @@
+//go:build windows
+
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// StorageKeyFileName holds the DPAPI-wrapped storage key next to the config.
+const StorageKeyFileName = "obs-agent.key"
+
+// MachineKeyFileName holds the storage key wrapped for the machine instead
+// of the user (see ShareStorageKeyWithMachine). It takes precedence.
+const MachineKeyFileName = "obs-agent.machine.key"
+
+// DeriveStorageKeySecure returns a random 32-byte storage key protected by
+// DPAPI for the current user. The wrapped key lives in obs-agent.key in the
+// config directory and is created on first use; only this Windows account
+// can unwrap it, so knowing the machine ID is no longer enough.
+//
+// Once ShareStorageKeyWithMachine has run, the key in obs-agent.machine.key
+// is used instead, which every account on this machine can unwrap.
+func DeriveStorageKeySecure() ([]byte, error) {
+	dir := keyDir()
+	if dir == "" {
+		return nil, fmt.Errorf("no key directory configured")
+	}
+
+	key, err := readWrappedKey(filepath.Join(dir, MachineKeyFileName))
+	if !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+	path := filepath.Join(dir, StorageKeyFileName)
+	key, err = readWrappedKey(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate storage key: %w", err)
+	}
+	if err := writeWrappedKey(path, key, 0); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// readWrappedKey unwraps the storage key in path; a missing file is
+// reported as os.ErrNotExist.
+func readWrappedKey(path string) ([]byte, error) {
+	blob, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read storage key: %w", err)
+	}
+	key, err := dpapiUnprotect(blob)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap %s: %w", path, err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("%s holds a %d-byte key", path, len(key))
+	}
+	return key, nil
+}
+
+// writeWrappedKey wraps key with DPAPI (flags adds e.g.
+// CRYPTPROTECT_LOCAL_MACHINE) and writes it to path.
+func writeWrappedKey(path string, key []byte, flags uint32) error {
+	blob, err := dpapiProtect(key, flags)
+	if err != nil {
+		return fmt.Errorf("wrap storage key: %w", err)
+	}
+	if err := os.WriteFile(path, blob, 0600); err != nil {
+		return fmt.Errorf("write storage key: %w", err)
+	}
+	return nil
+}
+
+// ShareStorageKeyWithMachine re-wraps the storage key (creating one if
+// there is none) with machine-scope DPAPI (CRYPTPROTECT_LOCAL_MACHINE), so
+// the Windows service, which runs as LocalSystem, can decrypt the config
+// the user wrote. Any account on this machine can then unwrap it: the
+// config stays machine-locked but is no longer tied to one user, so file
+// permissions are what keeps other users out. It returns the key's path.
+func ShareStorageKeyWithMachine() (string, error) {
+	dir := keyDir()
+	if dir == "" {
+		return "", fmt.Errorf("no key directory configured")
+	}
+	path := filepath.Join(dir, MachineKeyFileName)
+	if MachineScope() {
+		return path, nil
+	}
+	key, err := DeriveStorageKeySecure()
+	if err != nil {
+		return "", err
+	}
+	if err := writeWrappedKey(path, key, windows.CRYPTPROTECT_LOCAL_MACHINE); err != nil {
+		return "", err
+	}
+	os.Remove(filepath.Join(dir, StorageKeyFileName))
+	return path, nil
+}
+
+// MachineScope reports whether the storage key is shared with every
+// account on this machine (see ShareStorageKeyWithMachine).
+func MachineScope() bool {
+	dir := keyDir()
+	if dir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, MachineKeyFileName))
+	return err == nil
+}
+
+func dpapiProtect(data []byte, flags uint32) ([]byte, error) {
+	in := windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN|flags, &out); err != nil {
+		return nil, err
+	}
+	return takeBlob(&out), nil
+}
+
+func dpapiUnprotect(data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty blob")
+	}
+	in := windows.DataBlob{Size: uint32(len(data)), Data: &data[0]}
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(&in, nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeBlob(&out), nil
+}
+
+// takeBlob copies a DPAPI output blob into Go memory and frees it.
+func takeBlob(b *windows.DataBlob) []byte {
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(b.Data)))
+	return append([]byte(nil), unsafe.Slice(b.Data, b.Size)...)
+}
+
+// rotateSecureKey removes the wrapped key so the next call creates a new
+// one. A machine-scope key is replaced right away, as it would otherwise
+// come back user-scoped.
+func rotateSecureKey() (func(), error) {
+	dir := keyDir()
+	if dir == "" {
+		return nil, fmt.Errorf("no key directory configured")
+	}
+	if !MachineScope() {
+		return removeWithRestore(filepath.Join(dir, StorageKeyFileName))
+	}
+
+	path := filepath.Join(dir, MachineKeyFileName)
+	restore, err := removeWithRestore(path)
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		restore()
+		return nil, fmt.Errorf("generate storage key: %w", err)
+	}
+	if err := writeWrappedKey(path, key, windows.CRYPTPROTECT_LOCAL_MACHINE); err != nil {
+		restore()
+		return nil, err
+	}
+	return restore, nil
+}
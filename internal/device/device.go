@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +12,33 @@ import (
 	"time"
 )
 
+// RequestCode retry policy for transient failures (network errors, 5xx, 429).
+const (
+	requestCodeBudget     = 2 * time.Minute
+	requestCodeBaseDelay  = 2 * time.Second
+	requestCodeMaxBackoff = 30 * time.Second
+)
+
+// HTTPError is a non-200 response from the device endpoints.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.StatusCode, e.Body)
+}
+
+// transient reports whether err is worth retrying: network failures and
+// server-side errors are, 4xx rejections are not.
+func transient(err error) bool {
+	var he *HTTPError
+	if errors.As(err, &he) {
+		return he.StatusCode >= 500 || he.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
 // CodeResponse holds the server's response to a device code request.
 type CodeResponse struct {
 	DeviceCode      string `json:"device_code"`
@@ -28,11 +56,48 @@ type CodeResponse struct {
 type Flow struct {
 	BaseURL string // e.g. "https://4throck.cloud"
 	Version string // agent version string
+
+	// OnRetry, if set, is called before each RequestCode retry so the UI can
+	// show progress ("retrying in 10s…") instead of an opaque hang.
+	OnRetry func(attempt int, wait time.Duration, err error)
 }
 
 // RequestCode asks the server for a new device code.
 // If the machine already has an active token, the server returns it directly.
+//
+// Transient failures (network errors, 5xx) are retried with exponential
+// backoff for up to ~2 minutes; 4xx responses fail immediately.
 func (f *Flow) RequestCode(ctx context.Context, agentName string) (*CodeResponse, error) {
+	deadline := time.Now().Add(requestCodeBudget)
+	delay := requestCodeBaseDelay
+
+	for attempt := 1; ; attempt++ {
+		cr, err := f.requestCodeOnce(ctx, agentName)
+		if err == nil {
+			return cr, nil
+		}
+		if !transient(err) || ctx.Err() != nil || time.Now().Add(delay).After(deadline) {
+			return nil, err
+		}
+
+		if f.OnRetry != nil {
+			f.OnRetry(attempt, delay, err)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		delay *= 2
+		if delay > requestCodeMaxBackoff {
+			delay = requestCodeMaxBackoff
+		}
+	}
+}
+
+// requestCodeOnce performs a single device code request.
+func (f *Flow) requestCodeOnce(ctx context.Context, agentName string) (*CodeResponse, error) {
 	body, _ := json.Marshal(map[string]string{
 		"agent_name":    agentName,
 		"agent_version": f.Version,
@@ -57,7 +122,7 @@ func (f *Flow) RequestCode(ctx context.Context, agentName string) (*CodeResponse
 
 	if resp.StatusCode != 200 {
 		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
-		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, string(b))
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(b)}
 	}
 
 	var cr CodeResponse
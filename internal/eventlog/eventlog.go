@@ -0,0 +1,222 @@
+// Package eventlog appends OBS events to a local newline-delimited JSON file
+// (-event-log) so stream history can be replayed, and reads its tail back
+// for /api/events.
+package eventlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxSize is the size at which the log is rotated to <path>.1,
+	// replacing the previous rotation.
+	MaxSize = 50 * 1024 * 1024
+
+	flushInterval = time.Second
+	readChunk     = 64 * 1024
+)
+
+// Log is an append-only event log. A nil *Log discards everything.
+type Log struct {
+	path string
+
+	mu     sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	size   int64
+	failed bool // last write failed (logged once per streak)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Open opens (or creates) the log at path for appending.
+func Open(path string) (*Log, error) {
+	l := &Log{path: path, stop: make(chan struct{}), done: make(chan struct{})}
+	if err := l.openFile(); err != nil {
+		return nil, err
+	}
+	go l.flushLoop()
+	return l, nil
+}
+
+func (l *Log) openFile() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open event log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("open event log: %w", err)
+	}
+	l.f, l.w, l.size = f, bufio.NewWriter(f), info.Size()
+	return nil
+}
+
+// Append writes one raw OBS event as a line, with "logged_at" (RFC 3339,
+// UTC) added to the object.
+func (l *Log) Append(event []byte) {
+	if l == nil {
+		return
+	}
+	var line bytes.Buffer
+	if err := json.Compact(&line, event); err != nil || line.Len() < 2 || line.Bytes()[0] != '{' {
+		return // not a JSON object; the bridge only passes validated events
+	}
+	obj := line.Bytes()
+	stamped := make([]byte, 0, len(obj)+48)
+	stamped = fmt.Appendf(stamped, `{"logged_at":%q`, time.Now().UTC().Format(time.RFC3339Nano))
+	if len(obj) > 2 {
+		stamped = append(stamped, ',')
+	}
+	stamped = append(stamped, obj[1:]...)
+	stamped = append(stamped, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	err := l.rotateIfFull(int64(len(stamped)))
+	if err == nil {
+		_, err = l.w.Write(stamped)
+	}
+	if err != nil {
+		if !l.failed {
+			log.Printf("[eventlog] Write to %s failed: %v", l.path, err)
+		}
+		l.failed = true
+		return
+	}
+	l.failed = false
+	l.size += int64(len(stamped))
+}
+
+// rotateIfFull moves the log to <path>.1 when n more bytes would take it
+// past MaxSize. Caller holds l.mu.
+func (l *Log) rotateIfFull(n int64) error {
+	if l.f == nil {
+		return l.openFile() // a previous rotation failed half way
+	}
+	if l.size == 0 || l.size+n <= MaxSize {
+		return nil
+	}
+	l.w.Flush()
+	l.f.Close()
+	l.f = nil
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return fmt.Errorf("rotate event log: %w", err)
+	}
+	log.Printf("[eventlog] Rotated %s (%d MB)", l.path, MaxSize>>20)
+	return l.openFile()
+}
+
+func (l *Log) flushLoop() {
+	defer close(l.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			if l.w != nil {
+				l.w.Flush()
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// Close flushes and closes the log.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	close(l.stop)
+	<-l.done
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	l.w.Flush()
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// Events returns the newest limit events logged after since (zero = any),
+// oldest first. Only the current file is read, not the rotated one.
+func (l *Log) Events(since time.Time, limit int) ([]json.RawMessage, error) {
+	if l == nil {
+		return nil, nil
+	}
+	l.mu.Lock()
+	if l.w != nil {
+		l.w.Flush()
+	}
+	l.mu.Unlock()
+
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk back from the end a chunk at a time; lines are chronological,
+	// so the first one older than since ends the scan
+	var events []json.RawMessage
+	var partial []byte
+	end := info.Size()
+	for end > 0 && len(events) < limit {
+		start := max(end-readChunk, 0)
+		chunk := make([]byte, end-start, end-start+int64(len(partial)))
+		if _, err := f.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return nil, err
+		}
+		chunk = append(chunk, partial...)
+		lines := bytes.Split(chunk, []byte("\n"))
+		if start > 0 {
+			partial, lines = lines[0], lines[1:] // may continue in the previous chunk
+		} else {
+			partial = nil
+		}
+		for i := len(lines) - 1; i >= 0 && len(events) < limit; i-- {
+			line := lines[i]
+			if len(line) == 0 {
+				continue
+			}
+			var stamp struct {
+				LoggedAt time.Time `json:"logged_at"`
+			}
+			if json.Unmarshal(line, &stamp) != nil {
+				continue // torn write
+			}
+			if !stamp.LoggedAt.After(since) {
+				end = 0
+				break
+			}
+			events = append(events, json.RawMessage(line))
+		}
+		if end > 0 {
+			end = start
+		}
+	}
+
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
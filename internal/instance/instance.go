@@ -2,13 +2,50 @@ package instance
 
 import (
 	"fmt"
+	"hash/fnv"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 )
 
-const lockFileName = "obs-agent.lock"
+// DefaultName is the instance an agent runs as without -instance. Its
+// files keep their historical names (obs-agent.lock, obs-agent.log, …).
+const DefaultName = "default"
+
+// instanceName limits instance names to what is safe in file names.
+var instanceName = regexp.MustCompile(`^[A-Za-z0-9-]{1,32}$`)
+
+// ValidateName checks that name is 1–32 letters, digits or hyphens.
+func ValidateName(name string) error {
+	if !instanceName.MatchString(name) {
+		return fmt.Errorf("invalid instance name %q: use 1-32 letters, digits or hyphens", name)
+	}
+	return nil
+}
+
+// FileName returns the name of one of an instance's files next to the
+// binary: obs-agent<ext> for the default instance, obs-agent-<name><ext>
+// for the others.
+func FileName(name, ext string) string {
+	if name == DefaultName {
+		return "obs-agent" + ext
+	}
+	return "obs-agent-" + name + ext
+}
+
+// Port returns the preferred local port of an instance's server: base for
+// the default instance, and for the others one of the 99 ports after it,
+// picked by hashing the name so it stays the same from run to run.
+func Port(name string, base int) int {
+	if name == DefaultName {
+		return base
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return base + 1 + int(h.Sum32()%99)
+}
 
 // Lock represents a held instance lock.
 type Lock struct {
@@ -16,10 +53,10 @@ type Lock struct {
 	path string
 }
 
-// Acquire tries to obtain an exclusive instance lock in the given directory.
-// Returns an error if another instance is already running.
-func Acquire(dir string) (*Lock, error) {
-	path := filepath.Join(dir, lockFileName)
+// Acquire tries to obtain the exclusive lock of the named instance in the
+// given directory. Returns an error if that instance is already running.
+func Acquire(dir, name string) (*Lock, error) {
+	path := filepath.Join(dir, FileName(name, ".lock"))
 
 	fd, err := tryLock(path)
 	if err != nil {
@@ -41,6 +78,27 @@ func Acquire(dir string) (*Lock, error) {
 	return &Lock{fd: fd, path: path}, nil
 }
 
+// Probe reports whether another process holds the named instance's lock
+// in dir, with its PID when known, without keeping the lock. An error
+// means the lock file could not be opened at all (e.g. the directory is
+// read-only).
+func Probe(dir, name string) (running bool, pid string, err error) {
+	path := filepath.Join(dir, FileName(name, ".lock"))
+
+	fd, err := tryLock(path)
+	if err == nil {
+		unlock(fd)
+		return false, "", nil
+	}
+	if !isLocked(err) {
+		return false, "", err
+	}
+	if data, readErr := os.ReadFile(path); readErr == nil {
+		pid = strings.TrimSpace(string(data))
+	}
+	return true, pid, nil
+}
+
 // Release releases the instance lock.
 func (l *Lock) Release() {
 	if l == nil {
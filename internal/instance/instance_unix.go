@@ -3,6 +3,7 @@
 package instance
 
 import (
+	"errors"
 	"os"
 	"syscall"
 )
@@ -37,3 +38,8 @@ func unlock(fd lockHandle) {
 	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
 	f.Close()
 }
+
+// isLocked reports whether a tryLock error means another process holds the lock.
+func isLocked(err error) bool {
+	return errors.Is(err, syscall.EWOULDBLOCK)
+}
@@ -3,6 +3,7 @@
 package instance
 
 import (
+	"errors"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -61,5 +62,11 @@ func unlock(fd lockHandle) {
 	windows.CloseHandle(windows.Handle(fd))
 }
 
+// isLocked reports whether a tryLock error means another process holds the
+// lock: the file is opened without sharing, so a second open fails.
+func isLocked(err error) bool {
+	return errors.Is(err, windows.ERROR_SHARING_VIOLATION) || errors.Is(err, windows.ERROR_LOCK_VIOLATION)
+}
+
 // Keep unsafe import used for compilation (windows.Overlapped uses it internally)
 var _ = unsafe.Sizeof(0)
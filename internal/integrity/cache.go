@@ -0,0 +1,48 @@
+package integrity
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheFileName is the last signature-verified manifest, stored next to the
+// binary (or in Options.CacheDir) together with its .sig.
+const CacheFileName = "obs-agent.manifest.json"
+
+// CacheMaxAge is how long Verify with a CacheDir uses a cached manifest without
+// fetching a new one.
+const CacheMaxAge = 24 * time.Hour
+
+// cachePath is the cache file in dir: next to the binary, or
+// Options.CacheDir.
+func cachePath(dir string) string {
+	return filepath.Join(dir, CacheFileName)
+}
+
+// readCache returns the cached manifest at path, its signature and its
+// age. The signature is checked again by the caller, so a tampered cache
+// fails.
+func readCache(path string) (body, sig []byte, age time.Duration, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if body, err = os.ReadFile(path); err != nil {
+		return nil, nil, 0, err
+	}
+	if sig, err = os.ReadFile(path + SignatureSuffix); err != nil {
+		return nil, nil, 0, fmt.Errorf("cached manifest has no signature: %w", err)
+	}
+	return body, sig, time.Since(info.ModTime()), nil
+}
+
+// writeCache stores a verified manifest at path. Best effort: the binary's
+// directory is often read-only (Program Files, /usr/local/bin).
+func writeCache(path string, body, sig []byte) {
+	if os.WriteFile(path+SignatureSuffix, sig, 0644) != nil {
+		return
+	}
+	os.WriteFile(path, body, 0644)
+}
@@ -0,0 +1,108 @@
+package integrity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// signedManifest returns a manifest for exe and its signature, with the
+// build's public key set for the test.
+func signedManifest(t *testing.T, exe string) (body, sig []byte) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := ManifestPublicKey
+	ManifestPublicKey = base64.StdEncoding.EncodeToString(pub)
+	t.Cleanup(func() { ManifestPublicKey = old })
+
+	hash, err := FileHash(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = json.Marshal(manifest{
+		Version: "1.2.0",
+		Builds:  []build{{OS: runtime.GOOS, Arch: runtime.GOARCH, SHA256: hash}},
+	})
+	return body, []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, body)))
+}
+
+func TestVerifyCached(t *testing.T) {
+	tests := []struct {
+		name       string
+		cacheAge   time.Duration // 0: no cache file
+		maxAge     time.Duration
+		offline    bool
+		wantSource string
+		wantFetch  bool
+	}{
+		{name: "fresh cache", cacheAge: time.Hour, maxAge: CacheMaxAge, wantSource: SourceCached},
+		{name: "stale cache", cacheAge: 2 * CacheMaxAge, maxAge: CacheMaxAge, wantSource: SourceLive, wantFetch: true},
+		{name: "no max age", cacheAge: time.Minute, maxAge: 0, wantSource: SourceLive, wantFetch: true},
+		{name: "stale cache offline", cacheAge: 2 * CacheMaxAge, maxAge: CacheMaxAge, offline: true, wantSource: SourceCached, wantFetch: true},
+		{name: "no cache", maxAge: CacheMaxAge, wantSource: SourceLive, wantFetch: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			exe := filepath.Join(dir, "obs-agent")
+			if err := os.WriteFile(exe, []byte("release"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			body, sig := signedManifest(t, exe)
+
+			var fetches atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fetches.Add(1)
+				if tt.offline {
+					http.Error(w, "offline", http.StatusServiceUnavailable)
+					return
+				}
+				switch r.URL.Path {
+				case "/manifest.json":
+					w.Write(body)
+				case "/manifest.json" + SignatureSuffix:
+					w.Write(sig)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer srv.Close()
+
+			cache := cachePath(dir)
+			if tt.cacheAge > 0 {
+				writeCache(cache, body, sig)
+				mtime := time.Now().Add(-tt.cacheAge)
+				if err := os.Chtimes(cache, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			result, err := verifyCached(exe, cache, tt.maxAge, Options{ManifestURL: srv.URL + "/manifest.json"})
+			if err != nil {
+				t.Fatalf("verifyCached: %v", err)
+			}
+			if !result.Match || result.Source != tt.wantSource {
+				t.Errorf("result = match %v source %q, want match source %q", result.Match, result.Source, tt.wantSource)
+			}
+			if got := fetches.Load() > 0; got != tt.wantFetch {
+				t.Errorf("fetched = %v, want %v", got, tt.wantFetch)
+			}
+			if _, _, age, err := readCache(cache); err != nil {
+				t.Errorf("cache after verify: %v", err)
+			} else if tt.wantSource == SourceLive && age > time.Minute {
+				t.Errorf("live manifest not written to cache (age %v)", age)
+			}
+		})
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
 	"time"
 )
@@ -20,8 +22,22 @@ type Result struct {
 	Expected string
 	Actual   string
 	Version  string
+	// Signature is SignatureVerified, or SignatureUnavailable when a
+	// lenient check fell back to trusting TLS.
+	Signature string
+	// Source is where the manifest came from (SourceLive, SourceCached or
+	// SourceLocal); CacheAge is the cached copy's age.
+	Source   string
+	CacheAge time.Duration
 }
 
+// Manifest sources reported in Result.Source.
+const (
+	SourceLive   = "live"
+	SourceCached = "cached"
+	SourceLocal  = "local"
+)
+
 type manifest struct {
 	Version string  `json:"version"`
 	Builds  []build `json:"builds"`
@@ -39,8 +55,12 @@ func SelfHash() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("resolve executable: %w", err)
 	}
+	return FileHash(exe)
+}
 
-	f, err := os.Open(exe)
+// FileHash computes the SHA256 of the file at path.
+func FileHash(path string) (string, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return "", fmt.Errorf("open executable: %w", err)
 	}
@@ -54,30 +74,167 @@ func SelfHash() (string, error) {
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
-// Verify fetches the manifest and compares the SHA256 for this platform.
-func Verify(manifestURL string) (*Result, error) {
-	if manifestURL == "" {
-		manifestURL = DefaultManifestURL
+// Options configures Verify.
+type Options struct {
+	// ManifestURL is where the manifest is fetched ("" =
+	// DefaultManifestURL).
+	ManifestURL string
+
+	// CacheDir, when set, holds the cached manifest (created if missing)
+	// instead of the binary's directory, and a cached manifest younger
+	// than CacheMaxAge that the binary matches is used without fetching.
+	CacheDir string
+
+	// Strict makes a missing signature an ErrSignature instead of a logged
+	// warning.
+	Strict bool
+}
+
+// Verify fetches the manifest, checks its Ed25519 signature, and compares
+// the SHA256 for this platform. Errors wrap ErrFetch (network) or
+// ErrSignature (untrusted manifest). A signed manifest is cached next to
+// the binary (or in opts.CacheDir) and used when the fetch fails, so
+// offline starts still verify.
+func Verify(opts Options) (*Result, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("resolve executable: %w", err)
+	}
+	if opts.CacheDir == "" {
+		return verifyCached(exe, cachePath(filepath.Dir(exe)), 0, opts)
+	}
+	if err := os.MkdirAll(opts.CacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("create manifest cache: %w", err)
+	}
+	return verifyCached(exe, cachePath(opts.CacheDir), CacheMaxAge, opts)
+}
+
+// verifyCached checks exe against the manifest cached at cache when it is
+// younger than maxAge and matches, and against the fetched manifest
+// otherwise. The cached copy, whatever its age, stands in when the fetch
+// fails; a signed fetched manifest replaces it.
+func verifyCached(exe, cache string, maxAge time.Duration, opts Options) (*Result, error) {
+	cached, cachedSig, age, cerr := readCache(cache)
+	if cerr == nil && age < maxAge {
+		// A mismatch may just mean the binary was updated since: fetch
+		if result, err := compare(exe, cached, cachedSig, opts.Strict); err == nil && result.Match {
+			result.Source = SourceCached
+			result.CacheAge = age
+			return result, nil
+		}
 	}
 
-	actual, err := SelfHash()
+	body, sig, err := fetchManifest(opts.ManifestURL)
+	if errors.Is(err, ErrFetch) {
+		if cerr != nil {
+			return nil, err
+		}
+		result, cerr := compare(exe, cached, cachedSig, opts.Strict)
+		if cerr != nil {
+			return nil, fmt.Errorf("%w (cached manifest unusable: %v)", err, cerr)
+		}
+		result.Source = SourceCached
+		result.CacheAge = age
+		return result, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	result, err := compare(exe, body, sig, opts.Strict)
+	if err != nil {
+		return nil, err
+	}
+	result.Source = SourceLive
+	if result.Signature == SignatureVerified {
+		writeCache(cache, body, sig)
+	}
+	return result, nil
+}
+
+// VerifyFile is Verify for an arbitrary binary (e.g. a downloaded update).
+// It always fetches the manifest; the cache is only used for the running
+// binary. A missing signature is only reported in Result.Signature, which
+// the caller must check.
+func VerifyFile(manifestURL, path string) (*Result, error) {
+	body, sig, err := fetchManifest(manifestURL)
+	if err != nil {
+		return nil, err
+	}
+	result, err := compare(path, body, sig, false)
+	if err != nil {
+		return nil, err
+	}
+	result.Source = SourceLive
+	return result, nil
+}
+
+// VerifyLocal checks the running binary against a manifest file on disk
+// (for air-gapped machines). A detached signature is read from
+// manifestPath + SignatureSuffix if present; strict is Options.Strict.
+func VerifyLocal(manifestPath string, strict bool) (*Result, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("resolve executable: %w", err)
+	}
+	body, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	sig, err := os.ReadFile(manifestPath + SignatureSuffix)
+	if errors.Is(err, os.ErrNotExist) {
+		sig = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read manifest signature: %w", err)
+	}
+
+	result, err := compare(exe, body, sig, strict)
+	if err != nil {
+		return nil, err
+	}
+	result.Source = SourceLocal
+	return result, nil
+}
+
+// fetchManifest downloads the manifest and its signature (nil if the
+// release has none).
+func fetchManifest(manifestURL string) (body, sig []byte, err error) {
+	if manifestURL == "" {
+		manifestURL = DefaultManifestURL
+	}
+
 	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(manifestURL)
+	body, err = fetch(client, manifestURL)
 	if err != nil {
-		return nil, fmt.Errorf("fetch manifest: %w", err)
+		return nil, nil, err
 	}
-	defer resp.Body.Close()
+	sig, err = fetch(client, manifestURL+SignatureSuffix)
+	if errors.Is(err, errNotFound) {
+		return body, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, sig, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("manifest HTTP %d", resp.StatusCode)
+// compare checks the manifest signature (see checkSignature), then the
+// SHA256 of path against this platform's entry.
+func compare(path string, body, sig []byte, strict bool) (*Result, error) {
+	actual, err := FileHash(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// SECURITY: TLS only proves who served the file; the signature proves
+	// the release key produced it. Parse nothing before it is checked.
+	sigState, err := checkSignature(body, sig, strict)
+	if err != nil {
+		return nil, err
 	}
 
 	var m manifest
-	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+	if err := json.Unmarshal(body, &m); err != nil {
 		return nil, fmt.Errorf("parse manifest: %w", err)
 	}
 
@@ -88,9 +245,41 @@ func Verify(manifestURL string) (*Result, error) {
 				Expected: b.SHA256,
 				Actual:   actual,
 				Version:  m.Version,
+
+				Signature: sigState,
 			}, nil
 		}
 	}
 
 	return nil, fmt.Errorf("no manifest entry for %s/%s", runtime.GOOS, runtime.GOARCH)
 }
+
+// maxManifestSize bounds the manifest and signature downloads.
+const maxManifestSize = 1 << 20
+
+// errNotFound marks an HTTP 404 from fetch.
+var errNotFound = errors.New("not found")
+
+// fetch downloads url; failures wrap ErrFetch (and errNotFound for 404).
+func fetch(client *http.Client, url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFetch, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s: %w", ErrFetch, url, errNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s: HTTP %d", ErrFetch, url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFetch, err)
+	}
+	if len(body) > maxManifestSize {
+		return nil, fmt.Errorf("%w: %s larger than %d bytes", ErrFetch, url, maxManifestSize)
+	}
+	return body, nil
+}
@@ -0,0 +1,63 @@
+package integrity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestCheckSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"version":"1.2.0"}`)
+	raw := ed25519.Sign(priv, body)
+	b64 := []byte(base64.StdEncoding.EncodeToString(raw) + "\n")
+	key := base64.StdEncoding.EncodeToString(pub)
+
+	tests := []struct {
+		name      string
+		key       string
+		manifest  []byte
+		sig       []byte
+		strict    bool
+		wantState string
+		wantErr   error // nil = no error
+	}{
+		{"raw signature", key, body, raw, true, SignatureVerified, nil},
+		{"base64 signature", key, body, b64, true, SignatureVerified, nil},
+		{"tampered manifest", key, []byte(`{"version":"6.6.6"}`), raw, false, "", ErrSignature},
+		{"other key", key, body, ed25519.Sign(otherPriv, body), false, "", ErrSignature},
+		{"malformed signature", key, body, []byte("not a signature"), false, "", ErrSignature},
+		{"malformed public key", "AAAA", body, raw, false, "", ErrSignature},
+		{"missing signature, lenient", key, body, nil, false, SignatureUnavailable, nil},
+		{"missing signature, strict", key, body, nil, true, "", ErrSignature},
+		{"no public key, lenient", "", body, raw, false, SignatureUnavailable, nil},
+		{"no public key, strict", "", body, raw, true, "", ErrSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := ManifestPublicKey
+			ManifestPublicKey = tt.key
+			defer func() { ManifestPublicKey = old }()
+
+			state, err := checkSignature(tt.manifest, tt.sig, tt.strict)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if state != tt.wantState {
+				t.Errorf("state %q, want %q", state, tt.wantState)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+// Package keyring stores small secrets (the agent token) in the OS
+// credential store: Windows Credential Manager, the macOS Keychain, or the
+// Secret Service (libsecret) on Linux.
+package keyring
+
+import "errors"
+
+// Service names every item this agent stores.
+const Service = "cloud.4throck.obs-agent"
+
+// ErrNotFound is returned by Get when no item exists for the account.
+var ErrNotFound = errors.New("keyring: item not found")
+
+// Keyring is a credential store keyed by account name.
+type Keyring interface {
+	Get(account string) (string, error)
+	Set(account, secret string) error
+	Delete(account string) error
+}
+
+// System returns the OS credential store, or nil if this platform has none
+// or its tooling is missing (e.g. no secret-tool / session bus on Linux).
+func System() Keyring {
+	return system()
+}
@@ -0,0 +1,75 @@
+//go:build darwin
+
+package keyring
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const securityTool = "/usr/bin/security"
+
+// errSecItemNotFound is security(1)'s exit status for a missing item.
+const errSecItemNotFound = 44
+
+// keychain uses security(1) so the binary stays cgo-free.
+type keychain struct{}
+
+func system() Keyring {
+	if _, err := exec.LookPath(securityTool); err != nil {
+		return nil
+	}
+	return keychain{}
+}
+
+func (keychain) Get(account string) (string, error) {
+	out, err := exec.Command(securityTool, "find-generic-password",
+		"-s", Service, "-a", account, "-w").Output()
+	if err != nil {
+		if notFound(err) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("keychain lookup failed: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (k keychain) Set(account, secret string) error {
+	// security -i reads its commands from stdin, so the secret never
+	// appears in argv, where any local user could see it with ps. -X takes
+	// it hex-encoded, which needs no quoting; -U updates an existing item.
+	cmdline := fmt.Sprintf("add-generic-password -U -s %s -a %s -l \"4thRock OBS Agent\" -X %s\n",
+		Service, account, hex.EncodeToString([]byte(secret)))
+	var stderr bytes.Buffer
+	cmd := exec.Command(securityTool, "-i")
+	cmd.Stdin = strings.NewReader(cmdline)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("keychain store failed: %w", err)
+	}
+	if stderr.Len() > 0 {
+		return fmt.Errorf("keychain store failed: %s", strings.TrimSpace(stderr.String()))
+	}
+	// security -i exits 0 even when a command fails: read the item back
+	if got, err := k.Get(account); err != nil || got != secret {
+		return fmt.Errorf("keychain store failed: item does not read back")
+	}
+	return nil
+}
+
+func (keychain) Delete(account string) error {
+	err := exec.Command(securityTool, "delete-generic-password", "-s", Service, "-a", account).Run()
+	if err != nil && !notFound(err) {
+		return fmt.Errorf("keychain delete failed: %w", err)
+	}
+	return nil
+}
+
+func notFound(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == errSecItemNotFound
+}
@@ -0,0 +1,63 @@
+//go:build linux
+
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// secretService talks to the Secret Service (GNOME Keyring, KWallet) via
+// libsecret's secret-tool, which reads the secret from stdin.
+type secretService struct {
+	tool string
+}
+
+func system() Keyring {
+	// Headless hosts and services usually have no session bus or keyring daemon
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return nil
+	}
+	tool, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil
+	}
+	return secretService{tool: tool}
+}
+
+func (s secretService) Get(account string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command(s.tool, "lookup", "service", Service, "account", account)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// secret-tool exits 1 with no output when nothing matches
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(out) == 0 && stderr.Len() == 0 {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("secret-tool lookup failed: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (s secretService) Set(account, secret string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command(s.tool, "store", "--label=4thRock OBS Agent", "service", Service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("secret-tool store failed: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func (s secretService) Delete(account string) error {
+	// clear succeeds (or exits 1) when nothing matches — either is fine
+	exec.Command(s.tool, "clear", "service", Service, "account", account).Run()
+	return nil
+}
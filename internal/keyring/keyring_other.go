@@ -0,0 +1,7 @@
+//go:build !windows && !darwin && !linux
+
+package keyring
+
+func system() Keyring {
+	return nil
+}
@@ -0,0 +1,38 @@
+package keyring
+
+import "sync"
+
+// Memory is an in-process Keyring for tests and tooling.
+type Memory struct {
+	mu    sync.Mutex
+	items map[string]string
+}
+
+// NewMemory returns an empty in-memory keyring.
+func NewMemory() *Memory {
+	return &Memory{items: make(map[string]string)}
+}
+
+func (m *Memory) Get(account string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	secret, ok := m.items[account]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (m *Memory) Set(account, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[account] = secret
+	return nil
+}
+
+func (m *Memory) Delete(account string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, account)
+	return nil
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// componentPattern matches the "[bridge] " style prefix used by every log line.
+var componentPattern = regexp.MustCompile(`^\[([a-z]+)\] ?`)
+
+// defaultComponent is used for lines without a prefix.
+const defaultComponent = "agent"
+
+// jsonRecord is one structured log line.
+type jsonRecord struct {
+	TS        string `json:"ts"`
+	Level     string `json:"level"`
+	Component string `json:"component"`
+	Msg       string `json:"msg"`
+}
+
+// JSONWriter turns std log output into one JSON object per line.
+// Use with log.SetFlags(0) — it stamps each record itself. Messages are
+// passed through Redact, so registered secrets never reach the output.
+type JSONWriter struct {
+	mu      sync.Mutex
+	out     io.Writer
+	partial []byte
+}
+
+// NewJSONWriter returns a JSONWriter that writes records to out.
+func NewJSONWriter(out io.Writer) *JSONWriter {
+	return &JSONWriter{out: out}
+}
+
+// Write emits a record for each complete line in p.
+func (w *JSONWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	data := append(w.partial, p...)
+	var buf bytes.Buffer
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		rec := newRecord(string(data[:i]), time.Now())
+		line, err := json.Marshal(rec)
+		if err == nil {
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+		data = data[i+1:]
+	}
+	w.partial = append([]byte(nil), data...)
+
+	if buf.Len() > 0 {
+		if _, err := w.out.Write(buf.Bytes()); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func newRecord(line string, now time.Time) jsonRecord {
+	rec := jsonRecord{
+		TS:        now.UTC().Format(time.RFC3339Nano),
+		Component: defaultComponent,
+	}
+	if m := componentPattern.FindStringSubmatch(line); m != nil {
+		rec.Component = m[1]
+		line = line[len(m[0]):]
+	}
+	rec.Msg = Redact(line)
+	rec.Level = levelOf(rec.Msg)
+	return rec
+}
+
+// levelOf infers a level from message wording. The agent logs through the
+// plain std logger, so this is a heuristic tuned to its phrasing.
+func levelOf(msg string) string {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.HasPrefix(msg, "SECURITY"), strings.HasPrefix(lower, "warning"):
+		return "warn"
+	case strings.Contains(lower, "failed"), strings.Contains(lower, "error"):
+		return "error"
+	default:
+		return "info"
+	}
+}
@@ -0,0 +1,120 @@
+// Package logging holds log sinks shared by the agent and its status API.
+package logging
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// DefaultRingLines is how many recent lines the agent keeps in memory.
+const DefaultRingLines = 1000
+
+// tokenPattern matches 64-hex agent tokens (and anything shaped like one).
+var tokenPattern = regexp.MustCompile(`\b[0-9a-fA-F]{64}\b`)
+
+// minSecretLen skips values too short to mask without mangling every line.
+const minSecretLen = 4
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// AddSecret registers a value (e.g. the OBS password) that Redact masks
+// wherever it appears. Values shorter than 4 characters are ignored.
+func AddSecret(s string) {
+	if len(s) < minSecretLen {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, existing := range secrets {
+		if existing == s {
+			return
+		}
+	}
+	secrets = append(secrets, s)
+}
+
+// Redact masks anything shaped like an agent token, plus registered secrets.
+//
+// SECURITY: the agent never logs full tokens on purpose; this is a second
+// line of defence for lines served over the status API and JSON logs.
+func Redact(line string) string {
+	line = tokenPattern.ReplaceAllString(line, "[REDACTED]")
+	secretsMu.RLock()
+	defer secretsMu.RUnlock()
+	for _, s := range secrets {
+		line = strings.ReplaceAll(line, s, "[REDACTED]")
+	}
+	return line
+}
+
+// Ring is an io.Writer that keeps the last N complete log lines in memory.
+// Add it to the log MultiWriter; it is safe for concurrent use.
+type Ring struct {
+	mu      sync.Mutex
+	lines   []string
+	next    int
+	full    bool
+	partial []byte
+}
+
+// NewRing creates a ring holding up to size lines.
+func NewRing(size int) *Ring {
+	if size <= 0 {
+		size = DefaultRingLines
+	}
+	return &Ring{lines: make([]string, size)}
+}
+
+// Write records each complete line in p. A trailing partial line is held
+// until its newline arrives.
+func (r *Ring) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data := append(r.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		r.add(Redact(string(data[:i])))
+		data = data[i+1:]
+	}
+	r.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+func (r *Ring) add(line string) {
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Lines returns up to n of the most recent lines, oldest first.
+// n <= 0 returns everything held.
+func (r *Ring) Lines(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := r.next
+	if r.full {
+		count = len(r.lines)
+	}
+	if n <= 0 || n > count {
+		n = count
+	}
+
+	out := make([]string, 0, n)
+	start := (r.next - n + len(r.lines)) % len(r.lines)
+	for i := 0; i < n; i++ {
+		out = append(out, r.lines[(start+i)%len(r.lines)])
+	}
+	return out
+}
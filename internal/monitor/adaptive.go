@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	// maxIntervalFactor caps the adaptive interval at this multiple of the
+	// configured one.
+	maxIntervalFactor = 5
+	// latencyWindow is how many poll response times the rolling mean covers.
+	latencyWindow = 5
+	// slowRatio and fastRatio are the mean/single response time, relative
+	// to the effective interval, above which polling slows down and below
+	// which (for recoverPolls polls in a row) it speeds back up.
+	slowRatio    = 0.8
+	fastRatio    = 0.2
+	recoverPolls = 10
+)
+
+// adaptiveInterval backs the poll interval off while OBS is slow to answer
+// (scene transitions, heavy load) so polls don't queue up, and returns to
+// the configured interval once it recovers. It is runtime-only state.
+type adaptiveInterval struct {
+	base    time.Duration
+	current time.Duration
+	samples []time.Duration // last latencyWindow response times
+	fast    int             // consecutive polls under fastRatio
+}
+
+func newAdaptiveInterval(base time.Duration) *adaptiveInterval {
+	return &adaptiveInterval{base: base, current: base}
+}
+
+// observe records one poll's response time and returns the interval to
+// use from now on and whether it changed.
+func (a *adaptiveInterval) observe(latency time.Duration) (time.Duration, bool) {
+	a.samples = append(a.samples, latency)
+	if len(a.samples) > latencyWindow {
+		a.samples = a.samples[1:]
+	}
+	if latency < time.Duration(fastRatio*float64(a.current)) {
+		a.fast++
+	} else {
+		a.fast = 0
+	}
+
+	var sum time.Duration
+	for _, s := range a.samples {
+		sum += s
+	}
+	mean := sum / time.Duration(len(a.samples))
+
+	limit := a.base * maxIntervalFactor
+	switch {
+	case len(a.samples) == latencyWindow && mean > time.Duration(slowRatio*float64(a.current)) && a.current < limit:
+		a.current = min(a.current*2, limit)
+		log.Printf("[monitor] WARNING: OBS is slow to respond (mean %v) — polling every %v instead of %v",
+			mean.Round(time.Millisecond), a.current, a.base)
+	case a.fast >= recoverPolls && a.current > a.base:
+		a.current = max(a.current/2, a.base)
+		log.Printf("[monitor] OBS responding quickly again — polling every %v", a.current)
+	default:
+		return a.current, false
+	}
+	// Judge the new interval on fresh measurements
+	a.samples = a.samples[:0]
+	a.fast = 0
+	return a.current, true
+}
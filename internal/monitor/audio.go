@@ -0,0 +1,277 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/backoff"
+	"github.com/4throck/obs-agent/internal/obs"
+)
+
+const (
+	// DefaultAudioThresholdDb is the peak level under which an input counts
+	// as silent (Config.AudioThresholdDb 0).
+	DefaultAudioThresholdDb = -60.0
+	// DefaultAudioWindow is how long an input must stay under the threshold
+	// to be reported silent (Config.AudioWindowMs 0).
+	DefaultAudioWindow = 10 * time.Second
+
+	// minLevelDb stands in for -Inf (digital silence), which JSON cannot carry.
+	minLevelDb = -100.0
+
+	audioEvaluateInterval = time.Second
+	audioReadTimeout      = 10 * time.Second
+	audioRetryDelay       = 5 * time.Second
+)
+
+// Audio states reported in AgentAudioState.
+const (
+	audioOK     = "ok"
+	audioSilent = "silent"
+	audioMuted  = "muted"
+)
+
+// levelSample is one meter reading: the loudest channel's peak, in dB.
+type levelSample struct {
+	at     time.Time
+	peakDb float64
+}
+
+// audioInput is the watch state of one configured input.
+type audioInput struct {
+	samples []levelSample // within the window, oldest first
+	muted   bool
+	state   string // last reported ("" before the first report)
+}
+
+// audioWatch turns InputVolumeMeters readings and mute changes into
+// per-input ok/silent/muted states. Only its transitions reach the relay.
+type audioWatch struct {
+	threshold float64
+	window    time.Duration
+	started   time.Time // levels are judged once one window has passed
+	inputs    map[string]*audioInput
+}
+
+func newAudioWatch(inputs []string, thresholdDb float64, window time.Duration, now time.Time) *audioWatch {
+	if thresholdDb == 0 {
+		thresholdDb = DefaultAudioThresholdDb
+	}
+	if window <= 0 {
+		window = DefaultAudioWindow
+	}
+	w := &audioWatch{threshold: thresholdDb, window: window, started: now, inputs: make(map[string]*audioInput)}
+	for _, name := range inputs {
+		w.inputs[name] = &audioInput{}
+	}
+	return w
+}
+
+// observeMeters records one InputVolumeMeters event's eventData.
+func (w *audioWatch) observeMeters(data json.RawMessage, now time.Time) {
+	var meters struct {
+		Inputs []struct {
+			InputName      string      `json:"inputName"`
+			InputLevelsMul [][]float64 `json:"inputLevelsMul"`
+		} `json:"inputs"`
+	}
+	if json.Unmarshal(data, &meters) != nil {
+		return
+	}
+	for _, m := range meters.Inputs {
+		in := w.inputs[m.InputName]
+		if in == nil {
+			continue
+		}
+		peak := 0.0
+		for _, ch := range m.InputLevelsMul {
+			if len(ch) > 1 { // [magnitude, peak, input peak]
+				peak = max(peak, ch[1])
+			}
+		}
+		in.samples = append(in.samples, levelSample{at: now, peakDb: levelDb(peak)})
+	}
+}
+
+// setMuted records an input's mute state.
+func (w *audioWatch) setMuted(name string, muted bool) {
+	if in := w.inputs[name]; in != nil {
+		in.muted = muted
+	}
+}
+
+// evaluate drops samples older than the window and calls report for each
+// input whose state changed. The first reports come once a full window of
+// levels has been seen (or at once for a muted input).
+func (w *audioWatch) evaluate(now time.Time, report func(name, state string, peakDb float64)) {
+	cutoff := now.Add(-w.window)
+	for name, in := range w.inputs {
+		i := 0
+		for i < len(in.samples) && in.samples[i].at.Before(cutoff) {
+			i++
+		}
+		in.samples = in.samples[i:]
+
+		peak := minLevelDb
+		for _, s := range in.samples {
+			peak = max(peak, s.peakDb)
+		}
+
+		state := audioOK
+		switch {
+		case in.muted:
+			state = audioMuted
+		case now.Sub(w.started) < w.window:
+			continue // not a full window of levels yet
+		case peak < w.threshold:
+			state = audioSilent
+		}
+		if state != in.state {
+			in.state = state
+			report(name, state, peak)
+		}
+	}
+}
+
+// levelDb converts a linear meter level to dBFS, floored at minLevelDb.
+func levelDb(mul float64) float64 {
+	if mul <= 0 {
+		return minLevelDb
+	}
+	return max(20*math.Log10(mul), minLevelDb)
+}
+
+// audioLoop watches inputs over its own OBS connection subscribed to the
+// meters and input events, reconnecting with backoff until ctx is done.
+// Initial mute states are read over pool.
+func (m *Monitor) audioLoop(ctx context.Context, inputs []string, thresholdDb float64, window time.Duration, pool *obs.Pool) {
+	watch := newAudioWatch(inputs, thresholdDb, window, time.Now())
+	failures := 0
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := m.watchAudio(ctx, watch, pool)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > connRetryMax {
+			failures = 0 // it was up for a while: start the backoff over
+		}
+		delay := backoff.Delay(failures, audioRetryDelay, connRetryMax)
+		failures++
+		log.Printf("[monitor] Audio meters: %v — retrying in %v", err, delay.Round(100*time.Millisecond))
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+	}
+}
+
+// watchAudio runs one meter connection until it fails or ctx is done.
+func (m *Monitor) watchAudio(ctx context.Context, watch *audioWatch, pool *obs.Pool) error {
+	type obsEvent struct {
+		eventType string
+		eventData json.RawMessage
+	}
+	events := make(chan obsEvent, 16)
+
+	client, err := m.dial(ctx, m.obsAddr, m.obsPass, obs.EventSubInputs|obs.EventSubInputVolumeMeters)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	client.OnEvent(func(eventType string, eventData json.RawMessage) {
+		select {
+		case events <- obsEvent{eventType, eventData}:
+		case <-client.Done():
+		}
+	})
+
+	for name := range watch.inputs {
+		err := m.withConn(ctx, pool, func(c *obs.Client) error {
+			resp, err := c.Request(ctx, "GetInputMute", map[string]interface{}{"inputName": name})
+			if err == nil {
+				muted, _ := resp["inputMuted"].(bool)
+				watch.setMuted(name, muted)
+			}
+			return err
+		})
+		if err != nil {
+			log.Printf("[monitor] GetInputMute %q failed: %v", name, err)
+		}
+	}
+
+	report := func(name, state string, peakDb float64) {
+		m.sendAudioState(name, state, peakDb, watch)
+	}
+	ticker := time.NewTicker(audioEvaluateInterval)
+	defer ticker.Stop()
+	watch.evaluate(time.Now(), report)
+	// OBS sends meters every 50ms: a silent connection is a dead one
+	lastEvent := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-client.Done():
+			return client.Err()
+		case <-ticker.C:
+			if time.Since(lastEvent) > audioReadTimeout {
+				return fmt.Errorf("no OBS events for %v", audioReadTimeout)
+			}
+			watch.evaluate(time.Now(), report)
+		case ev := <-events:
+			lastEvent = time.Now()
+			switch ev.eventType {
+			case "InputVolumeMeters":
+				watch.observeMeters(ev.eventData, time.Now())
+			case "InputMuteStateChanged":
+				var d struct {
+					InputName  string `json:"inputName"`
+					InputMuted bool   `json:"inputMuted"`
+				}
+				if json.Unmarshal(ev.eventData, &d) == nil {
+					watch.setMuted(d.InputName, d.InputMuted)
+					watch.evaluate(time.Now(), report) // mute is reported at once
+				}
+			}
+		}
+	}
+}
+
+// sendAudioState builds an op 5 AgentAudioState event and calls sendEvent.
+func (m *Monitor) sendAudioState(inputName, state string, peakDb float64, watch *audioWatch) {
+	m.hookMu.Lock()
+	fn := m.sendEvent
+	m.hookMu.Unlock()
+
+	if fn == nil {
+		return
+	}
+
+	event := map[string]interface{}{
+		"op": 5,
+		"d": map[string]interface{}{
+			"eventType":   "AgentAudioState",
+			"eventIntent": 1,
+			"eventData": map[string]interface{}{
+				"inputName":   inputName,
+				"state":       state,
+				"peakDb":      math.Round(peakDb*10) / 10,
+				"thresholdDb": watch.threshold,
+				"windowMs":    watch.window.Milliseconds(),
+			},
+		},
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[monitor] Failed to marshal event: %v", err)
+		return
+	}
+
+	fn(data)
+}
@@ -0,0 +1,159 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/obs"
+)
+
+// DefaultStreamHealthInterval is how often AgentStreamHealth is sampled
+// (Config.StreamHealthIntervalMs 0).
+const DefaultStreamHealthInterval = 5 * time.Second
+
+// healthSample is one GetStreamStatus + GetStats reading.
+type healthSample struct {
+	at                  time.Time
+	outputBytes         float64
+	outputDuration      float64 // ms
+	outputSkippedFrames float64
+	outputTotalFrames   float64
+	congestion          float64
+	renderSkippedFrames float64
+	renderTotalFrames   float64
+	cpuUsage            float64
+}
+
+// healthLoop sends an AgentStreamHealth event every interval while OBS is
+// streaming. Sampling restarts (no deltas) with each new stream.
+func (m *Monitor) healthLoop(ctx context.Context, interval time.Duration, pool *obs.Pool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var prev *healthSample
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if m.obsDown.Load() {
+			prev = nil
+			continue
+		}
+
+		var sample *healthSample
+		var active bool
+		err := m.withConn(ctx, pool, func(c *obs.Client) (err error) {
+			sample, active, err = sampleHealth(ctx, c)
+			return err
+		})
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, errConnBackoff) {
+				log.Printf("[monitor] Stream health sample failed: %v", err)
+			}
+			continue
+		}
+		if !active {
+			prev = nil
+			continue
+		}
+		m.sendStreamHealth(sample, prev)
+		prev = sample
+	}
+}
+
+// sampleHealth reads the stream output and OBS stats. active is false
+// (and sample nil) when OBS is not streaming.
+func sampleHealth(ctx context.Context, c *obs.Client) (*healthSample, bool, error) {
+	stream, err := c.Request(ctx, "GetStreamStatus", nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if active, _ := stream["outputActive"].(bool); !active {
+		return nil, false, nil
+	}
+	stats, err := c.Request(ctx, "GetStats", nil)
+	if err != nil {
+		return nil, false, err
+	}
+	num := func(resp map[string]interface{}, key string) float64 {
+		v, _ := resp[key].(float64)
+		return v
+	}
+	return &healthSample{
+		at:                  time.Now(),
+		outputBytes:         num(stream, "outputBytes"),
+		outputDuration:      num(stream, "outputDuration"),
+		outputSkippedFrames: num(stream, "outputSkippedFrames"),
+		outputTotalFrames:   num(stream, "outputTotalFrames"),
+		congestion:          num(stream, "outputCongestion"),
+		renderSkippedFrames: num(stats, "renderSkippedFrames"),
+		renderTotalFrames:   num(stats, "renderTotalFrames"),
+		cpuUsage:            num(stats, "cpuUsage"),
+	}, true, nil
+}
+
+// sendStreamHealth builds an op 5 AgentStreamHealth event and calls
+// sendEvent. Deltas are against prev; the first sample of a stream (prev
+// nil) counts the output from the start of the stream and no render delta.
+func (m *Monitor) sendStreamHealth(cur, prev *healthSample) {
+	m.hookMu.Lock()
+	fn := m.sendEvent
+	m.hookMu.Unlock()
+
+	if fn == nil {
+		return
+	}
+
+	base := &healthSample{}
+	elapsedMs := cur.outputDuration
+	if prev != nil {
+		base = prev
+		elapsedMs = float64(cur.at.Sub(prev.at).Milliseconds())
+	} else {
+		base.renderSkippedFrames, base.renderTotalFrames = cur.renderSkippedFrames, cur.renderTotalFrames
+	}
+	kbps := 0.0
+	if elapsedMs > 0 {
+		kbps = (cur.outputBytes - base.outputBytes) * 8 / elapsedMs // bytes/ms*8 = kbit/s
+	}
+
+	event := map[string]interface{}{
+		"op": 5,
+		"d": map[string]interface{}{
+			"eventType":   "AgentStreamHealth",
+			"eventIntent": 1,
+			"eventData": map[string]interface{}{
+				"outputSkippedFrames": cur.outputSkippedFrames,
+				"outputTotalFrames":   cur.outputTotalFrames,
+				"congestion":          cur.congestion,
+				"kbitsPerSec":         kbps,
+				"renderSkippedFrames": cur.renderSkippedFrames,
+				"renderTotalFrames":   cur.renderTotalFrames,
+				"cpuUsage":            cur.cpuUsage,
+
+				"outputSkippedFramesDelta": nonNegative(cur.outputSkippedFrames - base.outputSkippedFrames),
+				"outputTotalFramesDelta":   nonNegative(cur.outputTotalFrames - base.outputTotalFrames),
+				"renderSkippedFramesDelta": nonNegative(cur.renderSkippedFrames - base.renderSkippedFrames),
+			},
+		},
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[monitor] Failed to marshal event: %v", err)
+		return
+	}
+
+	fn(data)
+}
+
+// nonNegative clamps counter deltas, which go negative if OBS resets a
+// counter between samples.
+func nonNegative(v float64) float64 {
+	return max(v, 0)
+}
@@ -0,0 +1,77 @@
+package monitor
+
+import "time"
+
+// DefaultStableCount is how many consecutive polls must agree on a new
+// state before the monitor reports it (Config.StableCount 0).
+const DefaultStableCount = 2
+
+// DefaultHeartbeatInterval is how often an unchanged state is re-sent, so
+// the server can tell a quiet agent from a stuck one
+// (Config.MinReportIntervalMs 0).
+const DefaultHeartbeatInterval = 30 * time.Second
+
+// stateFilter decides which polled states are reported, so a briefly
+// hiccuping ingest does not flip the dashboard (and its notifications)
+// between normal and buffering on every poll, and a steady one is not
+// re-sent on every poll. One is created per Configure (and so per
+// source), so the first poll after it always reports.
+type stateFilter struct {
+	stable      int
+	minInterval time.Duration
+
+	reported   string // last reported state ("" before the first report)
+	detail     string // the media state and containing scene reported with it
+	reportedAt time.Time
+	candidate  string // unreported new state and how many polls in a row saw it
+	seen       int
+}
+
+// newStateFilter requires stable consecutive polls for a transition
+// (< 1 = DefaultStableCount) and repeats an unchanged state at most every
+// minInterval (0 = DefaultHeartbeatInterval, negative = every poll).
+func newStateFilter(stable int, minInterval time.Duration) *stateFilter {
+	if stable < 1 {
+		stable = DefaultStableCount
+	}
+	if minInterval == 0 {
+		minInterval = DefaultHeartbeatInterval
+	}
+	return &stateFilter{stable: stable, minInterval: minInterval}
+}
+
+// observe records one polled state and reports whether to send it. detail
+// is what else the report carries (media state, containing scene): a
+// change in it is sent at once while state holds.
+func (f *stateFilter) observe(state, detail string, now time.Time) bool {
+	if f.reported == "" {
+		f.force(state, now)
+		f.detail = detail
+		return true
+	}
+	if state == f.reported {
+		f.candidate, f.seen = "", 0 // a flap that came back: nothing to report
+		if detail == f.detail && now.Sub(f.reportedAt) < f.minInterval {
+			return false
+		}
+		f.detail, f.reportedAt = detail, now
+		return true
+	}
+	if state != f.candidate {
+		f.candidate, f.seen = state, 0
+	}
+	f.seen++
+	if f.seen < f.stable {
+		return false
+	}
+	f.force(state, now)
+	f.detail = detail
+	return true
+}
+
+// force records state as reported without hysteresis or detail, for
+// events that are not poll results (the consolidated OBS-unreachable state).
+func (f *stateFilter) force(state string, now time.Time) {
+	f.reported, f.detail, f.reportedAt = state, "", now
+	f.candidate, f.seen = "", 0
+}
@@ -0,0 +1,59 @@
+package monitor
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStateFilterFlapping(t *testing.T) {
+	tests := []struct {
+		name   string
+		stable int
+		polls  string // one state per poll: n = normal, b = buffering
+		want   string // the states reported as transitions, in order
+	}{
+		{"steady", 2, "nnnnnn", "n"},
+		{"flapping never settles", 2, "nbnbnbnbn", "n"},
+		{"flapping then settles", 2, "nbnbnbbbbnbn", "nb"},
+		{"every poll reports without hysteresis", 1, "nbnb", "nbnb"},
+		{"three polls to switch", 3, "nbbnbbbnn", "nb"},
+	}
+	names := map[byte]string{'n': "normal", 'b': "buffering"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newStateFilter(tt.stable, time.Hour)
+			now := time.Unix(0, 0)
+			var got strings.Builder
+			last := ""
+			for i := 0; i < len(tt.polls); i++ {
+				state := names[tt.polls[i]]
+				now = now.Add(time.Second)
+				if f.observe(state, "", now) && state != last {
+					got.WriteByte(tt.polls[i])
+					last = state
+				}
+			}
+			if got.String() != tt.want {
+				t.Errorf("transitions %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestStateFilterHeartbeat(t *testing.T) {
+	f := newStateFilter(2, 30*time.Second)
+	start := time.Unix(0, 0)
+	if !f.observe("normal", "", start) {
+		t.Fatal("first poll not reported")
+	}
+	if f.observe("normal", "", start.Add(10*time.Second)) {
+		t.Error("unchanged state repeated before the heartbeat")
+	}
+	if !f.observe("normal", "Scene 2", start.Add(11*time.Second)) {
+		t.Error("changed detail not reported at once")
+	}
+	if !f.observe("normal", "Scene 2", start.Add(42*time.Second)) {
+		t.Error("unchanged state not repeated after the heartbeat")
+	}
+}
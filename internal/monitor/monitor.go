@@ -3,24 +3,80 @@ package monitor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/4throck/obs-agent/internal/backoff"
 	"github.com/4throck/obs-agent/internal/obs"
-	"github.com/gorilla/websocket"
 )
 
 // Config is the configuration pushed from the server via AgentConfigureMonitor.
 type Config struct {
 	Source         string `json:"source"`
-	PollIntervalMs int   `json:"pollIntervalMs"`
-	Enabled        bool  `json:"enabled"`
+	PollIntervalMs int    `json:"pollIntervalMs"`
+	Enabled        bool   `json:"enabled"`
+	// WatchSceneChanges emits AgentSceneChanged when the program scene
+	// changes (the monitor connection subscribes to no OBS events).
+	WatchSceneChanges bool `json:"watchSceneChanges"`
+	// PoolSize is how many OBS connections the monitor may use for parallel
+	// reads (0 = 1, capped at MaxPoolSize).
+	PoolSize int `json:"poolSize"`
+	// StableCount is how many consecutive polls must see a new state
+	// before it is reported (0 = DefaultStableCount, 1 = immediately).
+	StableCount int `json:"stableCount"`
+	// MinReportIntervalMs is how often an unchanged state is re-sent as a
+	// heartbeat (0 = DefaultHeartbeatInterval, negative = every poll).
+	// Changes to the state, media state or containing scene are still sent
+	// as soon as they are stable.
+	MinReportIntervalMs int `json:"minReportIntervalMs"`
+	// StreamHealth emits AgentStreamHealth (dropped frames, bitrate,
+	// congestion, CPU) while OBS is streaming. It works without Source.
+	StreamHealth bool `json:"streamHealth"`
+	// StreamHealthIntervalMs is the AgentStreamHealth sample interval
+	// (0 = DefaultStreamHealthInterval).
+	StreamHealthIntervalMs int `json:"streamHealthIntervalMs"`
+	// StreamState emits AgentStreamState when streaming or recording
+	// starts, stops, pauses or reconnects. It works without Source.
+	StreamState bool `json:"streamState"`
+	// StreamStateIntervalMs is how often the outputs are polled for it
+	// (0 = DefaultStreamStateInterval).
+	StreamStateIntervalMs int `json:"streamStateIntervalMs"`
+	// AudioInputs are watched for AgentAudioState (ok, silent or muted)
+	// from OBS's audio meters. Empty disables audio monitoring.
+	AudioInputs []string `json:"audioInputs"`
+	// AudioThresholdDb is the peak level under which an input counts as
+	// silent (0 = DefaultAudioThresholdDb).
+	AudioThresholdDb float64 `json:"audioThresholdDb"`
+	// AudioWindowMs is how long an input must stay under the threshold to
+	// be reported silent (0 = DefaultAudioWindow).
+	AudioWindowMs int `json:"audioWindowMs"`
+	// StateMap overrides mediaStateMap entries (OBS media state → reported
+	// state), e.g. to report OBS_MEDIA_STATE_ENDED as "ended".
+	StateMap map[string]string `json:"stateMap"`
 }
 
+// Validate reports a config the monitor cannot use as given.
+func (c Config) Validate() error {
+	for obsState, state := range c.StateMap {
+		if strings.TrimSpace(state) == "" {
+			return fmt.Errorf("stateMap: empty state for %q", obsState)
+		}
+	}
+	return nil
+}
+
+// MaxPoolSize caps the monitor's OBS connection pool. OBS serves each
+// connection on its own thread, so a few are cheap but many are not.
+const MaxPoolSize = 4
+
 // mediaStateMap maps OBS media states to internal state strings.
-// Only 2 states: "normal" (playing) and "buffering" (everything else).
+// Only 2 states: "normal" (playing) and "buffering" (everything else),
+// unless Config.StateMap overrides them.
 // Mirrors MEDIA_STATE_MAP in ingest-monitor-service/src/monitor.js.
 var mediaStateMap = map[string]string{
 	"OBS_MEDIA_STATE_PLAYING":   "normal",
@@ -32,38 +88,101 @@ var mediaStateMap = map[string]string{
 	"OBS_MEDIA_STATE_NONE":      "buffering",
 }
 
+// stateMapWith returns mediaStateMap with override's entries applied.
+func stateMapWith(override map[string]string) map[string]string {
+	merged := make(map[string]string, len(mediaStateMap)+len(override))
+	for obsState, state := range mediaStateMap {
+		merged[obsState] = state
+	}
+	for obsState, state := range override {
+		merged[obsState] = state
+	}
+	return merged
+}
+
 const minPollInterval = 500 * time.Millisecond
 
+// Backoff between monitor connect attempts while OBS is not accepting
+// connections (see backoff.Delay).
+const (
+	connRetryBase = time.Second
+	connRetryMax  = 30 * time.Second
+)
+
+// errConnBackoff is returned, wrapped, for a failed OBS connect (logged by
+// dialOBS) and instead of dialing while the backoff after it runs.
+var errConnBackoff = errors.New("waiting to retry the OBS connection")
+
 // Monitor polls a local OBS media source and pushes state events to the relay.
 type Monitor struct {
 	mu         sync.Mutex
 	obsAddr    string
 	obsPass    string
 	config     *Config
+	hookMu     sync.Mutex   // guards the callbacks; not mu, which Stop holds while the loops finish
 	sendEvent  func([]byte) // callback to push raw event JSON to relaySend channel
+	onResponse func(time.Duration)
 	pollCancel context.CancelFunc
 	pollDone   chan struct{}
 	// Scene map: source name → scene name (which scene contains this source)
 	sceneMap   map[string]string
 	sceneMapAt time.Time
+	// sceneMapStale forces the next refresh (set from the bridge goroutine)
+	sceneMapStale atomic.Bool
+	// obsDown is set by the bridge while it is reconnecting to OBS
+	obsDown atomic.Bool
+	// obsBack wakes the poll loop when the bridge reaches OBS again
+	obsBack chan struct{}
+	// shared, when set, carries requests over the bridge's OBS connection
+	// instead of the pool (see SetSharedClient)
+	shared atomic.Pointer[obs.Client]
+	// pollInterval is the effective poll interval in ms, reported in
+	// AgentSourceState (see adaptiveInterval)
+	pollInterval atomic.Int64
+
+	// dialMu serialises pool dials and guards the connect backoff every
+	// loop shares: after dialFailures failed connects in a row, none is
+	// tried before dialRetryAt
+	dialMu       sync.Mutex
+	dialFailures int
+	dialRetryAt  time.Time
+
+	// dial and now are obs.Dial and time.Now (replaced in tests)
+	dial func(ctx context.Context, addr, password string, subscriptions int) (*obs.Client, error)
+	now  func() time.Time
 }
 
+// unreachableReason tags the single consolidated offline event sent while
+// the bridge reports OBS down.
+const unreachableReason = "obs_unreachable"
+
 // New creates a new Monitor. It does not start polling until Configure() is called.
 func New(obsAddr, obsPass string) *Monitor {
 	return &Monitor{
 		obsAddr: obsAddr,
 		obsPass: obsPass,
+		obsBack: make(chan struct{}, 1),
+		dial:    obs.Dial,
+		now:     time.Now,
 	}
 }
 
 // SetSendEvent sets the callback used to push event bytes to the relay writer.
 func (m *Monitor) SetSendEvent(fn func([]byte)) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	m.hookMu.Lock()
+	defer m.hookMu.Unlock()
 	m.sendEvent = fn
 }
 
-// Configure starts, stops, or restarts the poll goroutine based on cfg.
+// SetResponseHook sets a callback that receives how long OBS took to
+// answer each monitor request (nil disables it).
+func (m *Monitor) SetResponseHook(fn func(time.Duration)) {
+	m.hookMu.Lock()
+	defer m.hookMu.Unlock()
+	m.onResponse = fn
+}
+
+// Configure starts, stops, or restarts the poll goroutines based on cfg.
 func (m *Monitor) Configure(cfg Config) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -73,7 +192,11 @@ func (m *Monitor) Configure(cfg Config) {
 
 	m.config = &cfg
 
-	if !cfg.Enabled || cfg.Source == "" {
+	pollSource := cfg.Enabled && cfg.Source != ""
+	streamHealth := cfg.Enabled && cfg.StreamHealth
+	audio := cfg.Enabled && len(cfg.AudioInputs) > 0
+	streamState := cfg.Enabled && cfg.StreamState
+	if !pollSource && !streamHealth && !audio && !streamState {
 		log.Printf("[monitor] Disabled (source=%q, enabled=%v)", cfg.Source, cfg.Enabled)
 		return
 	}
@@ -85,11 +208,126 @@ func (m *Monitor) Configure(cfg Config) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 	m.pollCancel = cancel
-	m.pollDone = make(chan struct{})
+	done := make(chan struct{})
+	m.pollDone = done
+
+	poolSize := cfg.PoolSize
+	if poolSize < 1 {
+		poolSize = 1
+	}
+	if poolSize > MaxPoolSize {
+		poolSize = MaxPoolSize
+	}
+	pool := obs.NewPool(poolSize, m.dialOBS)
+
+	// The loops share the pool, which is closed once they have exited
+	var wg sync.WaitGroup
+	if pollSource {
+		filter := newStateFilter(cfg.StableCount, time.Duration(cfg.MinReportIntervalMs)*time.Millisecond)
+
+		stateMap := stateMapWith(cfg.StateMap)
+
+		log.Printf("[monitor] Configured: source=%s, interval=%dms, watchScenes=%v, pool=%d, stable=%d, minReport=%v",
+			cfg.Source, interval.Milliseconds(), cfg.WatchSceneChanges, poolSize, filter.stable, filter.minInterval)
+		if len(cfg.StateMap) > 0 {
+			log.Printf("[monitor] State map overrides: %v", cfg.StateMap)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.pollLoop(ctx, cfg.Source, interval, cfg.WatchSceneChanges, pool, filter, stateMap)
+		}()
+	}
+	if streamHealth {
+		healthInterval := time.Duration(cfg.StreamHealthIntervalMs) * time.Millisecond
+		if healthInterval <= 0 {
+			healthInterval = DefaultStreamHealthInterval
+		}
+		if healthInterval < minPollInterval {
+			healthInterval = minPollInterval
+		}
+		log.Printf("[monitor] Stream health every %dms while streaming", healthInterval.Milliseconds())
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.healthLoop(ctx, healthInterval, pool)
+		}()
+	}
+	if streamState {
+		stateInterval := time.Duration(cfg.StreamStateIntervalMs) * time.Millisecond
+		if stateInterval <= 0 {
+			stateInterval = DefaultStreamStateInterval
+		}
+		if stateInterval < minPollInterval {
+			stateInterval = minPollInterval
+		}
+		log.Printf("[monitor] Stream/record state every %dms", stateInterval.Milliseconds())
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.outputLoop(ctx, stateInterval, pool)
+		}()
+	}
+	if audio {
+		window := time.Duration(cfg.AudioWindowMs) * time.Millisecond
+		log.Printf("[monitor] Audio: inputs=%q, thresholdDb=%v, windowMs=%d", cfg.AudioInputs, cfg.AudioThresholdDb, cfg.AudioWindowMs)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.audioLoop(ctx, cfg.AudioInputs, cfg.AudioThresholdDb, window, pool)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		pool.Close()
+		close(done)
+	}()
+}
 
-	log.Printf("[monitor] Configured: source=%s, interval=%dms", cfg.Source, interval.Milliseconds())
+// SetSharedClient sends the monitor's requests through c — the bridge's
+// own OBS connection — instead of monitor connections of its own, so OBS
+// sees one client per agent. Nil goes back to dedicated connections; the
+// audio watch always keeps one, as it needs OBS events. Call before
+// Configure.
+func (m *Monitor) SetSharedClient(c *obs.Client) {
+	if c != nil {
+		c.OnResponse(m.observeResponse)
+	}
+	m.shared.Store(c)
+}
+
+// InvalidateSceneMap drops the cached scene map so the next poll rebuilds it.
+// Called when OBS switches scene collections or scenes and scene items
+// change. Safe from any goroutine.
+func (m *Monitor) InvalidateSceneMap() {
+	m.sceneMapStale.Store(true)
+}
 
-	go m.pollLoop(ctx, cfg.Source, interval)
+// SetOBSAvailable is the bridge's OBS-availability signal. While OBS is
+// unavailable the poll loop stops polling and reports one consolidated
+// offline state instead of a burst of per-poll offline events; when it is
+// available again the loop polls at once, skipping any connect backoff.
+// Safe from any goroutine.
+func (m *Monitor) SetOBSAvailable(available bool) {
+	if m.obsDown.Swap(!available) == !available {
+		return
+	}
+	if available {
+		log.Println("[monitor] OBS available again — resuming polls")
+		m.dialMu.Lock()
+		m.dialRetryAt = time.Time{}
+		m.dialMu.Unlock()
+		select {
+		case m.obsBack <- struct{}{}:
+		default:
+		}
+	} else {
+		log.Println("[monitor] OBS unavailable — pausing polls")
+	}
 }
 
 // Stop stops the poll goroutine and closes any monitor OBS connection.
@@ -111,19 +349,73 @@ func (m *Monitor) stopLocked() {
 	}
 }
 
-// pollLoop runs the ticker-based poll. It manages its own OBS connection.
-func (m *Monitor) pollLoop(ctx context.Context, source string, interval time.Duration) {
-	defer close(m.pollDone)
+// dialOBS opens one event-suppressed monitor connection for the pool.
+// After a failed connect it returns errConnBackoff until the backoff
+// delay has passed, so the loops do not each retry on every tick.
+func (m *Monitor) dialOBS(ctx context.Context) (*obs.Client, error) {
+	m.dialMu.Lock()
+	defer m.dialMu.Unlock()
+	if m.now().Before(m.dialRetryAt) {
+		return nil, errConnBackoff
+	}
 
-	var obsConn *websocket.Conn
-	defer func() {
-		if obsConn != nil {
-			obsConn.Close()
+	c, err := m.dial(ctx, m.obsAddr, m.obsPass, 0)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, err
 		}
-	}()
+		delay := backoff.Delay(m.dialFailures, connRetryBase, connRetryMax)
+		m.dialFailures++
+		m.dialRetryAt = m.now().Add(delay)
+		log.Printf("[monitor] OBS connect failed: %v — retrying in %v (attempt %d)", err, delay.Round(100*time.Millisecond), m.dialFailures)
+		return nil, fmt.Errorf("%w: %w", errConnBackoff, err)
+	}
+	if m.dialFailures > 0 {
+		log.Printf("[monitor] OBS connection restored after %d failed attempts", m.dialFailures)
+		m.dialFailures, m.dialRetryAt = 0, time.Time{}
+	}
+	c.OnResponse(m.observeResponse)
+	log.Println("[monitor] OBS monitor connection established")
+	return c, nil
+}
+
+// withConn runs fn with the bridge's connection (see SetSharedClient) or,
+// without one, a pooled connection. A pooled connection that failed is
+// dropped so the next Get dials a fresh one.
+func (m *Monitor) withConn(ctx context.Context, pool *obs.Pool, fn func(*obs.Client) error) error {
+	if client := m.shared.Load(); client != nil {
+		return fn(client)
+	}
+
+	c, err := pool.Get(ctx)
+	if err != nil {
+		return err
+	}
+	err = fn(c)
+	pool.Put(c, err)
+	return err
+}
+
+// pollLoop runs the ticker-based source poll until ctx is done. OBS media
+// states are reported as stateMap gives them, through filter; see
+// stateFilter.
+func (m *Monitor) pollLoop(ctx context.Context, source string, interval time.Duration, watchScenes bool, pool *obs.Pool, filter *stateFilter, stateMap map[string]string) {
+
+	report := func(mediaState, state, containingScene string) {
+		if filter.observe(state, mediaState+"\x00"+containingScene, time.Now()) {
+			m.sendState(source, mediaState, state, containingScene, "")
+		}
+	}
 
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	adaptive := newAdaptiveInterval(interval)
+	m.pollInterval.Store(interval.Milliseconds())
+
+	// unreachableSent is true once the consolidated offline state went out
+	unreachableSent := false
+	// programScene is the last program scene reported (watchScenes only)
+	programScene := ""
 
 	for {
 		select {
@@ -131,143 +423,145 @@ func (m *Monitor) pollLoop(ctx context.Context, source string, interval time.Dur
 			log.Println("[monitor] Poll loop stopped")
 			return
 		case <-ticker.C:
-			// Ensure OBS monitor connection exists
-			if obsConn == nil {
-				var err error
-				obsConn, err = obs.ConnectMonitor(ctx, m.obsAddr, m.obsPass)
-				if err != nil {
-					log.Printf("[monitor] OBS connect failed: %v", err)
-					m.sendState(source, "", "offline", "")
-					continue
-				}
-				log.Println("[monitor] OBS monitor connection established")
-			}
+		case <-m.obsBack: // the bridge reached OBS again: retry now
+		}
 
-			// Refresh scene map (cached 30s) to find which scene contains this source
-			m.refreshSceneMap(obsConn)
-			containingScene := ""
-			if m.sceneMap != nil {
-				containingScene = m.sceneMap[source]
+		if m.obsDown.Load() {
+			if !unreachableSent {
+				pool.CloseIdle()
+				m.sendState(source, "", "offline", "", unreachableReason)
+				filter.force("offline", time.Now())
+				unreachableSent = true
 			}
+			continue
+		}
+		unreachableSent = false
 
-			mediaState, err := m.pollOBS(obsConn, source)
-			if err != nil {
-				log.Printf("[monitor] Poll error: %v", err)
-				obsConn.Close()
-				obsConn = nil
-				m.sendState(source, "", "offline", containingScene)
+		// Ensure at least one OBS monitor connection can be opened. While
+		// OBS refuses connections dialOBS retries with backoff rather than
+		// on every tick, and offline is reported once.
+		if err := m.withConn(ctx, pool, func(*obs.Client) error { return nil }); err != nil {
+			if ctx.Err() != nil {
 				continue
 			}
-
-			state := mediaStateMap[mediaState]
-			if state == "" {
-				state = "offline"
+			if filter.reported != "offline" {
+				m.sendState(source, "", "offline", "", "")
+				filter.force("offline", time.Now())
 			}
-			m.sendState(source, mediaState, state, containingScene)
+			continue
 		}
-	}
-}
 
-// pollOBS sends GetMediaInputStatus and reads the response.
-func (m *Monitor) pollOBS(conn *websocket.Conn, source string) (string, error) {
-	reqID := fmt.Sprintf("mon-%d", time.Now().UnixMilli())
-
-	req := map[string]interface{}{
-		"op": 6,
-		"d": map[string]interface{}{
-			"requestType": "GetMediaInputStatus",
-			"requestId":   reqID,
-			"requestData": map[string]interface{}{
-				"inputName": source,
-			},
-		},
-	}
-
-	data, err := json.Marshal(req)
-	if err != nil {
-		return "", fmt.Errorf("marshal request: %w", err)
-	}
-
-	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		return "", fmt.Errorf("write request: %w", err)
-	}
-
-	// Read response — skip non-op-7 messages (shouldn't happen with events suppressed, but be safe)
-	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
-	for i := 0; i < 10; i++ {
-		_, respData, err := conn.ReadMessage()
-		if err != nil {
-			return "", fmt.Errorf("read response: %w", err)
+		// Refresh scene map (cached 30s) to find which scene contains this source
+		m.refreshSceneMap(ctx, pool)
+		containingScene := ""
+		if m.sceneMap != nil {
+			containingScene = m.sceneMap[source]
 		}
 
-		var msg struct {
-			Op int `json:"op"`
-			D  struct {
-				RequestID    string                 `json:"requestId"`
-				ResponseData map[string]interface{} `json:"responseData"`
-			} `json:"d"`
+		if watchScenes {
+			var scene string
+			err := m.withConn(ctx, pool, func(c *obs.Client) (err error) {
+				scene, err = currentProgramScene(ctx, c)
+				return err
+			})
+			if err != nil {
+				log.Printf("[monitor] GetCurrentProgramScene failed: %v", err)
+			} else if scene != programScene {
+				m.sendSceneChanged(scene, programScene)
+				programScene = scene
+			}
 		}
-		if err := json.Unmarshal(respData, &msg); err != nil {
+
+		var mediaState string
+		var latency time.Duration
+		err := m.withConn(ctx, pool, func(c *obs.Client) (err error) {
+			mediaState, latency, err = pollOBS(ctx, c, source)
+			return err
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				continue // stopping
+			}
+			log.Printf("[monitor] Poll error: %v", err)
+			report("", "offline", containingScene)
 			continue
 		}
+		if next, changed := adaptive.observe(latency); changed {
+			ticker.Reset(next)
+			m.pollInterval.Store(next.Milliseconds())
+		}
 
-		if msg.Op == 7 && msg.D.RequestID == reqID {
-			if msg.D.ResponseData == nil {
-				return "OBS_MEDIA_STATE_NONE", nil
-			}
-			ms, _ := msg.D.ResponseData["mediaState"].(string)
-			if ms == "" {
-				return "OBS_MEDIA_STATE_NONE", nil
-			}
-			return ms, nil
+		state := stateMap[mediaState]
+		if state == "" {
+			state = "offline"
 		}
+		report(mediaState, state, containingScene)
 	}
+}
 
-	return "", fmt.Errorf("no matching response after 10 messages")
+// pollOBS sends GetMediaInputStatus and returns the media state and how
+// long OBS took to answer. A source OBS cannot report on (e.g. missing or
+// not a media input) is OBS_MEDIA_STATE_NONE.
+func pollOBS(ctx context.Context, c *obs.Client, source string) (string, time.Duration, error) {
+	sent := time.Now()
+	ms, err := c.GetMediaInputStatus(ctx, source)
+	var reqErr *obs.RequestError
+	if err != nil && !errors.As(err, &reqErr) {
+		return "", 0, err
+	}
+	latency := time.Since(sent)
+	if ms == "" {
+		ms = "OBS_MEDIA_STATE_NONE"
+	}
+	return ms, latency, nil
 }
 
 // refreshSceneMap walks all OBS scenes to build a sourceName → sceneName map.
-// Cached for 30 seconds to avoid excessive OBS calls.
-func (m *Monitor) refreshSceneMap(conn *websocket.Conn) {
+// Cached for 30 seconds to avoid excessive OBS calls, or until
+// InvalidateSceneMap on an OBS scene change. Per-scene item lists
+// are fetched in parallel across the pool.
+func (m *Monitor) refreshSceneMap(ctx context.Context, pool *obs.Pool) {
+	if m.sceneMapStale.Swap(false) {
+		m.sceneMap = nil
+	}
 	if time.Since(m.sceneMapAt) < 30*time.Second && m.sceneMap != nil {
 		return
 	}
 
-	scenes, err := m.obsRequest(conn, "GetSceneList", nil)
+	var sceneNames []string
+	err := m.withConn(ctx, pool, func(c *obs.Client) (err error) {
+		sceneNames, _, err = c.GetSceneList(ctx)
+		return err
+	})
 	if err != nil {
 		log.Printf("[monitor] refreshSceneMap GetSceneList failed: %v", err)
 		return
 	}
-
-	sceneList, _ := scenes["scenes"].([]interface{})
-	if len(sceneList) == 0 {
+	if len(sceneNames) == 0 {
 		return
 	}
 
-	newMap := make(map[string]string)
-	for _, s := range sceneList {
-		sc, _ := s.(map[string]interface{})
-		sceneName, _ := sc["sceneName"].(string)
-		if sceneName == "" {
-			continue
-		}
-
-		items, err := m.obsRequest(conn, "GetSceneItemList", map[string]interface{}{
-			"sceneName": sceneName,
-		})
-		if err != nil {
-			continue
-		}
+	// Fetch concurrently (bounded by the pool), merge in scene order so the
+	// first scene containing a source still wins
+	sourceLists := make([][]string, len(sceneNames))
+	var wg sync.WaitGroup
+	for i, sceneName := range sceneNames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.withConn(ctx, pool, func(c *obs.Client) (err error) {
+				sourceLists[i], err = c.GetSceneItemList(ctx, sceneName)
+				return err
+			})
+		}()
+	}
+	wg.Wait()
 
-		itemList, _ := items["sceneItems"].([]interface{})
-		for _, item := range itemList {
-			it, _ := item.(map[string]interface{})
-			srcName, _ := it["sourceName"].(string)
-			if srcName != "" {
-				if _, exists := newMap[srcName]; !exists {
-					newMap[srcName] = sceneName
-				}
+	newMap := make(map[string]string)
+	for i, sceneName := range sceneNames {
+		for _, srcName := range sourceLists[i] {
+			if _, exists := newMap[srcName]; !exists {
+				newMap[srcName] = sceneName
 			}
 		}
 	}
@@ -277,67 +571,82 @@ func (m *Monitor) refreshSceneMap(conn *websocket.Conn) {
 	log.Printf("[monitor] Scene map refreshed: %d sources mapped", len(newMap))
 }
 
-// obsRequest sends a request to OBS and reads the op 7 response.
-func (m *Monitor) obsRequest(conn *websocket.Conn, requestType string, requestData map[string]interface{}) (map[string]interface{}, error) {
-	reqID := fmt.Sprintf("mon-%s-%d", requestType, time.Now().UnixMilli())
-
-	d := map[string]interface{}{
-		"requestType": requestType,
-		"requestId":   reqID,
+// currentProgramScene returns the name of the active program scene.
+func currentProgramScene(ctx context.Context, c *obs.Client) (string, error) {
+	resp, err := c.Request(ctx, "GetCurrentProgramScene", nil)
+	if err != nil {
+		return "", err
 	}
-	if requestData != nil {
-		d["requestData"] = requestData
+	// Every v5 release returns currentProgramSceneName; sceneName is only
+	// a fallback for servers that leave it out.
+	name, _ := resp["currentProgramSceneName"].(string)
+	if name == "" {
+		name, _ = resp["sceneName"].(string)
 	}
-
-	req := map[string]interface{}{
-		"op": 6,
-		"d":  d,
+	if name == "" {
+		return "", fmt.Errorf("no scene name in response")
 	}
+	return name, nil
+}
 
-	data, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("marshal: %w", err)
+// observeResponse reports how long OBS took to answer a request to the
+// response hook.
+func (m *Monitor) observeResponse(d time.Duration) {
+	m.hookMu.Lock()
+	fn := m.onResponse
+	m.hookMu.Unlock()
+	if fn != nil {
+		fn(d)
 	}
+}
+
+// sendState builds an op 5 AgentSourceState event and calls sendEvent.
+// reason is optional context for the state (e.g. unreachableReason).
+func (m *Monitor) sendState(inputName, mediaState, state, containingScene, reason string) {
+	m.hookMu.Lock()
+	fn := m.sendEvent
+	m.hookMu.Unlock()
 
-	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-		return nil, fmt.Errorf("write: %w", err)
+	if fn == nil {
+		return
 	}
 
-	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
-	for i := 0; i < 10; i++ {
-		_, respData, err := conn.ReadMessage()
-		if err != nil {
-			return nil, fmt.Errorf("read: %w", err)
-		}
+	eventData := map[string]interface{}{
+		"inputName":       inputName,
+		"mediaState":      mediaState,
+		"state":           state,
+		"containingScene": containingScene,
 
-		var msg struct {
-			Op int `json:"op"`
-			D  struct {
-				RequestID    string                 `json:"requestId"`
-				ResponseData map[string]interface{} `json:"responseData"`
-			} `json:"d"`
-		}
-		if err := json.Unmarshal(respData, &msg); err != nil {
-			continue
-		}
+		"effectivePollIntervalMs": m.pollInterval.Load(),
+	}
+	if reason != "" {
+		eventData["reason"] = reason
+	}
 
-		if msg.Op == 7 && msg.D.RequestID == reqID {
-			if msg.D.ResponseData == nil {
-				return map[string]interface{}{}, nil
-			}
-			return msg.D.ResponseData, nil
-		}
+	event := map[string]interface{}{
+		"op": 5,
+		"d": map[string]interface{}{
+			"eventType":   "AgentSourceState",
+			"eventIntent": 1,
+			"eventData":   eventData,
+		},
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[monitor] Failed to marshal event: %v", err)
+		return
 	}
 
-	return nil, fmt.Errorf("no matching response")
+	fn(data)
 }
 
-// sendState builds an op 5 AgentSourceState event and calls sendEvent.
-func (m *Monitor) sendState(inputName, mediaState, state, containingScene string) {
-	m.mu.Lock()
+// sendSceneChanged builds an op 5 AgentSceneChanged event and calls sendEvent.
+// previousScene is empty for the first observation after (re)configuring.
+func (m *Monitor) sendSceneChanged(sceneName, previousScene string) {
+	m.hookMu.Lock()
 	fn := m.sendEvent
-	m.mu.Unlock()
+	m.hookMu.Unlock()
 
 	if fn == nil {
 		return
@@ -346,13 +655,11 @@ func (m *Monitor) sendState(inputName, mediaState, state, containingScene string
 	event := map[string]interface{}{
 		"op": 5,
 		"d": map[string]interface{}{
-			"eventType":   "AgentSourceState",
+			"eventType":   "AgentSceneChanged",
 			"eventIntent": 1,
 			"eventData": map[string]interface{}{
-				"inputName":       inputName,
-				"mediaState":      mediaState,
-				"state":           state,
-				"containingScene": containingScene,
+				"sceneName":     sceneName,
+				"previousScene": previousScene,
 			},
 		},
 	}
@@ -0,0 +1,182 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/obs"
+	"github.com/4throck/obs-agent/internal/obstest"
+)
+
+func startOBS(t *testing.T) *obstest.OBSTestServer {
+	t.Helper()
+	fakeOBS, err := obstest.NewServer("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fakeOBS.Close() })
+	return fakeOBS
+}
+
+func TestPollOBS(t *testing.T) {
+	fakeOBS := startOBS(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := obs.Dial(ctx, fakeOBS.Addr(), "hunter2", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		name     string
+		response interface{} // GetMediaInputStatus responseData; nil = request fails
+		want     string
+	}{
+		{"playing", map[string]interface{}{"mediaState": "OBS_MEDIA_STATE_PLAYING"}, "OBS_MEDIA_STATE_PLAYING"},
+		{"buffering", map[string]interface{}{"mediaState": "OBS_MEDIA_STATE_BUFFERING"}, "OBS_MEDIA_STATE_BUFFERING"},
+		{"no media state", map[string]interface{}{}, "OBS_MEDIA_STATE_NONE"},
+		{"not a media input", nil, "OBS_MEDIA_STATE_NONE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeOBS.SetResponse("GetMediaInputStatus", tt.response)
+			state, _, err := pollOBS(ctx, c, "Camera")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if state != tt.want {
+				t.Errorf("pollOBS = %q, want %q", state, tt.want)
+			}
+			rec := fakeOBS.RecordedRequests()
+			if last := rec[len(rec)-1]; string(last.RequestData) != `{"inputName":"Camera"}` {
+				t.Errorf("requestData %s", last.RequestData)
+			}
+		})
+	}
+
+	c.Close()
+	if _, _, err := pollOBS(ctx, c, "Camera"); err == nil {
+		t.Error("pollOBS on a closed connection succeeded")
+	}
+}
+
+// sourceStates collects the AgentSourceState events a monitor sends.
+type sourceStates struct {
+	mu     sync.Mutex
+	states []map[string]interface{}
+}
+
+func (s *sourceStates) send(data []byte) {
+	var ev struct {
+		D struct {
+			EventType string                 `json:"eventType"`
+			EventData map[string]interface{} `json:"eventData"`
+		} `json:"d"`
+	}
+	if json.Unmarshal(data, &ev) != nil || ev.D.EventType != "AgentSourceState" {
+		return
+	}
+	s.mu.Lock()
+	s.states = append(s.states, ev.D.EventData)
+	s.mu.Unlock()
+}
+
+func (s *sourceStates) get() []map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]map[string]interface{}(nil), s.states...)
+}
+
+// waitFor polls until cond holds for the collected states.
+func (s *sourceStates) waitFor(t *testing.T, what string, cond func([]map[string]interface{}) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond(s.get()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s; states %v", what, s.get())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func countPolls(fakeOBS *obstest.OBSTestServer) int {
+	n := 0
+	for _, r := range fakeOBS.RecordedRequests() {
+		if r.RequestType == "GetMediaInputStatus" {
+			n++
+		}
+	}
+	return n
+}
+
+// While the bridge reports OBS down the monitor stops polling and sends
+// one consolidated offline state, then resumes at once when OBS is back.
+func TestOBSUnavailableReportsOnce(t *testing.T) {
+	fakeOBS := startOBS(t)
+	states := &sourceStates{}
+	m := New(fakeOBS.Addr(), "hunter2")
+	m.SetSendEvent(states.send)
+	m.Configure(Config{Source: "Camera", Enabled: true, PollIntervalMs: 500, StableCount: 1, MinReportIntervalMs: -1})
+	defer m.Stop()
+
+	states.waitFor(t, "the first state", func(s []map[string]interface{}) bool { return len(s) > 0 })
+
+	m.SetOBSAvailable(false)
+	time.Sleep(100 * time.Millisecond) // a poll already under way may finish
+	polls, reported := countPolls(fakeOBS), len(states.get())
+	time.Sleep(1600 * time.Millisecond)
+
+	if n := countPolls(fakeOBS); n != polls {
+		t.Errorf("%d polls while OBS was unavailable", n-polls)
+	}
+	offline := states.get()[reported:]
+	if len(offline) != 1 || offline[0]["state"] != "offline" || offline[0]["reason"] != unreachableReason {
+		t.Fatalf("states while OBS was unavailable: %v, want one offline %s", offline, unreachableReason)
+	}
+
+	reported = len(states.get())
+	m.SetOBSAvailable(true)
+	states.waitFor(t, "polling to resume", func(s []map[string]interface{}) bool {
+		return len(s) > reported && s[len(s)-1]["state"] == "normal"
+	})
+}
+
+// Over a minute of OBS refusing connections, the monitor dials on its
+// backoff schedule, not on every poll.
+func TestDialBackoff(t *testing.T) {
+	clock := time.Unix(1700000000, 0)
+	dials := 0
+	m := New("127.0.0.1:1", "")
+	m.now = func() time.Time { return clock }
+	m.dial = func(context.Context, string, string, int) (*obs.Client, error) {
+		dials++
+		return nil, errors.New("connection refused")
+	}
+
+	// Every loop asks for a connection on every 500ms tick
+	ctx := context.Background()
+	for elapsed := time.Duration(0); elapsed < time.Minute; elapsed += minPollInterval {
+		clock = clock.Add(minPollInterval)
+		if _, err := m.dialOBS(ctx); !errors.Is(err, errConnBackoff) {
+			t.Fatalf("dialOBS error = %v, want errConnBackoff", err)
+		}
+	}
+	// 1s doubling to 30s, ±25% jitter: attempts at ~0, 1, 3, 7, 15, 31(, 46)s
+	if dials < 6 || dials > 7 {
+		t.Errorf("%d dials in 60s, want 6-7", dials)
+	}
+
+	// The bridge reaching OBS skips the rest of the backoff
+	dials = 0
+	m.SetOBSAvailable(false)
+	m.SetOBSAvailable(true)
+	m.dialOBS(ctx)
+	if dials != 1 {
+		t.Errorf("%d dials right after OBS came back, want 1", dials)
+	}
+}
@@ -0,0 +1,139 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/obs"
+)
+
+// DefaultStreamStateInterval is how often the stream and record outputs
+// are polled for AgentStreamState (Config.StreamStateIntervalMs 0).
+const DefaultStreamStateInterval = 2 * time.Second
+
+// outputState is one GetStreamStatus + GetRecordStatus reading.
+type outputState struct {
+	streaming          bool
+	streamReconnecting bool
+	streamBytes        float64
+	streamDuration     float64 // ms
+	recording          bool
+	recordPaused       bool
+	recordBytes        float64
+	recordDuration     float64 // ms
+}
+
+// changed reports whether o differs from prev in anything but the
+// counters, which move on every poll.
+func (o *outputState) changed(prev *outputState) bool {
+	return prev == nil ||
+		o.streaming != prev.streaming || o.streamReconnecting != prev.streamReconnecting ||
+		o.recording != prev.recording || o.recordPaused != prev.recordPaused
+}
+
+// outputLoop polls the stream and record outputs every interval and sends
+// AgentStreamState when one starts, stops, pauses or reconnects, and on
+// the first poll (again after OBS comes back).
+func (m *Monitor) outputLoop(ctx context.Context, interval time.Duration, pool *obs.Pool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var prev *outputState
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if m.obsDown.Load() {
+			prev = nil
+			continue
+		}
+
+		var cur *outputState
+		err := m.withConn(ctx, pool, func(c *obs.Client) (err error) {
+			cur, err = readOutputs(ctx, c)
+			return err
+		})
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, errConnBackoff) {
+				log.Printf("[monitor] Stream/record status failed: %v", err)
+			}
+			continue
+		}
+		if cur.changed(prev) {
+			m.sendStreamState(cur)
+		}
+		prev = cur
+	}
+}
+
+// readOutputs reads the stream and record output status.
+func readOutputs(ctx context.Context, c *obs.Client) (*outputState, error) {
+	stream, err := c.Request(ctx, "GetStreamStatus", nil)
+	if err != nil {
+		return nil, err
+	}
+	record, err := c.Request(ctx, "GetRecordStatus", nil)
+	if err != nil {
+		return nil, err
+	}
+	flag := func(resp map[string]interface{}, key string) bool {
+		v, _ := resp[key].(bool)
+		return v
+	}
+	num := func(resp map[string]interface{}, key string) float64 {
+		v, _ := resp[key].(float64)
+		return v
+	}
+	return &outputState{
+		streaming:          flag(stream, "outputActive"),
+		streamReconnecting: flag(stream, "outputReconnecting"),
+		streamBytes:        num(stream, "outputBytes"),
+		streamDuration:     num(stream, "outputDuration"),
+		recording:          flag(record, "outputActive"),
+		recordPaused:       flag(record, "outputPaused"),
+		recordBytes:        num(record, "outputBytes"),
+		recordDuration:     num(record, "outputDuration"),
+	}, nil
+}
+
+// sendStreamState builds an op 5 AgentStreamState event and calls sendEvent.
+func (m *Monitor) sendStreamState(s *outputState) {
+	m.hookMu.Lock()
+	fn := m.sendEvent
+	m.hookMu.Unlock()
+
+	if fn == nil {
+		return
+	}
+
+	event := map[string]interface{}{
+		"op": 5,
+		"d": map[string]interface{}{
+			"eventType":   "AgentStreamState",
+			"eventIntent": 1,
+			"eventData": map[string]interface{}{
+				"streaming":          s.streaming,
+				"streamReconnecting": s.streamReconnecting,
+				"streamBytes":        s.streamBytes,
+				"streamDurationMs":   s.streamDuration,
+				"recording":          s.recording,
+				"recordPaused":       s.recordPaused,
+				"recordBytes":        s.recordBytes,
+				"recordDurationMs":   s.recordDuration,
+			},
+		},
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("[monitor] Failed to marshal event: %v", err)
+		return
+	}
+
+	fn(data)
+}
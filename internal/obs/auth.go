@@ -18,6 +18,7 @@ type obsMessage struct {
 
 type helloData struct {
 	ObsWebSocketVersion string          `json:"obsWebSocketVersion"`
+	RPCVersion          int             `json:"rpcVersion"`
 	Authentication      *authChallenge  `json:"authentication,omitempty"`
 }
 
@@ -36,27 +37,42 @@ type identifiedData struct {
 	NegotiatedRPCVersion int `json:"negotiatedRpcVersion"`
 }
 
+// Info is what OBS reported during the handshake.
+type Info struct {
+	WebSocketVersion string // obs-websocket plugin version from Hello (e.g. "5.5.2")
+	RPCVersion       int    // negotiated RPC version from Identified
+}
+
 // authenticate performs OBS WebSocket v5 SHA256 challenge-response auth
-func authenticate(conn *websocket.Conn, password string) error {
+func authenticate(conn *websocket.Conn, password string) (Info, error) {
 	// Read Hello (op 0)
 	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
 	_, data, err := conn.ReadMessage()
 	if err != nil {
-		return fmt.Errorf("failed to read Hello: %w", err)
+		return Info{}, errHello(err)
+	}
+	if isLegacyMessage(data) {
+		return Info{}, ErrWebSocketV4
 	}
 
 	var hello obsMessage
 	if err := json.Unmarshal(data, &hello); err != nil {
-		return fmt.Errorf("failed to parse Hello: %w", err)
+		return Info{}, fmt.Errorf("failed to parse Hello: %w", err)
 	}
 
 	if hello.Op != 0 {
-		return fmt.Errorf("expected Hello (op 0), got op %d", hello.Op)
+		return Info{}, fmt.Errorf("expected Hello (op 0), got op %d", hello.Op)
 	}
 
 	var hd helloData
 	if err := json.Unmarshal(hello.D, &hd); err != nil {
-		return fmt.Errorf("failed to parse Hello data: %w", err)
+		return Info{}, fmt.Errorf("failed to parse Hello data: %w", err)
+	}
+	if err := checkRPCVersion(hd.RPCVersion); err != nil {
+		return Info{}, err
+	}
+	if err := checkVersion(hd.ObsWebSocketVersion); err != nil {
+		return Info{}, err
 	}
 
 	// Build Identify (op 1)
@@ -72,7 +88,7 @@ func authenticate(conn *websocket.Conn, password string) error {
 
 	identifyData, err := json.Marshal(identify)
 	if err != nil {
-		return fmt.Errorf("failed to marshal Identify: %w", err)
+		return Info{}, fmt.Errorf("failed to marshal Identify: %w", err)
 	}
 
 	msg := obsMessage{
@@ -82,40 +98,53 @@ func authenticate(conn *websocket.Conn, password string) error {
 
 	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 	if err := conn.WriteJSON(msg); err != nil {
-		return fmt.Errorf("failed to send Identify: %w", err)
+		return Info{}, fmt.Errorf("failed to send Identify: %w", err)
 	}
 
 	// Read Identified (op 2) or error
 	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
 	_, data, err = conn.ReadMessage()
 	if err != nil {
-		return fmt.Errorf("failed to read Identified: %w", err)
+		return Info{}, fmt.Errorf("failed to read Identified: %w", err)
 	}
 
 	var response obsMessage
 	if err := json.Unmarshal(data, &response); err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
+		return Info{}, fmt.Errorf("failed to parse response: %w", err)
 	}
 
 	if response.Op != 2 {
-		return fmt.Errorf("authentication failed (op %d)", response.Op)
+		return Info{}, fmt.Errorf("authentication failed (op %d)", response.Op)
+	}
+
+	var id identifiedData
+	json.Unmarshal(response.D, &id)
+	if err := checkRPCVersion(id.NegotiatedRPCVersion); err != nil {
+		return Info{}, err
 	}
 
 	// Clear deadlines for normal operation
 	conn.SetReadDeadline(time.Time{})
 	conn.SetWriteDeadline(time.Time{})
 
-	return nil
+	return Info{
+		WebSocketVersion: hd.ObsWebSocketVersion,
+		RPCVersion:       id.NegotiatedRPCVersion,
+	}, nil
 }
 
-// authenticateMonitor performs OBS WebSocket v5 auth with event subscriptions suppressed (0).
-// Used for the monitor's dedicated connection that only needs request-response.
-func authenticateMonitor(conn *websocket.Conn, password string) error {
+// authenticateMonitor performs OBS WebSocket v5 auth with the given event
+// subscriptions (0 suppresses all events). Used for the monitor's dedicated
+// connections, which mostly need request-response only.
+func authenticateMonitor(conn *websocket.Conn, password string, subscriptions int) error {
 	// Read Hello (op 0)
 	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
 	_, data, err := conn.ReadMessage()
 	if err != nil {
-		return fmt.Errorf("failed to read Hello: %w", err)
+		return errHello(err)
+	}
+	if isLegacyMessage(data) {
+		return ErrWebSocketV4
 	}
 
 	var hello obsMessage
@@ -131,12 +160,17 @@ func authenticateMonitor(conn *websocket.Conn, password string) error {
 	if err := json.Unmarshal(hello.D, &hd); err != nil {
 		return fmt.Errorf("failed to parse Hello data: %w", err)
 	}
+	if err := checkRPCVersion(hd.RPCVersion); err != nil {
+		return err
+	}
+	if err := checkVersion(hd.ObsWebSocketVersion); err != nil {
+		return err
+	}
 
-	// Build Identify (op 1) with EventSubscriptions = 0 to suppress all events
-	zero := 0
+	// Build Identify (op 1) with only the requested events (0 = none)
 	identify := identifyMsg{
 		RPCVersion:         1,
-		EventSubscriptions: &zero,
+		EventSubscriptions: &subscriptions,
 	}
 
 	if hd.Authentication != nil {
@@ -175,6 +209,12 @@ func authenticateMonitor(conn *websocket.Conn, password string) error {
 		return fmt.Errorf("authentication failed (op %d)", response.Op)
 	}
 
+	var id identifiedData
+	json.Unmarshal(response.D, &id)
+	if err := checkRPCVersion(id.NegotiatedRPCVersion); err != nil {
+		return err
+	}
+
 	// Clear deadlines for normal operation
 	conn.SetReadDeadline(time.Time{})
 	conn.SetWriteDeadline(time.Time{})
@@ -0,0 +1,322 @@
+package obs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// clientRequestTimeout bounds how long a Client request waits for OBS.
+const clientRequestTimeout = 5 * time.Second
+
+// ErrClientClosed is returned by Client requests once the connection the
+// client uses has gone away.
+var ErrClientClosed = errors.New("OBS client closed")
+
+// RequestError is a request OBS answered with a failed requestStatus. The
+// connection itself is fine.
+type RequestError struct {
+	RequestType string
+	Code        int
+	Comment     string
+}
+
+func (e *RequestError) Error() string {
+	if e.Comment != "" {
+		return fmt.Sprintf("%s failed (code %d): %s", e.RequestType, e.Code, e.Comment)
+	}
+	return fmt.Sprintf("%s failed (code %d)", e.RequestType, e.Code)
+}
+
+// response is one op 7 response to a Client request.
+type response struct {
+	status struct {
+		Result  bool   `json:"result"`
+		Code    int    `json:"code"`
+		Comment string `json:"comment,omitempty"`
+	}
+	data map[string]interface{}
+}
+
+// Client sends OBS requests and matches their op 7 responses by requestId.
+//
+// A Client from Dial or NewConnClient owns its connection and reads it in
+// a background pump, handing events to the OnEvent callback. A Client from
+// NewClient instead multiplexes over a connection another goroutine reads
+// and writes — the bridge's, which also carries the relay's traffic:
+// requests are written with write, which must serialise with the owner's
+// writes, and the owner's reader passes every message to Deliver.
+//
+// Request IDs carry a random per-client prefix, so a relay request cannot
+// pick an ID that steals a Client response.
+type Client struct {
+	write  func([]byte) error
+	prefix string
+	seq    atomic.Uint64
+	done   chan struct{}
+
+	// conn is the connection an owning Client reads and writes (nil for
+	// NewClient); writeMu serialises writes to it
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+
+	mu         sync.Mutex
+	pending    map[string]chan *response
+	closed     bool
+	err        error
+	onEvent    func(eventType string, eventData json.RawMessage)
+	onResponse func(time.Duration)
+}
+
+// NewClient creates a Client that sends its requests with write and gets
+// their responses from Deliver.
+func NewClient(write func([]byte) error) *Client {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return &Client{
+		write:   write,
+		prefix:  "agent-" + hex.EncodeToString(b) + "-",
+		done:    make(chan struct{}),
+		pending: make(map[string]chan *response),
+	}
+}
+
+// NewConnClient creates a Client that owns conn, an identified OBS
+// connection, and starts reading it. Close closes conn.
+func NewConnClient(conn *websocket.Conn) *Client {
+	c := NewClient(nil)
+	c.conn = conn
+	c.write = c.writeConn
+	go c.readPump()
+	return c
+}
+
+// Dial connects to OBS at addr subscribed to the given events (a mask of
+// EventSub* bits, 0 for none) and returns a Client owning the connection.
+func Dial(ctx context.Context, addr, password string, subscriptions int) (*Client, error) {
+	conn, err := ConnectEvents(ctx, addr, password, subscriptions)
+	if err != nil {
+		return nil, err
+	}
+	return NewConnClient(conn), nil
+}
+
+// OnEvent sets the callback for the OBS events an owning Client reads. It
+// runs on the read pump, so it must not block for long; events read before
+// it is set are dropped.
+func (c *Client) OnEvent(fn func(eventType string, eventData json.RawMessage)) {
+	c.mu.Lock()
+	c.onEvent = fn
+	c.mu.Unlock()
+}
+
+// OnResponse sets a callback that gets how long OBS took to answer each
+// request.
+func (c *Client) OnResponse(fn func(time.Duration)) {
+	c.mu.Lock()
+	c.onResponse = fn
+	c.mu.Unlock()
+}
+
+// Request sends one op 6 request and returns its responseData (empty, not
+// nil, when OBS sent none). It waits for the response until ctx is done,
+// the client closes or clientRequestTimeout passes. A failed requestStatus
+// is returned as a *RequestError.
+func (c *Client) Request(ctx context.Context, requestType string, requestData map[string]interface{}) (map[string]interface{}, error) {
+	id := c.prefix + fmt.Sprint(c.seq.Add(1))
+	d := map[string]interface{}{
+		"requestType": requestType,
+		"requestId":   id,
+	}
+	if requestData != nil {
+		d["requestData"] = requestData
+	}
+	data, err := json.Marshal(map[string]interface{}{"op": 6, "d": d})
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+
+	ch := make(chan *response, 1)
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClientClosed
+	}
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(data); err != nil {
+		return nil, fmt.Errorf("write: %w", err)
+	}
+	sent := time.Now()
+
+	timer := time.NewTimer(clientRequestTimeout)
+	defer timer.Stop()
+	select {
+	case resp, ok := <-ch:
+		if !ok {
+			return nil, ErrClientClosed
+		}
+		c.mu.Lock()
+		fn := c.onResponse
+		c.mu.Unlock()
+		if fn != nil {
+			fn(time.Since(sent))
+		}
+		if !resp.status.Result {
+			return nil, &RequestError{RequestType: requestType, Code: resp.status.Code, Comment: resp.status.Comment}
+		}
+		return resp.data, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+		return nil, fmt.Errorf("%s: no response within %v", requestType, clientRequestTimeout)
+	}
+}
+
+// requestInto is Request with the responseData decoded into v.
+func (c *Client) requestInto(ctx context.Context, requestType string, requestData map[string]interface{}, v interface{}) error {
+	resp, err := c.Request(ctx, requestType, requestData)
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%s: bad response: %w", requestType, err)
+	}
+	return nil
+}
+
+// Deliver hands one message read from the connection to the client. It
+// reports whether the message was the response to a Client request, which
+// the caller must then not pass on.
+func (c *Client) Deliver(data []byte) bool {
+	var msg struct {
+		Op int `json:"op"`
+		D  struct {
+			RequestID     string                 `json:"requestId"`
+			RequestStatus json.RawMessage        `json:"requestStatus"`
+			ResponseData  map[string]interface{} `json:"responseData"`
+		} `json:"d"`
+	}
+	if json.Unmarshal(data, &msg) != nil || msg.Op != 7 || !strings.HasPrefix(msg.D.RequestID, c.prefix) {
+		return false
+	}
+
+	c.mu.Lock()
+	ch := c.pending[msg.D.RequestID]
+	delete(c.pending, msg.D.RequestID)
+	c.mu.Unlock()
+
+	// A late response to a request that gave up is still ours: drop it
+	if ch != nil {
+		resp := &response{data: msg.D.ResponseData}
+		json.Unmarshal(msg.D.RequestStatus, &resp.status)
+		if resp.data == nil {
+			resp.data = map[string]interface{}{}
+		}
+		ch <- resp
+	}
+	return true
+}
+
+// writeConn writes one message to an owning Client's connection.
+func (c *Client) writeConn(data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.conn.SetWriteDeadline(time.Now().Add(clientRequestTimeout))
+	return c.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// readPump reads an owning Client's connection until it fails, delivering
+// responses and events, then closes the client.
+func (c *Client) readPump() {
+	c.conn.SetReadDeadline(time.Time{}) // idle connections may see no traffic
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			c.closeWith(fmt.Errorf("read: %w", err))
+			return
+		}
+		if c.Deliver(data) {
+			continue
+		}
+		var msg struct {
+			Op int `json:"op"`
+			D  struct {
+				EventType string          `json:"eventType"`
+				EventData json.RawMessage `json:"eventData"`
+			} `json:"d"`
+		}
+		if json.Unmarshal(data, &msg) != nil || msg.Op != 5 {
+			continue
+		}
+		c.mu.Lock()
+		fn := c.onEvent
+		c.mu.Unlock()
+		if fn != nil {
+			fn(msg.D.EventType, msg.D.EventData)
+		}
+	}
+}
+
+// Done is closed when the client closes, by Close or, for an owning
+// Client, when its connection fails.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns why the client closed (nil while it is open).
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Close fails the pending requests and every later one, and closes an
+// owning Client's connection.
+func (c *Client) Close() {
+	c.closeWith(ErrClientClosed)
+}
+
+func (c *Client) closeWith(err error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	c.err = err
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	close(c.done)
+	c.mu.Unlock()
+
+	if c.conn == nil {
+		return
+	}
+	if err == ErrClientClosed {
+		CloseConn(c.conn)
+	} else {
+		c.conn.Close() // the connection already failed
+	}
+}
@@ -0,0 +1,168 @@
+package obs_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/obs"
+	"github.com/4throck/obs-agent/internal/obstest"
+)
+
+// Requests issued concurrently over a shared connection each get their own
+// response, whatever order OBS answers in (run with -race).
+func TestClientMultiplexer(t *testing.T) {
+	const n = 16
+	written := make(chan []byte, n)
+	c := obs.NewClient(func(data []byte) error {
+		written <- data
+		return nil
+	})
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := fmt.Sprint("input-", i)
+			state, err := c.GetMediaInputStatus(context.Background(), input)
+			if err != nil {
+				errs <- err
+			} else if state != "state-of-"+input {
+				errs <- fmt.Errorf("%s got %q", input, state)
+			}
+		}(i)
+	}
+
+	// Answer in reverse order, with relay traffic mixed in
+	var reqs []map[string]interface{}
+	for i := 0; i < n; i++ {
+		var msg struct {
+			D map[string]interface{} `json:"d"`
+		}
+		if err := json.Unmarshal(<-written, &msg); err != nil {
+			t.Fatal(err)
+		}
+		reqs = append(reqs, msg.D)
+	}
+	if c.Deliver([]byte(`{"op":7,"d":{"requestId":"relay-1","requestStatus":{"result":true,"code":100}}}`)) {
+		t.Error("Deliver claimed a relay request's response")
+	}
+	for i := len(reqs) - 1; i >= 0; i-- {
+		input := reqs[i]["requestData"].(map[string]interface{})["inputName"]
+		resp, _ := json.Marshal(map[string]interface{}{
+			"op": 7,
+			"d": map[string]interface{}{
+				"requestType":   "GetMediaInputStatus",
+				"requestId":     reqs[i]["requestId"],
+				"requestStatus": map[string]interface{}{"result": true, "code": 100},
+				"responseData":  map[string]interface{}{"mediaState": fmt.Sprint("state-of-", input)},
+			},
+		})
+		if !c.Deliver(resp) {
+			t.Errorf("Deliver did not claim the response to %v", reqs[i]["requestId"])
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestClientAgainstFakeOBS(t *testing.T) {
+	fakeOBS, err := obstest.NewServer("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fakeOBS.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := obs.Dial(ctx, fakeOBS.Addr(), "wrong", 0); err == nil {
+		t.Fatal("Dial accepted a wrong password")
+	}
+	c, err := obs.Dial(ctx, fakeOBS.Addr(), "hunter2", obs.EventSubInputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	v, err := c.GetVersion(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.OBSWebSocketVersion != "5.3.0" || v.RPCVersion != 1 {
+		t.Errorf("GetVersion = %+v", v)
+	}
+
+	scenes, program, err := c.GetSceneList(ctx)
+	if err != nil || program != "Scene" || !reflect.DeepEqual(scenes, []string{"Scene"}) {
+		t.Errorf("GetSceneList = %v, %q, %v", scenes, program, err)
+	}
+
+	fakeOBS.SetResponse("GetSceneItemList", map[string]interface{}{
+		"sceneItems": []map[string]interface{}{{"sourceName": "Camera"}, {"sourceName": ""}, {"sourceName": "Mic"}},
+	})
+	sources, err := c.GetSceneItemList(ctx, "Scene")
+	if err != nil || !reflect.DeepEqual(sources, []string{"Camera", "Mic"}) {
+		t.Errorf("GetSceneItemList = %v, %v", sources, err)
+	}
+
+	state, err := c.GetMediaInputStatus(ctx, "Camera")
+	if err != nil || state != "OBS_MEDIA_STATE_PLAYING" {
+		t.Errorf("GetMediaInputStatus = %q, %v", state, err)
+	}
+	rec := fakeOBS.RecordedRequests()
+	if last := rec[len(rec)-1]; last.RequestType != "GetMediaInputStatus" || string(last.RequestData) != `{"inputName":"Camera"}` {
+		t.Errorf("OBS received %s %s", last.RequestType, last.RequestData)
+	}
+
+	// A failed request leaves the connection usable
+	_, err = c.Request(ctx, "NoSuchRequest", nil)
+	var reqErr *obs.RequestError
+	if !errors.As(err, &reqErr) || reqErr.Code != 204 {
+		t.Errorf("unknown request error = %v, want a *RequestError with code 204", err)
+	}
+	if c.Err() != nil {
+		t.Errorf("client closed after a failed request: %v", c.Err())
+	}
+
+	events := make(chan string, 1)
+	c.OnEvent(func(eventType string, _ json.RawMessage) {
+		select {
+		case events <- eventType:
+		default:
+		}
+	})
+	fakeOBS.SendEvent("InputMuteStateChanged", map[string]interface{}{"inputName": "Mic", "inputMuted": true})
+	select {
+	case ev := <-events:
+		if ev != "InputMuteStateChanged" {
+			t.Errorf("event %q", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("no event delivered")
+	}
+
+	// Losing the connection closes the client and fails later requests
+	fakeOBS.DisconnectClients()
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("client still open after OBS dropped it")
+	}
+	if c.Err() == nil {
+		t.Error("Err() = nil after the connection failed")
+	}
+	if _, err := c.GetVersion(ctx); !errors.Is(err, obs.ErrClientClosed) {
+		t.Errorf("request after close = %v, want ErrClientClosed", err)
+	}
+}
@@ -2,6 +2,7 @@ package obs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -14,6 +15,12 @@ const OBSReadTimeout = 90 * time.Second
 
 // Connect establishes a WebSocket connection to local OBS Studio
 func Connect(ctx context.Context, addr, password string) (*websocket.Conn, error) {
+	conn, _, err := ConnectInfo(ctx, addr, password)
+	return conn, err
+}
+
+// ConnectInfo is Connect that also returns the versions OBS reported.
+func ConnectInfo(ctx context.Context, addr, password string) (*websocket.Conn, Info, error) {
 	url := fmt.Sprintf("ws://%s", addr)
 
 	dialer := &websocket.Dialer{
@@ -22,27 +29,49 @@ func Connect(ctx context.Context, addr, password string) (*websocket.Conn, error
 
 	conn, _, err := dialer.DialContext(ctx, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("OBS WS dial failed: %w", err)
+		return nil, Info{}, fmt.Errorf("OBS WS dial failed: %w", err)
 	}
 
 	conn.SetReadLimit(1 * 1024 * 1024) // 1MB
 
 	// OBS WebSocket v5 always requires Hello/Identify handshake,
 	// even without a password (Identify still must be sent)
-	if err := authenticate(conn, password); err != nil {
+	info, err := authenticate(conn, password)
+	if err != nil {
 		conn.Close()
-		return nil, fmt.Errorf("OBS auth failed: %w", err)
+		if errors.Is(err, errNoHello) && isLegacyServer(ctx, url) {
+			err = ErrWebSocketV4
+		}
+		var verr *ErrUnsupportedVersion
+		if errors.As(err, &verr) || errors.Is(err, ErrWebSocketV4) {
+			return nil, Info{}, err
+		}
+		return nil, Info{}, fmt.Errorf("OBS auth failed: %w", err)
 	}
 
 	// Set initial read deadline — bridge resets on each successful read
 	conn.SetReadDeadline(time.Now().Add(OBSReadTimeout))
 
-	return conn, nil
+	return conn, info, nil
 }
 
+// Event subscription bits (obs-websocket EventSubscription) for ConnectEvents.
+const (
+	EventSubInputs = 1 << 3
+	// EventSubInputVolumeMeters is high-volume (every 50ms) and not part
+	// of OBS's "All".
+	EventSubInputVolumeMeters = 1 << 16
+)
+
 // ConnectMonitor establishes a WebSocket connection to local OBS with events suppressed.
 // Used for the monitor's dedicated polling connection (EventSubscriptions: 0).
 func ConnectMonitor(ctx context.Context, addr, password string) (*websocket.Conn, error) {
+	return ConnectEvents(ctx, addr, password, 0)
+}
+
+// ConnectEvents is ConnectMonitor subscribed to the given events (a mask of
+// EventSub* bits), for monitor features that follow OBS events.
+func ConnectEvents(ctx context.Context, addr, password string, subscriptions int) (*websocket.Conn, error) {
 	url := fmt.Sprintf("ws://%s", addr)
 
 	dialer := &websocket.Dialer{
@@ -56,8 +85,15 @@ func ConnectMonitor(ctx context.Context, addr, password string) (*websocket.Conn
 
 	conn.SetReadLimit(1 * 1024 * 1024) // 1MB
 
-	if err := authenticateMonitor(conn, password); err != nil {
+	if err := authenticateMonitor(conn, password, subscriptions); err != nil {
 		conn.Close()
+		if errors.Is(err, errNoHello) && isLegacyServer(ctx, url) {
+			err = ErrWebSocketV4
+		}
+		var verr *ErrUnsupportedVersion
+		if errors.As(err, &verr) || errors.Is(err, ErrWebSocketV4) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("OBS monitor auth failed: %w", err)
 	}
 
@@ -65,3 +101,16 @@ func ConnectMonitor(ctx context.Context, addr, password string) (*websocket.Conn
 
 	return conn, nil
 }
+
+// closeTimeout bounds sending the close frame in CloseConn.
+const closeTimeout = time.Second
+
+// CloseConn closes an OBS connection with a close handshake rather than a
+// bare TCP close, so OBS logs a clean disconnect. An OBS that is already
+// gone costs at most closeTimeout.
+func CloseConn(conn *websocket.Conn) {
+	conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, "agent disconnecting"),
+		time.Now().Add(closeTimeout))
+	conn.Close()
+}
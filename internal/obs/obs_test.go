@@ -0,0 +1,68 @@
+package obs_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/obs"
+	"github.com/4throck/obs-agent/internal/obstest"
+)
+
+func TestConnect(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverPass string
+		clientPass string
+		wantErr    bool
+	}{
+		{"no authentication", "", "", false},
+		{"password accepted", "hunter2", "hunter2", false},
+		{"wrong password", "hunter2", "wrong", true},
+		{"password missing", "hunter2", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeOBS, err := obstest.NewServer(tt.serverPass)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer fakeOBS.Close()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			conn, err := obs.Connect(ctx, fakeOBS.Addr(), tt.clientPass)
+			if tt.wantErr {
+				if err == nil {
+					conn.Close()
+					t.Fatal("connected with a bad password")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			// The identified connection carries requests
+			if err := conn.WriteJSON(map[string]interface{}{
+				"op": 6,
+				"d":  map[string]string{"requestType": "GetVersion", "requestId": "v"},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			var resp struct {
+				Op int `json:"op"`
+				D  struct {
+					RequestID string `json:"requestId"`
+				} `json:"d"`
+			}
+			if err := conn.ReadJSON(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Op != 7 || resp.D.RequestID != "v" {
+				t.Errorf("response %+v", resp)
+			}
+		})
+	}
+}
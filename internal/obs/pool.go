@@ -0,0 +1,105 @@
+package obs
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// DialFunc opens one OBS connection for a Pool (typically Dial with no
+// event subscriptions).
+type DialFunc func(ctx context.Context) (*Client, error)
+
+// Pool is a bounded set of request/response OBS connections for parallel
+// reads. At most size connections are checked out at once; Get blocks until
+// one is free. Connections are dialed lazily and reused until a request on
+// them fails.
+type Pool struct {
+	dial DialFunc
+	sem  chan struct{}
+
+	mu     sync.Mutex
+	idle   []*Client
+	closed bool
+}
+
+// NewPool creates a pool of up to size connections (minimum 1).
+func NewPool(size int, dial DialFunc) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	return &Pool{
+		dial: dial,
+		sem:  make(chan struct{}, size),
+	}
+}
+
+// Size returns the maximum number of concurrent connections.
+func (p *Pool) Size() int {
+	return cap(p.sem)
+}
+
+// Get checks out an idle connection, dialing a new one if none is idle,
+// and blocks while all size connections are in use.
+func (p *Pool) Get(ctx context.Context) (*Client, error) {
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	for n := len(p.idle); n > 0; n = len(p.idle) {
+		c := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		if c.Err() == nil {
+			p.mu.Unlock()
+			return c, nil
+		}
+	}
+	p.mu.Unlock()
+
+	c, err := p.dial(ctx)
+	if err != nil {
+		<-p.sem
+		return nil, err
+	}
+	return c, nil
+}
+
+// Put returns a connection checked out with Get. A non-nil err other than
+// a *RequestError (the connection failed, not just the request) closes the
+// connection instead of reusing it.
+func (p *Pool) Put(c *Client, err error) {
+	defer func() { <-p.sem }()
+
+	var reqErr *RequestError
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if (err != nil && !errors.As(err, &reqErr)) || c.Err() != nil || p.closed {
+		c.Close()
+		return
+	}
+	p.idle = append(p.idle, c)
+}
+
+// CloseIdle closes all idle connections. Checked-out connections are
+// unaffected and return to the pool as usual.
+func (p *Pool) CloseIdle() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+
+	for _, c := range idle {
+		c.Close()
+	}
+}
+
+// Close closes idle connections and makes Put close the rest.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.CloseIdle()
+}
@@ -0,0 +1,104 @@
+package obs_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/obs"
+)
+
+// slowOBS dials clients whose requests OBS answers after delay.
+type slowOBS struct {
+	delay time.Duration
+	dials atomic.Int32
+}
+
+func (s *slowOBS) dial(ctx context.Context) (*obs.Client, error) {
+	s.dials.Add(1)
+	var c *obs.Client
+	c = obs.NewClient(func(data []byte) error {
+		var msg struct {
+			D struct {
+				RequestType string `json:"requestType"`
+				RequestID   string `json:"requestId"`
+			} `json:"d"`
+		}
+		json.Unmarshal(data, &msg)
+		resp, _ := json.Marshal(map[string]interface{}{
+			"op": 7,
+			"d": map[string]interface{}{
+				"requestType":   msg.D.RequestType,
+				"requestId":     msg.D.RequestID,
+				"requestStatus": map[string]interface{}{"result": true, "code": 100},
+			},
+		})
+		time.AfterFunc(s.delay, func() { c.Deliver(resp) })
+		return nil
+	})
+	return c, nil
+}
+
+// run issues n requests through a pool of size from n goroutines and
+// returns how long they took and the most connections checked out at once.
+func (s *slowOBS) run(t *testing.T, size, n int) (time.Duration, int32) {
+	pool := obs.NewPool(size, s.dial)
+	defer pool.Close()
+
+	var inUse, maxInUse atomic.Int32
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := pool.Get(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			cur := inUse.Add(1)
+			for {
+				m := maxInUse.Load()
+				if cur <= m || maxInUse.CompareAndSwap(m, cur) {
+					break
+				}
+			}
+			_, err = c.Request(context.Background(), "GetMediaInputStatus", nil)
+			inUse.Add(-1)
+			pool.Put(c, err)
+		}()
+	}
+	wg.Wait()
+	return time.Since(start), maxInUse.Load()
+}
+
+func TestPoolParallelRequests(t *testing.T) {
+	const (
+		size  = 4
+		n     = 12
+		delay = 40 * time.Millisecond
+	)
+
+	serialOBS := &slowOBS{delay: delay}
+	serial, _ := serialOBS.run(t, 1, n)
+
+	parallelOBS := &slowOBS{delay: delay}
+	parallel, maxInUse := parallelOBS.run(t, size, n)
+
+	if parallel*2 > serial {
+		t.Errorf("pool of %d took %v, serial took %v", size, parallel, serial)
+	}
+	if maxInUse > size {
+		t.Errorf("%d connections in use at once, pool size %d", maxInUse, size)
+	}
+	if d := parallelOBS.dials.Load(); d > size {
+		t.Errorf("pool dialed %d connections, size %d", d, size)
+	}
+	if d := serialOBS.dials.Load(); d != 1 {
+		t.Errorf("pool of 1 dialed %d connections", d)
+	}
+}
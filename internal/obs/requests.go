@@ -0,0 +1,74 @@
+package obs
+
+import "context"
+
+// Typed helpers for the requests the agent itself makes. Relay requests
+// are passed through untouched and never go through these.
+
+// Version is the GetVersion response.
+type Version struct {
+	OBSVersion          string   `json:"obsVersion"`
+	OBSWebSocketVersion string   `json:"obsWebSocketVersion"`
+	RPCVersion          int      `json:"rpcVersion"`
+	AvailableRequests   []string `json:"availableRequests"`
+	// Platform is "windows", "macos", "linux" or similar
+	Platform string `json:"platform"`
+}
+
+// GetVersion returns the OBS and obs-websocket versions.
+func (c *Client) GetVersion(ctx context.Context) (*Version, error) {
+	var v Version
+	if err := c.requestInto(ctx, "GetVersion", nil, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// GetMediaInputStatus returns the OBS_MEDIA_STATE_* of a media input.
+func (c *Client) GetMediaInputStatus(ctx context.Context, inputName string) (string, error) {
+	var resp struct {
+		MediaState string `json:"mediaState"`
+	}
+	err := c.requestInto(ctx, "GetMediaInputStatus", map[string]interface{}{"inputName": inputName}, &resp)
+	return resp.MediaState, err
+}
+
+// GetSceneList returns the scene names in the order OBS lists them and the
+// current program scene.
+func (c *Client) GetSceneList(ctx context.Context) (scenes []string, program string, err error) {
+	var resp struct {
+		CurrentProgramSceneName string `json:"currentProgramSceneName"`
+		Scenes                  []struct {
+			SceneName string `json:"sceneName"`
+		} `json:"scenes"`
+	}
+	if err := c.requestInto(ctx, "GetSceneList", nil, &resp); err != nil {
+		return nil, "", err
+	}
+	for _, s := range resp.Scenes {
+		if s.SceneName != "" {
+			scenes = append(scenes, s.SceneName)
+		}
+	}
+	return scenes, resp.CurrentProgramSceneName, nil
+}
+
+// GetSceneItemList returns the source names of a scene's items, in the
+// order OBS lists them.
+func (c *Client) GetSceneItemList(ctx context.Context, sceneName string) ([]string, error) {
+	var resp struct {
+		SceneItems []struct {
+			SourceName string `json:"sourceName"`
+		} `json:"sceneItems"`
+	}
+	if err := c.requestInto(ctx, "GetSceneItemList", map[string]interface{}{"sceneName": sceneName}, &resp); err != nil {
+		return nil, err
+	}
+	sources := make([]string, 0, len(resp.SceneItems))
+	for _, it := range resp.SceneItems {
+		if it.SourceName != "" {
+			sources = append(sources, it.SourceName)
+		}
+	}
+	return sources, nil
+}
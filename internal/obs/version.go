@@ -0,0 +1,162 @@
+package obs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultMinVersion is the oldest obs-websocket release accepted. Older
+// builds lack some whitelisted requests and fail with confusing
+// "no matching response" errors instead of a clear one.
+const DefaultMinVersion = "5.0.0"
+
+// ValidateMinVersion checks that v is a version CheckMinVersion can use
+// as a floor (e.g. "5.3.0").
+func ValidateMinVersion(v string) error {
+	if _, ok := parseVersion(v); !ok {
+		return fmt.Errorf("invalid version %q (want e.g. 5.0.0)", v)
+	}
+	return nil
+}
+
+// CheckMinVersion returns an *ErrUnsupportedVersion when found, an
+// obsWebSocketVersion from Info, is older than min ("" =
+// DefaultMinVersion). Connect itself only enforces DefaultMinVersion;
+// callers with a higher floor (-min-obs-version) check the Info.
+func CheckMinVersion(found, min string) error {
+	if min == "" {
+		min = DefaultMinVersion
+	}
+	want, ok := parseVersion(min)
+	if !ok {
+		return fmt.Errorf("invalid minimum OBS WebSocket version %q", min)
+	}
+	got, ok := parseVersion(found)
+	if !ok || compareVersions(got, want) < 0 {
+		return &ErrUnsupportedVersion{Found: found, Min: min}
+	}
+	return nil
+}
+
+// ErrUnsupportedVersion is returned by Connect when OBS reports an
+// obs-websocket version below DefaultMinVersion, and by CheckMinVersion.
+type ErrUnsupportedVersion struct {
+	Found string // as reported in Hello; empty if missing
+	Min   string
+}
+
+func (e *ErrUnsupportedVersion) Error() string {
+	found := e.Found
+	if found == "" {
+		found = "no version"
+	}
+	return fmt.Sprintf("OBS WebSocket %s or newer required, found %s — update OBS Studio", e.Min, found)
+}
+
+// ErrWebSocketV4 is returned by Connect when the server does not speak the
+// v5 protocol (RPC version 1+): obs-websocket 4.x, the plugin for OBS < 28.
+var ErrWebSocketV4 = errors.New("the obs-websocket plugin is too old (4.x) — OBS 28+ required")
+
+// errNoHello is wrapped in the error of a connect that got no Hello in
+// time, as with obs-websocket 4.x, which sends none.
+var errNoHello = errors.New("no Hello from OBS")
+
+// errHello wraps a failure to read the Hello, marking a timeout errNoHello.
+func errHello(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("failed to read Hello: %w: %w", errNoHello, err)
+	}
+	return fmt.Errorf("failed to read Hello: %w", err)
+}
+
+// isLegacyMessage reports whether data has the shape of an obs-websocket
+// 4.x message (a request response or an event) rather than a v5 one.
+func isLegacyMessage(data []byte) bool {
+	var msg map[string]json.RawMessage
+	if json.Unmarshal(data, &msg) != nil {
+		return false
+	}
+	_, event := msg["update-type"]
+	_, response := msg["message-id"]
+	return event || response
+}
+
+// isLegacyServer reports whether the server at url answers an
+// obs-websocket 4.x GetVersion request. It uses a fresh connection, as a
+// timed-out Hello read leaves the first one unusable; 4.x answers
+// GetVersion without authentication.
+func isLegacyServer(ctx context.Context, url string) bool {
+	dialer := &websocket.Dialer{HandshakeTimeout: 5 * time.Second}
+	conn, _, err := dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+	probe := map[string]string{"request-type": "GetVersion", "message-id": "obs-agent-v4-probe"}
+	if err := conn.WriteJSON(probe); err != nil {
+		return false
+	}
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var resp struct {
+		MessageID string `json:"message-id"`
+	}
+	return conn.ReadJSON(&resp) == nil && resp.MessageID == probe["message-id"]
+}
+
+// checkRPCVersion rejects an RPC version below 1 from Hello or Identified.
+func checkRPCVersion(v int) error {
+	if v < 1 {
+		return ErrWebSocketV4
+	}
+	return nil
+}
+
+// checkVersion compares a Hello's obsWebSocketVersion with
+// DefaultMinVersion.
+func checkVersion(found string) error {
+	return CheckMinVersion(found, DefaultMinVersion)
+}
+
+// parseVersion parses "major[.minor[.patch]]", ignoring a pre-release or
+// build suffix ("5.0.0-beta1").
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if s == "" || len(parts) > 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,21 @@
+//go:build linux
+
+package obsdetect
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// configDirs returns the OBS config directories to look in: the native
+// install's, then the Flatpak's.
+func configDirs() []string {
+	var dirs []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		dirs = append(dirs, filepath.Join(dir, "obs-studio"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".var", "app", "com.obsproject.Studio", "config", "obs-studio"))
+	}
+	return dirs
+}
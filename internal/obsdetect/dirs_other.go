@@ -0,0 +1,18 @@
+//go:build !linux
+
+package obsdetect
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// configDirs returns the OBS config directory: %APPDATA%\obs-studio on
+// Windows, ~/Library/Application Support/obs-studio on macOS.
+func configDirs() []string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(dir, "obs-studio")}
+}
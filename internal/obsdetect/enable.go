@@ -0,0 +1,137 @@
+package obsdetect
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// passwordLength matches the passwords OBS generates itself.
+const passwordLength = 16
+
+// Enable turns the WebSocket server on in the file s was read from. When
+// authentication is off or has no password, it is switched on with a new
+// random one. The original file is first copied to BackupPath(s.Path).
+//
+// OBS keeps these settings in memory and may write them back when it
+// exits, so the change only sticks if OBS is closed now (see Running) or
+// restarted right away.
+func Enable(s *Settings) (*Settings, error) {
+	if s == nil || s.Path == "" {
+		return nil, fmt.Errorf("no OBS WebSocket settings file")
+	}
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(BackupPath(s.Path), data, info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+
+	password := s.Password
+	if password == "" {
+		if password, err = newPassword(); err != nil {
+			return nil, err
+		}
+	}
+	if strings.HasSuffix(s.Path, ".json") {
+		data, err = enableJSON(data, password)
+	} else {
+		data = enableINI(data, password)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Path, err)
+	}
+	if err := os.WriteFile(s.Path, data, info.Mode().Perm()); err != nil {
+		return nil, err
+	}
+	return &Settings{Enabled: true, Port: s.Port, Password: password, Path: s.Path}, nil
+}
+
+// BackupPath is where Enable copies the settings file at path first.
+func BackupPath(path string) string {
+	return path + ".bak"
+}
+
+// enableJSON sets the server keys in config.json, keeping the others.
+func enableJSON(data []byte, password string) ([]byte, error) {
+	var c map[string]any
+	if err := json.Unmarshal(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), &c); err != nil {
+		return nil, err
+	}
+	c["server_enabled"] = true
+	c["auth_required"] = true
+	c["server_password"] = password
+	return json.MarshalIndent(c, "", "    ")
+}
+
+// enableINI sets the server keys in the [OBSWebSocket] section, keeping
+// everything else (including the BOM and line endings) as it was.
+func enableINI(data []byte, password string) []byte {
+	nl := "\n"
+	if bytes.Contains(data, []byte("\r\n")) {
+		nl = "\r\n"
+	}
+	set := map[string]string{
+		"ServerEnabled":  "true",
+		"AuthRequired":   "true",
+		"ServerPassword": password,
+	}
+	order := []string{"ServerEnabled", "AuthRequired", "ServerPassword"}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\r\n"), nl)
+	var out []string
+	inSection := false
+	flush := func() {
+		for _, k := range order {
+			if v, ok := set[k]; ok {
+				out = append(out, k+"="+v)
+				delete(set, k)
+			}
+		}
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(strings.TrimPrefix(line, "\xef\xbb\xbf"))
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			if inSection {
+				flush()
+			}
+			inSection = trimmed == "[OBSWebSocket]"
+		} else if inSection {
+			if k, _, ok := strings.Cut(trimmed, "="); ok {
+				if v, ok := set[strings.TrimSpace(k)]; ok {
+					out = append(out, strings.TrimSpace(k)+"="+v)
+					delete(set, strings.TrimSpace(k))
+					continue
+				}
+			}
+		}
+		out = append(out, line)
+	}
+	if inSection {
+		flush()
+	}
+	return []byte(strings.Join(out, nl) + nl)
+}
+
+// newPassword returns a random alphanumeric password.
+func newPassword() (string, error) {
+	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, passwordLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		b[i] = chars[n.Int64()]
+	}
+	return string(b), nil
+}
@@ -0,0 +1,137 @@
+// Package obsdetect reads the WebSocket server settings OBS Studio keeps
+// in its own config, so setup can fill in the port and password for users
+// who never looked at Tools → WebSocket Server Settings.
+package obsdetect
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Settings is OBS's WebSocket server configuration.
+type Settings struct {
+	Enabled bool
+	Port    int
+	// Password is "" when authentication is off. It is a secret: never
+	// log it.
+	Password string
+	// Path is the file the settings were read from
+	Path string
+}
+
+// Where obs-websocket keeps its settings under the OBS config directory,
+// newest first: OBS 31+ user.ini, OBS 30 global.ini (both in the
+// [OBSWebSocket] section), and the plugin's config.json before that.
+var settingsFiles = []string{
+	"user.ini",
+	"global.ini",
+	filepath.Join("plugin_config", "obs-websocket", "config.json"),
+}
+
+// Read returns the WebSocket server settings of the OBS installation for
+// the current user. It fails with an error satisfying os.IsNotExist when
+// no OBS config with WebSocket settings is found.
+func Read() (*Settings, error) {
+	for _, dir := range configDirs() {
+		s, err := ReadDir(dir)
+		if err == nil || !os.IsNotExist(err) {
+			return s, err
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+// ReadDir reads the WebSocket server settings from the OBS config
+// directory dir (e.g. %APPDATA%\obs-studio).
+func ReadDir(dir string) (*Settings, error) {
+	for _, name := range settingsFiles {
+		path := filepath.Join(dir, name)
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // OBS writes a BOM on Windows
+
+		var s *Settings
+		if strings.HasSuffix(name, ".json") {
+			s, err = parseJSON(data)
+		} else {
+			s, err = parseINI(data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if s == nil {
+			continue // no [OBSWebSocket] section: an older OBS
+		}
+		s.Path = path
+		return s, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+// parseJSON parses obs-websocket's plugin_config config.json.
+func parseJSON(data []byte) (*Settings, error) {
+	var c struct {
+		ServerEnabled  bool   `json:"server_enabled"`
+		ServerPort     int    `json:"server_port"`
+		AuthRequired   bool   `json:"auth_required"`
+		ServerPassword string `json:"server_password"`
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return newSettings(c.ServerEnabled, c.ServerPort, c.AuthRequired, c.ServerPassword), nil
+}
+
+// parseINI parses the [OBSWebSocket] section of global.ini or user.ini,
+// or returns nil if there is none.
+func parseINI(data []byte) (*Settings, error) {
+	vals := make(map[string]string)
+	var section string
+	found := false
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			found = found || section == "OBSWebSocket"
+			continue
+		}
+		if section != "OBSWebSocket" {
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); ok {
+			vals[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	port, _ := strconv.Atoi(vals["ServerPort"])
+	return newSettings(vals["ServerEnabled"] == "true", port, vals["AuthRequired"] == "true", vals["ServerPassword"]), nil
+}
+
+// newSettings applies obs-websocket's defaults: port 4455, and no
+// password unless authentication is on.
+func newSettings(enabled bool, port int, authRequired bool, password string) *Settings {
+	if port <= 0 || port > 65535 {
+		port = 4455
+	}
+	if !authRequired {
+		password = ""
+	}
+	return &Settings{Enabled: enabled, Port: port, Password: password}
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package obsdetect
+
+import (
+	"errors"
+	"os/exec"
+	"runtime"
+)
+
+// Running reports whether an OBS process is running for any user.
+func Running() (bool, error) {
+	name := "obs"
+	if runtime.GOOS == "darwin" {
+		name = "OBS"
+	}
+	err := exec.Command("pgrep", "-x", name).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil // no match
+	}
+	return err == nil, err
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package obsdetect
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Running reports whether an OBS process is running for any user.
+func Running() (bool, error) {
+	cmd := exec.Command("tasklist", "/FO", "CSV", "/NH")
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	out, err := cmd.Output()
+	if err != nil {
+		return false, err
+	}
+	list := strings.ToLower(string(out))
+	return strings.Contains(list, `"obs64.exe"`) || strings.Contains(list, `"obs32.exe"`), nil
+}
@@ -0,0 +1,68 @@
+// Package obslaunch starts OBS Studio for unattended machines where the
+// agent should bring OBS up itself when it is not running.
+package obslaunch
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Respawn guard: at most maxLaunches launches in any launchWindow, so an
+// OBS that crashes on start (or never opens its WebSocket port) is not
+// started over and over.
+const (
+	maxLaunches  = 3
+	launchWindow = 10 * time.Minute
+)
+
+// ErrTooManyLaunches is returned by Launch while the respawn guard holds.
+var ErrTooManyLaunches = errors.New("OBS was launched too often recently")
+
+// Launcher starts OBS and remembers its recent launches. Keep one per
+// process so the respawn guard spans agent restarts.
+type Launcher struct {
+	mu       sync.Mutex
+	launches []time.Time
+}
+
+// New returns a Launcher.
+func New() *Launcher {
+	return &Launcher{}
+}
+
+// Launch starts path with args, detached from the agent, unless the
+// respawn guard holds. It returns once the process has started, not once
+// OBS is ready; on macOS path may be an application bundle.
+func (l *Launcher) Launch(path string, args []string) error {
+	l.mu.Lock()
+	now := time.Now()
+	recent := l.launches[:0]
+	for _, t := range l.launches {
+		if now.Sub(t) < launchWindow {
+			recent = append(recent, t)
+		}
+	}
+	l.launches = recent
+	if len(l.launches) >= maxLaunches {
+		retry := l.launches[0].Add(launchWindow).Sub(now)
+		l.mu.Unlock()
+		return fmt.Errorf("%w (%d in %v; next allowed in %v)", ErrTooManyLaunches, maxLaunches, launchWindow, retry.Round(time.Second))
+	}
+	l.launches = append(l.launches, now)
+	l.mu.Unlock()
+
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("OBS launch path: %w", err)
+	}
+	return start(path, args)
+}
+
+// InContainer reports whether the agent runs in a Docker container, where
+// OBS runs on the host and can never be launched.
+func InContainer() bool {
+	_, err := os.Stat("/.dockerenv")
+	return err == nil
+}
@@ -0,0 +1,23 @@
+//go:build darwin
+
+package obslaunch
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// start opens OBS through Launch Services (open -a), so it runs as a
+// normal app rather than a child of the agent. A path inside the bundle
+// (…/OBS.app/Contents/MacOS/OBS) opens the bundle.
+func start(path string, args []string) error {
+	if i := strings.Index(path, ".app/"); i >= 0 {
+		path = path[:i+len(".app")]
+	}
+	cmdArgs := []string{"-a", path}
+	if len(args) > 0 {
+		cmdArgs = append(cmdArgs, "--args")
+		cmdArgs = append(cmdArgs, args...)
+	}
+	return exec.Command("open", cmdArgs...).Run()
+}
@@ -0,0 +1,22 @@
+//go:build !windows && !darwin
+
+package obslaunch
+
+import (
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+// start runs OBS in a session of its own, so it outlives the agent and
+// does not get the agent's terminal signals.
+func start(path string, args []string) error {
+	cmd := exec.Command(path, args...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait() // reap it if it exits while the agent runs
+	return nil
+}
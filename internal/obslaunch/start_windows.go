@@ -0,0 +1,27 @@
+//go:build windows
+
+package obslaunch
+
+import (
+	"os/exec"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	detachedProcess       = 0x00000008
+	createNewProcessGroup = 0x00000200
+)
+
+// start runs obs64.exe detached from the agent's console. OBS finds its
+// locale and plugin data relative to the working directory, so it runs in
+// its own bin directory as the Start menu shortcut does.
+func start(path string, args []string) error {
+	cmd := exec.Command(path, args...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: detachedProcess | createNewProcessGroup}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
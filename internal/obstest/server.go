@@ -0,0 +1,361 @@
+// Package obstest provides a minimal in-process OBS WebSocket v5 server for
+// integration and end-to-end tests of the agent.
+package obstest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// OBS WebSocket v5 request status codes used by the fake server.
+const (
+	codeSuccess            = 100
+	codeUnknownRequestType = 204
+)
+
+// closeAuthFailed is the OBS close code for a bad Identify authentication.
+const closeAuthFailed = 4009
+
+// RecordedRequest is a single op 6 request (or batch entry) the server received.
+type RecordedRequest struct {
+	RequestType string
+	RequestID   string
+	RequestData json.RawMessage
+	Received    time.Time
+}
+
+// OBSTestServer is a fake OBS WebSocket v5 server listening on a random
+// loopback port. Each instance is independent, so tests may run in parallel.
+type OBSTestServer struct {
+	password string
+
+	ln     net.Listener
+	server *http.Server
+
+	mu        sync.Mutex
+	responses map[string]json.RawMessage
+	recorded  []RecordedRequest
+	conns     map[*websocket.Conn]struct{}
+	closed    bool
+}
+
+// defaultResponses covers the requests the agent, monitor and snapshot
+// collector issue.
+var defaultResponses = map[string]interface{}{
+	"GetVersion": map[string]interface{}{
+		"obsVersion":          "30.0.0",
+		"obsWebSocketVersion": "5.3.0",
+		"rpcVersion":          1,
+		"availableRequests":   []string{"GetVersion", "GetSceneList", "GetMediaInputStatus", "GetSceneItemList"},
+	},
+	"GetSceneList": map[string]interface{}{
+		"currentProgramSceneName": "Scene",
+		"currentPreviewSceneName": nil,
+		"scenes":                  []map[string]interface{}{{"sceneIndex": 0, "sceneName": "Scene"}},
+	},
+	"GetMediaInputStatus": map[string]interface{}{
+		"mediaState":    "OBS_MEDIA_STATE_PLAYING",
+		"mediaDuration": 60000,
+		"mediaCursor":   0,
+	},
+	"GetSceneItemList": map[string]interface{}{
+		"sceneItems": []map[string]interface{}{},
+	},
+	"GetStreamStatus": map[string]interface{}{
+		"outputActive": false,
+	},
+	"GetRecordStatus": map[string]interface{}{
+		"outputActive": false,
+	},
+	"GetStats": map[string]interface{}{
+		"cpuUsage":            1.5,
+		"memoryUsage":         300.0,
+		"activeFps":           60.0,
+		"renderSkippedFrames": 0,
+		"renderTotalFrames":   0,
+		"outputSkippedFrames": 0,
+		"outputTotalFrames":   0,
+	},
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// NewServer starts a fake OBS on 127.0.0.1 with a random port. An empty
+// password disables authentication, matching OBS's own behaviour.
+func NewServer(password string) (*OBSTestServer, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &OBSTestServer{
+		password:  password,
+		ln:        ln,
+		responses: make(map[string]json.RawMessage),
+		conns:     make(map[*websocket.Conn]struct{}),
+	}
+	for rt, data := range defaultResponses {
+		raw, _ := json.Marshal(data)
+		s.responses[rt] = raw
+	}
+
+	s.server = &http.Server{Handler: http.HandlerFunc(s.handleWS)}
+	go s.server.Serve(ln)
+	return s, nil
+}
+
+// Addr returns the host:port the server is listening on.
+func (s *OBSTestServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// SetResponse sets the responseData returned for requestType. Passing nil
+// makes the request fail with UnknownRequestType.
+func (s *OBSTestServer) SetResponse(requestType string, responseData interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if responseData == nil {
+		delete(s.responses, requestType)
+		return
+	}
+	raw, _ := json.Marshal(responseData)
+	s.responses[requestType] = raw
+}
+
+// RecordedRequests returns a copy of every request received so far, in order.
+func (s *OBSTestServer) RecordedRequests() []RecordedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]RecordedRequest, len(s.recorded))
+	copy(out, s.recorded)
+	return out
+}
+
+// SendEvent broadcasts an op 5 event to every identified client.
+func (s *OBSTestServer) SendEvent(eventType string, eventData interface{}) {
+	msg, _ := json.Marshal(map[string]interface{}{
+		"op": 5,
+		"d": map[string]interface{}{
+			"eventType":   eventType,
+			"eventIntent": 0,
+			"eventData":   eventData,
+		},
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		c.WriteMessage(websocket.TextMessage, msg)
+	}
+}
+
+// DisconnectClients drops every connected client but keeps listening, like
+// OBS does while switching scene collections.
+func (s *OBSTestServer) DisconnectClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.conns = map[*websocket.Conn]struct{}{}
+}
+
+// Close stops the listener and drops every client connection.
+func (s *OBSTestServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.conns = map[*websocket.Conn]struct{}{}
+	s.mu.Unlock()
+	return s.server.Close()
+}
+
+type message struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+}
+
+type request struct {
+	RequestType string          `json:"requestType"`
+	RequestID   string          `json:"requestId"`
+	RequestData json.RawMessage `json:"requestData,omitempty"`
+}
+
+func (s *OBSTestServer) handleWS(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if !s.handshake(conn) {
+		return
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+
+		var reply interface{}
+		switch msg.Op {
+		case 6:
+			var req request
+			if err := json.Unmarshal(msg.D, &req); err != nil {
+				continue
+			}
+			reply = map[string]interface{}{"op": 7, "d": s.respond(req)}
+		case 8:
+			var batch struct {
+				RequestID string    `json:"requestId"`
+				Requests  []request `json:"requests"`
+			}
+			if err := json.Unmarshal(msg.D, &batch); err != nil {
+				continue
+			}
+			results := make([]interface{}, 0, len(batch.Requests))
+			for _, req := range batch.Requests {
+				results = append(results, s.respond(req))
+			}
+			reply = map[string]interface{}{"op": 9, "d": map[string]interface{}{
+				"requestId": batch.RequestID,
+				"results":   results,
+			}}
+		default:
+			continue
+		}
+
+		out, _ := json.Marshal(reply)
+		s.mu.Lock()
+		conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		err = conn.WriteMessage(websocket.TextMessage, out)
+		s.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// handshake runs Hello → Identify → Identified. Returns false if the client
+// failed authentication or disconnected.
+func (s *OBSTestServer) handshake(conn *websocket.Conn) bool {
+	hello := map[string]interface{}{
+		"obsWebSocketVersion": "5.3.0",
+		"rpcVersion":          1,
+	}
+	var challenge, salt string
+	if s.password != "" {
+		challenge, salt = randomString(), randomString()
+		hello["authentication"] = map[string]string{"challenge": challenge, "salt": salt}
+	}
+	if err := conn.WriteJSON(map[string]interface{}{"op": 0, "d": hello}); err != nil {
+		return false
+	}
+
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return false
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Op != 1 {
+		return false
+	}
+	var identify struct {
+		RPCVersion     int    `json:"rpcVersion"`
+		Authentication string `json:"authentication"`
+	}
+	if err := json.Unmarshal(msg.D, &identify); err != nil {
+		return false
+	}
+
+	if s.password != "" && identify.Authentication != authString(s.password, salt, challenge) {
+		conn.WriteMessage(websocket.CloseMessage,
+			websocket.FormatCloseMessage(closeAuthFailed, "Authentication failed."))
+		return false
+	}
+
+	err = conn.WriteJSON(map[string]interface{}{
+		"op": 2,
+		"d":  map[string]int{"negotiatedRpcVersion": 1},
+	})
+	return err == nil
+}
+
+// respond records req and builds its op 7 response data.
+func (s *OBSTestServer) respond(req request) map[string]interface{} {
+	s.mu.Lock()
+	s.recorded = append(s.recorded, RecordedRequest{
+		RequestType: req.RequestType,
+		RequestID:   req.RequestID,
+		RequestData: req.RequestData,
+		Received:    time.Now(),
+	})
+	data, ok := s.responses[req.RequestType]
+	s.mu.Unlock()
+
+	d := map[string]interface{}{
+		"requestType": req.RequestType,
+		"requestId":   req.RequestID,
+	}
+	if !ok {
+		d["requestStatus"] = map[string]interface{}{
+			"result":  false,
+			"code":    codeUnknownRequestType,
+			"comment": "Your request type is not valid.",
+		}
+		return d
+	}
+	d["requestStatus"] = map[string]interface{}{"result": true, "code": codeSuccess}
+	d["responseData"] = data
+	return d
+}
+
+// authString mirrors the OBS v5 scheme: base64(sha256(base64(sha256(password+salt)) + challenge)).
+func authString(password, salt, challenge string) string {
+	h1 := sha256.Sum256([]byte(password + salt))
+	secret := base64.StdEncoding.EncodeToString(h1[:])
+	h2 := sha256.Sum256([]byte(secret + challenge))
+	return base64.StdEncoding.EncodeToString(h2[:])
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,399 @@
+// Package qr is a minimal QR code encoder for terminal display.
+//
+// It supports byte mode at error correction level L, versions 1–10
+// (up to 271 bytes) — enough for a verification URL. Output follows
+// ISO/IEC 18004; the mask is chosen by the standard penalty rules.
+package qr
+
+import "fmt"
+
+// MaxBytes is the longest payload Encode accepts (version 10, level L).
+const MaxBytes = 271
+
+// Code is an encoded QR symbol. Modules are addressed (x, y) from the
+// top-left corner; the quiet zone is not included.
+type Code struct {
+	Size    int
+	modules [][]bool
+}
+
+// Dark reports whether the module at (x, y) is dark. Out-of-range
+// coordinates are light, so callers can draw the quiet zone freely.
+func (c *Code) Dark(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// versionInfo holds the level L block structure for one version.
+type versionInfo struct {
+	total     int   // total codewords
+	ecPer     int   // EC codewords per block
+	blocks    int   // number of blocks
+	alignment []int // alignment pattern centre coordinates
+}
+
+var versions = [...]versionInfo{
+	1:  {26, 7, 1, nil},
+	2:  {44, 10, 1, []int{6, 18}},
+	3:  {70, 15, 1, []int{6, 22}},
+	4:  {100, 20, 1, []int{6, 26}},
+	5:  {134, 26, 1, []int{6, 30}},
+	6:  {172, 18, 2, []int{6, 34}},
+	7:  {196, 20, 2, []int{6, 22, 38}},
+	8:  {242, 24, 2, []int{6, 24, 42}},
+	9:  {292, 30, 2, []int{6, 26, 46}},
+	10: {346, 18, 4, []int{6, 28, 50}},
+}
+
+// Error correction level L format bits.
+const eclFormatBits = 1
+
+// Encode encodes text in byte mode using the smallest version that fits.
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	for v := 1; v < len(versions); v++ {
+		vi := versions[v]
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		capacity := (vi.total - vi.ecPer*vi.blocks) * 8
+		if 4+countBits+len(data)*8 > capacity {
+			continue
+		}
+		codewords := buildCodewords(data, countBits, capacity/8)
+		return newCode(v, interleave(codewords, vi)), nil
+	}
+	return nil, fmt.Errorf("qr: payload of %d bytes exceeds %d", len(data), MaxBytes)
+}
+
+// buildCodewords produces the padded data codewords for a byte-mode segment.
+func buildCodewords(data []byte, countBits, dataLen int) []byte {
+	var bb bitBuffer
+	bb.append(0x4, 4) // byte mode
+	bb.append(uint(len(data)), countBits)
+	for _, b := range data {
+		bb.append(uint(b), 8)
+	}
+	capacity := dataLen * 8
+	term := capacity - bb.len()
+	if term > 4 {
+		term = 4
+	}
+	bb.append(0, term)
+	bb.append(0, (8-bb.len()%8)%8)
+	for pad := uint(0xEC); bb.len() < capacity; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+	return bb.bytes()
+}
+
+// interleave splits data into blocks, appends Reed-Solomon EC codewords
+// and interleaves the result as the symbol expects.
+func interleave(data []byte, vi versionInfo) []byte {
+	numLong := vi.total % vi.blocks
+	numShort := vi.blocks - numLong
+	shortLen := vi.total/vi.blocks - vi.ecPer
+	divisor := rsDivisor(vi.ecPer)
+
+	dataBlocks := make([][]byte, vi.blocks)
+	ecBlocks := make([][]byte, vi.blocks)
+	off := 0
+	for i := range dataBlocks {
+		n := shortLen
+		if i >= numShort {
+			n++
+		}
+		dataBlocks[i] = data[off : off+n]
+		ecBlocks[i] = rsRemainder(dataBlocks[i], divisor)
+		off += n
+	}
+
+	out := make([]byte, 0, vi.total)
+	for i := 0; i <= shortLen; i++ {
+		for _, blk := range dataBlocks {
+			if i < len(blk) {
+				out = append(out, blk[i])
+			}
+		}
+	}
+	for i := 0; i < vi.ecPer; i++ {
+		for _, blk := range ecBlocks {
+			out = append(out, blk[i])
+		}
+	}
+	return out
+}
+
+// newCode lays out function patterns and data, then applies the best mask.
+func newCode(version int, codewords []byte) *Code {
+	size := version*4 + 17
+	m := &matrix{
+		size:     size,
+		modules:  newGrid(size),
+		function: newGrid(size),
+	}
+	m.drawFunctionPatterns(version)
+	m.drawCodewords(codewords)
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		m.applyMask(mask)
+		m.drawFormatBits(mask)
+		if p := m.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		m.applyMask(mask) // XOR again to undo
+	}
+	m.applyMask(best)
+	m.drawFormatBits(best)
+
+	return &Code{Size: size, modules: m.modules}
+}
+
+type matrix struct {
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+func newGrid(size int) [][]bool {
+	g := make([][]bool, size)
+	for i := range g {
+		g[i] = make([]bool, size)
+	}
+	return g
+}
+
+func (m *matrix) setFunction(x, y int, dark bool) {
+	m.modules[y][x] = dark
+	m.function[y][x] = true
+}
+
+func (m *matrix) drawFunctionPatterns(version int) {
+	size := m.size
+	for i := 0; i < size; i++ {
+		m.setFunction(6, i, i%2 == 0)
+		m.setFunction(i, 6, i%2 == 0)
+	}
+
+	m.drawFinder(3, 3)
+	m.drawFinder(size-4, 3)
+	m.drawFinder(3, size-4)
+
+	align := versions[version].alignment
+	last := len(align) - 1
+	for i, ay := range align {
+		for j, ax := range align {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue // overlaps a finder
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					m.setFunction(ax+dx, ay+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format area; real bits are drawn once the mask is known
+	m.drawFormatBits(0)
+
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 != 0
+			a, b := size-11+i%3, i/3
+			m.setFunction(a, b, dark)
+			m.setFunction(b, a, dark)
+		}
+	}
+}
+
+// drawFinder draws a finder pattern and its separator centred on (x, y).
+func (m *matrix) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= m.size || yy >= m.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			m.setFunction(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+func (m *matrix) drawFormatBits(mask int) {
+	data := eclFormatBits<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 != 0 }
+
+	size := m.size
+	for i := 0; i <= 5; i++ {
+		m.setFunction(8, i, bit(i))
+	}
+	m.setFunction(8, 7, bit(6))
+	m.setFunction(8, 8, bit(7))
+	m.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.setFunction(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		m.setFunction(size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.setFunction(8, size-15+i, bit(i))
+	}
+	m.setFunction(8, size-8, true) // always-dark module
+}
+
+// drawCodewords places data bits in the zigzag order from the bottom right.
+func (m *matrix) drawCodewords(data []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip the vertical timing column
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < m.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if upward {
+					y = m.size - 1 - vert
+				}
+				if m.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				m.modules[y][x] = (data[i>>3]>>(7-uint(i&7)))&1 != 0
+				i++
+			}
+		}
+	}
+}
+
+func (m *matrix) applyMask(mask int) {
+	for y := 0; y < m.size; y++ {
+		for x := 0; x < m.size; x++ {
+			if m.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				m.modules[y][x] = !m.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol using the four ISO/IEC 18004 rules.
+func (m *matrix) penalty() int {
+	size := m.size
+	at := func(x, y int, transpose bool) bool {
+		if transpose {
+			return m.modules[x][y]
+		}
+		return m.modules[y][x]
+	}
+
+	score := 0
+	finderLike := [][]bool{
+		{true, false, true, true, true, false, true, false, false, false, false},
+		{false, false, false, false, true, false, true, true, true, false, true},
+	}
+	for _, transpose := range []bool{false, true} {
+		for y := 0; y < size; y++ {
+			// Rule 1: runs of five or more same-colour modules
+			run := 1
+			for x := 1; x < size; x++ {
+				if at(x, y, transpose) == at(x-1, y, transpose) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					score += run - 2
+				}
+				run = 1
+			}
+			if run >= 5 {
+				score += run - 2
+			}
+
+			// Rule 3: finder-like patterns
+			for x := 0; x+11 <= size; x++ {
+				for _, pat := range finderLike {
+					match := true
+					for k, dark := range pat {
+						if at(x+k, y, transpose) != dark {
+							match = false
+							break
+						}
+					}
+					if match {
+						score += 40
+					}
+				}
+			}
+		}
+	}
+
+	// Rule 2: 2×2 blocks of one colour
+	dark := 0
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if m.modules[y][x] {
+				dark++
+			}
+			if x+1 < size && y+1 < size {
+				c := m.modules[y][x]
+				if c == m.modules[y][x+1] && c == m.modules[y+1][x] && c == m.modules[y+1][x+1] {
+					score += 3
+				}
+			}
+		}
+	}
+
+	// Rule 4: dark/light balance
+	total := size * size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	score += k * 10
+
+	return score
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
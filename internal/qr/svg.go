@@ -0,0 +1,33 @@
+package qr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SVG renders the code as a standalone SVG with a 4-module quiet zone,
+// one unit per module. Dark modules in the same row are merged into runs
+// to keep the markup small.
+func (c *Code) SVG() string {
+	const quiet = 4
+	dim := c.Size + 2*quiet
+
+	var path strings.Builder
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; {
+			if !c.Dark(x, y) {
+				x++
+				continue
+			}
+			start := x
+			for x < c.Size && c.Dark(x, y) {
+				x++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", start+quiet, y+quiet, x-start, x-start)
+		}
+	}
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="%s"/></svg>`,
+		dim, dim, dim, dim, path.String())
+}
@@ -0,0 +1,250 @@
+// Package relaytest implements the relay side of the agent protocol for
+// integration tests of the tunnel package: token header, session handshake
+// and signed envelopes, over WSS with a freshly generated self-signed cert.
+package relaytest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/tunnel"
+	"github.com/gorilla/websocket"
+)
+
+// CloseTokenRejected is the relay's close code for a refused token.
+const CloseTokenRejected = 4100
+
+// RelayTestServer is a fake relay. Each instance listens on its own random
+// loopback port, so tests may run in parallel.
+type RelayTestServer struct {
+	srv     *httptest.Server
+	rootCAs *x509.CertPool
+
+	mu        sync.Mutex
+	conn      *websocket.Conn
+	lastToken string
+	nonce     string
+	keys      *tunnel.SessionKeyHolder
+	cache     *tunnel.NonceCache
+	received  [][]byte
+	closeCode int
+	features  []string
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// NewServer starts a fake relay. With useTLS it serves WSS using a
+// self-signed certificate generated on the spot; pass RootCAs() to
+// tunnel.ConnectOptions so the agent trusts it.
+func NewServer(useTLS bool) (*RelayTestServer, error) {
+	s := &RelayTestServer{}
+	s.srv = httptest.NewUnstartedServer(http.HandlerFunc(s.handle))
+
+	if useTLS {
+		cert, pool, err := selfSignedCert()
+		if err != nil {
+			return nil, err
+		}
+		s.rootCAs = pool
+		s.srv.TLS = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS13,
+		}
+		s.srv.StartTLS()
+	} else {
+		s.srv.Start()
+	}
+	return s, nil
+}
+
+// URL returns the ws:// or wss:// address of the relay endpoint.
+func (s *RelayTestServer) URL() string {
+	if strings.HasPrefix(s.srv.URL, "https://") {
+		return "wss://" + strings.TrimPrefix(s.srv.URL, "https://") + "/ws/agent"
+	}
+	return "ws://" + strings.TrimPrefix(s.srv.URL, "http://") + "/ws/agent"
+}
+
+// RootCAs returns a pool trusting the server's certificate (nil without TLS).
+func (s *RelayTestServer) RootCAs() *x509.CertPool {
+	return s.rootCAs
+}
+
+// LastToken is the X-Agent-Token sent by the most recent connection.
+func (s *RelayTestServer) LastToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastToken
+}
+
+// SetCloseCode makes the next connection close with code right after the
+// upgrade, before the session handshake (e.g. CloseTokenRejected). 0 clears it.
+func (s *RelayTestServer) SetCloseCode(code int) {
+	s.mu.Lock()
+	s.closeCode = code
+	s.mu.Unlock()
+}
+
+// SetFeatures sets the envelope features advertised in the session message.
+func (s *RelayTestServer) SetFeatures(features ...string) {
+	s.mu.Lock()
+	s.features = features
+	s.mu.Unlock()
+}
+
+// SendToAgent seals payload with the current session key and sends it.
+func (s *RelayTestServer) SendToAgent(payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return errors.New("relaytest: no agent connected")
+	}
+	sealed, err := tunnel.Seal(s.keys, payload)
+	if err != nil {
+		return err
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	return s.conn.WriteMessage(websocket.TextMessage, sealed)
+}
+
+// Rekey sends a "rekey" message with a fresh nonce, sealed with the current
+// key, and switches to the new key. The old key keeps verifying agent
+// envelopes during the grace window.
+func (s *RelayTestServer) Rekey() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return errors.New("relaytest: no agent connected")
+	}
+	n := make([]byte, 16)
+	rand.Read(n)
+	s.nonce = hex.EncodeToString(n)
+	sealed, err := tunnel.Seal(s.keys, []byte(`{"type":"rekey","nonce":"`+s.nonce+`"}`))
+	if err != nil {
+		return err
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if err := s.conn.WriteMessage(websocket.TextMessage, sealed); err != nil {
+		return err
+	}
+	s.keys.Rotate(tunnel.DeriveSessionKey(s.lastToken, s.nonce))
+	return nil
+}
+
+// ReceivedFromAgent returns the verified payloads the agent has sent so far.
+func (s *RelayTestServer) ReceivedFromAgent() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([][]byte, len(s.received))
+	copy(out, s.received)
+	return out
+}
+
+// Close drops the agent and stops the server.
+func (s *RelayTestServer) Close() {
+	s.mu.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.mu.Unlock()
+	s.srv.Close()
+}
+
+func (s *RelayTestServer) handle(w http.ResponseWriter, r *http.Request) {
+	token := r.Header.Get("X-Agent-Token")
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	n := make([]byte, 16)
+	rand.Read(n)
+
+	s.mu.Lock()
+	s.lastToken = token
+	if code := s.closeCode; code != 0 {
+		s.closeCode = 0
+		s.mu.Unlock()
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(code, "refused"), time.Now().Add(time.Second))
+		return
+	}
+	s.conn = conn
+	s.nonce = hex.EncodeToString(n)
+	s.cache = tunnel.NewNonceCache(0, 0)
+	session := map[string]interface{}{"type": "session", "nonce": s.nonce, "server_time": time.Now().UnixMilli()}
+	if len(s.features) > 0 {
+		session["features"] = s.features
+	}
+	conn.WriteJSON(session)
+	conn.WriteJSON(map[string]string{"type": "connected"})
+	s.keys = tunnel.NewSessionKeyHolder(tunnel.DeriveSessionKey(token, s.nonce))
+	keys := s.keys
+	cache := s.cache
+	s.mu.Unlock()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		res := tunnel.Open(keys, data, cache)
+		if !res.Valid {
+			continue
+		}
+		s.mu.Lock()
+		s.received = append(s.received, res.Payload)
+		s.mu.Unlock()
+	}
+}
+
+// selfSignedCert generates an ECDSA P-256 certificate for 127.0.0.1/localhost.
+func selfSignedCert() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "relaytest"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool, nil
+}
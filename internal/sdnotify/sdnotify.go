@@ -0,0 +1,66 @@
+// Package sdnotify implements the systemd service notification protocol
+// (sd_notify(3)) without cgo or libsystemd. Every call is a no-op when
+// NOTIFY_SOCKET is unset, i.e. when not started by systemd as
+// Type=notify.
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Notify sends one or more newline-separated VAR=value assignments to the
+// service manager.
+func Notify(state string) error {
+	path := os.Getenv("NOTIFY_SOCKET")
+	if path == "" {
+		return nil
+	}
+	// A leading '@' names a socket in the Linux abstract namespace
+	if path[0] == '@' {
+		path = "\x00" + path[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Ready tells systemd that start-up is complete.
+func Ready() error {
+	return Notify("READY=1")
+}
+
+// Status sets the free-form status line shown by systemctl status.
+func Status(s string) error {
+	return Notify("STATUS=" + s)
+}
+
+// Stopping tells systemd the service is shutting down.
+func Stopping() error {
+	return Notify("STOPPING=1")
+}
+
+// Watchdog resets the service's watchdog timer.
+func Watchdog() error {
+	return Notify("WATCHDOG=1")
+}
+
+// WatchdogInterval returns the unit's WatchdogSec (from WATCHDOG_USEC), or
+// 0 when the watchdog is disabled or meant for another process. Ping at
+// half this interval.
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// stoppedTimeout bounds how long Stopped waits for the service manager
+// to acknowledge the final status.
+const stoppedTimeout = 5 * time.Second
+
+// Control links a process started by the Windows service manager to the
+// agent's shutdown path. Create it with StartControl.
+type Control struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+	exit     chan uint32
+	done     chan struct{}
+}
+
+func newControl() *Control {
+	return &Control{
+		stop: make(chan struct{}),
+		exit: make(chan uint32, 1),
+		done: make(chan struct{}),
+	}
+}
+
+// StopRequested is closed when the service manager asks the agent to stop
+// (Stop, or Shutdown when the machine is going down).
+func (c *Control) StopRequested() <-chan struct{} {
+	return c.stop
+}
+
+func (c *Control) requestStop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+// Stopped reports the agent's exit to the service manager and waits for
+// the dispatcher to return. A non-zero code is reported as a
+// service-specific error, which triggers the recovery actions. Call it
+// once, as the last thing before main returns.
+func (c *Control) Stopped(code uint32) {
+	c.exit <- code
+	select {
+	case <-c.done:
+	case <-time.After(stoppedTimeout):
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package service
+
+import "errors"
+
+// IsWindowsService reports whether the process was started by the Windows
+// service manager. Always false on this platform.
+func IsWindowsService() bool { return false }
+
+// StartControl is only supported on Windows.
+func StartControl() (*Control, error) {
+	return nil, errors.New("service control is only available on Windows")
+}
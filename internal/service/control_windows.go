@@ -0,0 +1,60 @@
+//go:build windows
+
+package service
+
+import (
+	"log"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// IsWindowsService reports whether the process was started by the service
+// manager rather than from a console or the Task Scheduler.
+func IsWindowsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// StartControl connects to the service manager and reports the service as
+// running. Must be called early: the SCM gives a new service 30 seconds
+// to connect before it gives up on it.
+func StartControl() (*Control, error) {
+	c := newControl()
+	go func() {
+		defer close(c.done)
+		if err := svc.Run(taskName, &handler{c: c}); err != nil {
+			log.Printf("[agent] Service dispatcher: %v", err)
+			c.requestStop()
+		}
+	}()
+	return c, nil
+}
+
+type handler struct {
+	c *Control
+}
+
+// Execute maps Stop and Shutdown onto Control.StopRequested and returns
+// once the agent calls Stopped.
+func (h *handler) Execute(_ []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown
+	s <- svc.Status{State: svc.StartPending}
+	s <- svc.Status{State: svc.Running, Accepts: accepts}
+
+	for {
+		select {
+		case code := <-h.c.exit:
+			s <- svc.Status{State: svc.StopPending}
+			return code != 0, code
+		case req := <-r:
+			switch req.Cmd {
+			case svc.Interrogate:
+				s <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				log.Println("[agent] Stop requested by the service manager")
+				s <- svc.Status{State: svc.StopPending, WaitHint: 10000}
+				h.c.requestStop()
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+//go:build windows
+
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// recoveryActions restart the service after a crash or a non-zero exit,
+// backing off a little each time; the failure count resets after a day.
+var recoveryActions = []mgr.RecoveryAction{
+	{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+	{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+	{Type: mgr.ServiceRestart, Delay: 2 * time.Minute},
+}
+
+const recoveryResetPeriod = 24 * 60 * 60 // seconds
+
+// installSCM creates an auto-start service running as LocalSystem. An
+// existing registration is updated in place so -install can be re-run
+// after moving the binary. LocalSystem cannot open the user's DPAPI key or
+// Credential Manager, so the caller shares the config first (see
+// agent.ShareWithService).
+func installSCM(binaryPath, configPath string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager (run as Administrator): %w", err)
+	}
+	defer m.Disconnect()
+
+	var args []string
+	if configPath != "" {
+		args = append(args, "-config", configPath)
+	}
+
+	s, err := m.OpenService(taskName)
+	if err == nil {
+		defer s.Close()
+		cfg, err := s.Config()
+		if err != nil {
+			return fmt.Errorf("read service config: %w", err)
+		}
+		cfg.BinaryPathName = commandLine(binaryPath, args)
+		cfg.StartType = mgr.StartAutomatic
+		if err := s.UpdateConfig(cfg); err != nil {
+			return fmt.Errorf("update service: %w", err)
+		}
+	} else {
+		s, err = m.CreateService(taskName, binaryPath, mgr.Config{
+			DisplayName: "4thRock OBS Agent",
+			Description: "Connects local OBS Studio to 4thRock Cloud.",
+			StartType:   mgr.StartAutomatic,
+		}, args...)
+		if err != nil {
+			return fmt.Errorf("create service: %w", err)
+		}
+		defer s.Close()
+	}
+
+	if err := s.SetRecoveryActions(recoveryActions, recoveryResetPeriod); err != nil {
+		return fmt.Errorf("set recovery actions: %w", err)
+	}
+	// Count a clean non-zero exit (e.g. after installing an update) as a failure too
+	if err := s.SetRecoveryActionsOnNonCrashFailures(true); err != nil {
+		return fmt.Errorf("set recovery actions: %w", err)
+	}
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("start service: %w", err)
+	}
+	return nil
+}
+
+// uninstallSCM stops the service if it is running and deletes it.
+func uninstallSCM() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager (run as Administrator): %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(taskName)
+	if err != nil {
+		return fmt.Errorf("open service: %w", err)
+	}
+	defer s.Close()
+
+	if st, err := s.Query(); err == nil && st.State != svc.Stopped {
+		if _, err := s.Control(svc.Stop); err != nil {
+			return fmt.Errorf("stop service: %w", err)
+		}
+		waitStopped(s, 15*time.Second)
+	}
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("delete service: %w", err)
+	}
+	return nil
+}
+
+func waitStopped(s *mgr.Service, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		st, err := s.Query()
+		if err != nil || st.State == svc.Stopped {
+			return
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// queryService looks the service up with query-only rights, so it works
+// without elevation (mgr.Connect asks for full access).
+func queryService() (exists, running bool) {
+	scm, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return false, false
+	}
+	defer windows.CloseServiceHandle(scm)
+
+	name, err := windows.UTF16PtrFromString(taskName)
+	if err != nil {
+		return false, false
+	}
+	h, err := windows.OpenService(scm, name, windows.SERVICE_QUERY_STATUS)
+	if err != nil {
+		return false, false
+	}
+	defer windows.CloseServiceHandle(h)
+
+	var st windows.SERVICE_STATUS
+	if err := windows.QueryServiceStatus(h, &st); err != nil {
+		return true, false
+	}
+	return true, st.CurrentState == windows.SERVICE_RUNNING
+}
+
+func scmInstalled() bool {
+	exists, _ := queryService()
+	return exists
+}
+
+func scmRunning() bool {
+	_, running := queryService()
+	return running
+}
+
+// commandLine quotes a binary path and arguments the way CreateService does.
+func commandLine(exe string, args []string) string {
+	s := windows.EscapeArg(exe)
+	for _, a := range args {
+		s += " " + windows.EscapeArg(a)
+	}
+	return s
+}
@@ -1,10 +1,50 @@
 package service
 
+import (
+	"fmt"
+	"runtime"
+)
+
+// Mode is how the agent is registered to start automatically.
+type Mode string
+
+const (
+	// ModeService is a system service: a Windows service managed by the
+	// SCM, a launchd agent on macOS, a systemd user unit on Linux or an
+	// rc.d script on FreeBSD and OpenBSD.
+	ModeService Mode = "service"
+	// ModeTask is a Windows Scheduled Task that starts the agent at logon
+	// in the user's session. Windows only.
+	ModeTask Mode = "task"
+)
+
+// DefaultMode is used when Install is given an empty mode.
+const DefaultMode = ModeService
+
+// ParseMode validates an -install-mode value.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case "":
+		return DefaultMode, nil
+	case ModeService, ModeTask:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown install mode %q (want %q or %q)", s, ModeService, ModeTask)
+	}
+}
+
 // Install registers the agent as a startup service for the current OS.
 // binaryPath is the absolute path to the agent binary.
 // configPath is the absolute path to the config file (may be empty).
-func Install(binaryPath, configPath string) error {
-	return install(binaryPath, configPath)
+// On Windows, installing in one mode removes a registration in the other.
+func Install(binaryPath, configPath string, mode Mode) error {
+	if mode == "" {
+		mode = DefaultMode
+	}
+	if mode == ModeTask && runtime.GOOS != "windows" {
+		return fmt.Errorf("install mode %q is only available on Windows", mode)
+	}
+	return install(binaryPath, configPath, mode)
 }
 
 // Uninstall removes the agent startup service.
@@ -12,7 +52,27 @@ func Uninstall() error {
 	return uninstall()
 }
 
-// IsInstalled returns whether the startup service is currently registered.
-func IsInstalled() bool {
+// IsInstalled returns whether the startup service is currently registered,
+// and in which mode.
+func IsInstalled() (Mode, bool) {
 	return isInstalled()
 }
+
+// State is the startup registration as the OS service manager sees it.
+type State struct {
+	Installed bool `json:"installed"`
+	Running   bool `json:"running"`
+	Mode      Mode `json:"mode,omitempty"`
+	// Path is the unit file, plist or rc.d script, or the service/task name
+	// on Windows.
+	Path string `json:"path,omitempty"`
+	// Managed reports whether this process was started by the service
+	// manager, as opposed to by hand next to an installed service.
+	Managed bool `json:"managed"`
+}
+
+// Status queries the service manager for the agent's registration. It
+// runs systemctl, launchctl, schtasks, service or rcctl, so avoid calling it in hot paths.
+func Status() State {
+	return status()
+}
@@ -0,0 +1,60 @@
+//go:build freebsd || openbsd
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+)
+
+// rcUser returns the account the rc.d service runs the agent as: the user
+// who ran -install through sudo or doas, whose config and keyring entry the
+// agent reads, or the current user when there is none.
+func rcUser() (*user.User, error) {
+	for _, env := range []string{"SUDO_USER", "DOAS_USER"} {
+		if name := os.Getenv(env); name != "" && name != "root" {
+			return user.Lookup(name)
+		}
+	}
+	return user.Current()
+}
+
+// requireRoot fails unless the process can write the rc.d directory.
+func requireRoot(elevate string) error {
+	if os.Geteuid() != 0 {
+		return errors.New("installing the rc.d service needs root (run it with " + elevate + ")")
+	}
+	return nil
+}
+
+// checkPaths rejects paths the rc.d script cannot hold: they end up inside
+// a double-quoted variable, where quoting does not stop these characters.
+func checkPaths(paths ...string) error {
+	for _, p := range paths {
+		if strings.ContainsAny(p, "\"$`\\\n") {
+			return fmt.Errorf("path %q cannot be used in an rc.d script", p)
+		}
+	}
+	return nil
+}
+
+// shellQuote quotes s for the rc.d script's shell, leaving plain paths
+// unquoted so they still match the running command line.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789/._-+:@") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// agentArgs is the agent's command line in the rc.d script.
+func agentArgs(binaryPath, configPath string) string {
+	args := shellQuote(binaryPath)
+	if configPath != "" {
+		args += " -config " + shellQuote(configPath)
+	}
+	return args
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
 const plistLabel = "cloud.4throck.obs-agent"
@@ -16,7 +17,7 @@ func plistPath() string {
 	return filepath.Join(home, "Library", "LaunchAgents", plistLabel+".plist")
 }
 
-func install(binaryPath, configPath string) error {
+func install(binaryPath, configPath string, _ Mode) error {
 	dir := filepath.Dir(plistPath())
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("create LaunchAgents dir: %w", err)
@@ -70,7 +71,26 @@ func uninstall() error {
 	return nil
 }
 
-func isInstalled() bool {
-	_, err := os.Stat(plistPath())
-	return err == nil
+func isInstalled() (Mode, bool) {
+	if _, err := os.Stat(plistPath()); err != nil {
+		return "", false
+	}
+	return ModeService, true
+}
+
+func status() State {
+	st := State{
+		Path: plistPath(),
+		// launchd names the job in the environment of processes it starts
+		Managed: os.Getenv("XPC_SERVICE_NAME") == plistLabel,
+	}
+	if _, err := os.Stat(st.Path); err != nil {
+		return st
+	}
+	st.Installed = true
+	st.Mode = ModeService
+	// A loaded job that is running lists its "PID" = n
+	out, err := exec.Command("launchctl", "list", plistLabel).Output()
+	st.Running = err == nil && strings.Contains(string(out), `"PID" =`)
+	return st
 }
@@ -0,0 +1,111 @@
+//go:build freebsd
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	rcScriptPath = "/usr/local/etc/rc.d/obs-agent"
+	// rcName is the rc.subr name; rc.conf variables cannot contain a dash
+	rcName = "obs_agent"
+)
+
+func install(binaryPath, configPath string, _ Mode) error {
+	if err := requireRoot("sudo"); err != nil {
+		return err
+	}
+	if err := checkPaths(binaryPath, configPath); err != nil {
+		return err
+	}
+	u, err := rcUser()
+	if err != nil {
+		return fmt.Errorf("look up service user: %w", err)
+	}
+
+	// daemon(8) drops to the user, keeps the pidfile and restarts the
+	// agent 10s after it exits
+	script := strings.Join([]string{
+		"#!/bin/sh",
+		"",
+		"# PROVIDE: " + rcName,
+		"# REQUIRE: LOGIN NETWORKING",
+		"# KEYWORD: shutdown",
+		"#",
+		"# 4thRock OBS Agent. Add to /etc/rc.conf:",
+		"#   " + rcName + `_enable="YES"`,
+		"",
+		". /etc/rc.subr",
+		"",
+		`name="` + rcName + `"`,
+		`rcvar="` + rcName + `_enable"`,
+		"",
+		"load_rc_config $name",
+		"",
+		`: ${` + rcName + `_enable:="NO"}`,
+		`: ${` + rcName + `_runas:="` + u.Username + `"}`,
+		"",
+		`pidfile="/var/run/${name}.pid"`,
+		`command="/usr/sbin/daemon"`,
+		`command_args="-f -r -R 10 -u ${` + rcName + `_runas} -P ${pidfile} ` + agentArgs(binaryPath, configPath) + `"`,
+		"",
+		`run_rc_command "$1"`,
+	}, "\n")
+
+	if err := os.WriteFile(rcScriptPath, []byte(script+"\n"), 0755); err != nil {
+		return fmt.Errorf("write rc script: %w", err)
+	}
+
+	if err := exec.Command("sysrc", rcName+"_enable=YES").Run(); err != nil {
+		return fmt.Errorf("enable service: %w", err)
+	}
+
+	return nil
+}
+
+func uninstall() error {
+	_ = exec.Command("service", "obs-agent", "onestop").Run()
+	_ = exec.Command("sysrc", "-x", rcName+"_enable").Run()
+
+	if err := os.Remove(rcScriptPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove rc script: %w", err)
+	}
+
+	return nil
+}
+
+func isInstalled() (Mode, bool) {
+	if _, err := os.Stat(rcScriptPath); err != nil {
+		return "", false
+	}
+	return ModeService, true
+}
+
+func status() State {
+	st := State{
+		Path: rcScriptPath,
+		// The rc script runs the agent under daemon(8)
+		Managed: parentCommand() == "daemon",
+	}
+	if _, err := os.Stat(st.Path); err != nil {
+		return st
+	}
+	st.Installed = true
+	st.Mode = ModeService
+	st.Running = exec.Command("service", "obs-agent", "onestatus").Run() == nil
+	return st
+}
+
+// parentCommand returns the command name of the parent process.
+func parentCommand() string {
+	out, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(os.Getppid())).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -21,7 +21,7 @@ func unitPath() string {
 	return filepath.Join(unitDir(), serviceName+".service")
 }
 
-func install(binaryPath, configPath string) error {
+func install(binaryPath, configPath string, _ Mode) error {
 	dir := unitDir()
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("create systemd user dir: %w", err)
@@ -39,7 +39,12 @@ func install(binaryPath, configPath string) error {
 		"Wants=network-online.target",
 		"",
 		"[Service]",
-		"Type=simple",
+		// The agent sends READY=1 once OBS and the relay are bridged, which
+		// may be much later than process start if OBS isn't running yet
+		"Type=notify",
+		"NotifyAccess=main",
+		"TimeoutStartSec=infinity",
+		"WatchdogSec=90",
 		"ExecStart=" + execStart,
 		"Restart=on-failure",
 		"RestartSec=10",
@@ -75,7 +80,24 @@ func uninstall() error {
 	return nil
 }
 
-func isInstalled() bool {
-	_, err := os.Stat(unitPath())
-	return err == nil
+func isInstalled() (Mode, bool) {
+	if _, err := os.Stat(unitPath()); err != nil {
+		return "", false
+	}
+	return ModeService, true
+}
+
+func status() State {
+	st := State{
+		Path: unitPath(),
+		// systemd sets INVOCATION_ID for every process it starts
+		Managed: os.Getenv("INVOCATION_ID") != "",
+	}
+	if _, err := os.Stat(st.Path); err != nil {
+		return st
+	}
+	st.Installed = true
+	st.Mode = ModeService
+	st.Running = exec.Command("systemctl", "--user", "is-active", "--quiet", serviceName).Run() == nil
+	return st
 }
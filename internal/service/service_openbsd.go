@@ -0,0 +1,93 @@
+//go:build openbsd
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// serviceName is the rc.d script and rcctl name; rcctl rejects names with
+// a dash, so it cannot be obs-agent as on the other platforms
+const serviceName = "obs_agent"
+
+const rcScriptPath = "/etc/rc.d/" + serviceName
+
+func install(binaryPath, configPath string, _ Mode) error {
+	if err := requireRoot("doas"); err != nil {
+		return err
+	}
+	if err := checkPaths(binaryPath, configPath); err != nil {
+		return err
+	}
+	u, err := rcUser()
+	if err != nil {
+		return fmt.Errorf("look up service user: %w", err)
+	}
+
+	flags := ""
+	if configPath != "" {
+		flags = "-config " + shellQuote(configPath)
+	}
+
+	// The agent stays in the foreground, so rc_bg backgrounds it
+	script := strings.Join([]string{
+		"#!/bin/ksh",
+		"#",
+		"# 4thRock OBS Agent",
+		"",
+		`daemon="` + shellQuote(binaryPath) + `"`,
+		`daemon_flags="` + flags + `"`,
+		`daemon_user="` + u.Username + `"`,
+		"",
+		". /etc/rc.d/rc.subr",
+		"",
+		"rc_bg=YES",
+		"rc_reload=NO",
+		"",
+		`rc_cmd "$1"`,
+	}, "\n")
+
+	if err := os.WriteFile(rcScriptPath, []byte(script+"\n"), 0555); err != nil {
+		return fmt.Errorf("write rc script: %w", err)
+	}
+
+	if err := exec.Command("rcctl", "enable", serviceName).Run(); err != nil {
+		return fmt.Errorf("enable service: %w", err)
+	}
+
+	return nil
+}
+
+func uninstall() error {
+	_ = exec.Command("rcctl", "stop", serviceName).Run()
+	_ = exec.Command("rcctl", "disable", serviceName).Run()
+
+	if err := os.Remove(rcScriptPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove rc script: %w", err)
+	}
+
+	return nil
+}
+
+func isInstalled() (Mode, bool) {
+	if _, err := os.Stat(rcScriptPath); err != nil {
+		return "", false
+	}
+	return ModeService, true
+}
+
+func status() State {
+	// rc.subr leaves nothing in the agent's environment to tell it was
+	// started by rc.d, so Managed stays false
+	st := State{Path: rcScriptPath}
+	if _, err := os.Stat(st.Path); err != nil {
+		return st
+	}
+	st.Installed = true
+	st.Mode = ModeService
+	st.Running = exec.Command("rcctl", "check", serviceName).Run() == nil
+	return st
+}
@@ -3,14 +3,78 @@
 package service
 
 import (
+	"encoding/csv"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strings"
 )
 
+// taskName is also the SCM service name, so either registration shows up
+// under the same name in Task Scheduler and services.msc.
 const taskName = "4thRockOBSAgent"
 
-func install(binaryPath, configPath string) error {
+func install(binaryPath, configPath string, mode Mode) error {
+	if mode == ModeTask {
+		if err := installTask(binaryPath, configPath); err != nil {
+			return err
+		}
+		if scmInstalled() {
+			return uninstallSCM()
+		}
+		return nil
+	}
+
+	if err := installSCM(binaryPath, configPath); err != nil {
+		return err
+	}
+	if taskInstalled() {
+		return uninstallTask()
+	}
+	return nil
+}
+
+func uninstall() error {
+	var errs []error
+	removed := false
+	if scmInstalled() {
+		errs = append(errs, uninstallSCM())
+		removed = true
+	}
+	if taskInstalled() {
+		errs = append(errs, uninstallTask())
+		removed = true
+	}
+	if !removed {
+		return fmt.Errorf("neither service nor scheduled task %s is installed", taskName)
+	}
+	return errors.Join(errs...)
+}
+
+func isInstalled() (Mode, bool) {
+	if scmInstalled() {
+		return ModeService, true
+	}
+	if taskInstalled() {
+		return ModeTask, true
+	}
+	return "", false
+}
+
+func status() State {
+	st := State{Managed: IsWindowsService()}
+	switch {
+	case scmInstalled():
+		st.Installed, st.Mode, st.Path = true, ModeService, taskName
+		st.Running = scmRunning()
+	case taskInstalled():
+		st.Installed, st.Mode, st.Path = true, ModeTask, `\`+taskName
+		st.Running = taskRunning()
+	}
+	return st
+}
+
+func installTask(binaryPath, configPath string) error {
 	args := binaryPath
 	if configPath != "" {
 		args += " -config " + configPath
@@ -31,7 +95,7 @@ func install(binaryPath, configPath string) error {
 	return nil
 }
 
-func uninstall() error {
+func uninstallTask() error {
 	cmd := exec.Command("schtasks.exe", "/Delete", "/TN", taskName, "/F")
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -40,7 +104,22 @@ func uninstall() error {
 	return nil
 }
 
-func isInstalled() bool {
+func taskInstalled() bool {
 	err := exec.Command("schtasks.exe", "/Query", "/TN", taskName).Run()
 	return err == nil
 }
+
+// taskRunning reports whether the task's status column says Running.
+// schtasks localizes the text, so on non-English systems this may report
+// false for a running task.
+func taskRunning() bool {
+	out, err := exec.Command("schtasks.exe", "/Query", "/TN", taskName, "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return false
+	}
+	rec, err := csv.NewReader(strings.NewReader(string(out))).Read()
+	if err != nil || len(rec) == 0 {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(rec[len(rec)-1]), "Running")
+}
@@ -0,0 +1,336 @@
+// Package snapshot keeps a cached model of OBS's scenes, their items, the
+// program scene and the output state, and pushes it to the relay as one
+// AgentSnapshot event so the dashboard need not query each scene itself.
+package snapshot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/obs"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// MaxSize bounds the snapshot JSON; larger ones are truncated (see Snapshot.Truncated).
+	MaxSize = 256 * 1024
+
+	refreshInterval = 60 * time.Second
+	// debounce coalesces bursts of OBS events (a scene collection switch
+	// sends dozens) into one refresh.
+	debounce       = 500 * time.Millisecond
+	requestTimeout = 5 * time.Second
+)
+
+// refreshEvents are the OBS events that change what the snapshot holds.
+var refreshEvents = map[string]bool{
+	"CurrentSceneCollectionChanged": true,
+	"CurrentProgramSceneChanged":    true,
+	"SceneCreated":                  true,
+	"SceneRemoved":                  true,
+	"SceneNameChanged":              true,
+	"SceneListChanged":              true,
+	"SceneItemCreated":              true,
+	"SceneItemRemoved":              true,
+	"SceneItemListReindexed":        true,
+	"SceneItemEnableStateChanged":   true,
+	"InputNameChanged":              true,
+	"StreamStateChanged":            true,
+	"RecordStateChanged":            true,
+}
+
+// Snapshot is the eventData of an AgentSnapshot event.
+type Snapshot struct {
+	CurrentProgramScene string  `json:"currentProgramScene"`
+	Scenes              []Scene `json:"scenes"`
+	Streaming           bool    `json:"streaming"`
+	Recording           bool    `json:"recording"`
+	// Truncated is set when scene items (and if need be scenes) were
+	// dropped to stay under MaxSize.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Scene is one scene and its items, in OBS's order.
+type Scene struct {
+	Name  string `json:"sceneName"`
+	Items []Item `json:"sceneItems"`
+}
+
+// Item is one scene item.
+type Item struct {
+	ID         int    `json:"sceneItemId"`
+	SourceName string `json:"sourceName"`
+	InputKind  string `json:"inputKind,omitempty"`
+	Enabled    bool   `json:"sceneItemEnabled"`
+}
+
+// Collector refreshes the snapshot over its own event-suppressed OBS
+// connection, on relevant events (see HandleEvent) and every minute.
+type Collector struct {
+	obsAddr string
+	obsPass string
+	trigger chan struct{}
+
+	mu         sync.Mutex
+	sendEvent  func([]byte) // pushes the AgentSnapshot event to the relay
+	onSnapshot func([]byte) // receives every refreshed snapshot (local copy)
+	last       []byte
+}
+
+// New creates a Collector. It does nothing until Run.
+func New(obsAddr, obsPass string) *Collector {
+	return &Collector{
+		obsAddr: obsAddr,
+		obsPass: obsPass,
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+// SetSendEvent sets the callback that pushes the AgentSnapshot event
+// (raw op 5 JSON) to the relay. It is called only when the snapshot changed.
+func (c *Collector) SetSendEvent(fn func([]byte)) {
+	c.mu.Lock()
+	c.sendEvent = fn
+	c.mu.Unlock()
+}
+
+// SetOnSnapshot sets a callback that receives the snapshot JSON after every
+// refresh, changed or not.
+func (c *Collector) SetOnSnapshot(fn func([]byte)) {
+	c.mu.Lock()
+	c.onSnapshot = fn
+	c.mu.Unlock()
+}
+
+// HandleEvent schedules a refresh if eventType can change the snapshot.
+// It never blocks.
+func (c *Collector) HandleEvent(eventType string) {
+	if refreshEvents[eventType] {
+		c.Refresh()
+	}
+}
+
+// Refresh schedules a refresh. It never blocks.
+func (c *Collector) Refresh() {
+	select {
+	case c.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run refreshes until ctx is done.
+func (c *Collector) Run(ctx context.Context) {
+	var conn *websocket.Conn
+	defer func() {
+		if conn != nil {
+			obs.CloseConn(conn)
+		}
+	}()
+
+	refresh := func() {
+		if conn == nil {
+			var err error
+			if conn, err = obs.ConnectMonitor(ctx, c.obsAddr, c.obsPass); err != nil {
+				log.Printf("[snapshot] OBS connection failed: %v", err)
+				return
+			}
+		}
+		snap, err := collect(conn)
+		if err != nil {
+			log.Printf("[snapshot] Refresh failed: %v", err)
+			conn.Close()
+			conn = nil
+			return
+		}
+		c.publish(snap)
+	}
+
+	refresh()
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			refresh()
+		case <-c.trigger:
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(debounce):
+			}
+			// Events during the debounce are covered by this refresh
+			select {
+			case <-c.trigger:
+			default:
+			}
+			refresh()
+		}
+	}
+}
+
+// publish hands the snapshot to the local callback, and to the relay if it
+// differs from the last one sent.
+func (c *Collector) publish(snap *Snapshot) {
+	data, err := marshalBounded(snap)
+	if err != nil {
+		log.Printf("[snapshot] Failed to marshal: %v", err)
+		return
+	}
+
+	c.mu.Lock()
+	changed := string(data) != string(c.last)
+	if changed {
+		c.last = data
+	}
+	send, local := c.sendEvent, c.onSnapshot
+	c.mu.Unlock()
+
+	if local != nil {
+		local(data)
+	}
+	if !changed || send == nil {
+		return
+	}
+	event, err := json.Marshal(map[string]interface{}{
+		"op": 5,
+		"d": map[string]interface{}{
+			"eventType":   "AgentSnapshot",
+			"eventIntent": 1,
+			"eventData":   json.RawMessage(data),
+		},
+	})
+	if err != nil {
+		log.Printf("[snapshot] Failed to marshal event: %v", err)
+		return
+	}
+	send(event)
+}
+
+// marshalBounded marshals snap, dropping the items of the last scenes that
+// still have any (then whole scenes from the end) until it fits MaxSize.
+func marshalBounded(snap *Snapshot) ([]byte, error) {
+	for {
+		data, err := json.Marshal(snap)
+		if err != nil || len(data) <= MaxSize {
+			return data, err
+		}
+		snap.Truncated = true
+		trimmed := false
+		for i := len(snap.Scenes) - 1; i >= 0; i-- {
+			if len(snap.Scenes[i].Items) > 0 {
+				snap.Scenes[i].Items = []Item{}
+				trimmed = true
+				break
+			}
+		}
+		if !trimmed {
+			if len(snap.Scenes) == 0 {
+				return data, nil // cannot get smaller
+			}
+			snap.Scenes = snap.Scenes[:len(snap.Scenes)-1]
+		}
+	}
+}
+
+// collect queries OBS for a full snapshot.
+func collect(conn *websocket.Conn) (*Snapshot, error) {
+	var sceneList struct {
+		CurrentProgramSceneName string `json:"currentProgramSceneName"`
+		Scenes                  []struct {
+			SceneName string `json:"sceneName"`
+		} `json:"scenes"`
+	}
+	if err := request(conn, "GetSceneList", nil, &sceneList); err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{
+		CurrentProgramScene: sceneList.CurrentProgramSceneName,
+		Scenes:              make([]Scene, 0, len(sceneList.Scenes)),
+	}
+	for _, s := range sceneList.Scenes {
+		var items struct {
+			SceneItems []Item `json:"sceneItems"`
+		}
+		if err := request(conn, "GetSceneItemList", map[string]interface{}{"sceneName": s.SceneName}, &items); err != nil {
+			return nil, err
+		}
+		if items.SceneItems == nil {
+			items.SceneItems = []Item{}
+		}
+		snap.Scenes = append(snap.Scenes, Scene{Name: s.SceneName, Items: items.SceneItems})
+	}
+
+	var output struct {
+		OutputActive bool `json:"outputActive"`
+	}
+	if err := request(conn, "GetStreamStatus", nil, &output); err != nil {
+		return nil, err
+	}
+	snap.Streaming = output.OutputActive
+	output.OutputActive = false
+	if err := request(conn, "GetRecordStatus", nil, &output); err != nil {
+		return nil, err
+	}
+	snap.Recording = output.OutputActive
+	return snap, nil
+}
+
+// request sends one op 6 request and decodes the matching op 7 response's
+// responseData into out.
+func request(conn *websocket.Conn, requestType string, requestData map[string]interface{}, out interface{}) error {
+	reqID := fmt.Sprintf("snap-%s-%d", requestType, time.Now().UnixNano())
+	d := map[string]interface{}{
+		"requestType": requestType,
+		"requestId":   reqID,
+	}
+	if requestData != nil {
+		d["requestData"] = requestData
+	}
+	data, err := json.Marshal(map[string]interface{}{"op": 6, "d": d})
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", requestType, err)
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+	if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return fmt.Errorf("write %s: %w", requestType, err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(requestTimeout))
+	for i := 0; i < 10; i++ {
+		_, respData, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("read %s: %w", requestType, err)
+		}
+		var msg struct {
+			Op int `json:"op"`
+			D  struct {
+				RequestID     string `json:"requestId"`
+				RequestStatus struct {
+					Result  bool   `json:"result"`
+					Code    int    `json:"code"`
+					Comment string `json:"comment"`
+				} `json:"requestStatus"`
+				ResponseData json.RawMessage `json:"responseData"`
+			} `json:"d"`
+		}
+		if json.Unmarshal(respData, &msg) != nil || msg.Op != 7 || msg.D.RequestID != reqID {
+			continue
+		}
+		if !msg.D.RequestStatus.Result {
+			return fmt.Errorf("%s failed (code %d): %s", requestType, msg.D.RequestStatus.Code, msg.D.RequestStatus.Comment)
+		}
+		if len(msg.D.ResponseData) == 0 {
+			return nil
+		}
+		return json.Unmarshal(msg.D.ResponseData, out)
+	}
+	return fmt.Errorf("%s: no matching response", requestType)
+}
@@ -0,0 +1,91 @@
+// Package stats provides the histograms and rate counters behind
+// /api/stats. Everything is safe for concurrent use.
+package stats
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Histogram counts values into fixed buckets.
+type Histogram struct {
+	bounds []float64
+	labels []string
+	counts []atomic.Uint64
+}
+
+// NewHistogram returns a histogram with len(bounds)+1 buckets: below
+// bounds[0], between each pair of bounds, and from the last bound up.
+// labels names the buckets in that order. bounds must be ascending.
+func NewHistogram(bounds []float64, labels []string) *Histogram {
+	if len(labels) != len(bounds)+1 {
+		panic("stats: histogram needs one more label than bounds")
+	}
+	return &Histogram{
+		bounds: bounds,
+		labels: labels,
+		counts: make([]atomic.Uint64, len(labels)),
+	}
+}
+
+// Record counts value in its bucket.
+func (h *Histogram) Record(value float64) {
+	i := 0
+	for i < len(h.bounds) && value >= h.bounds[i] {
+		i++
+	}
+	h.counts[i].Add(1)
+}
+
+// Buckets returns the count per bucket label.
+func (h *Histogram) Buckets() map[string]uint64 {
+	out := make(map[string]uint64, len(h.labels))
+	for i, l := range h.labels {
+		out[l] = h.counts[i].Load()
+	}
+	return out
+}
+
+// Rate counts events per wall-clock minute over a sliding window.
+type Rate struct {
+	mu     sync.Mutex
+	counts []uint64
+	// minute is the unix minute each slot of counts belongs to
+	minute []int64
+}
+
+// NewRate keeps per-minute counts for the last minutes minutes.
+func NewRate(minutes int) *Rate {
+	return &Rate{counts: make([]uint64, minutes), minute: make([]int64, minutes)}
+}
+
+// Inc counts one event now.
+func (r *Rate) Inc() {
+	m := time.Now().Unix() / 60
+	i := int(m % int64(len(r.counts)))
+	r.mu.Lock()
+	if r.minute[i] != m {
+		r.minute[i] = m
+		r.counts[i] = 0
+	}
+	r.counts[i]++
+	r.mu.Unlock()
+}
+
+// PerMinute returns the counts for the window, oldest first; the last
+// entry is the current (partial) minute.
+func (r *Rate) PerMinute() []uint64 {
+	now := time.Now().Unix() / 60
+	n := int64(len(r.counts))
+	out := make([]uint64, n)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k := int64(0); k < n; k++ {
+		m := now - n + 1 + k
+		if i := m % n; r.minute[i] == m {
+			out[k] = r.counts[i]
+		}
+	}
+	return out
+}
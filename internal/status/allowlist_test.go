@@ -0,0 +1,78 @@
+package status
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseAllowlist(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    int
+		wantErr bool
+	}{
+		{DefaultAllowlist, 2, false},
+		{" 10.0.0.0/8 , 192.168.1.0/24 ", 2, false},
+		{"10.0.0.1", 0, true},
+		{"10.0.0.0/8,nope", 0, true},
+		{"", 0, true},
+		{" , ", 0, true},
+	}
+	for _, tt := range tests {
+		nets, err := ParseAllowlist(tt.list)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseAllowlist(%q) error = %v, wantErr %v", tt.list, err, tt.wantErr)
+			continue
+		}
+		if len(nets) != tt.want {
+			t.Errorf("ParseAllowlist(%q) = %d ranges, want %d", tt.list, len(nets), tt.want)
+		}
+	}
+}
+
+func TestDefaultAllowlistMatchesConstant(t *testing.T) {
+	nets, err := ParseAllowlist(DefaultAllowlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nets, loopbackAllowlist()) {
+		t.Errorf("loopbackAllowlist() = %v, want %v", loopbackAllowlist(), nets)
+	}
+	if !OnlyLoopback(nets) {
+		t.Error("default allowlist is not loopback only")
+	}
+}
+
+func TestIPAllowlistMiddleware(t *testing.T) {
+	lan, _ := ParseAllowlist("192.168.1.0/24")
+	tests := []struct {
+		name   string
+		allow  []*net.IPNet // nil = default
+		remote string
+		want   int
+	}{
+		{"loopback v4", nil, "127.0.0.1:5000", http.StatusOK},
+		{"loopback v6", nil, "[::1]:5000", http.StatusOK},
+		{"lan refused by default", nil, "192.168.1.20:5000", http.StatusForbidden},
+		{"lan allowed", lan, "192.168.1.20:5000", http.StatusOK},
+		{"loopback outside custom list", lan, "127.0.0.1:5000", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New("test", "localhost", 4455, "wss://relay.example")
+			if tt.allow != nil {
+				s.SetAllowlist(tt.allow)
+			}
+			req := httptest.NewRequest("GET", "/livez", nil)
+			req.RemoteAddr = tt.remote
+			rec := httptest.NewRecorder()
+			s.handler().ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package status
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DashboardOrigin is the origin of the hosted dashboard and setup pages,
+// the only one CORS and the pairing exchange answer.
+const DashboardOrigin = "https://agent.4throck.cloud"
+
+// pairingTTL bounds how long a dashboard pairing code can be exchanged.
+const pairingTTL = 2 * time.Minute
+
+// newControlToken returns a random per-run bearer token (32 bytes hex).
+func newControlToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("control token generation failed: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// ControlToken returns the bearer token required by mutating endpoints.
+func (s *Server) ControlToken() string {
+	return s.controlToken
+}
+
+// WriteTokenFile writes the control token to path (obs-agent.token, or
+// obs-agent-<instance>.token for a named -instance, next to the binary)
+// with 0600 permissions so only the current user can read it. Local tools
+// (-status, -restart, …) read it to call mutating endpoints.
+func (s *Server) WriteTokenFile(path string) error {
+	// Remove first — WriteFile keeps the mode of an existing file
+	os.Remove(path)
+	return os.WriteFile(path, []byte(s.controlToken+"\n"), 0600)
+}
+
+// ReadTokenFile reads a running agent's control token from path.
+func ReadTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// WritePortFile writes the port the server bound to to path (the
+// instance's .port file next to the token). Local tools read it because
+// the preferred port may have been taken, e.g. by another instance whose
+// name hashes to the same port.
+func (s *Server) WritePortFile(path string) error {
+	port := s.Port()
+	if port == 0 {
+		return fmt.Errorf("status server not listening")
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(port)+"\n"), 0644)
+}
+
+// ReadPortFile reads a running agent's status server port from path.
+func ReadPortFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	port, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("invalid port in %s", path)
+	}
+	return port, nil
+}
+
+// NewPairingCode returns a single-use code the hosted page exchanges for
+// the control token at POST /api/control-token. It expires after
+// pairingTTL.
+//
+// SECURITY: the page cannot read the token file, and the token itself must
+// not go in the URL the agent opens (browser command lines are visible to
+// other local users). A code that is spent when the page loads, and only
+// from the dashboard's origin, leaves nothing reusable behind.
+func (s *Server) NewPairingCode() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("pairing code generation failed: %v", err))
+	}
+	code := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	now := time.Now()
+	for c, exp := range s.pairing {
+		if now.After(exp) {
+			delete(s.pairing, c)
+		}
+	}
+	s.pairing[code] = now.Add(pairingTTL)
+	s.mu.Unlock()
+	return code
+}
+
+// DashboardURL is the hosted dashboard for this server, paired through a
+// new code in the URL fragment, which browsers never send to the web server.
+func (s *Server) DashboardURL() string {
+	return fmt.Sprintf("%s/status?port=%d#pair=%s", DashboardOrigin, s.Port(), s.NewPairingCode())
+}
+
+// handleControlTokenExchange trades a pairing code for the control token.
+func (s *Server) handleControlTokenExchange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST only", 405)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("Origin") != DashboardOrigin {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"ok":false,"error":"forbidden"}`)
+		return
+	}
+	var req struct {
+		Code string `json:"code"`
+	}
+	json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req)
+
+	s.mu.Lock()
+	exp, ok := s.pairing[req.Code]
+	delete(s.pairing, req.Code)
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(exp) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"ok":false,"error":"unknown or expired pairing code"}`)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "token": s.controlToken})
+}
+
+// HandleControlFunc registers a mutating endpoint that requires the control
+// token in an "Authorization: Bearer <token>" header.
+func (s *Server) HandleControlFunc(pattern string, handler http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, s.requireControlToken(handler))
+}
+
+// requireControlToken rejects requests without a valid bearer token (401).
+//
+// SECURITY: /api/status and /health stay open (read-only). Anything that can
+// stop or reconfigure the agent must prove it can read the 0600 token file.
+func (s *Server) requireControlToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		token, ok := strings.CutPrefix(auth, "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.controlToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="obs-agent"`)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"ok":false,"error":"unauthorized"}`)
+			return
+		}
+		next(w, r)
+	}
+}
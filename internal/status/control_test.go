@@ -0,0 +1,156 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serve sends req through the server's full handler chain from loopback.
+func serve(s *Server, req *http.Request) *httptest.ResponseRecorder {
+	req.RemoteAddr = "127.0.0.1:50000"
+	rec := httptest.NewRecorder()
+	s.handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestControlEndpointsRequireToken(t *testing.T) {
+	s := New("test", "localhost", 4455, "wss://relay.example")
+
+	called := make(chan string, 3)
+	s.SetQuitHandler(func() { called <- "quit" })
+	s.SetReconfigureHandler(func() { called <- "reconfigure" })
+	s.SetRestartHandler(func() { called <- "restart" })
+
+	tests := []struct {
+		name string
+		path string
+		auth string
+		want int
+	}{
+		{"quit without token", "/api/quit", "", http.StatusUnauthorized},
+		{"quit with wrong token", "/api/quit", "Bearer nope", http.StatusUnauthorized},
+		{"quit with token not as bearer", "/api/quit", s.ControlToken(), http.StatusUnauthorized},
+		{"reconfigure without token", "/api/reconfigure", "", http.StatusUnauthorized},
+		{"restart without token", "/api/restart", "", http.StatusUnauthorized},
+		{"quit with token", "/api/quit", "Bearer " + s.ControlToken(), http.StatusOK},
+		{"reconfigure with token", "/api/reconfigure", "Bearer " + s.ControlToken(), http.StatusOK},
+		{"restart with token", "/api/restart", "Bearer " + s.ControlToken(), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := serve(s, req)
+			if rec.Code != tt.want {
+				t.Fatalf("%s = %d, want %d (%s)", tt.path, rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			want := strings.TrimPrefix(tt.path, "/api/")
+			select {
+			case got := <-called:
+				if got != want {
+					t.Fatalf("handler %q called, want %q", got, want)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("%s handler not called", want)
+			}
+		})
+	}
+
+	select {
+	case got := <-called:
+		t.Fatalf("unauthorized request reached the %q handler", got)
+	default:
+	}
+}
+
+func TestControlTokenExchange(t *testing.T) {
+	s := New("test", "localhost", 4455, "wss://relay.example")
+
+	exchange := func(origin, code string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/control-token", strings.NewReader(`{"code":"`+code+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		return serve(s, req)
+	}
+
+	code := s.NewPairingCode()
+	if rec := exchange("https://evil.example", code); rec.Code != http.StatusForbidden {
+		t.Fatalf("foreign origin = %d, want 403", rec.Code)
+	}
+	if rec := exchange("", code); rec.Code != http.StatusForbidden {
+		t.Fatalf("no origin = %d, want 403", rec.Code)
+	}
+	if rec := exchange(DashboardOrigin, "not-a-code"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unknown code = %d, want 401", rec.Code)
+	}
+
+	rec := exchange(DashboardOrigin, code)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("exchange = %d, want 200 (%s)", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp.Token != s.ControlToken() {
+		t.Fatalf("exchange returned %q (%v), want the control token", resp.Token, err)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != DashboardOrigin {
+		t.Fatalf("Access-Control-Allow-Origin = %q", got)
+	}
+
+	if rec := exchange(DashboardOrigin, code); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("reused code = %d, want 401", rec.Code)
+	}
+
+	expired := s.NewPairingCode()
+	s.mu.Lock()
+	s.pairing[expired] = time.Now().Add(-time.Second)
+	s.mu.Unlock()
+	if rec := exchange(DashboardOrigin, expired); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expired code = %d, want 401", rec.Code)
+	}
+}
+
+func TestPortFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "obs-agent-studio.port")
+
+	s := New("test", "localhost", 4455, "wss://relay.example")
+	if err := s.WritePortFile(path); err == nil {
+		t.Error("WritePortFile before Start succeeded, want error")
+	}
+	s.SetPreferredAddr("127.0.0.1:0")
+	s.Start()
+	defer s.Stop()
+	if err := s.WritePortFile(path); err != nil {
+		t.Fatal(err)
+	}
+	port, err := ReadPortFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if port != s.Port() {
+		t.Errorf("ReadPortFile = %d, want %d", port, s.Port())
+	}
+
+	for _, bad := range []string{"", "http", "0", "70000"} {
+		if err := os.WriteFile(path, []byte(bad+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadPortFile(path); err == nil {
+			t.Errorf("ReadPortFile(%q) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package status
+
+import "time"
+
+// DefaultConnectedDebounce is how long a link must stay connected before
+// the "connected" state change fires.
+const DefaultConnectedDebounce = 5 * time.Second
+
+// connNotifier debounces state-change callbacks for one link (OBS or
+// relay). A connection that drops before the debounce elapses announces
+// neither the connect nor the matching disconnect, so flapping links do
+// not produce notification churn.
+type connNotifier struct {
+	timer     *time.Timer // pending "connected" announcement
+	announced bool        // last state reported to onStateChange was connected
+}
+
+// SetConnectedDebounce sets how long a link must stay up before its
+// connected state change fires (0 fires immediately).
+func (s *Server) SetConnectedDebounce(d time.Duration) {
+	s.mu.Lock()
+	s.connectedDebounce = d
+	s.mu.Unlock()
+}
+
+// transitionLocked records a connection change on n and returns the
+// callback to run after s.mu is released, or nil. Caller holds s.mu.
+func (s *Server) transitionLocked(n *connNotifier, connected bool, onEvent, onMsg, offEvent, offMsg string) func() {
+	if connected {
+		if n.announced || n.timer != nil {
+			return nil
+		}
+		if s.connectedDebounce <= 0 {
+			n.announced = true
+			return s.callbackLocked(onEvent, onMsg)
+		}
+		var t *time.Timer
+		t = time.AfterFunc(s.connectedDebounce, func() {
+			s.mu.Lock()
+			if n.timer != t {
+				s.mu.Unlock()
+				return // cancelled by a disconnect
+			}
+			n.timer = nil
+			n.announced = true
+			fire := s.callbackLocked(onEvent, onMsg)
+			s.mu.Unlock()
+			if fire != nil {
+				fire()
+			}
+		})
+		n.timer = t
+		return nil
+	}
+
+	if n.timer != nil {
+		n.timer.Stop()
+		n.timer = nil
+	}
+	if !n.announced {
+		return nil
+	}
+	n.announced = false
+	return s.callbackLocked(offEvent, offMsg)
+}
+
+func (s *Server) callbackLocked(event, message string) func() {
+	cb := s.onStateChange
+	if cb == nil {
+		return nil
+	}
+	return func() { cb(event, message) }
+}
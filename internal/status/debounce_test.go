@@ -0,0 +1,106 @@
+package status
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConnNotifierDebounce(t *testing.T) {
+	const debounce = 100 * time.Millisecond
+	tests := []struct {
+		name  string
+		steps func(set func(bool))
+		want  []string
+	}{
+		{
+			name: "drop inside the debounce",
+			steps: func(set func(bool)) {
+				set(true)
+				time.Sleep(debounce / 4)
+				set(false)
+			},
+			want: nil,
+		},
+		{
+			name: "flapping",
+			steps: func(set func(bool)) {
+				for i := 0; i < 5; i++ {
+					set(true)
+					time.Sleep(debounce / 4)
+					set(false)
+				}
+			},
+			want: nil,
+		},
+		{
+			name: "stays up",
+			steps: func(set func(bool)) {
+				set(true)
+				time.Sleep(3 * debounce)
+			},
+			want: []string{"connected"},
+		},
+		{
+			name: "stays up, then drops",
+			steps: func(set func(bool)) {
+				set(true)
+				time.Sleep(3 * debounce)
+				set(false)
+			},
+			want: []string{"connected", "disconnected"},
+		},
+	}
+	links := []struct {
+		prefix string
+		set    func(s *Server, connected bool)
+	}{
+		{"obs_", (*Server).SetOBSConnected},
+		{"relay_", (*Server).SetRelayConnected},
+	}
+	for _, link := range links {
+		for _, tt := range tests {
+			t.Run(link.prefix+tt.name, func(t *testing.T) {
+				s := New("test", "localhost", 4455, "wss://relay.example")
+				s.SetConnectedDebounce(debounce)
+				var mu sync.Mutex
+				var got []string
+				s.SetStateChangeHandler(func(event, _ string) {
+					mu.Lock()
+					got = append(got, event)
+					mu.Unlock()
+				})
+
+				tt.steps(func(connected bool) { link.set(s, connected) })
+				time.Sleep(2 * debounce) // let a pending announcement fire
+
+				var want []string
+				for _, w := range tt.want {
+					want = append(want, link.prefix+w)
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("events %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestConnNotifierDefaultDebounce(t *testing.T) {
+	s := New("test", "localhost", 4455, "wss://relay.example")
+	var fired []string
+	s.SetStateChangeHandler(func(event, _ string) { fired = append(fired, event) })
+
+	// A connect and drop well inside DefaultConnectedDebounce: nothing
+	s.SetOBSConnected(true)
+	s.SetOBSConnected(false)
+	if fired != nil {
+		t.Errorf("events %v for a link that never stayed up", fired)
+	}
+	if s.obsNotify.timer != nil || s.obsNotify.announced {
+		t.Error("cancelled announcement still pending")
+	}
+}
@@ -0,0 +1,71 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultEventLimit = 100
+	maxEventLimit     = 1000
+)
+
+// EventSource supplies logged OBS events (see eventlog.Log).
+type EventSource interface {
+	Events(since time.Time, limit int) ([]json.RawMessage, error)
+}
+
+// SetEventSource enables GET /api/events backed by src.
+func (s *Server) SetEventSource(src EventSource) {
+	s.mu.Lock()
+	s.eventSource = src
+	s.mu.Unlock()
+}
+
+// handleEvents serves the event log tail: /api/events?since=<rfc3339>&limit=100.
+// Events are the raw OBS op 5 messages plus "logged_at", oldest first.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	src := s.eventSource
+	s.mu.RUnlock()
+
+	if src == nil {
+		http.Error(w, "event log disabled (start the agent with -event-log)", 404)
+		return
+	}
+
+	var since time.Time
+	if v := r.URL.Query().Get("since"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 timestamp", 400)
+			return
+		}
+		since = parsed
+	}
+	limit := defaultEventLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "limit must be a positive integer", 400)
+			return
+		}
+		limit = parsed
+	}
+	if limit > maxEventLimit {
+		limit = maxEventLimit
+	}
+
+	events, err := src.Events(since, limit)
+	if err != nil {
+		http.Error(w, "failed to read event log", 500)
+		return
+	}
+	if events == nil {
+		events = []json.RawMessage{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"events": events})
+}
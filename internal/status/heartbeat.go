@@ -0,0 +1,48 @@
+package status
+
+import "time"
+
+// HeartbeatTimeout is how long the agent loop may go without a Heartbeat
+// before HeartbeatSilence reports it (and /readyz fails). It covers the longest reconnect backoff (60s plus
+// jitter) and two relay keepalive intervals.
+const HeartbeatTimeout = 2 * time.Minute
+
+// Heartbeat records that the agent's connect loop is making progress. The
+// loop beats on every attempt and while it waits; a running bridge beats
+// on every relay keepalive pong, which needs both its reader and writer.
+func (s *Server) Heartbeat() {
+	s.mu.Lock()
+	s.heartbeat = time.Now()
+	s.mu.Unlock()
+}
+
+// SuspendHeartbeat stops HeartbeatSilence from judging the agent loop, for while it
+// is deliberately parked (e.g. waiting on a setup wizard).
+func (s *Server) SuspendHeartbeat() {
+	s.mu.Lock()
+	s.heartbeatHeld = true
+	s.mu.Unlock()
+}
+
+// ResumeHeartbeat undoes SuspendHeartbeat and restarts the timeout.
+func (s *Server) ResumeHeartbeat() {
+	s.mu.Lock()
+	s.heartbeatHeld = false
+	s.heartbeat = time.Now()
+	s.mu.Unlock()
+}
+
+// HeartbeatSilence returns how long the loop has been silent, or 0 while
+// it is within HeartbeatTimeout, suspended or has not started beating.
+// The systemd watchdog checks it in-process.
+func (s *Server) HeartbeatSilence() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.heartbeat.IsZero() || s.heartbeatHeld {
+		return 0
+	}
+	if d := time.Since(s.heartbeat); d > HeartbeatTimeout {
+		return d
+	}
+	return 0
+}
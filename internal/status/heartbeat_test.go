@@ -0,0 +1,55 @@
+package status
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadyzFollowsHeartbeat(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(s *Server)
+		want  int
+	}{
+		{"no heartbeat yet", func(s *Server) {}, http.StatusOK},
+		{"recent heartbeat", func(s *Server) { s.Heartbeat() }, http.StatusOK},
+		{"stale heartbeat", func(s *Server) {
+			s.heartbeat = time.Now().Add(-HeartbeatTimeout - time.Second)
+		}, http.StatusServiceUnavailable},
+		{"stale but suspended", func(s *Server) {
+			s.heartbeat = time.Now().Add(-HeartbeatTimeout - time.Second)
+			s.SuspendHeartbeat()
+		}, http.StatusOK},
+		{"resumed after suspend", func(s *Server) {
+			s.SuspendHeartbeat()
+			s.heartbeat = time.Now().Add(-HeartbeatTimeout - time.Second)
+			s.ResumeHeartbeat()
+		}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New("test", "localhost", 4455, "wss://relay.example")
+			s.obsConn, s.relayConn = true, true
+			tt.setup(s)
+
+			stalled := tt.want != http.StatusOK
+			if got := s.HeartbeatSilence() > 0; got != stalled {
+				t.Errorf("HeartbeatSilence() > 0 = %v, want %v", got, stalled)
+			}
+			rec := serve(s, httptest.NewRequest("GET", "/readyz", nil))
+			if rec.Code != tt.want {
+				t.Errorf("/readyz = %d (%s), want %d", rec.Code, rec.Body, tt.want)
+			}
+			if stalled && !strings.Contains(rec.Body.String(), "agent_loop_stalled") {
+				t.Errorf("/readyz body %s, want agent_loop_stalled", rec.Body)
+			}
+			// Liveness never depends on the loop
+			if rec := serve(s, httptest.NewRequest("GET", "/livez", nil)); rec.Code != http.StatusOK {
+				t.Errorf("/livez = %d (%s), want 200", rec.Code, rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultLogLines = 200
+	maxLogLines     = 1000
+)
+
+// LogSource supplies recent log lines (see logging.Ring).
+type LogSource interface {
+	Lines(n int) []string
+}
+
+// SetLogSource enables GET /api/logs backed by src.
+func (s *Server) SetLogSource(src LogSource) {
+	s.mu.Lock()
+	s.logSource = src
+	s.mu.Unlock()
+}
+
+// handleLogs serves the recent log tail: /api/logs?lines=200[&format=text].
+// Lines are redacted by the source before they are stored.
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	src := s.logSource
+	s.mu.RUnlock()
+
+	if src == nil {
+		http.Error(w, "logs unavailable", 404)
+		return
+	}
+
+	n := defaultLogLines
+	if v := r.URL.Query().Get("lines"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "lines must be a positive integer", 400)
+			return
+		}
+		n = parsed
+	}
+	if n > maxLogLines {
+		n = maxLogLines
+	}
+
+	lines := src.Lines(n)
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(strings.Join(lines, "\n") + "\n"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"lines": lines})
+}
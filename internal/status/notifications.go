@@ -0,0 +1,154 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// NotificationEvents are the state-change events that can raise a desktop
+// notification (see SetStateChangeHandler).
+var NotificationEvents = []string{"obs_connected", "obs_disconnected", "relay_connected", "relay_disconnected"}
+
+// NotificationPrefs controls which state changes raise a desktop
+// notification. It is stored in the config file and edited through
+// /api/notifications.
+type NotificationPrefs struct {
+	Enabled bool `json:"enabled"`
+	// Events limits notifications to these NotificationEvents (empty = all).
+	Events []string `json:"events,omitempty"`
+	// QuietHours, when set, suppresses every notification in that window.
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+}
+
+// QuietHours is a daily local-time window, "HH:MM" to "HH:MM". A window
+// whose end is before its start runs past midnight (22:00–07:00).
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// DefaultNotificationPrefs notifies on every event, as before preferences existed.
+func DefaultNotificationPrefs() NotificationPrefs {
+	return NotificationPrefs{Enabled: true}
+}
+
+// Validate rejects unknown events and malformed quiet hours.
+func (p NotificationPrefs) Validate() error {
+	for _, e := range p.Events {
+		if !slices.Contains(NotificationEvents, e) {
+			return fmt.Errorf("unknown event %q", e)
+		}
+	}
+	if q := p.QuietHours; q != nil {
+		if _, err := parseClock(q.Start); err != nil {
+			return fmt.Errorf("quiet_hours.start: %w", err)
+		}
+		if _, err := parseClock(q.End); err != nil {
+			return fmt.Errorf("quiet_hours.end: %w", err)
+		}
+	}
+	return nil
+}
+
+// Allows reports whether event should raise a notification at t.
+func (p NotificationPrefs) Allows(event string, t time.Time) bool {
+	if !p.Enabled {
+		return false
+	}
+	if len(p.Events) > 0 && !slices.Contains(p.Events, event) {
+		return false
+	}
+	return p.QuietHours == nil || !p.QuietHours.contains(t)
+}
+
+// contains reports whether t's local time of day falls in the window.
+func (q *QuietHours) contains(t time.Time) bool {
+	start, err1 := parseClock(q.Start)
+	end, err2 := parseClock(q.End)
+	if err1 != nil || err2 != nil || start == end {
+		return false
+	}
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// parseClock parses "HH:MM" as an offset from midnight.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// SetNotificationPrefs sets the preferences served by /api/notifications.
+func (s *Server) SetNotificationPrefs(p NotificationPrefs) {
+	s.mu.Lock()
+	s.notifyPrefs = p
+	s.mu.Unlock()
+}
+
+// NotificationPrefs returns the current notification preferences.
+func (s *Server) NotificationPrefs() NotificationPrefs {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.notifyPrefs
+}
+
+// SetNotificationPrefsHandler sets the callback that persists preferences
+// posted to /api/notifications. They only take effect if it returns nil.
+func (s *Server) SetNotificationPrefsHandler(fn func(NotificationPrefs) error) {
+	s.mu.Lock()
+	s.onNotifyPrefs = fn
+	s.mu.Unlock()
+}
+
+// handleNotifications serves GET /api/notifications (open, like
+// /api/status) and POST, which replaces the preferences and needs the
+// control token.
+func (s *Server) handleNotifications(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.NotificationPrefs())
+	case "POST":
+		s.requireControlToken(s.handleSetNotifications)(w, r)
+	default:
+		http.Error(w, "GET or POST only", 405)
+	}
+}
+
+func (s *Server) handleSetNotifications(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var p NotificationPrefs
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&p); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": "invalid JSON"})
+		return
+	}
+	if err := p.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": err.Error()})
+		return
+	}
+
+	s.mu.RLock()
+	cb := s.onNotifyPrefs
+	s.mu.RUnlock()
+	if cb != nil {
+		if err := cb(p); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": err.Error()})
+			return
+		}
+	}
+	s.SetNotificationPrefs(p)
+	json.NewEncoder(w).Encode(map[string]any{"ok": true})
+}
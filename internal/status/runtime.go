@@ -0,0 +1,49 @@
+package status
+
+import (
+	"runtime"
+	"time"
+)
+
+// runtimeSampleInterval is how often the agent samples its own resource usage.
+// ReadMemStats stops the world briefly, so it is not done per request.
+const runtimeSampleInterval = 10 * time.Second
+
+// runtimeStats is the agent's own resource usage, reported under "runtime".
+type runtimeStats struct {
+	Goroutines     int     `json:"goroutines"`
+	HeapAllocBytes uint64  `json:"heap_alloc_bytes"`
+	SysBytes       uint64  `json:"sys_bytes"`
+	CPUSeconds     float64 `json:"cpu_seconds"`
+	SampledAt      string  `json:"sampled_at"`
+}
+
+func sampleRuntime() runtimeStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return runtimeStats{
+		Goroutines:     runtime.NumGoroutine(),
+		HeapAllocBytes: ms.HeapAlloc,
+		SysBytes:       ms.Sys,
+		CPUSeconds:     processCPUTime().Seconds(),
+		SampledAt:      time.Now().Format(time.RFC3339),
+	}
+}
+
+// runtimeSampler refreshes s.runtime until done is closed.
+func (s *Server) runtimeSampler(done <-chan struct{}) {
+	ticker := time.NewTicker(runtimeSampleInterval)
+	defer ticker.Stop()
+	for {
+		stats := sampleRuntime()
+		s.mu.Lock()
+		s.runtime = stats
+		s.mu.Unlock()
+
+		select {
+		case <-ticker.C:
+		case <-done:
+			return
+		}
+	}
+}
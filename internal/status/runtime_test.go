@@ -0,0 +1,55 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRuntimeStats(t *testing.T) {
+	s := New("test", "localhost", 4455, "wss://relay.example")
+	done := make(chan struct{})
+	go s.runtimeSampler(done)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.RLock()
+		sampled := s.runtime.SampledAt != ""
+		s.mu.RUnlock()
+		if sampled {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("runtime never sampled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(done)
+
+	rec := httptest.NewRecorder()
+	s.handleAPIStatus(rec, httptest.NewRequest("GET", "/api/status", nil))
+	var resp struct {
+		UptimeSeconds *int64 `json:"uptime_seconds"`
+		Runtime       struct {
+			Goroutines     int     `json:"goroutines"`
+			HeapAllocBytes uint64  `json:"heap_alloc_bytes"`
+			SysBytes       uint64  `json:"sys_bytes"`
+			CPUSeconds     float64 `json:"cpu_seconds"`
+			SampledAt      string  `json:"sampled_at"`
+		} `json:"runtime"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+
+	rt := resp.Runtime
+	if rt.Goroutines <= 0 || rt.HeapAllocBytes == 0 || rt.SysBytes < rt.HeapAllocBytes || rt.CPUSeconds < 0 {
+		t.Errorf("runtime %+v", rt)
+	}
+	if _, err := time.Parse(time.RFC3339, rt.SampledAt); err != nil {
+		t.Errorf("sampled_at: %v", err)
+	}
+	if resp.UptimeSeconds == nil || *resp.UptimeSeconds < 0 {
+		t.Errorf("uptime_seconds %v", resp.UptimeSeconds)
+	}
+}
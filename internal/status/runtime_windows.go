@@ -0,0 +1,24 @@
+//go:build windows
+
+package status
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime returns user+kernel CPU time consumed by this process.
+func processCPUTime() time.Duration {
+	var creation, exit, kernel, user syscall.Filetime
+	h, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0
+	}
+	if err := syscall.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return 0
+	}
+	// Filetime counts 100ns intervals
+	ticks := int64(kernel.HighDateTime)<<32 | int64(kernel.LowDateTime)
+	ticks += int64(user.HighDateTime)<<32 | int64(user.LowDateTime)
+	return time.Duration(ticks * 100)
+}
@@ -0,0 +1,34 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// SetOBSSnapshot stores the latest scene/source snapshot JSON for
+// /api/obs/snapshot.
+func (s *Server) SetOBSSnapshot(data []byte) {
+	s.mu.Lock()
+	s.obsSnapshot = data
+	s.obsSnapshotAt = time.Now()
+	s.mu.Unlock()
+}
+
+// handleOBSSnapshot serves GET /api/obs/snapshot (open, for debugging): the
+// snapshot last pushed to the relay, or 404 before the first refresh.
+func (s *Server) handleOBSSnapshot(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	data, at := s.obsSnapshot, s.obsSnapshotAt
+	s.mu.RUnlock()
+
+	if data == nil {
+		http.Error(w, "no snapshot yet", 404)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"updated_at": at.Format(time.RFC3339),
+		"snapshot":   json.RawMessage(data),
+	})
+}
@@ -0,0 +1,96 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/stats"
+)
+
+// rateWindow is how many minutes of message rate /api/stats reports.
+const rateWindow = 10
+
+// sessionStats are the metrics for one relay session (see ResetStats).
+type sessionStats struct {
+	started     time.Time
+	relayRTT    *stats.Histogram
+	obsResponse *stats.Histogram
+	msgSize     *stats.Histogram
+	rate        *stats.Rate
+}
+
+func newSessionStats() *sessionStats {
+	latencyBounds := []float64{5, 50, 500}
+	latencyLabels := []string{"<5ms", "5-50ms", "50-500ms", ">500ms"}
+	return &sessionStats{
+		started:     time.Now(),
+		relayRTT:    stats.NewHistogram(latencyBounds, latencyLabels),
+		obsResponse: stats.NewHistogram(latencyBounds, latencyLabels),
+		msgSize: stats.NewHistogram([]float64{1 << 10, 16 << 10, 256 << 10},
+			[]string{"<1KB", "1-16KB", "16-256KB", ">256KB"}),
+		rate: stats.NewRate(rateWindow),
+	}
+}
+
+// StatsResponse is served as JSON by /api/stats. Latency buckets are in
+// milliseconds. It is separate from /api/status so dashboards that parse
+// /api/status are unaffected.
+type StatsResponse struct {
+	SessionStartedAt  string            `json:"session_started_at"`
+	RelayRTT          map[string]uint64 `json:"relay_rtt"`
+	OBSResponseTime   map[string]uint64 `json:"obs_response_time"`
+	MessageSize       map[string]uint64 `json:"message_size"`
+	MessagesPerMinute []uint64          `json:"messages_per_minute"`
+}
+
+// ResetStats starts a new metrics session; the agent calls it when a
+// relay session is established.
+func (s *Server) ResetStats() {
+	st := newSessionStats()
+	s.mu.Lock()
+	s.stats = st
+	s.mu.Unlock()
+}
+
+func (s *Server) sessionStats() *sessionStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stats
+}
+
+// RecordRelayRTT records one relay ping round trip.
+func (s *Server) RecordRelayRTT(d time.Duration) {
+	s.sessionStats().relayRTT.Record(milliseconds(d))
+}
+
+// RecordOBSResponse records how long OBS took to answer a request.
+func (s *Server) RecordOBSResponse(d time.Duration) {
+	s.sessionStats().obsResponse.Record(milliseconds(d))
+}
+
+// RecordMessage records one bridged message of size bytes.
+func (s *Server) RecordMessage(size int) {
+	st := s.sessionStats()
+	st.msgSize.Record(float64(size))
+	st.rate.Inc()
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// handleStats serves GET /api/stats (open, like /api/status).
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	st := s.sessionStats()
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false) // keep the "<5ms" bucket labels readable
+	enc.Encode(StatsResponse{
+		SessionStartedAt:  st.started.Format(time.RFC3339),
+		RelayRTT:          st.relayRTT.Buckets(),
+		OBSResponseTime:   st.obsResponse.Buckets(),
+		MessageSize:       st.msgSize.Buckets(),
+		MessagesPerMinute: st.rate.PerMinute(),
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
@@ -16,29 +17,119 @@ import (
 // Start will bind to :0 and let the OS pick a free port.
 const DefaultAddr = "127.0.0.1:8765"
 
+// DefaultPort is DefaultAddr's port.
+const DefaultPort = 8765
+
+// DefaultAllowlist is the source ranges the status server answers by
+// default (see SetAllowlist): loopback only.
+const DefaultAllowlist = "127.0.0.0/8,::1/128"
+
 // Server provides a local HTTP status endpoint.
 type Server struct {
-	mu        sync.RWMutex
-	version   string
-	status    string
-	obsConn   bool
-	relayConn bool
-	obsHost   string
-	obsPort   int
-	relayURL  string
-	lastError string
-	startedAt time.Time
+	mu         sync.RWMutex
+	version    string
+	status     string
+	obsConn    bool
+	relayConn  bool
+	obsHost    string
+	obsPort    int
+	relayURL   string
+	lastError  string
+	startedAt  time.Time
 	listenAddr string // actual address after binding
 
+	// tokenRotatedAt is when the relay last rotated the agent token (zero = never)
+	tokenRotatedAt time.Time
+
+	// OBS versions from the last handshake (empty/0 = not connected yet)
+	obsWSVersion  string
+	obsRPCVersion int
+	// obsVersion and obsPlatform are from GetVersion (empty = unknown)
+	obsVersion  string
+	obsPlatform string
+
+	// service is the OS startup registration (nil = not reported yet)
+	service *ServiceInfo
+
+	// rateLimited counts relay messages dropped by the rate limiter
+	rateLimited int64
+
+	// messageCounter reports bridged message totals (nil = all zero)
+	messageCounter func() MessageCounts
+
+	// currentRelayURL is the relay of the last successful connection,
+	// which differs from relayURL after a failover (empty = none yet)
+	currentRelayURL string
+
+	// Last time each link was seen connected (zero = never; see /health)
+	lastOBSConnectedAt   time.Time
+	lastRelayConnectedAt time.Time
+
 	mux    *http.ServeMux
 	server *http.Server
 
 	onQuit        func()
 	onReconfigure func()
+	onRestart     func()
 	onStateChange func(event, message string)
+	onStatus      func(status string)
+
+	// notifyPrefs filters desktop notifications (see notifications.go)
+	notifyPrefs   NotificationPrefs
+	onNotifyPrefs func(NotificationPrefs) error
+
+	// connectedDebounce delays "connected" state changes (see debounce.go)
+	connectedDebounce time.Duration
+	obsNotify         connNotifier
+	relayNotify       connNotifier
+
+	// subscribers are SSE clients; each channel is signalled on state change.
+	subscribers map[chan struct{}]struct{}
+
+	runtime     runtimeStats
+	stopSampler chan struct{}
+
+	// heartbeat is the agent loop's last sign of progress (zero = none
+	// yet); HeartbeatSilence ignores it while heartbeatHeld (see heartbeat.go)
+	heartbeat     time.Time
+	heartbeatHeld bool
+
+	// controlToken authorizes mutating endpoints (see control.go)
+	controlToken string
+	// pairing holds unexchanged dashboard pairing codes and their expiry
+	pairing map[string]time.Time
+
+	// allowlist is the source ranges requests may come from (see SetAllowlist)
+	allowlist []*net.IPNet
+
+	// agentName is the display name the agent was authorized under
+	agentName string
+
+	// obsLaunch counts the agent's attempts to start OBS (nil = none)
+	obsLaunch *OBSLaunchInfo
+
+	logSource LogSource
+
+	// eventSource backs /api/events when -event-log is set (see events.go)
+	eventSource EventSource
+
+	// stats holds the current session's metrics for /api/stats (see stats.go)
+	stats *sessionStats
+
+	// obsSnapshot is the last scene/source snapshot (see snapshot.go)
+	obsSnapshot   []byte
+	obsSnapshotAt time.Time
+
+	// updateInfo is the newer release the relay last announced (see update.go)
+	updateInfo *UpdateInfo
+	onUpdate   func(UpdateInfo)
+
+	// preferredAddr is tried before a random port (see SetPreferredAddr)
+	preferredAddr string
 }
 
-type statusResponse struct {
+// Snapshot is the agent state served as JSON by /api/status.
+type Snapshot struct {
 	Version        string `json:"version"`
 	Status         string `json:"status"`
 	OBSConnected   bool   `json:"obs_connected"`
@@ -50,6 +141,25 @@ type statusResponse struct {
 	StartedAt      string `json:"started_at"`
 	LastError      string `json:"last_error,omitempty"`
 	PID            int    `json:"pid"`
+	TokenRotatedAt string `json:"token_rotated_at,omitempty"`
+
+	OBSWebSocketVersion string `json:"obs_ws_version,omitempty"`
+	OBSRPCVersion       int    `json:"obs_rpc_version,omitempty"`
+	OBSVersion          string `json:"obs_version,omitempty"`
+	OBSPlatform         string `json:"obs_platform,omitempty"`
+
+	Service     *ServiceInfo `json:"service,omitempty"`
+	RateLimited int64        `json:"rate_limited"`
+
+	CurrentRelayURL string `json:"current_relay_url,omitempty"`
+
+	AgentName string `json:"agent_name,omitempty"`
+
+	OBSLaunch *OBSLaunchInfo `json:"obs_launch,omitempty"`
+
+	Messages MessageCounts `json:"messages"`
+
+	Runtime runtimeStats `json:"runtime"`
 }
 
 // New creates a status server with a pre-built mux.
@@ -63,12 +173,37 @@ func New(version, obsHost string, obsPort int, relayURL string) *Server {
 		relayURL:  relayURL,
 		startedAt: time.Now(),
 		mux:       http.NewServeMux(),
+
+		subscribers: make(map[chan struct{}]struct{}),
+
+		controlToken: newControlToken(),
+		pairing:      make(map[string]time.Time),
+		allowlist:    loopbackAllowlist(),
+
+		connectedDebounce: DefaultConnectedDebounce,
+
+		notifyPrefs: DefaultNotificationPrefs(),
+
+		stats: newSessionStats(),
+
+		preferredAddr: DefaultAddr,
 	}
 	s.mux.HandleFunc("/", s.handleRoot)
 	s.mux.HandleFunc("/api/status", s.handleAPIStatus)
-	s.mux.HandleFunc("/api/quit", s.handleQuit)
-	s.mux.HandleFunc("/api/reconfigure", s.handleReconfigure)
+	s.mux.HandleFunc("/api/status/stream", s.handleStatusStream)
+	s.mux.HandleFunc("/api/logs", s.handleLogs)
+	s.mux.HandleFunc("/api/events", s.handleEvents)
+	s.mux.HandleFunc("/api/notifications", s.handleNotifications)
+	s.mux.HandleFunc("/api/stats", s.handleStats)
+	s.mux.HandleFunc("/api/obs/snapshot", s.handleOBSSnapshot)
+	s.mux.HandleFunc("/api/update-info", s.handleUpdateInfo)
+	s.HandleControlFunc("/api/quit", s.handleQuit)
+	s.HandleControlFunc("/api/reconfigure", s.handleReconfigure)
+	s.HandleControlFunc("/api/restart", s.handleRestart)
+	s.mux.HandleFunc("/api/control-token", s.handleControlTokenExchange)
 	s.mux.HandleFunc("/health", s.handleHealth)
+	s.mux.HandleFunc("/livez", s.handleLivez)
+	s.mux.HandleFunc("/readyz", s.handleReadyz)
 	s.mux.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(204)
 	})
@@ -104,6 +239,15 @@ func (s *Server) SetReconfigureHandler(fn func()) {
 	s.mu.Unlock()
 }
 
+// SetRestartHandler sets the callback invoked when POST /api/restart is received.
+// The handler should reconnect without releasing the instance lock or
+// stopping the status server.
+func (s *Server) SetRestartHandler(fn func()) {
+	s.mu.Lock()
+	s.onRestart = fn
+	s.mu.Unlock()
+}
+
 // SetStateChangeHandler sets the callback invoked on connection state transitions.
 func (s *Server) SetStateChangeHandler(fn func(event, message string)) {
 	s.mu.Lock()
@@ -111,15 +255,100 @@ func (s *Server) SetStateChangeHandler(fn func(event, message string)) {
 	s.mu.Unlock()
 }
 
+// SetStatusHandler sets the callback invoked with the new status whenever
+// SetStatus changes it (e.g. to drive a tray icon). It must not block.
+func (s *Server) SetStatusHandler(fn func(status string)) {
+	s.mu.Lock()
+	s.onStatus = fn
+	s.mu.Unlock()
+}
+
+// ParseAllowlist parses a comma-separated list of CIDR ranges.
+func ParseAllowlist(list string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, f := range strings.Split(list, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		_, n, err := net.ParseCIDR(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q", f)
+		}
+		nets = append(nets, n)
+	}
+	if len(nets) == 0 {
+		return nil, fmt.Errorf("no CIDR ranges")
+	}
+	return nets, nil
+}
+
+// loopbackAllowlist is DefaultAllowlist, parsed.
+func loopbackAllowlist() []*net.IPNet {
+	return []*net.IPNet{
+		{IP: net.IPv4(127, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+		{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+	}
+}
+
+// OnlyLoopback reports whether every range in nets is loopback, i.e. the
+// allowlist admits no other machine whatever address the server binds.
+func OnlyLoopback(nets []*net.IPNet) bool {
+	for _, n := range nets {
+		if !n.IP.IsLoopback() {
+			return false
+		}
+	}
+	return true
+}
+
+// SetAllowlist sets the source ranges the server answers; requests from
+// anywhere else get 403. Call before Start.
+func (s *Server) SetAllowlist(nets []*net.IPNet) {
+	s.mu.Lock()
+	s.allowlist = nets
+	s.mu.Unlock()
+}
+
+// ipAllowlistMiddleware rejects requests whose source address is outside
+// the allowlist. It is in addition to the CORS origin check, and also
+// covers non-browser clients.
+func (s *Server) ipAllowlistMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		ip := net.ParseIP(host)
+
+		s.mu.RLock()
+		allowed := false
+		for _, n := range s.allowlist {
+			if ip != nil && n.Contains(ip) {
+				allowed = true
+				break
+			}
+		}
+		s.mu.RUnlock()
+
+		if !allowed {
+			log.Printf("[status] Rejected %s %s from %s (not in -status-allowlist)", r.Method, r.URL.Path, host)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // corsHandler wraps the mux to add CORS headers for the remote agent site.
 // Only allows the specific remote origin; local same-origin requests pass through unchanged.
 func (s *Server) corsHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("Origin")
-		if origin == "https://agent.4throck.cloud" {
+		if origin == DashboardOrigin {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 			w.Header().Set("Access-Control-Max-Age", "3600")
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(204)
@@ -130,18 +359,42 @@ func (s *Server) corsHandler(next http.Handler) http.Handler {
 	})
 }
 
-// Start begins listening. Tries DefaultAddr first; if busy, binds to :0.
+// handler is the mux behind the allowlist and CORS layers, as served.
+func (s *Server) handler() http.Handler {
+	return s.ipAllowlistMiddleware(s.corsHandler(s.mux))
+}
+
+// SetPreferredAddr sets the address Start tries first instead of
+// DefaultAddr (e.g. a named instance's own port). Call before Start.
+func (s *Server) SetPreferredAddr(addr string) {
+	s.mu.Lock()
+	s.preferredAddr = addr
+	s.mu.Unlock()
+}
+
+// Start begins listening. Tries the preferred address (DefaultAddr unless
+// set) first; if busy, binds to port 0 on the same host.
 func (s *Server) Start() {
+	s.stopSampler = make(chan struct{})
+	go s.runtimeSampler(s.stopSampler)
+
 	s.server = &http.Server{
-		Handler:      s.corsHandler(s.mux),
+		Handler:      s.handler(),
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 30 * time.Second,
 	}
 
-	ln, err := net.Listen("tcp", DefaultAddr)
+	s.mu.RLock()
+	preferred := s.preferredAddr
+	s.mu.RUnlock()
+	ln, err := net.Listen("tcp", preferred)
 	if err != nil {
 		// Default port busy — let OS assign a free port
-		ln, err = net.Listen("tcp", "127.0.0.1:0")
+		host, _, herr := net.SplitHostPort(preferred)
+		if herr != nil {
+			host = "127.0.0.1"
+		}
+		ln, err = net.Listen("tcp", net.JoinHostPort(host, "0"))
 		if err != nil {
 			log.Printf("[status] Could not start status server: %v (non-fatal)", err)
 			return
@@ -184,6 +437,10 @@ func (s *Server) Port() int {
 
 // Stop shuts down the status server.
 func (s *Server) Stop() {
+	if s.stopSampler != nil {
+		close(s.stopSampler)
+		s.stopSampler = nil
+	}
 	if s.server != nil {
 		s.server.Close()
 	}
@@ -192,55 +449,222 @@ func (s *Server) Stop() {
 // SetStatus updates the current agent status.
 func (s *Server) SetStatus(st string) {
 	s.mu.Lock()
+	changed := s.status != st
 	s.status = st
+	cb := s.onStatus
+	s.mu.Unlock()
+
+	if changed {
+		s.notifySubscribers()
+		if cb != nil {
+			cb(st)
+		}
+	}
+}
+
+// SetTokenRotated records when the relay last rotated the agent token.
+func (s *Server) SetTokenRotated(t time.Time) {
+	s.mu.Lock()
+	s.tokenRotatedAt = t
+	s.mu.Unlock()
+	s.notifySubscribers()
+}
+
+// SetAgentName records the display name the agent was authorized under
+// (empty when unknown, e.g. for a pasted token).
+func (s *Server) SetAgentName(name string) {
+	s.mu.Lock()
+	changed := s.agentName != name
+	s.agentName = name
+	s.mu.Unlock()
+
+	if changed {
+		s.notifySubscribers()
+	}
+}
+
+// SetOBSVersion records the obs-websocket version and negotiated RPC
+// version from the latest OBS handshake.
+func (s *Server) SetOBSVersion(wsVersion string, rpcVersion int) {
+	s.mu.Lock()
+	changed := s.obsWSVersion != wsVersion || s.obsRPCVersion != rpcVersion
+	s.obsWSVersion = wsVersion
+	s.obsRPCVersion = rpcVersion
+	s.mu.Unlock()
+
+	if changed {
+		s.notifySubscribers()
+	}
+}
+
+// SetOBSInfo records the OBS Studio version and platform reported by
+// GetVersion. Empty strings clear them (OBS disconnected).
+func (s *Server) SetOBSInfo(obsVersion, platform string) {
+	s.mu.Lock()
+	changed := s.obsVersion != obsVersion || s.obsPlatform != platform
+	s.obsVersion = obsVersion
+	s.obsPlatform = platform
+	s.mu.Unlock()
+
+	if changed {
+		s.notifySubscribers()
+	}
+}
+
+// ServiceInfo is the agent's startup registration with the OS service
+// manager, so the dashboard can offer to install it when it runs by hand.
+type ServiceInfo struct {
+	Installed bool   `json:"installed"`
+	Running   bool   `json:"running"`
+	Mode      string `json:"mode,omitempty"` // "service" or "task"
+	Path      string `json:"path,omitempty"` // unit file, plist, or Windows service/task name
+	Managed   bool   `json:"managed"`        // this process was started by the service manager
+}
+
+// OBSLaunchInfo describes the agent's attempts to start OBS itself (see
+// -obs-launch-path).
+type OBSLaunchInfo struct {
+	Attempts  int    `json:"attempts"`
+	LastAt    string `json:"last_at"`
+	LastError string `json:"last_error,omitempty"`
+}
+
+// RecordOBSLaunch records an attempt to start OBS and its error, if any.
+func (s *Server) RecordOBSLaunch(err error) {
+	s.mu.Lock()
+	if s.obsLaunch == nil {
+		s.obsLaunch = &OBSLaunchInfo{}
+	}
+	s.obsLaunch.Attempts++
+	s.obsLaunch.LastAt = time.Now().Format(time.RFC3339)
+	s.obsLaunch.LastError = ""
+	if err != nil {
+		s.obsLaunch.LastError = err.Error()
+	}
+	s.mu.Unlock()
+	s.notifySubscribers()
+}
+
+// SetService records the startup registration reported in /api/status.
+func (s *Server) SetService(info ServiceInfo) {
+	s.mu.Lock()
+	changed := s.service == nil || *s.service != info
+	s.service = &info
 	s.mu.Unlock()
+
+	if changed {
+		s.notifySubscribers()
+	}
+}
+
+// IncRateLimited counts one relay message dropped by the rate limiter.
+func (s *Server) IncRateLimited() {
+	s.mu.Lock()
+	s.rateLimited++
+	s.mu.Unlock()
+	s.notifySubscribers()
 }
 
 // SetError sets the last error message.
 func (s *Server) SetError(err string) {
 	s.mu.Lock()
+	changed := s.lastError != err
 	s.lastError = err
 	s.mu.Unlock()
+
+	if changed {
+		s.notifySubscribers()
+	}
 }
 
-// SetOBSConnected updates OBS connection state and fires state change callback on transitions.
+// SetOBSConnected updates OBS connection state and fires state change callback
+// on transitions (connects only once stable, see SetConnectedDebounce).
 func (s *Server) SetOBSConnected(connected bool) {
 	s.mu.Lock()
 	prev := s.obsConn
 	s.obsConn = connected
-	cb := s.onStateChange
+	if prev || connected {
+		s.lastOBSConnectedAt = time.Now()
+	}
+	fire := s.transitionLocked(&s.obsNotify, connected,
+		"obs_connected", fmt.Sprintf("OBS connected (%s:%d)", s.obsHost, s.obsPort),
+		"obs_disconnected", fmt.Sprintf("OBS disconnected (%s:%d)", s.obsHost, s.obsPort))
 	s.mu.Unlock()
 
-	if cb != nil && prev != connected {
-		if connected {
-			cb("obs_connected", fmt.Sprintf("OBS connected (%s:%d)", s.obsHost, s.obsPort))
-		} else {
-			cb("obs_disconnected", fmt.Sprintf("OBS disconnected (%s:%d)", s.obsHost, s.obsPort))
-		}
+	if prev != connected {
+		s.notifySubscribers()
+	}
+	if fire != nil {
+		fire()
 	}
 }
 
-// SetRelayConnected updates relay connection state and fires state change callback on transitions.
+// SetRelayConnected updates relay connection state and fires state change callback
+// on transitions (connects only once stable, see SetConnectedDebounce).
 func (s *Server) SetRelayConnected(connected bool) {
 	s.mu.Lock()
 	prev := s.relayConn
 	s.relayConn = connected
-	cb := s.onStateChange
+	if prev || connected {
+		s.lastRelayConnectedAt = time.Now()
+	}
+	fire := s.transitionLocked(&s.relayNotify, connected,
+		"relay_connected", "Relay server connected",
+		"relay_disconnected", "Relay server disconnected")
 	s.mu.Unlock()
 
-	if cb != nil && prev != connected {
-		if connected {
-			cb("relay_connected", "Relay server connected")
-		} else {
-			cb("relay_disconnected", "Relay server disconnected")
-		}
+	if prev != connected {
+		s.notifySubscribers()
+	}
+	if fire != nil {
+		fire()
+	}
+}
+
+// MessageCounts are the bridge's message totals since the agent started.
+type MessageCounts struct {
+	ToOBS    int64 `json:"to_obs"`
+	ToRelay  int64 `json:"to_relay"`
+	Rejected int64 `json:"rejected"`
+}
+
+// SetMessageCounter sets the source of the message totals in /api/status.
+// fn is called with the server's lock held and must not call back into it.
+func (s *Server) SetMessageCounter(fn func() MessageCounts) {
+	s.mu.Lock()
+	s.messageCounter = fn
+	s.mu.Unlock()
+}
+
+// SetCurrentRelay records which relay the agent is connected through.
+func (s *Server) SetCurrentRelay(relayURL string) {
+	s.mu.Lock()
+	if s.currentRelayURL == relayURL {
+		s.mu.Unlock()
+		return
 	}
+	s.currentRelayURL = relayURL
+	s.mu.Unlock()
+	s.notifySubscribers()
 }
 
-func (s *Server) buildResponse() statusResponse {
+func (s *Server) buildResponse() Snapshot {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return statusResponse{
+	var messages MessageCounts
+	if s.messageCounter != nil {
+		messages = s.messageCounter()
+	}
+	var rotatedAt string
+	if !s.tokenRotatedAt.IsZero() {
+		rotatedAt = s.tokenRotatedAt.Format(time.RFC3339)
+	}
+	var obsLaunch *OBSLaunchInfo
+	if s.obsLaunch != nil {
+		l := *s.obsLaunch
+		obsLaunch = &l
+	}
+	return Snapshot{
 		Version:        s.version,
 		Status:         s.status,
 		OBSConnected:   s.obsConn,
@@ -252,9 +676,32 @@ func (s *Server) buildResponse() statusResponse {
 		StartedAt:      s.startedAt.Format(time.RFC3339),
 		LastError:      s.lastError,
 		PID:            os.Getpid(),
+		TokenRotatedAt: rotatedAt,
+		Runtime:        s.runtime,
+
+		OBSWebSocketVersion: s.obsWSVersion,
+		OBSRPCVersion:       s.obsRPCVersion,
+		OBSVersion:          s.obsVersion,
+		OBSPlatform:         s.obsPlatform,
+
+		Service:     s.service,
+		RateLimited: s.rateLimited,
+
+		CurrentRelayURL: s.currentRelayURL,
+
+		AgentName: s.agentName,
+
+		OBSLaunch: obsLaunch,
+
+		Messages: messages,
 	}
 }
 
+// Snapshot returns the current state, as /api/status would serve it.
+func (s *Server) Snapshot() Snapshot {
+	return s.buildResponse()
+}
+
 // handleRoot returns JSON status. No HTML — all UI is hosted at agent.4throck.cloud.
 func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
@@ -265,12 +712,103 @@ func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(s.buildResponse())
 }
 
-// handleAPIStatus always returns JSON (polled by the dashboard page).
+// handleAPIStatus always returns JSON (read by -status, and polled by the
+// dashboard page when /api/status/stream is unavailable).
 func (s *Server) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(s.buildResponse())
 }
 
+// sseKeepAlive is how often an idle SSE stream sends a comment line so
+// proxies and the browser don't time it out.
+const sseKeepAlive = 15 * time.Second
+
+// subscribe registers an SSE client. The returned channel is signalled
+// (non-blocking, capacity 1) whenever status changes.
+func (s *Server) subscribe() chan struct{} {
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch
+}
+
+func (s *Server) unsubscribe(ch chan struct{}) {
+	s.mu.Lock()
+	delete(s.subscribers, ch)
+	s.mu.Unlock()
+}
+
+// notifySubscribers wakes every SSE client. Coalesces bursts: a client that
+// hasn't consumed the previous signal just gets the latest state once.
+func (s *Server) notifySubscribers() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// handleStatusStream pushes a status JSON frame over Server-Sent Events
+// whenever state changes. /api/status remains for polling clients.
+func (s *Server) handleStatusStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", 500)
+		return
+	}
+
+	// The server-wide WriteTimeout would cut long-lived streams — clear it per request
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	send := func() bool {
+		data, err := json.Marshal(s.buildResponse())
+		if err != nil {
+			return false
+		}
+		if _, err := fmt.Fprintf(w, "event: status\ndata: %s\n\n", data); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+
+	// Initial frame so the client renders immediately
+	if !send() {
+		return
+	}
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ch:
+			if !send() {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
 // handleQuit triggers graceful shutdown via callback.
 func (s *Server) handleQuit(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
@@ -317,7 +855,129 @@ func (s *Server) handleReconfigure(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleRestart drops and re-establishes the agent connection via callback.
+func (s *Server) handleRestart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "POST only", 405)
+		return
+	}
+
+	s.mu.RLock()
+	cb := s.onRestart
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if cb != nil {
+		fmt.Fprint(w, `{"ok":true}`)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			cb()
+		}()
+	} else {
+		fmt.Fprint(w, `{"ok":false,"error":"no restart handler"}`)
+	}
+}
+
+// handleHealth serves GET /health. Without parameters it always returns
+// 200 {"ok":true} (the process is up). obs_timeout and relay_timeout (in
+// seconds) make it return 503 {"ok":false,"reason":"obs_disconnected"} (or
+// "relay_disconnected") once that link has been down for longer, so it can
+// back a container health check:
+//
+//	HEALTHCHECK --interval=30s --timeout=5s \
+//	  CMD wget -qO- "http://127.0.0.1:$PORT/health?obs_timeout=60&relay_timeout=60" || exit 1
+//
+// A link that never connected counts as down since the agent started.
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+
+	obsTimeout, err := healthTimeout(r, "obs_timeout")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"ok":false,"error":%q}`, err.Error())
+		return
+	}
+	relayTimeout, err := healthTimeout(r, "relay_timeout")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"ok":false,"error":%q}`, err.Error())
+		return
+	}
+
+	s.mu.RLock()
+	now := time.Now()
+	reason := ""
+	if obsTimeout > 0 && !s.obsConn && now.Sub(s.connectedAt(s.lastOBSConnectedAt)) > obsTimeout {
+		reason = "obs_disconnected"
+	} else if relayTimeout > 0 && !s.relayConn && now.Sub(s.connectedAt(s.lastRelayConnectedAt)) > relayTimeout {
+		reason = "relay_disconnected"
+	}
+	s.mu.RUnlock()
+
+	if reason != "" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, `{"ok":false,"reason":%q}`, reason)
+		return
+	}
 	fmt.Fprint(w, `{"ok":true}`)
 }
+
+// handleLivez is the Kubernetes liveness probe: 200 while the process can
+// serve HTTP, whatever the connection state, so a reconnect never gets the
+// pod restarted. A wedged agent loop shows on /readyz (see Heartbeat).
+//
+// The server listens on loopback unless -status-bind says otherwise, so
+// probe from inside the pod with an exec probe (e.g. wget -qO-
+// http://127.0.0.1:8765/livez), or bind 0.0.0.0 and widen
+// -status-allowlist to the kubelet for httpGet.
+func (s *Server) handleLivez(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"ok":true,"pid":%d}`, os.Getpid())
+}
+
+// handleReadyz is the Kubernetes readiness probe: 200 only while both OBS
+// and the relay are connected and the agent loop keeps beating, otherwise
+// 503 with the first problem.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	silent := s.HeartbeatSilence()
+	s.mu.RLock()
+	reason := ""
+	if silent > 0 {
+		reason = "agent_loop_stalled"
+	} else if !s.obsConn {
+		reason = "obs_disconnected"
+	} else if !s.relayConn {
+		reason = "relay_disconnected"
+	}
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if reason != "" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, `{"ok":false,"reason":%q}`, reason)
+		return
+	}
+	fmt.Fprint(w, `{"ok":true}`)
+}
+
+// connectedAt falls back to the start time for a link that never connected.
+// Caller holds s.mu.
+func (s *Server) connectedAt(t time.Time) time.Time {
+	if t.IsZero() {
+		return s.startedAt
+	}
+	return t
+}
+
+// healthTimeout parses a /health threshold in whole seconds (0 = unset).
+func healthTimeout(r *http.Request, name string) (time.Duration, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative number of seconds", name)
+	}
+	return time.Duration(n) * time.Second, nil
+}
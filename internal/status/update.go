@@ -0,0 +1,61 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// UpdateInfo is a newer agent release announced by the relay in the
+// tunnel handshake.
+type UpdateInfo struct {
+	Version     string `json:"version"`
+	DownloadURL string `json:"download_url"`
+	SHA256      string `json:"sha256,omitempty"`
+	ReceivedAt  string `json:"received_at"`
+}
+
+// SetUpdateHandler sets the callback run when SetUpdateInfo stores a
+// version it has not seen before. It runs on its own goroutine, so it may
+// block (e.g. on a wizard waiting for the user).
+func (s *Server) SetUpdateHandler(fn func(UpdateInfo)) {
+	s.mu.Lock()
+	s.onUpdate = fn
+	s.mu.Unlock()
+}
+
+// SetUpdateInfo stores an update announcement for /api/update-info. The
+// relay repeats it on every handshake; only a new version reaches the
+// update handler.
+func (s *Server) SetUpdateInfo(version, downloadURL, sha256 string) {
+	info := UpdateInfo{
+		Version:     version,
+		DownloadURL: downloadURL,
+		SHA256:      sha256,
+		ReceivedAt:  time.Now().UTC().Format(time.RFC3339),
+	}
+	s.mu.Lock()
+	seen := s.updateInfo != nil && s.updateInfo.Version == version
+	s.updateInfo = &info
+	fn := s.onUpdate
+	s.mu.Unlock()
+
+	if !seen && fn != nil {
+		go fn(info)
+	}
+}
+
+// handleUpdateInfo serves GET /api/update-info (open): the running version
+// and the last update the relay announced, if any.
+func (s *Server) handleUpdateInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	info := s.updateInfo
+	s.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"current_version": s.version,
+		"available":       info != nil,
+		"update":          info,
+	})
+}
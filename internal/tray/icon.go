@@ -0,0 +1,76 @@
+package tray
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"runtime"
+	"sync"
+)
+
+const iconSize = 32
+
+var (
+	iconMu    sync.Mutex
+	iconCache = map[Status][]byte{}
+)
+
+// icon returns the tray image for s: a filled dot, grey when disconnected,
+// amber while connecting and green when connected. Windows wants an .ico,
+// the macOS menu bar a PNG.
+func icon(s Status) []byte {
+	iconMu.Lock()
+	defer iconMu.Unlock()
+	if b, ok := iconCache[s]; ok {
+		return b
+	}
+	b := dotPNG(iconColor(s))
+	if runtime.GOOS == "windows" {
+		b = pngToICO(b)
+	}
+	iconCache[s] = b
+	return b
+}
+
+func iconColor(s Status) color.NRGBA {
+	switch s {
+	case StatusConnected:
+		return color.NRGBA{R: 0x2e, G: 0xb8, B: 0x5c, A: 0xff}
+	case StatusConnecting:
+		return color.NRGBA{R: 0xf0, G: 0xa0, B: 0x20, A: 0xff}
+	default:
+		return color.NRGBA{R: 0x8a, G: 0x8a, B: 0x8a, A: 0xff}
+	}
+}
+
+func dotPNG(c color.NRGBA) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, iconSize, iconSize))
+	const r = iconSize/2 - 2
+	for y := 0; y < iconSize; y++ {
+		for x := 0; x < iconSize; x++ {
+			dx, dy := x-iconSize/2, y-iconSize/2
+			if dx*dx+dy*dy <= r*r {
+				img.SetNRGBA(x, y, c)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img) // can't fail writing to a bytes.Buffer
+	return buf.Bytes()
+}
+
+// pngToICO wraps a PNG in a single-image .ico container (PNG-compressed
+// icon entries are supported since Windows Vista).
+func pngToICO(p []byte) []byte {
+	var buf bytes.Buffer
+	// ICONDIR: reserved, type 1 (icon), one image
+	binary.Write(&buf, binary.LittleEndian, [3]uint16{0, 1, 1})
+	// ICONDIRENTRY
+	buf.Write([]byte{iconSize, iconSize, 0, 0})
+	binary.Write(&buf, binary.LittleEndian, [2]uint16{1, 32}) // planes, bpp
+	binary.Write(&buf, binary.LittleEndian, [2]uint32{uint32(len(p)), 6 + 16})
+	buf.Write(p)
+	return buf.Bytes()
+}
@@ -1,11 +1,12 @@
-package tray
-
-// System tray functionality is optional and requires CGO + platform-specific
-// build tags (getlantern/systray). For now, provide a no-op implementation
-// that compiles cross-platform without CGO.
+// Package tray shows the agent in the Windows notification area or the
+// macOS menu bar: an icon for the connection state, a tooltip with the
+// version and OBS target, and Open Dashboard / Reconfigure / Quit items.
 //
-// When building with CGO_ENABLED=1 on the target platform, the systray
-// implementation in tray_cgo.go will be used instead.
+// The icon needs the platform GUI toolkit, so it is only built with CGO
+// on Windows and macOS (tray_cgo.go). Other builds, including the
+// CGO_ENABLED=0 release builds, get the no-op in tray_nocgo.go; check
+// Supported before handing the main thread to Run.
+package tray
 
 // Status represents the agent connection state
 type Status int
@@ -16,31 +17,37 @@ const (
 	StatusConnected
 )
 
-// Tray provides system tray integration (no-op without CGO)
-type Tray struct {
-	status Status
-	onQuit func()
-}
-
-// New creates a new system tray (no-op in CGO_ENABLED=0 builds)
-func New(onQuit func()) *Tray {
-	return &Tray{
-		status: StatusDisconnected,
-		onQuit: onQuit,
+// StatusFor maps an agent status string (as passed to
+// status.Server.SetStatus) to the tray state.
+func StatusFor(s string) Status {
+	switch s {
+	case "connected":
+		return StatusConnected
+	case "", "stopped", "token_rejected":
+		return StatusDisconnected
+	default:
+		return StatusConnecting
 	}
 }
 
-// SetStatus updates the tray icon (no-op without CGO)
-func (t *Tray) SetStatus(s Status) {
-	t.status = s
+func (s Status) String() string {
+	switch s {
+	case StatusConnected:
+		return "Connected"
+	case StatusConnecting:
+		return "Connecting…"
+	default:
+		return "Disconnected"
+	}
 }
 
-// Run starts the tray event loop (no-op without CGO)
-func (t *Tray) Run() {
-	// No-op: in headless mode, the agent runs without a tray icon
-}
+// Options configures the tray. Callbacks may be nil and are called on the
+// tray's goroutine; they must not block.
+type Options struct {
+	Version   string
+	OBSTarget string // host:port shown in the tooltip
 
-// Stop cleans up the tray (no-op without CGO)
-func (t *Tray) Stop() {
-	// No-op
+	OnOpenDashboard func()
+	OnReconfigure   func()
+	OnQuit          func()
 }
@@ -0,0 +1,102 @@
+//go:build cgo && (windows || darwin)
+
+package tray
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+
+	"fyne.io/systray"
+)
+
+// Supported reports whether this build can show a tray icon.
+const Supported = true
+
+// The macOS menu bar must be driven from the main thread. Locking it in
+// an init function keeps the main goroutine there, so main can call Run.
+func init() {
+	runtime.LockOSThread()
+}
+
+// Tray is the notification-area / menu-bar icon.
+type Tray struct {
+	opts Options
+
+	mu     sync.Mutex
+	status Status
+	ready  bool
+	state  *systray.MenuItem
+}
+
+// New creates a tray; nothing is shown until Run.
+func New(opts Options) *Tray {
+	return &Tray{
+		status: StatusDisconnected,
+		opts:   opts,
+	}
+}
+
+// SetStatus switches the icon and tooltip. Safe from any goroutine, before
+// or after Run.
+func (t *Tray) SetStatus(s Status) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status = s
+	if t.ready {
+		t.applyLocked()
+	}
+}
+
+// Run shows the icon and blocks until Stop. Must be called from main.
+func (t *Tray) Run() {
+	systray.Run(t.onReady, nil)
+}
+
+// Stop removes the icon and makes Run return.
+func (t *Tray) Stop() {
+	systray.Quit()
+}
+
+func (t *Tray) onReady() {
+	systray.SetTitle("")
+	t.mu.Lock()
+	t.state = systray.AddMenuItem("", "")
+	t.state.Disable()
+	t.mu.Unlock()
+	systray.AddSeparator()
+	dashboard := systray.AddMenuItem("Open Dashboard", "Show agent status in the browser")
+	reconfigure := systray.AddMenuItem("Reconfigure", "Change OBS connection settings")
+	systray.AddSeparator()
+	quit := systray.AddMenuItem("Quit", "Stop the agent")
+
+	t.mu.Lock()
+	t.ready = true
+	t.applyLocked()
+	t.mu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-dashboard.ClickedCh:
+				call(t.opts.OnOpenDashboard)
+			case <-reconfigure.ClickedCh:
+				call(t.opts.OnReconfigure)
+			case <-quit.ClickedCh:
+				call(t.opts.OnQuit)
+			}
+		}
+	}()
+}
+
+func (t *Tray) applyLocked() {
+	systray.SetIcon(icon(t.status))
+	systray.SetTooltip(fmt.Sprintf("4thRock OBS Agent %s — %s\nOBS: %s", t.opts.Version, t.status, t.opts.OBSTarget))
+	t.state.SetTitle(t.status.String())
+}
+
+func call(fn func()) {
+	if fn != nil {
+		fn()
+	}
+}
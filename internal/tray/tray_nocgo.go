@@ -0,0 +1,35 @@
+//go:build !cgo || !(windows || darwin)
+
+package tray
+
+// Supported reports whether this build can show a tray icon.
+const Supported = false
+
+// Tray provides system tray integration (no-op without CGO)
+type Tray struct {
+	status Status
+	opts   Options
+}
+
+// New creates a new system tray (no-op in CGO_ENABLED=0 builds)
+func New(opts Options) *Tray {
+	return &Tray{
+		status: StatusDisconnected,
+		opts:   opts,
+	}
+}
+
+// SetStatus updates the tray icon (no-op without CGO)
+func (t *Tray) SetStatus(s Status) {
+	t.status = s
+}
+
+// Run starts the tray event loop (no-op without CGO)
+func (t *Tray) Run() {
+	// No-op: in headless mode, the agent runs without a tray icon
+}
+
+// Stop cleans up the tray (no-op without CGO)
+func (t *Tray) Stop() {
+	// No-op
+}
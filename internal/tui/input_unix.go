@@ -0,0 +1,20 @@
+//go:build !windows
+
+package tui
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// enableVT reports whether f understands ANSI escapes; Unix terminals do.
+func enableVT(f *os.File) bool { return true }
+
+// waitInput reports whether f has input ready within timeout.
+func waitInput(f *os.File, timeout time.Duration) bool {
+	fds := []unix.PollFd{{Fd: int32(f.Fd()), Events: unix.POLLIN}}
+	n, err := unix.Poll(fds, int(timeout.Milliseconds()))
+	return err == nil && n > 0
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package tui
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableVT turns on ANSI escape processing for the console behind f
+// (Windows 10 and later); false means only the plain view will work.
+func enableVT(f *os.File) bool {
+	h := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return false
+	}
+	return windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
+
+// waitInput reports whether the console behind f has input ready within
+// timeout.
+func waitInput(f *os.File, timeout time.Duration) bool {
+	ev, err := windows.WaitForSingleObject(windows.Handle(f.Fd()), uint32(timeout.Milliseconds()))
+	return err == nil && ev == windows.WAIT_OBJECT_0
+}
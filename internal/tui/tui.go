@@ -0,0 +1,360 @@
+// Package tui renders the -tui terminal dashboard: the status server's
+// state and the recent log, redrawn every second in the alternate screen.
+// Terminals without cursor addressing get a plain text block instead,
+// reprinted every plainInterval.
+package tui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/4throck/obs-agent/internal/branding"
+	"github.com/4throck/obs-agent/internal/status"
+	"golang.org/x/term"
+)
+
+const (
+	refreshInterval = time.Second
+	plainInterval   = 10 * time.Second
+	plainLogLines   = 5
+
+	// inputPoll bounds how long the key reader waits before re-checking
+	// whether it was stopped or suspended.
+	inputPoll = 200 * time.Millisecond
+)
+
+// ANSI sequences for the full-screen view.
+const (
+	enterAltScreen = "\033[?1049h\033[?25l"
+	leaveAltScreen = "\033[?25h\033[?1049l"
+	cursorHome     = "\033[H"
+	clearLine      = "\033[K"
+	clearBelow     = "\033[J"
+)
+
+// Options wires the dashboard to the agent.
+type Options struct {
+	Version string
+	// Snapshot returns the state to show (status.Server.Snapshot).
+	Snapshot func() status.Snapshot
+	// Logs returns up to n recent log lines, oldest first.
+	Logs func(n int) []string
+	// OnQuit and OnReconfigure are called for the q and r keys.
+	OnQuit        func()
+	OnReconfigure func()
+}
+
+// TUI is a running dashboard. All methods are safe on a nil *TUI.
+type TUI struct {
+	opts  Options
+	out   *os.File
+	in    *os.File
+	fancy bool // cursor addressing available
+	keys  bool // stdin is a terminal we can read keys from
+
+	mu        sync.Mutex
+	suspended bool
+	rawState  *term.State
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// New prepares a dashboard on stdout. It draws nothing until Run.
+func New(opts Options) *TUI {
+	t := &TUI{
+		opts: opts,
+		out:  os.Stdout,
+		in:   os.Stdin,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	if term.IsTerminal(int(t.out.Fd())) && branding.HasColor() && enableVT(t.out) {
+		t.fancy = true
+		t.keys = term.IsTerminal(int(t.in.Fd()))
+	}
+	return t
+}
+
+// Run draws until Stop. Call it on its own goroutine.
+func (t *TUI) Run() {
+	if t == nil {
+		return
+	}
+	defer close(t.done)
+	if !t.fancy {
+		t.runPlain()
+		return
+	}
+
+	t.mu.Lock()
+	t.enter()
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		if !t.suspended {
+			t.leave()
+		}
+		t.mu.Unlock()
+	}()
+
+	keys := make(chan byte, 8)
+	if t.keys {
+		go t.readKeys(keys)
+	}
+
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	t.draw()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case k := <-keys:
+			t.handleKey(k)
+		case <-ticker.C:
+			t.draw()
+		}
+	}
+}
+
+// Stop restores the terminal and waits for Run to return.
+func (t *TUI) Stop() {
+	if t == nil {
+		return
+	}
+	t.stopOnce.Do(func() { close(t.stop) })
+	<-t.done
+}
+
+// Suspend hands the terminal back (e.g. to the setup wizard): the normal
+// screen and line-buffered input return, and keys are no longer read.
+func (t *TUI) Suspend() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.suspended {
+		t.suspended = true
+		if t.fancy {
+			t.leave()
+		}
+	}
+}
+
+// Resume takes the terminal back after Suspend.
+func (t *TUI) Resume() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.suspended {
+		t.suspended = false
+		if t.fancy {
+			t.enter()
+		}
+	}
+	t.mu.Unlock()
+	if t.fancy {
+		t.draw()
+	}
+}
+
+// enter switches to the alternate screen and raw input. Caller holds t.mu.
+func (t *TUI) enter() {
+	fmt.Fprint(t.out, enterAltScreen)
+	if t.keys {
+		if st, err := term.MakeRaw(int(t.in.Fd())); err == nil {
+			t.rawState = st
+		}
+	}
+}
+
+// leave undoes enter. Caller holds t.mu.
+func (t *TUI) leave() {
+	if t.rawState != nil {
+		term.Restore(int(t.in.Fd()), t.rawState)
+		t.rawState = nil
+	}
+	fmt.Fprint(t.out, leaveAltScreen)
+}
+
+// readKeys forwards single key presses until Stop. Input is only read when
+// available and the dashboard is not suspended, so nothing is taken from
+// a wizard that owns the terminal meanwhile.
+func (t *TUI) readKeys(keys chan<- byte) {
+	buf := make([]byte, 1)
+	for {
+		select {
+		case <-t.stop:
+			return
+		default:
+		}
+		t.mu.Lock()
+		suspended := t.suspended
+		t.mu.Unlock()
+		if suspended {
+			time.Sleep(inputPoll)
+			continue
+		}
+		if !waitInput(t.in, inputPoll) {
+			continue
+		}
+		if n, err := t.in.Read(buf); err != nil {
+			return
+		} else if n == 1 {
+			select {
+			case keys <- buf[0]:
+			case <-t.stop:
+				return
+			}
+		}
+	}
+}
+
+func (t *TUI) handleKey(k byte) {
+	t.mu.Lock()
+	suspended := t.suspended
+	t.mu.Unlock()
+	if suspended {
+		return
+	}
+	switch k {
+	case 'q', 'Q', 3: // 3 = Ctrl+C, which raw mode delivers as a byte
+		if t.opts.OnQuit != nil {
+			t.opts.OnQuit()
+		}
+	case 'r', 'R':
+		if t.opts.OnReconfigure != nil {
+			t.opts.OnReconfigure()
+		}
+	}
+}
+
+// draw repaints the screen in place (home, overwrite, clear the rest) to
+// avoid the flicker of a full clear.
+func (t *TUI) draw() {
+	width, height, err := term.GetSize(int(t.out.Fd()))
+	if err != nil || width < 20 || height < 10 {
+		width, height = 80, 24
+	}
+
+	lines := t.header(width)
+	lines = append(lines, "", branding.White("Recent log"))
+	room := height - len(lines) - 2
+	if room > 0 && t.opts.Logs != nil {
+		for _, l := range t.opts.Logs(room) {
+			lines = append(lines, branding.Dim(truncate(l, width)))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(cursorHome)
+	for _, l := range lines {
+		b.WriteString(l + clearLine + "\r\n")
+	}
+	b.WriteString(clearBelow)
+	// Key help on the last row
+	fmt.Fprintf(&b, "\033[%d;1H%s%s", height, branding.Dim(t.help()), clearLine)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.suspended {
+		io.WriteString(t.out, b.String())
+	}
+}
+
+func (t *TUI) help() string {
+	if !t.keys {
+		return "Ctrl+C to quit"
+	}
+	return "q quit · r reconfigure"
+}
+
+// header renders the state block shared by both views. width > 0 keeps
+// the free-text last error on one line.
+func (t *TUI) header(width int) []string {
+	var snap status.Snapshot
+	if t.opts.Snapshot != nil {
+		snap = t.opts.Snapshot()
+	}
+
+	obs := fmt.Sprintf("%s:%d", snap.OBSHost, snap.OBSPort)
+	if snap.OBSWebSocketVersion != "" {
+		obs += fmt.Sprintf(" (obs-websocket %s)", snap.OBSWebSocketVersion)
+	}
+	relay := snap.RelayURL
+	if snap.CurrentRelayURL != "" {
+		relay = snap.CurrentRelayURL
+	}
+	lastErr := snap.LastError
+	if lastErr == "" {
+		lastErr = "—"
+	} else if width > 0 {
+		lastErr = truncate(lastErr, width-14)
+	}
+	m := snap.Messages
+	name := snap.AgentName
+	if name == "" {
+		name = "—"
+	}
+
+	return []string{
+		branding.BoldOrange("4thRock OBS Agent") + " " + t.opts.Version + "   " + branding.Dim("status: ") + snap.Status,
+		"",
+		fmt.Sprintf("  %-11s %s", "Agent", name),
+		fmt.Sprintf("  %-11s %s", "Uptime", time.Duration(snap.UptimeSeconds)*time.Second),
+		fmt.Sprintf("  %-11s %s  %s", "OBS", connState(snap.OBSConnected), obs),
+		fmt.Sprintf("  %-11s %s  %s", "Relay", connState(snap.RelayConnected), relay),
+		fmt.Sprintf("  %-11s %s", "Last error", lastErr),
+		fmt.Sprintf("  %-11s to OBS %d · to relay %d · rejected %d · rate-limited %d", "Messages", m.ToOBS, m.ToRelay, m.Rejected, snap.RateLimited),
+	}
+}
+
+func connState(connected bool) string {
+	if connected {
+		return branding.Green("● connected   ")
+	}
+	return branding.Red("○ disconnected")
+}
+
+// runPlain reprints the state block and the last few log lines until Stop.
+func (t *TUI) runPlain() {
+	ticker := time.NewTicker(plainInterval)
+	defer ticker.Stop()
+	for {
+		lines := t.header(0)
+		if t.opts.Logs != nil {
+			lines = append(lines, "")
+			lines = append(lines, t.opts.Logs(plainLogLines)...)
+		}
+		t.mu.Lock()
+		if !t.suspended {
+			fmt.Fprintln(t.out, strings.Join(lines, "\n"))
+			fmt.Fprintln(t.out, strings.Repeat("─", 40))
+		}
+		t.mu.Unlock()
+
+		select {
+		case <-t.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// truncate shortens s to at most width runes.
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:width-1]) + "…"
+}
@@ -0,0 +1,156 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// destructiveRequests are whitelisted but irreversible mid-show; with
+// approval mode on they are held until the local user confirms them.
+var destructiveRequests = map[string]bool{
+	"RemoveScene":     true,
+	"RemoveSceneItem": true,
+	"RemoveInput":     true,
+	"StopStream":      true,
+}
+
+// DefaultApprovalTimeout is how long a destructive request waits for the
+// local user before being denied.
+const DefaultApprovalTimeout = 30 * time.Second
+
+// ConfirmFunc asks the local user to approve a request. Returns true to
+// forward it. It must give up and return false once ctx is done, so a
+// timed-out prompt never lingers to answer the next one.
+type ConfirmFunc func(ctx context.Context, title, message string) bool
+
+// ApprovalQueue serialises local confirmations for destructive requests.
+// Only one prompt is shown at a time; later requests wait their turn and
+// are auto-denied if their timeout passes first.
+type ApprovalQueue struct {
+	confirm ConfirmFunc
+	timeout time.Duration
+	turn    chan struct{} // capacity 1 — holder owns the prompt
+}
+
+// NewApprovalQueue creates a queue using confirm for prompts. A zero timeout
+// uses DefaultApprovalTimeout.
+func NewApprovalQueue(confirm ConfirmFunc, timeout time.Duration) *ApprovalQueue {
+	if timeout <= 0 {
+		timeout = DefaultApprovalTimeout
+	}
+	return &ApprovalQueue{
+		confirm: confirm,
+		timeout: timeout,
+		turn:    make(chan struct{}, 1),
+	}
+}
+
+// Approve blocks until the user answers, the timeout elapses (deny), or ctx
+// is cancelled (deny). The timeout covers time spent waiting in the queue.
+// The prompt is cancelled rather than abandoned, so at most one is ever open.
+func (q *ApprovalQueue) Approve(ctx context.Context, requestTypes []string) bool {
+	ctx, cancel := context.WithTimeout(ctx, q.timeout)
+	defer cancel()
+
+	select {
+	case q.turn <- struct{}{}:
+	case <-ctx.Done():
+		return false
+	}
+	defer func() { <-q.turn }()
+
+	ok := q.confirm(ctx, "Approve OBS action?",
+		"The dashboard wants to run: "+strings.Join(requestTypes, ", ")+
+			"\n\nThis cannot be undone. Allow it?")
+	if ctx.Err() != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			log.Printf("[bridge] Approval timed out for %s — denied", strings.Join(requestTypes, ", "))
+		}
+		return false
+	}
+	return ok
+}
+
+// destructiveTypes returns the destructive request types in an op 6 or op 8
+// payload, or nil if there are none.
+func destructiveTypes(msg *obsMessage) []string {
+	if msg == nil || msg.D == nil {
+		return nil
+	}
+	var found []string
+	switch msg.Op {
+	case 6:
+		var req obsRequestData
+		if json.Unmarshal(*msg.D, &req) == nil && destructiveRequests[req.RequestType] {
+			found = append(found, req.RequestType)
+		}
+	case 8:
+		var batch obsRequestBatchData
+		if json.Unmarshal(*msg.D, &batch) == nil {
+			for _, req := range batch.Requests {
+				if destructiveRequests[req.RequestType] {
+					found = append(found, req.RequestType)
+				}
+			}
+		}
+	}
+	return found
+}
+
+// deniedResponse builds the op 7 / op 9 reply sent to the relay when the
+// local user denies (or doesn't answer) a destructive request.
+func deniedResponse(msg *obsMessage) []byte {
+	return failedResponse(msg, "Denied by local user")
+}
+
+// failedResponse builds an op 7 / op 9 reply that fails every request in
+// msg with RequestProcessingFailed and comment, so the relay isn't left
+// waiting for a request the agent dropped.
+func failedResponse(msg *obsMessage, comment string) []byte {
+	const code = 700 // RequestProcessingFailed
+	status := map[string]interface{}{
+		"result":  false,
+		"code":    code,
+		"comment": comment,
+	}
+
+	var d struct {
+		RequestType string            `json:"requestType"`
+		RequestID   string            `json:"requestId"`
+		Requests    []json.RawMessage `json:"requests"`
+	}
+	json.Unmarshal(*msg.D, &d)
+
+	var resp map[string]interface{}
+	if msg.Op == 8 {
+		results := make([]interface{}, 0, len(d.Requests))
+		for _, raw := range d.Requests {
+			var r struct {
+				RequestType string `json:"requestType"`
+				RequestID   string `json:"requestId"`
+			}
+			json.Unmarshal(raw, &r)
+			results = append(results, map[string]interface{}{
+				"requestType":   r.RequestType,
+				"requestId":     r.RequestID,
+				"requestStatus": status,
+			})
+		}
+		resp = map[string]interface{}{"op": 9, "d": map[string]interface{}{
+			"requestId": d.RequestID,
+			"results":   results,
+		}}
+	} else {
+		resp = map[string]interface{}{"op": 7, "d": map[string]interface{}{
+			"requestType":   d.RequestType,
+			"requestId":     d.RequestID,
+			"requestStatus": status,
+		}}
+	}
+	out, _ := json.Marshal(resp)
+	return out
+}
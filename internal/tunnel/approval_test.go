@@ -0,0 +1,65 @@
+package tunnel
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestApprovalTimeoutCancelsPrompt(t *testing.T) {
+	var open atomic.Int32
+	q := NewApprovalQueue(func(ctx context.Context, title, message string) bool {
+		open.Add(1)
+		defer open.Add(-1)
+		<-ctx.Done()
+		return true // an answer after the deadline must not count
+	}, 50*time.Millisecond)
+
+	if q.Approve(context.Background(), []string{"RemoveScene"}) {
+		t.Fatal("timed-out prompt approved the request")
+	}
+	if n := open.Load(); n != 0 {
+		t.Fatalf("%d prompt(s) still open after Approve returned", n)
+	}
+}
+
+func TestApprovalAnswer(t *testing.T) {
+	for _, answer := range []bool{true, false} {
+		q := NewApprovalQueue(func(ctx context.Context, title, message string) bool {
+			return answer
+		}, time.Second)
+		if got := q.Approve(context.Background(), []string{"StopStream"}); got != answer {
+			t.Errorf("Approve = %v, want %v", got, answer)
+		}
+	}
+}
+
+func TestDeniedResponseDelivered(t *testing.T) {
+	q := NewApprovalQueue(func(ctx context.Context, title, message string) bool {
+		return false
+	}, time.Second)
+	check := ValidateOBSProtocol([]byte(`{"op":6,"d":{"requestType":"RemoveScene","requestId":"r1"}}`), ToAgent, 0)
+	if !check.Valid {
+		t.Fatalf("fixture rejected: %s", check.Reason)
+	}
+
+	// Unbuffered: the denial has to wait for the writer instead of being dropped
+	relaySend := make(chan []byte)
+	done := make(chan struct{})
+	go func() {
+		awaitApproval(context.Background(), q, nil, check.Parsed, nil, []string{"RemoveScene"}, relaySend, BridgeOptions{}, nil)
+		close(done)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case resp := <-relaySend:
+		if len(resp) == 0 {
+			t.Fatal("empty denial")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("denial was not delivered")
+	}
+	<-done
+}
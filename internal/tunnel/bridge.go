@@ -2,12 +2,17 @@ package tunnel
 
 import (
 	"context"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sync/atomic"
 	"time"
 
 	"github.com/4throck/obs-agent/internal/monitor"
+	"github.com/4throck/obs-agent/internal/obs"
+	"github.com/4throck/obs-agent/internal/snapshot"
 	"github.com/gorilla/websocket"
 )
 
@@ -17,8 +22,102 @@ const (
 	pingInterval   = 30 * time.Second
 	obsReadTimeout = 90 * time.Second
 	relaySendCap   = 64
+	obsReadLimit   = 1024 * 1024 // matches obs.Connect
+
+	// DefaultOBSReconnectWindow is how long the bridge waits for OBS to come
+	// back after a scene-collection switch before giving up.
+	DefaultOBSReconnectWindow = 10 * time.Second
+
+	// DefaultDrainTimeout is how long the bridge waits on shutdown for OBS
+	// to answer requests it already forwarded.
+	DefaultDrainTimeout = 2 * time.Second
+	// flushTimeout bounds sending what is queued for the relay after that.
+	flushTimeout = time.Second
+	drainPoll    = 20 * time.Millisecond
 )
 
+// BridgeOptions tunes EnvelopeBridge behaviour. Zero values use the defaults.
+type BridgeOptions struct {
+	// OBSReconnectWindow bounds the OBS-only reconnect during a scene-collection
+	// switch. Negative disables it, and the OBS-only reconnect after any
+	// other OBS drop (any OBS drop then restarts the whole session).
+	OBSReconnectWindow time.Duration
+
+	// OnOBSLost and OnOBSRestored, when set, are called when the bridge
+	// loses OBS outside a scene-collection switch and when its OBS-only
+	// reconnect succeeds, with the new connection's handshake info.
+	OnOBSLost     func()
+	OnOBSRestored func(obs.Info)
+
+	// Approvals, when set, holds destructive requests until the local user
+	// confirms them. Nil forwards everything that passes the whitelist.
+	Approvals *ApprovalQueue
+
+	// RateLimiter, when set, drops requests arriving faster than their
+	// type's bucket allows. Nil forwards at any rate.
+	RateLimiter *RateLimiter
+
+	// Stats, when set, counts forwarded and rejected messages.
+	Stats *BridgeStats
+
+	// Metrics receives latency and message-size measurements.
+	Metrics BridgeMetrics
+
+	// OnSnapshot, when set, receives the scene/source snapshot JSON after
+	// every refresh (see snapshot.Collector).
+	OnSnapshot func([]byte)
+
+	// OnEvent, when set, receives every OBS event (raw op 5 JSON) bridged
+	// to the relay, e.g. for the -event-log file. It must not block.
+	OnEvent func([]byte)
+
+	// OnPong, when set, is called whenever the relay answers a keepalive
+	// ping. The ping goes through the relay writer and the pong is read by
+	// the relay reader, so it shows both are still moving. It must not block.
+	OnPong func()
+
+	// DrainTimeout bounds the graceful drain when ctx is cancelled (see
+	// EnvelopeBridge). Negative closes immediately.
+	DrainTimeout time.Duration
+
+	// MonitorOwnConnection gives the monitor OBS connections of its own
+	// instead of sending its requests over the bridge's (see obs.Client).
+	MonitorOwnConnection bool
+
+	// MaxBatchRequests caps the requests in a RequestBatch from the relay;
+	// larger batches are rejected as batch_too_large (0 =
+	// DefaultMaxBatchRequests).
+	MaxBatchRequests int
+
+	// MinOBSVersion is the oldest obs-websocket version a recovered OBS
+	// connection may report ("" = obs.DefaultMinVersion).
+	MinOBSVersion string
+
+	// PingInterval is how often the relay is pinged as a keepalive (0 =
+	// 30s).
+	PingInterval time.Duration
+}
+
+// skewWarnThreshold is how many envelopes in a row must fail the timestamp
+// check before the bridge blames the clock.
+const skewWarnThreshold = 3
+
+var skewWarned atomic.Bool
+
+// warnClockSkew logs, once per process, that the local clock looks wrong.
+// The envelopes passed HMAC first, so they really are from the relay.
+func warnClockSkew(skew, tolerance time.Duration) {
+	if skewWarned.Swap(true) {
+		return
+	}
+	dir := "ahead of"
+	if skew < 0 {
+		dir, skew = "behind", -skew
+	}
+	log.Printf("[bridge] WARNING: relay messages are %s %s this machine's clock (allowed ±%s) — the system clock may be wrong; enable NTP time sync or raise -clock-skew",
+		skew.Round(time.Second), dir, tolerance)
+}
+
 // EnvelopeBridge pipes messages bidirectionally between OBS and relay connections,
 // wrapping all messages in signed envelopes with OBS protocol validation.
 //
@@ -30,11 +129,34 @@ const (
 //
 // A channel-based relay writer serialises all writes to the relay connection
 // (OBS events, monitor events, pings) to prevent concurrent write panics.
-func EnvelopeBridge(ctx context.Context, obsConn, relayConn *websocket.Conn, sessionKey []byte, obsAddr, obsPass string) error {
-	ctx, cancel := context.WithCancel(ctx)
+//
+// When OBS switches scene collections it may briefly drop clients; the bridge
+// reconnects to OBS alone so the relay session does not flap. Any other OBS
+// drop (OBS restarted, say) is also retried on OBS alone, obsRecoverAttempts
+// times, before the bridge gives up the relay session as well.
+//
+// Cancelling ctx drains rather than cuts the session: new relay commands are
+// answered as failed, responses to requests OBS is already working on are
+// awaited for up to opts.DrainTimeout, and what is queued for the relay is
+// sent, followed by a close frame.
+func EnvelopeBridge(ctx context.Context, obsConn, relayConn *websocket.Conn, sess *Session, obsAddr, obsPass string, opts BridgeOptions) error {
+	parent := ctx
+	// The pipes outlive ctx for the drain; cancel stops them
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
 	defer cancel()
 
-	nonceCache := NewNonceCache()
+	window := opts.OBSReconnectWindow
+	if window == 0 {
+		window = DefaultOBSReconnectWindow
+	}
+	link := newOBSLink(obsConn, obsAddr, obsPass, opts.MinOBSVersion)
+	defer link.close()
+
+	// Share the handshake's nonce cache so envelopes seen there cannot replay
+	nonceCache := sess.nonces
+	if nonceCache == nil {
+		nonceCache = NewNonceCache(0, 0)
+	}
 	errCh := make(chan error, 3)
 
 	// Channel-based relay writer: nil = ping, otherwise raw payload to seal.
@@ -49,12 +171,49 @@ func EnvelopeBridge(ctx context.Context, obsConn, relayConn *websocket.Conn, ses
 			// Drop if channel full — transient back-pressure
 		}
 	})
+	mon.SetResponseHook(opts.Metrics.OBSResponse)
+	// Unless told otherwise the monitor's requests share the bridge's OBS
+	// connection, so OBS lists one client per agent
+	var obsClient *obs.Client
+	if !opts.MonitorOwnConnection {
+		obsClient = obs.NewClient(link.write)
+		defer obsClient.Close()
+		mon.SetSharedClient(obsClient)
+	}
 	defer mon.Stop()
 
+	// Scene/source snapshot, pushed to the relay as AgentSnapshot on change
+	snap := snapshot.New(obsAddr, obsPass)
+	snap.SetSendEvent(func(eventBytes []byte) {
+		select {
+		case relaySend <- eventBytes:
+		default:
+		}
+	})
+	snap.SetOnSnapshot(opts.OnSnapshot)
+	go snap.Run(ctx)
+
+	// Pings carry their send time; the relay echoes it in the pong
+	relayConn.SetPongHandler(func(data string) error {
+		if len(data) == 8 {
+			sent := time.Unix(0, int64(binary.BigEndian.Uint64([]byte(data))))
+			opts.Metrics.relayRTT(time.Since(sent))
+		}
+		if opts.OnPong != nil {
+			opts.OnPong()
+		}
+		return nil
+	})
+	pending := newPendingRequests(opts.Metrics.OBSResponse)
+	var draining atomic.Bool
+	flush := make(chan struct{})
+	writerDone := make(chan struct{})
+
 	// Relay writer goroutine — sole writer to relayConn
 	go func() {
+		defer close(writerDone)
 		defer cancel()
-		err := relayWriter(ctx, relayConn, sessionKey, relaySend)
+		err := relayWriter(ctx, relayConn, sess, relaySend, flush)
 		errCh <- fmt.Errorf("relay writer closed: %w", err)
 	}()
 
@@ -62,20 +221,24 @@ func EnvelopeBridge(ctx context.Context, obsConn, relayConn *websocket.Conn, ses
 	// AgentConfigureMonitor requests are intercepted and handled locally.
 	go func() {
 		defer cancel()
-		err := pipeRelayToOBS(ctx, relayConn, obsConn, sessionKey, nonceCache, mon, relaySend)
+		err := pipeRelayToOBS(ctx, relayConn, link, sess, nonceCache, mon, relaySend, opts, pending, &draining)
 		errCh <- fmt.Errorf("relay→OBS pipe closed: %w", err)
 	}()
 
 	// OBS → Relay: validate OBS protocol → send raw payload via channel (writer seals)
 	go func() {
 		defer cancel()
-		err := pipeOBSToRelay(ctx, obsConn, relaySend)
+		err := pipeOBSToRelay(ctx, link, window, mon, obsClient, snap, relaySend, opts, pending)
 		errCh <- fmt.Errorf("OBS→relay pipe closed: %w", err)
 	}()
 
 	// Ping relay to keep connection alive (sends nil to channel → writer sends WS ping)
+	interval := opts.PingInterval
+	if interval <= 0 {
+		interval = pingInterval
+	}
 	go func() {
-		ticker := time.NewTicker(pingInterval)
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 		for {
 			select {
@@ -90,21 +253,78 @@ func EnvelopeBridge(ctx context.Context, obsConn, relayConn *websocket.Conn, ses
 		}
 	}()
 
+	// Every exit lets the relay writer send a close frame first, so the
+	// relay sees a clean disconnect rather than a TCP drop
 	select {
 	case err := <-errCh:
+		cancel()
+		awaitWriter(writerDone)
 		return err
 	case <-ctx.Done():
+		awaitWriter(writerDone)
 		return ctx.Err()
+	case <-parent.Done():
+		drain(ctx, opts.DrainTimeout, &draining, pending, flush, writerDone)
+		return parent.Err()
 	}
 }
 
+// awaitWriter gives the relay writer up to flushTimeout to finish.
+func awaitWriter(writerDone <-chan struct{}) {
+	select {
+	case <-writerDone:
+	case <-time.After(flushTimeout):
+	}
+}
+
+// drain stops new relay commands, waits up to timeout (0 = default,
+// negative = not at all) for OBS to answer the requests in flight, then has
+// the relay writer flush its queue and close the connection.
+func drain(ctx context.Context, timeout time.Duration, draining *atomic.Bool, pending *pendingRequests, flush chan<- struct{}, writerDone <-chan struct{}) {
+	if timeout == 0 {
+		timeout = DefaultDrainTimeout
+	}
+	draining.Store(true)
+
+	deadline := time.Now().Add(timeout)
+	for pending.inFlight() > 0 && time.Now().Before(deadline) && ctx.Err() == nil {
+		time.Sleep(drainPoll)
+	}
+	if n := pending.inFlight(); n > 0 {
+		log.Printf("[bridge] Closing with %d OBS request(s) unanswered", n)
+	}
+
+	close(flush)
+	awaitWriter(writerDone)
+}
+
 // relayWriter is the sole goroutine that writes to relayConn.
 // nil payloads are sent as WS ping frames; non-nil payloads are sealed in envelopes.
-func relayWriter(ctx context.Context, relay *websocket.Conn, sessionKey []byte, ch <-chan []byte) error {
+// Large payloads are gzip-sealed when the session negotiated it. Once flush
+// is closed it sends what is queued, then a close frame, and returns; when
+// ctx is done it sends just the close frame.
+func relayWriter(ctx context.Context, relay *websocket.Conn, sess *Session, ch <-chan []byte, flush <-chan struct{}) error {
 	for {
 		select {
 		case <-ctx.Done():
+			writeClose(relay, "bridge closed")
 			return ctx.Err()
+		case <-flush:
+			for {
+				select {
+				case payload := <-ch:
+					if payload == nil {
+						continue // no pings while closing
+					}
+					relay.SetWriteDeadline(time.Now().Add(writeTimeout))
+					if err := writeSealed(relay, sess, payload); err != nil {
+						return err
+					}
+				default:
+					writeClose(relay, "agent shutting down")
+					return errors.New("closed for shutdown")
+				}
+			}
 		case payload, ok := <-ch:
 			if !ok {
 				return fmt.Errorf("relaySend channel closed")
@@ -113,30 +333,69 @@ func relayWriter(ctx context.Context, relay *websocket.Conn, sessionKey []byte,
 			relay.SetWriteDeadline(time.Now().Add(writeTimeout))
 
 			if payload == nil {
-				// Ping frame
-				if err := relay.WriteMessage(websocket.PingMessage, nil); err != nil {
+				// Ping frame, stamped for the RTT measurement
+				stamp := binary.BigEndian.AppendUint64(nil, uint64(time.Now().UnixNano()))
+				if err := relay.WriteMessage(websocket.PingMessage, stamp); err != nil {
 					return fmt.Errorf("ping write error: %w", err)
 				}
 				continue
 			}
 
-			// Seal and send
-			sealed, err := Seal(sessionKey, payload)
-			if err != nil {
-				log.Printf("[bridge] Failed to seal message: %v", err)
-				continue
-			}
-			if err := relay.WriteMessage(websocket.TextMessage, sealed); err != nil {
-				return fmt.Errorf("relay write error: %w", err)
+			if err := writeSealed(relay, sess, payload); err != nil {
+				return err
 			}
 		}
 	}
 }
 
+// writeClose sends a normal-closure close frame. A relay that is already
+// gone costs at most flushTimeout.
+func writeClose(relay *websocket.Conn, reason string) {
+	relay.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, reason),
+		time.Now().Add(flushTimeout))
+}
+
+// CloseRelay closes a relay connection with a close handshake rather than a
+// bare TCP close. Use it only where no relay writer is running (before or
+// after EnvelopeBridge); a close frame the writer already sent is not repeated.
+func CloseRelay(relay *websocket.Conn) {
+	writeClose(relay, "agent disconnecting")
+	relay.Close()
+}
+
+// writeSealed seals payload and writes it to the relay. A payload that
+// cannot be sealed is logged and skipped.
+func writeSealed(relay *websocket.Conn, sess *Session, payload []byte) error {
+	var sealed []byte
+	var err error
+	if sess.Gzip && len(payload) >= GzipThreshold {
+		sealed, err = SealGzip(sess.Keys, payload)
+	} else {
+		sealed, err = Seal(sess.Keys, payload)
+	}
+	if err != nil {
+		log.Printf("[bridge] Failed to seal message: %v", err)
+		return nil
+	}
+	if err := relay.WriteMessage(websocket.TextMessage, sealed); err != nil {
+		return fmt.Errorf("relay write error: %w", err)
+	}
+	return nil
+}
+
+// localOnlyEvents are OBS events never forwarded to the relay. Audio meters
+// arrive every 50ms; the monitor derives AgentAudioState from them instead.
+var localOnlyEvents = map[string]bool{
+	"InputVolumeMeters": true,
+}
+
 // pipeRelayToOBS reads signed envelopes from relay, verifies them,
 // validates OBS protocol, and forwards the raw OBS payload to local OBS.
 // AgentConfigureMonitor requests are intercepted and handled by the monitor.
-func pipeRelayToOBS(ctx context.Context, relay, obs *websocket.Conn, sessionKey []byte, cache *NonceCache, mon *monitor.Monitor, relaySend chan<- []byte) error {
+func pipeRelayToOBS(ctx context.Context, relay *websocket.Conn, link *obsLink, sess *Session, cache *NonceCache, mon *monitor.Monitor, relaySend chan<- []byte, opts BridgeOptions, pending *pendingRequests, draining *atomic.Bool) error {
+	approvals := opts.Approvals
+	expired := 0 // consecutive timestamp_expired rejections
 	for {
 		select {
 		case <-ctx.Done():
@@ -146,6 +405,10 @@ func pipeRelayToOBS(ctx context.Context, relay, obs *websocket.Conn, sessionKey
 
 		msgType, data, err := relay.ReadMessage()
 		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				log.Printf("[bridge] Relay sent a message larger than the %d-byte read limit — raise it with -relay-read-limit or OBS_AGENT_RELAY_READ_LIMIT", sess.ReadLimit)
+				return &ErrMessageTooLarge{Direction: "relay→agent", Limit: sess.ReadLimit}
+			}
 			return err
 		}
 
@@ -155,20 +418,62 @@ func pipeRelayToOBS(ctx context.Context, relay, obs *websocket.Conn, sessionKey
 		}
 
 		// Step 1: Verify signed envelope
-		result := Open(sessionKey, data, cache)
+		result := Open(sess.Keys, data, cache)
 		if !result.Valid {
 			log.Printf("[bridge] Rejected relay message: %s", result.Reason)
+			opts.Stats.addRejected()
+			if result.Reason == "timestamp_expired" {
+				expired++
+				if expired >= skewWarnThreshold {
+					warnClockSkew(result.Skew, cache.tolerance)
+				}
+			}
 			continue // DROP invalid envelopes
 		}
+		expired = 0
+
+		// Signed relay control: rekey and token rotation (never forwarded to OBS)
+		if nonce, ok := parseRekey(result); ok {
+			sess.handleRekey(nonce)
+			continue
+		}
+		if newToken, ok := parseTokenRotate(result.Payload); ok {
+			sess.handleTokenRotate(newToken)
+			continue
+		}
 
 		// Step 2: Validate OBS protocol (to_agent direction — these are commands TO local OBS)
-		check := ValidateOBSProtocol(result.Payload, ToAgent)
+		check := ValidateOBSProtocol(result.Payload, ToAgent, opts.MaxBatchRequests)
 		if !check.Valid {
 			log.Printf("[bridge] Rejected OBS message from relay: %s", check.Reason)
+			opts.Stats.addRejected()
 			continue // DROP forbidden ops/requests
 		}
 
-		// Step 3: Intercept AgentConfigureMonitor — handle locally, do NOT forward to OBS
+		// Shutting down: OBS gets no new commands, but the relay gets an answer
+		if draining.Load() {
+			if len(requestTypes(check.Parsed)) > 0 {
+				select {
+				case relaySend <- failedResponse(check.Parsed, "Agent is shutting down"):
+				default:
+				}
+			}
+			continue
+		}
+
+		// Step 3: Rate-limit per request type; a dropped request is answered
+		// as failed so the dashboard doesn't wait for it
+		if opts.RateLimiter != nil {
+			if types := requestTypes(check.Parsed); len(types) > 0 && !opts.RateLimiter.Allow(types...) {
+				select {
+				case relaySend <- failedResponse(check.Parsed, "Rate limited by agent"):
+				default:
+				}
+				continue
+			}
+		}
+
+		// Step 3a: Intercept AgentConfigureMonitor — handle locally, do NOT forward to OBS
 		if check.Parsed != nil && check.Parsed.Op == 6 && check.Parsed.D != nil {
 			var reqData struct {
 				RequestType string          `json:"requestType"`
@@ -178,22 +483,24 @@ func pipeRelayToOBS(ctx context.Context, relay, obs *websocket.Conn, sessionKey
 			if err := json.Unmarshal(*check.Parsed.D, &reqData); err == nil && reqData.RequestType == "AgentConfigureMonitor" {
 				// Parse config and configure monitor
 				var cfg monitor.Config
+				requestStatus := map[string]interface{}{"result": true, "code": 100}
 				if err := json.Unmarshal(reqData.RequestData, &cfg); err != nil {
 					log.Printf("[bridge] Bad AgentConfigureMonitor data: %v", err)
+				} else if err := cfg.Validate(); err != nil {
+					// Keep the current monitor config rather than apply half of it
+					log.Printf("[bridge] Rejected AgentConfigureMonitor: %v", err)
+					requestStatus = map[string]interface{}{"result": false, "code": 400, "comment": err.Error()}
 				} else {
 					mon.Configure(cfg)
 				}
 
-				// Build op 7 success response
+				// Build op 7 response
 				resp := map[string]interface{}{
 					"op": 7,
 					"d": map[string]interface{}{
-						"requestType": "AgentConfigureMonitor",
-						"requestId":   reqData.RequestID,
-						"requestStatus": map[string]interface{}{
-							"result": true,
-							"code":   100,
-						},
+						"requestType":   "AgentConfigureMonitor",
+						"requestId":     reqData.RequestID,
+						"requestStatus": requestStatus,
 					},
 				}
 				respBytes, _ := json.Marshal(resp)
@@ -207,17 +514,68 @@ func pipeRelayToOBS(ctx context.Context, relay, obs *websocket.Conn, sessionKey
 			}
 		}
 
-		// Step 4: Forward raw OBS payload to local OBS
-		obs.SetWriteDeadline(time.Now().Add(writeTimeout))
-		if err := obs.WriteMessage(websocket.TextMessage, result.Payload); err != nil {
+		// Step 4: Destructive requests wait for local approval off the read loop
+		if approvals != nil {
+			if types := destructiveTypes(check.Parsed); len(types) > 0 {
+				go awaitApproval(ctx, approvals, link, check.Parsed, result.Payload, types, relaySend, opts, pending)
+				continue
+			}
+		}
+
+		// Step 5: Forward raw OBS payload to local OBS
+		if err := link.write(result.Payload); err != nil {
+			if opts.OBSReconnectWindow >= 0 {
+				// The read side reconnects OBS, or ends the bridge if it cannot
+				log.Println("[bridge] Dropped relay message while OBS reconnects")
+				if len(requestTypes(check.Parsed)) > 0 {
+					select {
+					case relaySend <- failedResponse(check.Parsed, "OBS is reconnecting"):
+					default:
+					}
+				}
+				continue
+			}
 			return fmt.Errorf("OBS write error: %w", err)
 		}
+		pending.add(check.Parsed)
+		opts.Stats.addToOBS()
+		opts.Metrics.message(len(result.Payload))
+	}
+}
+
+// awaitApproval forwards payload to OBS once the local user approves it, or
+// answers the relay with a failed request status if denied or timed out.
+func awaitApproval(ctx context.Context, approvals *ApprovalQueue, link *obsLink, msg *obsMessage, payload []byte, types []string, relaySend chan<- []byte, opts BridgeOptions, pending *pendingRequests) {
+	log.Printf("[bridge] Holding %v for local approval", types)
+	if approvals.Approve(ctx, types) {
+		log.Printf("[bridge] Approved %v", types)
+		if err := link.write(payload); err != nil {
+			log.Printf("[bridge] OBS write error after approval: %v", err)
+			return
+		}
+		pending.add(msg)
+		opts.Stats.addToOBS()
+		opts.Metrics.message(len(payload))
+		return
+	}
+
+	// Always answer: the relay is waiting on this request ID
+	log.Printf("[bridge] Denied %v", types)
+	select {
+	case relaySend <- deniedResponse(msg):
+	case <-ctx.Done():
 	}
 }
 
 // pipeOBSToRelay reads raw OBS messages, validates the protocol,
 // and sends raw payload via channel (the relay writer handles sealing).
-func pipeOBSToRelay(ctx context.Context, obs *websocket.Conn, relaySend chan<- []byte) error {
+// OBS events also schedule a snapshot refresh. Scene-collection events
+// invalidate the monitor's scene map and open a window in which an OBS
+// disconnect is recovered by reconnecting OBS only; other disconnects get a
+// few OBS-only reconnect attempts (see obsLink.recover). Responses to the
+// monitor's requests (client, nil when it has its own connection) are
+// handed to it instead.
+func pipeOBSToRelay(ctx context.Context, link *obsLink, window time.Duration, mon *monitor.Monitor, client *obs.Client, snap *snapshot.Collector, relaySend chan<- []byte, opts BridgeOptions, pending *pendingRequests) error {
 	for {
 		select {
 		case <-ctx.Done():
@@ -225,13 +583,46 @@ func pipeOBSToRelay(ctx context.Context, obs *websocket.Conn, relaySend chan<- [
 		default:
 		}
 
-		msgType, data, err := obs.ReadMessage()
+		conn := link.current()
+		msgType, data, err := conn.ReadMessage()
+		if err != nil && window > 0 && link.switching() && ctx.Err() == nil {
+			log.Printf("[bridge] OBS dropped during scene-collection switch (%v) — reconnecting OBS only", err)
+			mon.SetOBSAvailable(false)
+			if rerr := link.reconnect(ctx, window); rerr == nil {
+				mon.SetOBSAvailable(true)
+				continue
+			}
+		} else if err != nil && window > 0 && ctx.Err() == nil && !errors.Is(err, websocket.ErrReadLimit) {
+			log.Printf("[bridge] Lost OBS (%v) — reconnecting OBS only, keeping the relay session", err)
+			mon.SetOBSAvailable(false)
+			if opts.OnOBSLost != nil {
+				opts.OnOBSLost()
+			}
+			info, rerr := link.recover(ctx)
+			if rerr == nil {
+				// Requests sent on the old connection are never answered
+				pending.clear()
+				mon.SetOBSAvailable(true)
+				snap.Refresh()
+				if opts.OnOBSRestored != nil {
+					opts.OnOBSRestored(info)
+				}
+				continue
+			}
+			if ctx.Err() == nil {
+				log.Printf("[bridge] OBS did not come back after %d attempts — restarting the session", obsRecoverAttempts)
+			}
+		}
 		if err != nil {
+			if errors.Is(err, websocket.ErrReadLimit) {
+				log.Printf("[bridge] OBS sent a message larger than the %d-byte read limit", obsReadLimit)
+				return &ErrMessageTooLarge{Direction: "OBS→agent", Limit: obsReadLimit}
+			}
 			return err
 		}
 
 		// Reset read deadline on successful read
-		obs.SetReadDeadline(time.Now().Add(obsReadTimeout))
+		conn.SetReadDeadline(time.Now().Add(obsReadTimeout))
 
 		// Only process text messages (OBS v5 is JSON)
 		if msgType != websocket.TextMessage {
@@ -239,21 +630,71 @@ func pipeOBSToRelay(ctx context.Context, obs *websocket.Conn, relaySend chan<- [
 		}
 
 		// Step 1: Validate OBS protocol (from_agent direction — these are responses FROM local OBS)
-		check := ValidateOBSProtocol(data, FromAgent)
+		check := ValidateOBSProtocol(data, FromAgent, opts.MaxBatchRequests)
 		if !check.Valid {
 			// Don't log — local OBS may send various messages during auth handshake
 			continue // DROP non-conforming messages
 		}
 
+		// The monitor's own requests never reach the relay
+		if client != nil && check.Parsed != nil && check.Parsed.Op == 7 && client.Deliver(data) {
+			continue
+		}
+
+		// Events: local-only ones stop here; scene collection switches and
+		// scene/scene item changes invalidate the monitor's cached scene map
+		if check.Parsed != nil && check.Parsed.Op == 5 && check.Parsed.D != nil {
+			var ev struct {
+				EventType string `json:"eventType"`
+			}
+			json.Unmarshal(*check.Parsed.D, &ev)
+			if localOnlyEvents[ev.EventType] {
+				continue
+			}
+			if opts.OnEvent != nil {
+				opts.OnEvent(data)
+			}
+			if ev.EventType != "" {
+				snap.HandleEvent(ev.EventType)
+				switch ev.EventType {
+				case "CurrentSceneCollectionChanging":
+					log.Println("[bridge] OBS scene collection changing")
+					mon.InvalidateSceneMap()
+					if window > 0 {
+						link.markSwitching(window)
+					}
+				case "CurrentSceneCollectionChanged",
+					"SceneItemCreated", "SceneItemRemoved",
+					"SceneListChanged", "SceneNameChanged":
+					mon.InvalidateSceneMap()
+				}
+			}
+		}
+
+		pending.done(check.Parsed)
+
 		// Step 2: Send raw payload to relay writer channel (writer handles sealing)
 		select {
 		case relaySend <- data:
+			opts.Stats.addToRelay()
+			opts.Metrics.message(len(data))
 		default:
 			log.Println("[bridge] Relay send channel full, dropping OBS message")
 		}
 	}
 }
 
+// ErrMessageTooLarge is returned when a peer sends a message over the read limit.
+// gorilla closes the connection in that case, so the bridge must restart.
+type ErrMessageTooLarge struct {
+	Direction string // e.g. "relay→agent"
+	Limit     int64  // bytes
+}
+
+func (e *ErrMessageTooLarge) Error() string {
+	return fmt.Sprintf("message too large: %s message exceeded %d bytes", e.Direction, e.Limit)
+}
+
 // ErrTokenRejected is returned when the relay refuses the token (close 4100).
 // The agent should stop retrying and trigger re-authentication.
 type ErrTokenRejected struct{}
@@ -262,14 +703,103 @@ func (e *ErrTokenRejected) Error() string {
 	return "token rejected by relay"
 }
 
+// Session holds the state negotiated during the relay handshake.
+type Session struct {
+	// Keys holds the session key; the relay may rotate it with "rekey".
+	Keys *SessionKeyHolder
+	// Gzip is true when the relay accepts version 2 (gzip) envelopes.
+	Gzip bool
+	// ReadLimit is the relay connection's effective read limit in bytes.
+	ReadLimit int64
+	// ClockSkew is how far the relay's clock was ahead of (positive) or
+	// behind this machine's at the handshake. Zero if the relay did not
+	// send its time.
+	ClockSkew time.Duration
+
+	token         string // needed to derive rotated keys
+	nonces        *NonceCache
+	onTokenRotate TokenRotateFunc
+}
+
+// ClockError reports a local clock too far from the relay's for envelopes
+// to pass the timestamp check, or nil if it is within the window (or the
+// relay did not send its time).
+func (s *Session) ClockError() error {
+	skew := s.ClockSkew
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew <= s.nonces.tolerance {
+		return nil
+	}
+	return fmt.Errorf("system clock is off by %s — envelopes will be rejected", skew.Round(time.Second))
+}
+
+// SessionOptions configures the session set up by WaitForSession. The
+// hooks (all optional) receive relay notices during the handshake and
+// session.
+type SessionOptions struct {
+	// ClockSkew is how far an envelope's timestamp may be from the local
+	// clock (0 = DefaultClockSkew). Raise it on machines without reliable
+	// NTP.
+	ClockSkew time.Duration
+	// NonceCacheSize caps the nonces remembered for replay protection (0 =
+	// DefaultNonceCacheSize). Raise it for sessions that carry more relay
+	// messages than that per 2×ClockSkew.
+	NonceCacheSize int
+
+	// OnTokenRotate receives a token from a signed token_rotate envelope.
+	OnTokenRotate TokenRotateFunc
+	// OnUpdateAvailable receives update_available notices. It must not
+	// block; the URL is untrusted until verified against the manifest.
+	OnUpdateAvailable func(UpdateNotice)
+}
+
+// UpdateNotice is a relay update_available message.
+type UpdateNotice struct {
+	Version     string
+	DownloadURL string
+	// SHA256 is the binary's hex digest as announced by the relay (may be
+	// empty). Like the URL it is only a hint: the installer also requires
+	// it to match the signed release manifest.
+	SHA256 string
+}
+
+// controlMessage is a plaintext relay message ({"type": ...}). Envelopes
+// never carry a "type" field, so the two are unambiguous.
+type controlMessage struct {
+	Type        string   `json:"type"`
+	Nonce       string   `json:"nonce,omitempty"`
+	Features    []string `json:"features,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	DownloadURL string   `json:"download_url,omitempty"`
+	SHA256      string   `json:"sha256,omitempty"`
+	Token       string   `json:"token,omitempty"` // token_rotate (enveloped only)
+
+	// ServerTime is the relay's clock in a session message (unix ms).
+	ServerTime int64 `json:"server_time,omitempty"`
+}
+
+func parseControl(data []byte) (controlMessage, bool) {
+	var msg controlMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Type == "" {
+		return msg, false
+	}
+	return msg, true
+}
+
 // WaitForSession reads the session handshake message from the relay and derives the session key.
-// The relay sends {"type":"session","nonce":"<hex>"} followed by {"type":"connected"}.
-// Returns the derived session key.
+// The relay sends {"type":"session","nonce":"<hex>","features":[...]} followed by {"type":"connected"}.
+// Envelope gzip is only enabled when the relay advertises it and the WebSocket
+// itself is not already compressed. A signed token_rotate envelope may arrive
+// once the session key exists; the hooks in opts receive relay notices.
+// When the session message carries the relay's "server_time", the local
+// clock is checked against it (see Session.ClockError).
 //
 // SECURITY: The session key is derived from token + nonce via HMAC-SHA256,
 // so both sides compute the same key without transmitting it.
-func WaitForSession(conn *websocket.Conn, token string) ([]byte, error) {
-	var sessionKey []byte
+func WaitForSession(conn *websocket.Conn, token string, info ConnInfo, opts SessionOptions) (*Session, error) {
+	var sess *Session
 
 	// Read session message (with timeout)
 	conn.SetReadDeadline(time.Now().Add(15 * time.Second))
@@ -284,13 +814,18 @@ func WaitForSession(conn *websocket.Conn, token string) ([]byte, error) {
 			return nil, fmt.Errorf("session handshake failed: %w", err)
 		}
 
-		var msg struct {
-			Type        string `json:"type"`
-			Nonce       string `json:"nonce,omitempty"`
-			Version     string `json:"version,omitempty"`
-			DownloadURL string `json:"download_url,omitempty"`
-		}
-		if err := json.Unmarshal(data, &msg); err != nil {
+		msg, ok := parseControl(data)
+		if !ok {
+			// Only signed rekeys and token rotations are accepted before "connected"
+			if sess != nil {
+				if result := Open(sess.Keys, data, sess.nonces); result.Valid {
+					if nonce, ok := parseRekey(result); ok {
+						sess.handleRekey(nonce)
+					} else if newToken, ok := parseTokenRotate(result.Payload); ok {
+						sess.handleTokenRotate(newToken)
+					}
+				}
+			}
 			continue // Skip unparseable messages during handshake
 		}
 
@@ -299,21 +834,46 @@ func WaitForSession(conn *websocket.Conn, token string) ([]byte, error) {
 			if msg.Nonce == "" {
 				return nil, fmt.Errorf("session message missing nonce")
 			}
-			sessionKey = DeriveSessionKey(token, msg.Nonce)
+			sess = &Session{
+				Keys:          NewSessionKeyHolder(DeriveSessionKey(token, msg.Nonce)),
+				ReadLimit:     info.ReadLimit,
+				token:         token,
+				nonces:        NewNonceCache(opts.ClockSkew, opts.NonceCacheSize),
+				onTokenRotate: opts.OnTokenRotate,
+			}
+			for _, f := range msg.Features {
+				if f == featureGzip && !info.Compressed {
+					sess.Gzip = true
+					log.Println("[agent] Relay supports gzip envelopes")
+				}
+			}
+			if msg.ServerTime > 0 {
+				sess.ClockSkew = time.Duration(msg.ServerTime-time.Now().UnixMilli()) * time.Millisecond
+				if err := sess.ClockError(); err != nil {
+					log.Printf("[agent] WARNING: %v (allowed ±%s); enable NTP time sync or raise -clock-skew", err, sess.nonces.tolerance)
+				}
+			}
 			log.Println("[agent] Session key derived")
 
 		case "connected":
-			if sessionKey == nil {
+			if sess == nil {
 				return nil, fmt.Errorf("received connected before session")
 			}
 			// Clear read deadline — bridge will manage its own
 			conn.SetReadDeadline(time.Time{})
 			log.Println("[agent] Session established")
-			return sessionKey, nil
+			return sess, nil
 
 		case "update_available":
 			log.Printf("[agent] *** Update available: %s — download: %s ***", msg.Version, msg.DownloadURL)
-			// Informational only — continue handshake
+			if opts.OnUpdateAvailable != nil && msg.Version != "" && msg.DownloadURL != "" {
+				opts.OnUpdateAvailable(UpdateNotice{
+					Version:     msg.Version,
+					DownloadURL: msg.DownloadURL,
+					SHA256:      msg.SHA256,
+				})
+			}
+			// Continue handshake — any update runs in the background
 
 		default:
 			// Unknown message type during handshake — skip
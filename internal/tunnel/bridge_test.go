@@ -0,0 +1,139 @@
+package tunnel_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/tunneltest"
+)
+
+// An unsigned rekey must not rotate the agent's key: if it did, the agent
+// would seal its responses with a key the relay never derived.
+func TestUnsignedRekeyRejected(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	h, err := tunneltest.Start(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if err := h.Relay.SendRaw([]byte(`{"type":"rekey","nonce":"00112233445566778899aabbccddeeff"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Request("GetVersion", "after-rekey", 5*time.Second); err != nil {
+		t.Fatalf("request after unsigned rekey: %v", err)
+	}
+	if rejected := h.Relay.Rejected(); len(rejected) > 0 {
+		t.Errorf("relay rejected agent envelopes %v; agent rotated its key", rejected)
+	}
+}
+
+// sourceState waits for the next AgentSourceState the agent sends the relay.
+func sourceState(t *testing.T, h *tunneltest.Harness) map[string]interface{} {
+	t.Helper()
+	for {
+		p, err := h.Relay.Receive(5 * time.Second)
+		if err != nil {
+			t.Fatalf("no AgentSourceState: %v", err)
+		}
+		var ev struct {
+			Op int `json:"op"`
+			D  struct {
+				EventType string                 `json:"eventType"`
+				EventData map[string]interface{} `json:"eventData"`
+			} `json:"d"`
+		}
+		if json.Unmarshal(p, &ev) == nil && ev.Op == 5 && ev.D.EventType == "AgentSourceState" {
+			return ev.D.EventData
+		}
+	}
+}
+
+// Requests and batches reach OBS; OBS responses and events reach the relay.
+func TestBridgeForwardsBothWays(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	h, err := tunneltest.Start(ctx, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if _, err := h.Relay.Send([]byte(`{"op":8,"d":{"requestId":"batch","requests":[{"requestType":"GetVersion","requestId":"b1"},{"requestType":"GetSceneList","requestId":"b2"}]}}`)); err != nil {
+		t.Fatal(err)
+	}
+	h.OBS.SendEvent("InputMuteStateChanged", map[string]interface{}{"inputName": "Mic", "inputMuted": true})
+
+	gotBatch, gotEvent := false, false
+	for !gotBatch || !gotEvent {
+		p, err := h.Relay.Receive(5 * time.Second)
+		if err != nil {
+			t.Fatalf("batch response %v, event %v: %v", gotBatch, gotEvent, err)
+		}
+		var msg struct {
+			Op int `json:"op"`
+			D  struct {
+				RequestID string            `json:"requestId"`
+				EventType string            `json:"eventType"`
+				Results   []json.RawMessage `json:"results"`
+			} `json:"d"`
+		}
+		json.Unmarshal(p, &msg)
+		switch {
+		case msg.Op == 9 && msg.D.RequestID == "batch":
+			gotBatch = len(msg.D.Results) == 2
+		case msg.Op == 5 && msg.D.EventType == "InputMuteStateChanged":
+			gotEvent = true
+		}
+	}
+
+	var ids []string
+	for _, r := range h.OBS.RecordedRequests() {
+		ids = append(ids, r.RequestID)
+	}
+	if !strings.Contains(strings.Join(ids, ","), "b1,b2") {
+		t.Errorf("OBS received %v", ids)
+	}
+}
+
+// The monitor's scene map is cached for 30s; a scene-collection switch
+// must drop it so the containing scene follows the new collection.
+func TestSceneCollectionSwitchInvalidatesSceneMap(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	h, err := tunneltest.Start(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.OBS.SetResponse("GetSceneItemList", map[string]interface{}{
+		"sceneItems": []map[string]interface{}{{"sourceName": "Camera"}},
+	})
+	if _, err := h.Relay.Send([]byte(`{"op":6,"d":{"requestType":"AgentConfigureMonitor","requestId":"mon","requestData":{"source":"Camera","enabled":true,"pollIntervalMs":500}}}`)); err != nil {
+		t.Fatal(err)
+	}
+	if st := sourceState(t, h); st["containingScene"] != "Scene" {
+		t.Fatalf("first state %v, want containingScene Scene", st)
+	}
+
+	h.OBS.SetResponse("GetSceneList", map[string]interface{}{
+		"currentProgramSceneName": "Show",
+		"scenes":                  []map[string]interface{}{{"sceneIndex": 0, "sceneName": "Show"}},
+	})
+	h.OBS.SendEvent("CurrentSceneCollectionChanged", map[string]interface{}{"sceneCollectionName": "Other"})
+	for {
+		st := sourceState(t, h)
+		if st["containingScene"] == "Show" {
+			break
+		}
+		if st["containingScene"] != "Scene" {
+			t.Fatalf("state %v", st)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package tunnel
 
 import (
+	"bytes"
+	"compress/gzip"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
@@ -8,7 +10,11 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
+	"regexp"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -18,22 +24,39 @@ import (
 // Every message between agent and relay is wrapped in a signed envelope:
 //   { v, t, n, p, h }
 // Where:
-//   v = envelope version (1)
+//   v = envelope version (1, or 2 when p is gzip-compressed)
 //   t = timestamp (unix ms)
 //   n = random nonce (16 bytes hex = 32 chars)
-//   p = base64-encoded payload
-//   h = HMAC-SHA256(session_key, "1|t|n|p")
+//   p = base64-encoded payload (v2: base64 of gzip(payload))
+//   h = HMAC-SHA256(session_key, "v|t|n|p")
+//
+// Version 2 is only sent when the relay advertises the "gzip" feature in the
+// session handshake; both versions are always accepted.
+//
+// The relay may rotate the session key mid-session with a
+// {"type":"rekey","nonce":"<hex>"} payload, sealed with the current key.
+// The previous key stays valid for Open during a short grace window so
+// in-flight envelopes drain.
 //
 // SECURITY:
 // - Integrity: HMAC prevents message tampering
-// - Replay protection: nonce + timestamp window (±30s)
+// - Replay protection: nonce + timestamp window (±30s by default, see NewNonceCache)
 // - Protocol enforcement: payload must be valid OBS WebSocket v5
 // - Action whitelist: only approved OBS request types pass through
 
 const (
-	timestampTolerance = 30 * time.Second // ±30s window
-	maxNonceCache      = 2000
-	nonceBytes         = 16 // 16 bytes = 32 hex chars
+	// DefaultClockSkew is the default timestamp window (±) for Open.
+	DefaultClockSkew = 30 * time.Second
+	// DefaultNonceCacheSize is the default cap on remembered nonces.
+	DefaultNonceCacheSize = 2000
+	nonceBytes            = 16 // 16 bytes = 32 hex chars
+
+	// GzipThreshold is the smallest payload worth compressing in a v2 envelope.
+	GzipThreshold = 4 * 1024
+	// maxInflatedPayload bounds v2 decompression (zip-bomb protection).
+	maxInflatedPayload = 4 * 1024 * 1024
+
+	featureGzip = "gzip"
 )
 
 // envelope is the wire format for signed messages.
@@ -49,14 +72,30 @@ type envelope struct {
 type NonceCache struct {
 	mu     sync.Mutex
 	nonces map[string]int64 // nonce → timestamp (unix ms)
-}
 
-const nonceTTL = 60000 // 60s TTL (2x the 30s tolerance window)
+	// tolerance is the timestamp window Open enforces with this cache;
+	// nonces are kept for twice that so a replay is caught anywhere in it
+	tolerance time.Duration
+	// size caps len(nonces)
+	size int
+}
 
-// NewNonceCache creates a bounded nonce cache.
-func NewNonceCache() *NonceCache {
+// NewNonceCache creates a bounded nonce cache. tolerance is how far an
+// envelope's timestamp may be from the local clock before Open rejects it
+// as timestamp_expired; size caps how many nonces are remembered, past
+// which the oldest are dropped even within the window. Zero or negative
+// values use DefaultClockSkew and DefaultNonceCacheSize.
+func NewNonceCache(tolerance time.Duration, size int) *NonceCache {
+	if tolerance <= 0 {
+		tolerance = DefaultClockSkew
+	}
+	if size <= 0 {
+		size = DefaultNonceCacheSize
+	}
 	return &NonceCache{
-		nonces: make(map[string]int64, maxNonceCache),
+		nonces:    make(map[string]int64, size),
+		tolerance: tolerance,
+		size:      size,
 	}
 }
 
@@ -65,11 +104,12 @@ func (nc *NonceCache) has(n string) bool {
 	return ok
 }
 
-// evictExpired removes nonces older than TTL.
+// evictExpired removes nonces older than TTL (2x the tolerance window).
 func (nc *NonceCache) evictExpired() {
 	now := time.Now().UnixMilli()
+	ttl := 2 * nc.tolerance.Milliseconds()
 	for nonce, ts := range nc.nonces {
-		if now-ts > nonceTTL {
+		if now-ts > ttl {
 			delete(nc.nonces, nonce)
 		}
 	}
@@ -78,7 +118,7 @@ func (nc *NonceCache) evictExpired() {
 func (nc *NonceCache) add(n string) {
 	nc.nonces[n] = time.Now().UnixMilli()
 	// Size cap as secondary protection
-	if len(nc.nonces) > maxNonceCache {
+	if len(nc.nonces) > nc.size {
 		// Evict oldest by timestamp
 		var oldestKey string
 		var oldestTs int64 = math.MaxInt64
@@ -102,9 +142,31 @@ func DeriveSessionKey(token string, sessionNonce string) []byte {
 	return mac.Sum(nil)
 }
 
-// Seal wraps a payload in a signed envelope.
+// Seal wraps a payload in a signed envelope using the holder's current key.
 // Must match relay's seal(sessionKey, payload) exactly.
-func Seal(sessionKey []byte, payload []byte) ([]byte, error) {
+func Seal(keys *SessionKeyHolder, payload []byte) ([]byte, error) {
+	return seal(keys.Current(), 1, payload)
+}
+
+// SealGzip wraps a gzip-compressed payload in a signed version 2 envelope.
+// Only use it once the relay has advertised the "gzip" feature. Payloads
+// that don't shrink (already-compressed image data) are sealed as version 1.
+func SealGzip(keys *SessionKeyHolder, payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(payload); err != nil {
+		return nil, fmt.Errorf("gzip failed: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("gzip failed: %w", err)
+	}
+	if buf.Len() >= len(payload) {
+		return seal(keys.Current(), 1, payload)
+	}
+	return seal(keys.Current(), 2, buf.Bytes())
+}
+
+func seal(sessionKey []byte, version int, body []byte) ([]byte, error) {
 	t := time.Now().UnixMilli()
 
 	nonce := make([]byte, nonceBytes)
@@ -113,14 +175,14 @@ func Seal(sessionKey []byte, payload []byte) ([]byte, error) {
 	}
 	n := hex.EncodeToString(nonce)
 
-	p := base64.StdEncoding.EncodeToString(payload)
+	p := base64.StdEncoding.EncodeToString(body)
 
-	sigInput := fmt.Sprintf("1|%d|%s|%s", t, n, p)
+	sigInput := fmt.Sprintf("%d|%d|%s|%s", version, t, n, p)
 	mac := hmac.New(sha256.New, sessionKey)
 	mac.Write([]byte(sigInput))
 	h := hex.EncodeToString(mac.Sum(nil))
 
-	env := envelope{V: 1, T: t, N: n, P: p, H: h}
+	env := envelope{V: version, T: t, N: n, P: p, H: h}
 	return json.Marshal(env)
 }
 
@@ -129,21 +191,30 @@ type OpenResult struct {
 	Valid   bool
 	Payload []byte
 	Reason  string
+
+	// Skew is how far the envelope's timestamp was ahead of (positive) or
+	// behind the local clock. Only set for timestamp_expired.
+	Skew time.Duration
+
+	// Previous is set when the envelope verified with the previous session
+	// key during the rekey grace window rather than the current one.
+	Previous bool
 }
 
 // Open verifies and unwraps a signed envelope.
 // Must match relay's open(sessionKey, raw, recentNonces) exactly.
 //
 // SECURITY: HMAC is verified BEFORE timestamp to prevent timing side-channel
-// that would differentiate expired vs bad-HMAC.
-func Open(sessionKey []byte, raw []byte, cache *NonceCache) OpenResult {
+// that would differentiate expired vs bad-HMAC. Right after a rekey the
+// previous key is also accepted so in-flight envelopes drain.
+func Open(keys *SessionKeyHolder, raw []byte, cache *NonceCache) OpenResult {
 	var env envelope
 	if err := json.Unmarshal(raw, &env); err != nil {
 		return OpenResult{Reason: "not_json"}
 	}
 
 	// Version check
-	if env.V != 1 {
+	if env.V != 1 && env.V != 2 {
 		return OpenResult{Reason: "bad_version"}
 	}
 
@@ -161,20 +232,28 @@ func Open(sessionKey []byte, raw []byte, cache *NonceCache) OpenResult {
 	}
 
 	// HMAC verification FIRST (timing-safe) — before timestamp check
-	sigInput := fmt.Sprintf("1|%d|%s|%s", env.T, env.N, env.P)
-	mac := hmac.New(sha256.New, sessionKey)
-	mac.Write([]byte(sigInput))
-	expected := mac.Sum(nil)
-
+	sigInput := fmt.Sprintf("%d|%d|%s|%s", env.V, env.T, env.N, env.P)
 	actual, err := hex.DecodeString(env.H)
-	if err != nil || !hmac.Equal(actual, expected) {
+	if err != nil {
+		return OpenResult{Reason: "bad_hmac"}
+	}
+	verified, previous := false, false
+	for i, key := range keys.openKeys() {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(sigInput))
+		if hmac.Equal(actual, mac.Sum(nil)) {
+			verified, previous = true, i > 0
+			break
+		}
+	}
+	if !verified {
 		return OpenResult{Reason: "bad_hmac"}
 	}
 
-	// Timestamp window (±30s) — checked after HMAC
+	// Timestamp window (±cache.tolerance) — checked after HMAC
 	now := time.Now().UnixMilli()
-	if abs64(now-env.T) > timestampTolerance.Milliseconds() {
-		return OpenResult{Reason: "timestamp_expired"}
+	if abs64(now-env.T) > cache.tolerance.Milliseconds() {
+		return OpenResult{Reason: "timestamp_expired", Skew: time.Duration(env.T-now) * time.Millisecond}
 	}
 
 	// Evict expired nonces (TTL-based) then replay check
@@ -196,7 +275,32 @@ func Open(sessionKey []byte, raw []byte, cache *NonceCache) OpenResult {
 		return OpenResult{Reason: "bad_payload"}
 	}
 
-	return OpenResult{Valid: true, Payload: payload}
+	if env.V == 2 {
+		payload, err = gunzip(payload)
+		if err != nil {
+			return OpenResult{Reason: "bad_payload"}
+		}
+	}
+
+	return OpenResult{Valid: true, Payload: payload, Previous: previous}
+}
+
+// gunzip inflates a v2 payload, refusing anything over maxInflatedPayload.
+func gunzip(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	out, err := io.ReadAll(io.LimitReader(zr, maxInflatedPayload+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxInflatedPayload {
+		return nil, fmt.Errorf("payload exceeds %d bytes", maxInflatedPayload)
+	}
+	return out, nil
 }
 
 // ── OBS WebSocket v5 protocol validation ────────────────────────────────
@@ -207,9 +311,11 @@ type obsMessage struct {
 	D  *json.RawMessage `json:"d,omitempty"`
 }
 
-// obsRequestData extracts requestType from op 6 data.
+// obsRequestData extracts requestType (and requestId, for batches) from
+// op 6 data.
 type obsRequestData struct {
 	RequestType string `json:"requestType"`
+	RequestID   string `json:"requestId"`
 }
 
 // obsRequestBatchData extracts requests array from op 8 data.
@@ -249,7 +355,8 @@ var allowedRequestTypes = map[string]bool{
 	"CreateScene": true, "RemoveScene": true, "SetSceneName": true,
 	// Scene items (sources within scenes)
 	"GetSceneItemList": true, "GetGroupSceneItemList": true, "GetSceneItemEnabled": true, "SetSceneItemEnabled": true,
-	"GetSceneItemTransform": true, "SetSceneItemTransform": true, "SetSceneItemIndex": true, "SetSceneItemBlendMode": true, "SetSceneItemLocked": true, "RemoveSceneItem": true,
+	"GetSceneItemTransform": true, "SetSceneItemTransform": true, "SetSceneItemLocked": true, "RemoveSceneItem": true,
+	"GetSceneItemIndex": true, "SetSceneItemIndex": true, "GetSceneItemBlendMode": true, "SetSceneItemBlendMode": true,
 	// Sources / Inputs
 	"GetSourcesList": true, "GetSourceActive": true,
 	"GetSourceFilterList": true, "CreateSourceFilter": true, "SetSourceFilterEnabled": true,
@@ -267,6 +374,9 @@ var allowedRequestTypes = map[string]bool{
 	"GetVirtualCamStatus": true, "StartVirtualCam": true, "StopVirtualCam": true,
 	// Studio mode
 	"GetStudioModeEnabled": true, "SetStudioModeEnabled": true,
+	// Profiles / scene collections (a switch reloads all of OBS, see DefaultRateLimitOverrides)
+	"GetProfileList": true, "SetCurrentProfile": true,
+	"GetSceneCollectionList": true, "SetCurrentSceneCollection": true,
 	// Media
 	"TriggerMediaInputAction": true,
 	"GetMediaInputStatus":     true,
@@ -278,6 +388,54 @@ var allowedRequestTypes = map[string]bool{
 	"GetSourceScreenshot": true,
 }
 
+// extraAllowed holds request types added from the local config at startup.
+//
+// SECURITY: only populated via AddAllowedRequests from the local config file —
+// nothing received from the relay can extend the whitelist.
+var (
+	extraAllowedMu sync.RWMutex
+	extraAllowed   = map[string]bool{}
+)
+
+// requestTypePattern matches plausible OBS request type names (e.g. ObsNDI_GetStatus).
+var requestTypePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+
+// AddAllowedRequests extends the whitelist with custom request types such as
+// those registered by OBS plugins. Malformed names and the agent-local
+// "Agent*" namespace are skipped. Returns the sorted list of types added.
+func AddAllowedRequests(types []string) []string {
+	extraAllowedMu.Lock()
+	defer extraAllowedMu.Unlock()
+
+	var added []string
+	for _, t := range types {
+		if !requestTypePattern.MatchString(t) || strings.HasPrefix(t, "Agent") {
+			continue
+		}
+		if allowedRequestTypes[t] || extraAllowed[t] {
+			continue
+		}
+		extraAllowed[t] = true
+		added = append(added, t)
+	}
+	sort.Strings(added)
+	return added
+}
+
+// isAllowedRequest checks the built-in whitelist, then the local extras.
+func isAllowedRequest(requestType string) bool {
+	if allowedRequestTypes[requestType] {
+		return true
+	}
+	extraAllowedMu.RLock()
+	defer extraAllowedMu.RUnlock()
+	return extraAllowed[requestType]
+}
+
+// DefaultMaxBatchRequests caps the requests in one RequestBatch (op 8)
+// from the relay. The dashboard sends a handful at most.
+const DefaultMaxBatchRequests = 50
+
 // ProtocolResult is returned by ValidateOBSProtocol.
 type ProtocolResult struct {
 	Valid  bool
@@ -286,8 +444,13 @@ type ProtocolResult struct {
 }
 
 // ValidateOBSProtocol checks that a message is valid OBS v5 going in the specified direction.
-// Must match envelope.js validateOBSProtocol() exactly.
-func ValidateOBSProtocol(payload []byte, dir Direction) ProtocolResult {
+// Must match envelope.js validateOBSProtocol() exactly. A RequestBatch
+// with more than maxBatch requests (DefaultMaxBatchRequests if <= 0) is
+// rejected as batch_too_large.
+func ValidateOBSProtocol(payload []byte, dir Direction, maxBatch int) ProtocolResult {
+	if maxBatch <= 0 {
+		maxBatch = DefaultMaxBatchRequests
+	}
 	var msg obsMessage
 	if err := json.Unmarshal(payload, &msg); err != nil {
 		return ProtocolResult{Reason: "not_json"}
@@ -308,20 +471,33 @@ func ValidateOBSProtocol(payload []byte, dir Direction) ProtocolResult {
 	if msg.Op == 6 && msg.D != nil {
 		var reqData obsRequestData
 		if err := json.Unmarshal(*msg.D, &reqData); err == nil {
-			if reqData.RequestType != "" && !allowedRequestTypes[reqData.RequestType] {
+			if reqData.RequestType != "" && !isAllowedRequest(reqData.RequestType) {
 				return ProtocolResult{Reason: fmt.Sprintf("forbidden_request_%s", reqData.RequestType)}
 			}
 		}
 	}
 
-	// For RequestBatch (op 8) going TO agent: validate each request
+	// For RequestBatch (op 8) going TO agent: bound the size, then validate
+	// each request. A batch that doesn't parse can't be checked, so it is
+	// rejected rather than passed through.
 	if msg.Op == 8 && msg.D != nil {
 		var batchData obsRequestBatchData
-		if err := json.Unmarshal(*msg.D, &batchData); err == nil {
-			for _, req := range batchData.Requests {
-				if req.RequestType != "" && !allowedRequestTypes[req.RequestType] {
-					return ProtocolResult{Reason: fmt.Sprintf("forbidden_batch_request_%s", req.RequestType)}
+		if err := json.Unmarshal(*msg.D, &batchData); err != nil {
+			return ProtocolResult{Reason: "bad_batch"}
+		}
+		if len(batchData.Requests) > maxBatch {
+			return ProtocolResult{Reason: "batch_too_large"}
+		}
+		seen := make(map[string]bool, len(batchData.Requests))
+		for _, req := range batchData.Requests {
+			if req.RequestType != "" && !isAllowedRequest(req.RequestType) {
+				return ProtocolResult{Reason: fmt.Sprintf("forbidden_batch_request_%s", req.RequestType)}
+			}
+			if req.RequestID != "" {
+				if seen[req.RequestID] {
+					return ProtocolResult{Reason: "batch_duplicate_request_id"}
 				}
+				seen[req.RequestID] = true
 			}
 		}
 	}
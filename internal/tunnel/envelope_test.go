@@ -0,0 +1,223 @@
+package tunnel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateRequestAllowlist(t *testing.T) {
+	tests := []struct {
+		requestType string
+		wantReason  string // "" = valid
+	}{
+		{"GetSceneItemIndex", ""},
+		{"SetSceneItemIndex", ""},
+		{"GetSceneItemBlendMode", ""},
+		{"SetSceneItemBlendMode", ""},
+		{"GetProfileList", ""},
+		{"SetCurrentProfile", ""},
+		{"GetSceneCollectionList", ""},
+		{"SetCurrentSceneCollection", ""},
+		{"SetCurrentProgramScene", ""},
+		{"CreateProfile", "forbidden_request_CreateProfile"},
+		{"RemoveSceneCollection", "forbidden_request_RemoveSceneCollection"},
+		{"CallVendorRequest", "forbidden_request_CallVendorRequest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.requestType, func(t *testing.T) {
+			single := fmt.Sprintf(`{"op":6,"d":{"requestType":%q,"requestId":"1"}}`, tt.requestType)
+			batch := fmt.Sprintf(`{"op":8,"d":{"requests":[{"requestType":"GetVersion","requestId":"0"},{"requestType":%q,"requestId":"1"}]}}`, tt.requestType)
+			for _, c := range []struct{ payload, wantReason string }{
+				{single, tt.wantReason},
+				{batch, ""},
+			} {
+				if c.payload == batch && tt.wantReason != "" {
+					c.wantReason = "forbidden_batch_request_" + tt.requestType
+				}
+				res := ValidateOBSProtocol([]byte(c.payload), ToAgent, 0)
+				if res.Valid != (c.wantReason == "") || res.Reason != c.wantReason {
+					t.Errorf("%s: valid %v reason %q, want reason %q", c.payload, res.Valid, res.Reason, c.wantReason)
+				}
+			}
+		})
+	}
+}
+
+// sealAt is seal with a fixed timestamp and nonce.
+func sealAt(key []byte, version int, t int64, nonce string, body []byte) []byte {
+	p := base64.StdEncoding.EncodeToString(body)
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%d|%d|%s|%s", version, t, nonce, p)
+	raw, _ := json.Marshal(envelope{V: version, T: t, N: nonce, P: p, H: hex.EncodeToString(mac.Sum(nil))})
+	return raw
+}
+
+func TestOpen(t *testing.T) {
+	key := DeriveSessionKey("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "01")
+	other := DeriveSessionKey("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", "02")
+	const nonce = "00112233445566778899aabbccddeeff"
+	payload := []byte(`{"op":6}`)
+	now := time.Now().UnixMilli()
+
+	tampered := sealAt(key, 1, now, nonce, payload)
+	tampered = bytes.Replace(tampered, []byte(base64.StdEncoding.EncodeToString(payload)), []byte(base64.StdEncoding.EncodeToString([]byte(`{"op":8}`))), 1)
+
+	tests := []struct {
+		name       string
+		raw        []byte
+		wantReason string // "" = valid
+	}{
+		{"valid", sealAt(key, 1, now, nonce, payload), ""},
+		{"not json", []byte("nope"), "not_json"},
+		{"unknown version", sealAt(key, 3, now, nonce, payload), "bad_version"},
+		{"short nonce", sealAt(key, 1, now, "0011", payload), "bad_nonce"},
+		{"other key", sealAt(other, 1, now, nonce, payload), "bad_hmac"},
+		{"tampered payload", tampered, "bad_hmac"},
+		{"too old", sealAt(key, 1, now-time.Minute.Milliseconds(), nonce, payload), "timestamp_expired"},
+		{"too new", sealAt(key, 1, now+time.Minute.Milliseconds(), nonce, payload), "timestamp_expired"},
+		{"v2 not gzip", sealAt(key, 2, now, nonce, payload), "bad_payload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Open(NewSessionKeyHolder(key), tt.raw, NewNonceCache(5*time.Second, 0))
+			if res.Valid != (tt.wantReason == "") || res.Reason != tt.wantReason {
+				t.Fatalf("valid %v reason %q, want reason %q", res.Valid, res.Reason, tt.wantReason)
+			}
+			if res.Valid && !bytes.Equal(res.Payload, payload) {
+				t.Errorf("payload %q, want %q", res.Payload, payload)
+			}
+		})
+	}
+}
+
+func TestSealGzipRoundTrip(t *testing.T) {
+	keys := NewSessionKeyHolder(DeriveSessionKey("token", "01"))
+	payload := bytes.Repeat([]byte(`{"op":7,"d":{"requestType":"GetSceneList"}}`), 100)
+	sealed, err := SealGzip(keys, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env envelope
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		t.Fatal(err)
+	}
+	if env.V != 2 {
+		t.Errorf("version %d, want 2 for a compressible payload", env.V)
+	}
+	res := Open(keys, sealed, NewNonceCache(0, 0))
+	if !res.Valid || !bytes.Equal(res.Payload, payload) {
+		t.Fatalf("open: valid %v reason %q", res.Valid, res.Reason)
+	}
+}
+
+func TestSealGzipIncompressible(t *testing.T) {
+	keys := NewSessionKeyHolder(DeriveSessionKey("token", "01"))
+	payload := make([]byte, GzipThreshold)
+	rand.Read(payload)
+	sealed, err := SealGzip(keys, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env envelope
+	if err := json.Unmarshal(sealed, &env); err != nil {
+		t.Fatal(err)
+	}
+	if env.V != 1 {
+		t.Errorf("version %d, want 1 for a payload gzip doesn't shrink", env.V)
+	}
+}
+
+// A v2 payload that inflates past maxInflatedPayload is rejected even
+// though its HMAC is valid.
+func TestOpenGzipTooLarge(t *testing.T) {
+	key := DeriveSessionKey("token", "01")
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(make([]byte, maxInflatedPayload+1))
+	zw.Close()
+
+	raw := sealAt(key, 2, time.Now().UnixMilli(), "00112233445566778899aabbccddeeff", buf.Bytes())
+	if res := Open(NewSessionKeyHolder(key), raw, NewNonceCache(0, 0)); res.Valid || res.Reason != "bad_payload" {
+		t.Errorf("valid %v reason %q, want bad_payload", res.Valid, res.Reason)
+	}
+}
+
+func TestNonceCacheReplay(t *testing.T) {
+	keys := NewSessionKeyHolder(DeriveSessionKey("token", "01"))
+	now := time.Now().UnixMilli()
+	nonces := []string{
+		"00000000000000000000000000000001",
+		"00000000000000000000000000000002",
+		"00000000000000000000000000000003",
+	}
+
+	cache := NewNonceCache(0, 2)
+	open := func(n string) OpenResult {
+		return Open(keys, sealAt(keys.Current(), 1, now, n, []byte("{}")), cache)
+	}
+
+	for _, n := range nonces {
+		if res := open(n); !res.Valid {
+			t.Fatalf("first use of %s: %s", n, res.Reason)
+		}
+		if res := open(n); res.Reason != "replay" {
+			t.Fatalf("second use of %s: valid %v reason %q, want replay", n, res.Valid, res.Reason)
+		}
+		time.Sleep(2 * time.Millisecond) // distinct timestamps for eviction
+	}
+
+	// Past the size cap the oldest nonce is forgotten
+	if got := len(cache.nonces); got != 2 {
+		t.Errorf("cache holds %d nonces, want 2", got)
+	}
+	if res := open(nonces[0]); !res.Valid {
+		t.Errorf("evicted nonce: valid %v reason %q, want accepted", res.Valid, res.Reason)
+	}
+}
+
+func TestValidateBatchLimit(t *testing.T) {
+	batch := func(n int, sameID bool) []byte {
+		reqs := make([]string, n)
+		for i := range reqs {
+			id := fmt.Sprint(i)
+			if sameID {
+				id = "x"
+			}
+			reqs[i] = fmt.Sprintf(`{"requestType":"GetVersion","requestId":%q}`, id)
+		}
+		return []byte(`{"op":8,"d":{"requests":[` + strings.Join(reqs, ",") + `]}}`)
+	}
+
+	tests := []struct {
+		name       string
+		payload    []byte
+		maxBatch   int
+		wantReason string
+	}{
+		{"at default limit", batch(DefaultMaxBatchRequests, false), 0, ""},
+		{"over default limit", batch(DefaultMaxBatchRequests+1, false), 0, "batch_too_large"},
+		{"at custom limit", batch(3, false), 3, ""},
+		{"over custom limit", batch(4, false), 3, "batch_too_large"},
+		{"duplicate ids", batch(2, true), 0, "batch_duplicate_request_id"},
+		{"unparsable batch", []byte(`{"op":8,"d":{"requests":{}}}`), 0, "bad_batch"},
+		{"op not allowed to agent", []byte(`{"op":7,"d":{}}`), 0, "forbidden_op_7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := ValidateOBSProtocol(tt.payload, ToAgent, tt.maxBatch)
+			if res.Valid != (tt.wantReason == "") || res.Reason != tt.wantReason {
+				t.Errorf("valid %v reason %q, want reason %q", res.Valid, res.Reason, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package tunnel
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/obs"
+	"github.com/gorilla/websocket"
+)
+
+// After OBS itself goes away (e.g. restarted) the bridge re-dials it this
+// many times, obsRecoverDelay apart, before giving up the relay session too.
+const (
+	obsRecoverAttempts = 5
+	obsRecoverDelay    = 2 * time.Second
+)
+
+// obsLink holds the bridge's current OBS connection so it can be replaced
+// when OBS briefly drops clients (e.g. while switching scene collections)
+// without tearing down the relay session.
+type obsLink struct {
+	addr       string
+	pass       string
+	minVersion string
+
+	mu             sync.RWMutex
+	conn           *websocket.Conn
+	switchingUntil time.Time
+
+	// writeMu serialises writes — approved requests are forwarded from
+	// their own goroutine alongside the relay→OBS pipe.
+	writeMu sync.Mutex
+}
+
+func newOBSLink(conn *websocket.Conn, addr, pass, minVersion string) *obsLink {
+	return &obsLink{conn: conn, addr: addr, pass: pass, minVersion: minVersion}
+}
+
+func (l *obsLink) current() *websocket.Conn {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.conn
+}
+
+// markSwitching opens a window during which an OBS disconnect is treated as
+// part of a scene-collection switch rather than a real failure.
+func (l *obsLink) markSwitching(window time.Duration) {
+	l.mu.Lock()
+	l.switchingUntil = time.Now().Add(window)
+	l.mu.Unlock()
+}
+
+func (l *obsLink) switching() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return time.Now().Before(l.switchingUntil)
+}
+
+// reconnect re-dials OBS until it succeeds or window elapses.
+func (l *obsLink) reconnect(ctx context.Context, window time.Duration) error {
+	deadline := time.Now().Add(window)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		conn, err := obs.Connect(ctx, l.addr, l.pass)
+		if err == nil {
+			l.mu.Lock()
+			old := l.conn
+			l.conn = conn
+			l.mu.Unlock()
+			old.Close()
+			log.Println("[bridge] Reconnected to OBS after scene-collection switch")
+			return nil
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+	return lastErr
+}
+
+// recover re-dials OBS after its connection failed outside a
+// scene-collection switch, up to obsRecoverAttempts times.
+func (l *obsLink) recover(ctx context.Context) (obs.Info, error) {
+	var lastErr error
+	for attempt := 1; attempt <= obsRecoverAttempts; attempt++ {
+		conn, info, err := obs.ConnectInfo(ctx, l.addr, l.pass)
+		if err == nil {
+			// OBS may have come back as an older build
+			if err := obs.CheckMinVersion(info.WebSocketVersion, l.minVersion); err != nil {
+				conn.Close()
+				return obs.Info{}, err
+			}
+			l.mu.Lock()
+			old := l.conn
+			l.conn = conn
+			l.mu.Unlock()
+			old.Close()
+			log.Printf("[bridge] Reconnected to OBS (attempt %d)", attempt)
+			return info, nil
+		}
+		lastErr = err
+		log.Printf("[bridge] OBS reconnect attempt %d/%d failed: %v", attempt, obsRecoverAttempts, err)
+		if attempt == obsRecoverAttempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return obs.Info{}, ctx.Err()
+		case <-time.After(obsRecoverDelay):
+		}
+	}
+	return obs.Info{}, lastErr
+}
+
+// write sends a text frame to the current OBS connection. A connection
+// that fails a write is closed, so the OBS→relay pipe sees it fail too and
+// reconnects.
+func (l *obsLink) write(data []byte) error {
+	l.writeMu.Lock()
+	defer l.writeMu.Unlock()
+	conn := l.current()
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	err := conn.WriteMessage(websocket.TextMessage, data)
+	if err != nil {
+		conn.Close()
+	}
+	return err
+}
+
+// close closes the current OBS connection with a close frame.
+func (l *obsLink) close() {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	obs.CloseConn(l.conn)
+}
@@ -0,0 +1,99 @@
+package tunnel
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// pinPrefix marks a fingerprint pin, as used by curl's --pinnedpubkey.
+const pinPrefix = "sha256//"
+
+// ErrCertPinMismatch is returned when the relay's certificate does not
+// match the configured pin. The TLS handshake is aborted before any
+// data (including the agent token) is sent.
+var ErrCertPinMismatch = errors.New("relay certificate does not match pinned key (possible interception)")
+
+// CertPin holds one or more SHA-256 hashes of a SubjectPublicKeyInfo.
+// Pinning the public key rather than the whole certificate survives
+// renewals that keep the same key.
+type CertPin struct {
+	hashes [][sha256.Size]byte
+}
+
+// ParseCertPin parses a pin spec: either "sha256//<base64 SPKI hash>" or
+// the path of a PEM file whose certificates are all accepted.
+func ParseCertPin(spec string) (*CertPin, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty certificate pin")
+	}
+
+	if strings.HasPrefix(spec, pinPrefix) {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(spec, pinPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pin fingerprint: %w", err)
+		}
+		if len(raw) != sha256.Size {
+			return nil, fmt.Errorf("invalid pin fingerprint: want %d bytes, got %d", sha256.Size, len(raw))
+		}
+		pin := &CertPin{hashes: make([][sha256.Size]byte, 1)}
+		copy(pin.hashes[0][:], raw)
+		return pin, nil
+	}
+
+	data, err := os.ReadFile(spec)
+	if err != nil {
+		return nil, fmt.Errorf("read pin file: %w", err)
+	}
+	pin := &CertPin{}
+	for rest := data; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse pin certificate: %w", err)
+		}
+		pin.hashes = append(pin.hashes, sha256.Sum256(cert.RawSubjectPublicKeyInfo))
+	}
+	if len(pin.hashes) == 0 {
+		return nil, fmt.Errorf("no certificates found in %s", spec)
+	}
+	return pin, nil
+}
+
+// String returns the pin in sha256// form (first hash only).
+func (p *CertPin) String() string {
+	return pinPrefix + base64.StdEncoding.EncodeToString(p.hashes[0][:])
+}
+
+// VerifyPeerCertificate implements the tls.Config hook. It runs after normal
+// chain verification and checks only the leaf.
+func (p *CertPin) VerifyPeerCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return ErrCertPinMismatch
+	}
+	leaf, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return fmt.Errorf("parse relay certificate: %w", err)
+	}
+	sum := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+	for _, h := range p.hashes {
+		if bytes.Equal(sum[:], h[:]) {
+			return nil
+		}
+	}
+	return ErrCertPinMismatch
+}
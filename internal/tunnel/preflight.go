@@ -0,0 +1,46 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// preflightTimeout bounds ValidateToken's wait for the relay's verdict.
+const preflightTimeout = 15 * time.Second
+
+// ValidateToken opens a throwaway relay connection to check token before
+// the agent spends time on OBS. It returns nil once the relay starts a
+// session (token accepted), *ErrTokenRejected on close 4100, and any other
+// error if the relay could not be reached. opts applies as for Connect, so
+// a pinned relay stays pinned.
+func ValidateToken(ctx context.Context, relayURL, token string, opts ConnectOptions) error {
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+
+	conn, _, err := Connect(ctx, relayURL, token, "", opts)
+	if err != nil {
+		return err
+	}
+	defer CloseRelay(conn)
+
+	deadline, _ := ctx.Deadline()
+	conn.SetReadDeadline(deadline)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, 4100) {
+				return &ErrTokenRejected{}
+			}
+			return fmt.Errorf("read relay response: %w", err)
+		}
+		if msg, ok := parseControl(data); ok && (msg.Type == "session" || msg.Type == "connected") {
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, "preflight"),
+				time.Now().Add(time.Second))
+			return nil
+		}
+	}
+}
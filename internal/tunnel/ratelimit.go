@@ -0,0 +1,203 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket: PerSecond tokens are added each second, up
+// to Burst. PerSecond <= 0 means unlimited.
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+func (l RateLimit) String() string {
+	if l.PerSecond <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%g/s burst %d", l.PerSecond, l.Burst)
+}
+
+// RateLimits configures a RateLimiter. Read applies to Get* requests,
+// Write to everything else (Set*, Start*, Stop*, Trigger*...); PerType
+// overrides either for a single request type.
+type RateLimits struct {
+	Read    RateLimit
+	Write   RateLimit
+	PerType map[string]RateLimit
+}
+
+// Default per-request-type limits, as ParseRateLimit specs. The dashboard
+// polls Get* requests a few times a second at most, and a user clicks far
+// slower than the write limit.
+const (
+	DefaultReadRateLimit  = "20:40"
+	DefaultWriteRateLimit = "5:10"
+
+	// DefaultRateLimitOverrides are per-type limits applied under the
+	// -rate-limit overrides: switching the profile or scene collection
+	// tears down and reloads every scene and source in OBS (clients stay
+	// connected and see CurrentSceneCollectionChanging/Changed), so one
+	// every 5 seconds.
+	DefaultRateLimitOverrides = "SetCurrentProfile=0.2:1,SetCurrentSceneCollection=0.2:1"
+)
+
+// ParseRateLimit parses "RATE[:BURST]" (requests per second, e.g. "5:10").
+// BURST defaults to twice RATE; a RATE of 0 disables the limit.
+func ParseRateLimit(s string) (RateLimit, error) {
+	rate, burst, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+	r, err := strconv.ParseFloat(rate, 64)
+	if err != nil || r < 0 || math.IsInf(r, 0) || math.IsNaN(r) {
+		return RateLimit{}, fmt.Errorf("invalid rate %q (want RATE[:BURST], e.g. 5:10)", s)
+	}
+	l := RateLimit{PerSecond: r, Burst: int(math.Ceil(2 * r))}
+	if hasBurst {
+		b, err := strconv.Atoi(burst)
+		if err != nil || b < 1 {
+			return RateLimit{}, fmt.Errorf("invalid burst in %q", s)
+		}
+		l.Burst = b
+	}
+	if l.PerSecond > 0 && l.Burst < 1 {
+		l.Burst = 1
+	}
+	return l, nil
+}
+
+// ParseRateLimitOverrides parses "Type=RATE[:BURST],..." for
+// RateLimits.PerType. An empty string yields no overrides.
+func ParseRateLimitOverrides(s string) (map[string]RateLimit, error) {
+	out := map[string]RateLimit{}
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		typ, spec, ok := strings.Cut(item, "=")
+		if !ok || !requestTypePattern.MatchString(typ) {
+			return nil, fmt.Errorf("invalid override %q (want Type=RATE[:BURST])", item)
+		}
+		l, err := ParseRateLimit(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", typ, err)
+		}
+		out[typ] = l
+	}
+	return out, nil
+}
+
+// RateLimiter drops relay requests that arrive faster than their request
+// type's bucket allows. One limiter is shared by all sessions, so a
+// reconnect does not refill the buckets.
+type RateLimiter struct {
+	limits RateLimits
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	onLimited func(requestTypes []string)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a limiter for limits.
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	return &RateLimiter{limits: limits, buckets: map[string]*bucket{}}
+}
+
+// SetOnLimited sets a callback invoked (outside the lock) for every dropped
+// message, e.g. to count it in the status server. It must not block.
+func (r *RateLimiter) SetOnLimited(fn func(requestTypes []string)) {
+	r.mu.Lock()
+	r.onLimited = fn
+	r.mu.Unlock()
+}
+
+func (r *RateLimiter) limitFor(requestType string) RateLimit {
+	if l, ok := r.limits.PerType[requestType]; ok {
+		return l
+	}
+	if strings.HasPrefix(requestType, "Get") {
+		return r.limits.Read
+	}
+	return r.limits.Write
+}
+
+// Allow takes one token for each request type (a batch passes several) and
+// reports whether all were available. Either every token is taken or none.
+// A batch is charged at most a full bucket per type, so a legal batch
+// larger than the burst can still pass once the bucket has refilled.
+func (r *RateLimiter) Allow(requestTypes ...string) bool {
+	now := time.Now()
+	r.mu.Lock()
+	need := map[string]float64{}
+	for _, t := range requestTypes {
+		need[t]++
+	}
+	ok := true
+	for t, n := range need {
+		l := r.limitFor(t)
+		if l.PerSecond <= 0 {
+			delete(need, t)
+			continue
+		}
+		b := r.buckets[t]
+		if b == nil {
+			b = &bucket{tokens: float64(l.Burst), last: now}
+			r.buckets[t] = b
+		}
+		b.tokens = math.Min(float64(l.Burst), b.tokens+now.Sub(b.last).Seconds()*l.PerSecond)
+		b.last = now
+		n = math.Min(n, float64(l.Burst))
+		need[t] = n
+		if b.tokens < n {
+			ok = false
+		}
+	}
+	if ok {
+		for t, n := range need {
+			r.buckets[t].tokens -= n
+		}
+	}
+	cb := r.onLimited
+	r.mu.Unlock()
+
+	if !ok && cb != nil {
+		cb(requestTypes)
+	}
+	return ok
+}
+
+// requestTypes lists the request types in an op 6 request or op 8 batch.
+func requestTypes(msg *obsMessage) []string {
+	if msg == nil || msg.D == nil {
+		return nil
+	}
+	switch msg.Op {
+	case 6:
+		var req obsRequestData
+		if json.Unmarshal(*msg.D, &req) == nil && req.RequestType != "" {
+			return []string{req.RequestType}
+		}
+	case 8:
+		var batch obsRequestBatchData
+		if json.Unmarshal(*msg.D, &batch) == nil {
+			types := make([]string, 0, len(batch.Requests))
+			for _, req := range batch.Requests {
+				if req.RequestType != "" {
+					types = append(types, req.RequestType)
+				}
+			}
+			return types
+		}
+	}
+	return nil
+}
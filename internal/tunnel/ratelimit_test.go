@@ -0,0 +1,122 @@
+package tunnel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func batch(requestType string, n int) []string {
+	types := make([]string, n)
+	for i := range types {
+		types[i] = requestType
+	}
+	return types
+}
+
+func TestRateLimiterBatch(t *testing.T) {
+	limits := RateLimits{Write: RateLimit{PerSecond: 5, Burst: 10}}
+	tests := []struct {
+		name      string
+		first     int // batch size on a full bucket
+		wantFirst bool
+		second    int // batch size straight after
+		wantNext  bool
+	}{
+		{"under burst", 4, true, 6, true},
+		{"at burst", 10, true, 1, false},
+		{"over burst", 11, true, 1, false},
+		{"max batch", DefaultMaxBatchRequests, true, 1, false},
+		{"under then over", 3, true, 11, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRateLimiter(limits)
+			if got := r.Allow(batch("SetInputMute", tt.first)...); got != tt.wantFirst {
+				t.Errorf("first batch of %d: Allow = %v, want %v", tt.first, got, tt.wantFirst)
+			}
+			if got := r.Allow(batch("SetInputMute", tt.second)...); got != tt.wantNext {
+				t.Errorf("second batch of %d: Allow = %v, want %v", tt.second, got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestRateLimiterAllOrNothing(t *testing.T) {
+	r := NewRateLimiter(RateLimits{
+		Read:  RateLimit{PerSecond: 1, Burst: 2},
+		Write: RateLimit{PerSecond: 1, Burst: 1},
+	})
+	if !r.Allow("SetInputMute") {
+		t.Fatal("first write refused")
+	}
+	// The write bucket is empty, so the read token must not be taken either
+	if r.Allow("GetVersion", "SetInputMute") {
+		t.Fatal("batch allowed with an empty write bucket")
+	}
+	if !r.Allow("GetVersion", "GetVersion") {
+		t.Error("read tokens were charged for a refused batch")
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	r := NewRateLimiter(RateLimits{PerType: map[string]RateLimit{"SetInputMute": {}}})
+	for i := 0; i < 100; i++ {
+		if !r.Allow("SetInputMute") {
+			t.Fatalf("request %d limited with a zero rate", i)
+		}
+	}
+}
+
+func TestParseRateLimitOverrides(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]RateLimit
+		wantErr bool
+	}{
+		{in: "", want: map[string]RateLimit{}},
+		{in: "GetStats=1", want: map[string]RateLimit{"GetStats": {PerSecond: 1, Burst: 2}}},
+		{in: " SetCurrentProgramScene=2:4 , GetStats=0 ", want: map[string]RateLimit{
+			"SetCurrentProgramScene": {PerSecond: 2, Burst: 4},
+			"GetStats":               {PerSecond: 0, Burst: 0},
+		}},
+		{in: DefaultRateLimitOverrides, want: map[string]RateLimit{
+			"SetCurrentProfile":         {PerSecond: 0.2, Burst: 1},
+			"SetCurrentSceneCollection": {PerSecond: 0.2, Burst: 1},
+		}},
+		{in: "GetStats", wantErr: true},
+		{in: "=1", wantErr: true},
+		{in: "Get Stats=1", wantErr: true},
+		{in: "GetStats=-1", wantErr: true},
+		{in: "GetStats=1:0", wantErr: true},
+		{in: "GetStats=fast", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRateLimitOverrides(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRateLimitOverrides(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseRateLimitOverrides(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultOverridesLimitSwitches(t *testing.T) {
+	overrides, err := ParseRateLimitOverrides(DefaultRateLimitOverrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRateLimiter(RateLimits{Write: RateLimit{PerSecond: 5, Burst: 10}, PerType: overrides})
+	for _, typ := range []string{"SetCurrentProfile", "SetCurrentSceneCollection"} {
+		if !r.Allow(typ) {
+			t.Errorf("first %s rejected", typ)
+		}
+		if r.Allow(typ) {
+			t.Errorf("second %s straight after allowed, want limited", typ)
+		}
+	}
+	if !r.Allow(batch("SetInputMute", 2)...) {
+		t.Error("other writes limited by the switch overrides")
+	}
+}
@@ -0,0 +1,76 @@
+package tunnel
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// rekeyGrace is how long envelopes sealed with the previous key are still
+// accepted after a rekey, so messages already in flight drain cleanly.
+const rekeyGrace = 10 * time.Second
+
+// SessionKeyHolder holds the current session key and, briefly after a
+// rekey, the previous one. Seal always uses the current key; Open accepts
+// either while the grace window is open.
+type SessionKeyHolder struct {
+	mu        sync.RWMutex
+	current   []byte
+	previous  []byte
+	rotatedAt time.Time
+}
+
+// NewSessionKeyHolder wraps an initial session key.
+func NewSessionKeyHolder(key []byte) *SessionKeyHolder {
+	return &SessionKeyHolder{current: key}
+}
+
+// Current returns the key used for sealing.
+func (h *SessionKeyHolder) Current() []byte {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.current
+}
+
+// Rotate swaps in a new key, keeping the old one for rekeyGrace.
+func (h *SessionKeyHolder) Rotate(key []byte) {
+	h.mu.Lock()
+	h.previous = h.current
+	h.current = key
+	h.rotatedAt = time.Now()
+	h.mu.Unlock()
+}
+
+// openKeys returns the keys Open should try, newest first.
+func (h *SessionKeyHolder) openKeys() [][]byte {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if h.previous != nil && time.Since(h.rotatedAt) < rekeyGrace {
+		return [][]byte{h.current, h.previous}
+	}
+	return [][]byte{h.current}
+}
+
+// parseRekey reports whether an opened envelope is a
+// {"type":"rekey","nonce":...} message and returns the nonce.
+//
+// SECURITY: rekeys are only honoured inside envelopes that verified with
+// the current session key. A plaintext rekey would let anyone on the path
+// desynchronise the session, and one sealed with the previous key could be
+// a stale message replayed from before the last rotation.
+func parseRekey(result OpenResult) (string, bool) {
+	if !result.Valid || result.Previous {
+		return "", false
+	}
+	msg, ok := parseControl(result.Payload)
+	if !ok || msg.Type != "rekey" || msg.Nonce == "" {
+		return "", false
+	}
+	return msg.Nonce, true
+}
+
+// handleRekey derives and installs the key for a relay "rekey" message.
+func (s *Session) handleRekey(nonce string) {
+	s.Keys.Rotate(DeriveSessionKey(s.token, nonce))
+	log.Println("[agent] Session key rotated")
+}
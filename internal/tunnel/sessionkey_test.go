@@ -0,0 +1,66 @@
+package tunnel
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseRekey(t *testing.T) {
+	const token = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	oldKey := DeriveSessionKey(token, "00000000000000000000000000000001")
+	newKey := DeriveSessionKey(token, "00000000000000000000000000000002")
+	rekey := []byte(`{"type":"rekey","nonce":"00000000000000000000000000000003"}`)
+
+	sealWith := func(key []byte, payload []byte) []byte {
+		t.Helper()
+		sealed, err := Seal(NewSessionKeyHolder(key), payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sealed
+	}
+
+	tests := []struct {
+		name   string
+		frame  []byte
+		wantOK bool
+	}{
+		{"plaintext", rekey, false},
+		{"signed with current key", sealWith(newKey, rekey), true},
+		{"signed with previous key", sealWith(oldKey, rekey), false},
+		{"signed with unrelated key", sealWith(DeriveSessionKey(token, "ff"), rekey), false},
+		{"signed without nonce", sealWith(newKey, []byte(`{"type":"rekey"}`)), false},
+		{"signed other control", sealWith(newKey, []byte(`{"type":"token_rotate","token":"x"}`)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := NewSessionKeyHolder(oldKey)
+			keys.Rotate(newKey) // previous key still inside the grace window
+
+			nonce, ok := parseRekey(Open(keys, tt.frame, NewNonceCache(0, 0)))
+			if ok != tt.wantOK {
+				t.Fatalf("parseRekey ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && nonce != "00000000000000000000000000000003" {
+				t.Errorf("nonce = %q", nonce)
+			}
+		})
+	}
+}
+
+func TestOpenReportsPreviousKey(t *testing.T) {
+	keys := NewSessionKeyHolder([]byte("old-session-key"))
+	sealed, err := Seal(keys, []byte(`{"op":6}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.Rotate([]byte("new-session-key"))
+
+	res := Open(keys, sealed, NewNonceCache(0, 0))
+	if !res.Valid || !res.Previous {
+		t.Fatalf("Open = %+v, want valid with Previous set", res)
+	}
+	if !bytes.Equal(res.Payload, []byte(`{"op":6}`)) {
+		t.Errorf("payload = %s", res.Payload)
+	}
+}
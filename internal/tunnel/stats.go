@@ -0,0 +1,161 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BridgeStats counts messages passing through EnvelopeBridge. One value
+// can be shared by successive bridges so the totals survive reconnects.
+// A nil *BridgeStats counts nothing.
+type BridgeStats struct {
+	toOBS    atomic.Int64
+	toRelay  atomic.Int64
+	rejected atomic.Int64
+}
+
+// Counts returns the relay→OBS and OBS→relay messages forwarded, and the
+// relay messages rejected (bad envelope or forbidden OBS request).
+func (s *BridgeStats) Counts() (toOBS, toRelay, rejected int64) {
+	if s == nil {
+		return 0, 0, 0
+	}
+	return s.toOBS.Load(), s.toRelay.Load(), s.rejected.Load()
+}
+
+func (s *BridgeStats) addToOBS() {
+	if s != nil {
+		s.toOBS.Add(1)
+	}
+}
+
+func (s *BridgeStats) addToRelay() {
+	if s != nil {
+		s.toRelay.Add(1)
+	}
+}
+
+func (s *BridgeStats) addRejected() {
+	if s != nil {
+		s.rejected.Add(1)
+	}
+}
+
+// BridgeMetrics receives measurements from EnvelopeBridge, typically the
+// status server's Record* methods. Nil funcs are skipped.
+type BridgeMetrics struct {
+	// RelayRTT receives the round trip of each keepalive ping.
+	RelayRTT func(time.Duration)
+	// OBSResponse receives how long OBS took to answer a request, from the
+	// relay or from the monitor.
+	OBSResponse func(time.Duration)
+	// Message receives the size of every message bridged either way.
+	Message func(size int)
+}
+
+func (m BridgeMetrics) relayRTT(d time.Duration) {
+	if m.RelayRTT != nil {
+		m.RelayRTT(d)
+	}
+}
+
+func (m BridgeMetrics) message(size int) {
+	if m.Message != nil {
+		m.Message(size)
+	}
+}
+
+// maxPendingRequests bounds the requests timed at once; OBS answers in
+// milliseconds, so only unanswered ones pile up.
+const maxPendingRequests = 1024
+
+// pendingRequests tracks relay requests from their write to OBS until the
+// matching response, for the shutdown drain and (when observe is set) the
+// response-time metric. A nil *pendingRequests tracks nothing.
+type pendingRequests struct {
+	mu      sync.Mutex
+	sent    map[string]time.Time
+	observe func(time.Duration)
+}
+
+func newPendingRequests(observe func(time.Duration)) *pendingRequests {
+	return &pendingRequests{sent: map[string]time.Time{}, observe: observe}
+}
+
+// add notes that the op 6/8 request msg was just written to OBS.
+func (p *pendingRequests) add(msg *obsMessage) {
+	if p == nil {
+		return
+	}
+	id := messageRequestID(msg)
+	if id == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.sent) >= maxPendingRequests {
+		for k, t := range p.sent {
+			if time.Since(t) > time.Minute {
+				delete(p.sent, k)
+			}
+		}
+		if len(p.sent) >= maxPendingRequests {
+			return
+		}
+	}
+	p.sent[id] = time.Now()
+}
+
+// done records the response time if msg answers a pending request.
+func (p *pendingRequests) done(msg *obsMessage) {
+	if p == nil || msg == nil || (msg.Op != 7 && msg.Op != 9) {
+		return
+	}
+	id := messageRequestID(msg)
+	p.mu.Lock()
+	t, ok := p.sent[id]
+	delete(p.sent, id)
+	p.mu.Unlock()
+	if ok && p.observe != nil {
+		p.observe(time.Since(t))
+	}
+}
+
+// clear forgets every pending request, e.g. when the OBS connection they
+// were sent on is replaced.
+func (p *pendingRequests) clear() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	clear(p.sent)
+	p.mu.Unlock()
+}
+
+// inFlight returns how many requests are still waiting for OBS. Requests
+// unanswered for a minute are assumed lost and forgotten.
+func (p *pendingRequests) inFlight() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for k, t := range p.sent {
+		if time.Since(t) > time.Minute {
+			delete(p.sent, k)
+		}
+	}
+	return len(p.sent)
+}
+
+// messageRequestID returns the requestId of an op 6/7/8/9 message.
+func messageRequestID(msg *obsMessage) string {
+	if msg == nil || msg.D == nil {
+		return ""
+	}
+	var d obsRequestData
+	json.Unmarshal(*msg.D, &d)
+	return d.RequestID
+}
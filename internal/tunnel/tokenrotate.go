@@ -0,0 +1,46 @@
+package tunnel
+
+import (
+	"log"
+	"regexp"
+)
+
+// tokenFormat is the agent token shape (64 lowercase hex characters).
+var tokenFormat = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// TokenRotateFunc receives a new agent token pushed by the relay. It should
+// persist the token and use it for the next connection; the current session
+// keeps running on the old one.
+type TokenRotateFunc func(newToken string)
+
+// parseTokenRotate reports whether an opened envelope payload is a
+// {"type":"token_rotate","token":...} message and returns the token.
+//
+// SECURITY: only called on payloads whose HMAC verified, so a token can
+// only come from a relay that already holds the current token.
+func parseTokenRotate(payload []byte) (string, bool) {
+	msg, ok := parseControl(payload)
+	if !ok || msg.Type != "token_rotate" {
+		return "", false
+	}
+	return msg.Token, true
+}
+
+// handleTokenRotate validates a rotated token and hands it to the callback.
+// Session keys for this session (including rekeys) stay derived from the
+// old token, which the relay honours until the session ends.
+func (s *Session) handleTokenRotate(token string) {
+	if !tokenFormat.MatchString(token) {
+		log.Println("[agent] Ignoring token rotation: invalid token format")
+		return
+	}
+	if token == s.token {
+		return
+	}
+	if s.onTokenRotate == nil {
+		log.Println("[agent] Token rotation received but not supported here — ignoring")
+		return
+	}
+	log.Printf("[agent] Relay rotated agent token (new token %s...%s)", token[:4], token[60:])
+	s.onTokenRotate(token)
+}
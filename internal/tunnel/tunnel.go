@@ -3,28 +3,70 @@ package tunnel
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/4throck/obs-agent/internal/obs"
 	"github.com/gorilla/websocket"
 )
 
+const (
+	// DefaultReadLimit caps relay frames. Large productions (hundreds of scenes)
+	// legitimately exceed 256 KB, but anything past this is suspicious.
+	DefaultReadLimit = 1024 * 1024
+	// DefaultCompressedReadLimit applies when permessage-deflate is negotiated,
+	// since large scene lists compress well and may legitimately exceed the default.
+	DefaultCompressedReadLimit = 4 * 1024 * 1024
+)
+
+// ConnectOptions tunes the relay connection. Zero values use the defaults.
+type ConnectOptions struct {
+	ReadLimit           int64
+	CompressedReadLimit int64
+	// RootCAs overrides the system roots (test relays with self-signed certs).
+	RootCAs *x509.CertPool
+	// Pin, when set, additionally requires the relay's leaf key to match.
+	Pin *CertPin
+
+	// OBS, when set, is sent in X-Agent-OBS-* headers so the relay can
+	// gate on the OBS the agent is bridging to.
+	OBS *obs.Version
+}
+
+// ConnInfo describes what was negotiated when connecting to the relay.
+type ConnInfo struct {
+	Compressed bool  // permessage-deflate active
+	ReadLimit  int64 // effective read limit in bytes
+}
+
 // Connect establishes a WSS connection to the relay server.
 //
 // SECURITY:
 // - TLS 1.3 minimum (prevents downgrade attacks)
+// - Optional public key pin on top of CA validation (ErrCertPinMismatch)
 // - Token sent in header (not URL) — never appears in server access logs
 // - Error messages are generic — do not leak server-side failure reasons
 // - Read limit prevents memory exhaustion from malicious frames
-func Connect(ctx context.Context, relayURL, token, version string) (*websocket.Conn, error) {
+func Connect(ctx context.Context, relayURL, token, version string, opts ConnectOptions) (*websocket.Conn, ConnInfo, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		RootCAs:    opts.RootCAs,
+		// CipherSuites: TLS 1.3 suites are not configurable in Go
+		// (all TLS 1.3 suites are considered secure). This is correct behavior.
+	}
+	if opts.Pin != nil {
+		tlsConfig.VerifyPeerCertificate = opts.Pin.VerifyPeerCertificate
+	}
 	dialer := &websocket.Dialer{
-		HandshakeTimeout: 15 * time.Second,
-		TLSClientConfig: &tls.Config{
-			MinVersion: tls.VersionTLS13,
-			// CipherSuites: TLS 1.3 suites are not configurable in Go
-			// (all TLS 1.3 suites are considered secure). This is correct behavior.
-		},
+		HandshakeTimeout:  15 * time.Second,
+		EnableCompression: true,
+		TLSClientConfig:   tlsConfig,
 	}
 
 	headers := http.Header{}
@@ -32,19 +74,70 @@ func Connect(ctx context.Context, relayURL, token, version string) (*websocket.C
 	if version != "" {
 		headers.Set("X-Agent-Version", version)
 	}
+	// Envelope features we can receive — the relay advertises its own in the session message
+	headers.Set("X-Agent-Features", featureGzip)
+	if v := opts.OBS; v != nil {
+		setHeader(headers, "X-Agent-OBS-Version", v.OBSVersion)
+		setHeader(headers, "X-Agent-OBS-WebSocket-Version", v.OBSWebSocketVersion)
+		if v.RPCVersion > 0 {
+			headers.Set("X-Agent-OBS-RPC-Version", strconv.Itoa(v.RPCVersion))
+		}
+		setHeader(headers, "X-Agent-OBS-Platform", v.Platform)
+	}
 
 	conn, resp, err := dialer.DialContext(ctx, relayURL, headers)
 	if err != nil {
 		if resp != nil {
 			// SECURITY: generic error — do not differentiate failure modes
 			// Close codes from relay are all 4100 "refused" (no enumeration possible)
-			return nil, fmt.Errorf("connection refused by relay (HTTP %d)", resp.StatusCode)
+			return nil, ConnInfo{}, fmt.Errorf("connection refused by relay (HTTP %d)", resp.StatusCode)
+		}
+		if errors.Is(err, ErrCertPinMismatch) {
+			return nil, ConnInfo{}, ErrCertPinMismatch
 		}
-		return nil, fmt.Errorf("connection failed: %w", err)
+		return nil, ConnInfo{}, fmt.Errorf("connection failed: %w", err)
 	}
 
-	// 256KB read limit — OBS messages are small, anything larger is suspicious
-	conn.SetReadLimit(256 * 1024)
+	compressed := deflateNegotiated(resp)
 
-	return conn, nil
+	limit := opts.ReadLimit
+	if limit <= 0 {
+		limit = DefaultReadLimit
+	}
+	if compressed {
+		compressedLimit := opts.CompressedReadLimit
+		if compressedLimit <= 0 {
+			compressedLimit = DefaultCompressedReadLimit
+		}
+		if compressedLimit > limit {
+			limit = compressedLimit
+		}
+		conn.EnableWriteCompression(true)
+		log.Println("[agent] permessage-deflate negotiated with relay")
+	}
+	conn.SetReadLimit(limit)
+
+	return conn, ConnInfo{Compressed: compressed, ReadLimit: limit}, nil
+}
+
+// setHeader sets a header from a value OBS reported, unless it is empty or
+// holds control characters.
+func setHeader(h http.Header, key, value string) {
+	if value == "" || strings.ContainsFunc(value, func(r rune) bool { return r < ' ' || r == 0x7f }) {
+		return
+	}
+	h.Set(key, value)
+}
+
+// deflateNegotiated reports whether the relay accepted permessage-deflate.
+func deflateNegotiated(resp *http.Response) bool {
+	if resp == nil {
+		return false
+	}
+	for _, ext := range resp.Header.Values("Sec-WebSocket-Extensions") {
+		if strings.Contains(ext, "permessage-deflate") {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,108 @@
+package tunnel_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/relaytest"
+	"github.com/4throck/obs-agent/internal/tunnel"
+	"github.com/gorilla/websocket"
+)
+
+const testToken = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func TestConnect(t *testing.T) {
+	wrongPin, err := tunnel.ParseCertPin("sha256//" + strings.Repeat("A", 43) + "=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		useTLS    bool
+		trustCert bool
+		pin       *tunnel.CertPin
+		closeCode int
+		wantErr   func(error) bool // nil = session established
+	}{
+		{name: "wss with trusted cert", useTLS: true, trustCert: true},
+		{name: "plain ws", useTLS: false},
+		{
+			name:    "untrusted cert",
+			useTLS:  true,
+			wantErr: func(err error) bool { return err != nil },
+		},
+		{
+			name:      "pin mismatch",
+			useTLS:    true,
+			trustCert: true,
+			pin:       wrongPin,
+			wantErr:   func(err error) bool { return errors.Is(err, tunnel.ErrCertPinMismatch) },
+		},
+		{
+			name:      "token rejected",
+			useTLS:    true,
+			trustCert: true,
+			closeCode: relaytest.CloseTokenRejected,
+			wantErr: func(err error) bool {
+				var rejected *tunnel.ErrTokenRejected
+				return errors.As(err, &rejected)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relay, err := relaytest.NewServer(tt.useTLS)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer relay.Close()
+			relay.SetCloseCode(tt.closeCode)
+
+			opts := tunnel.ConnectOptions{Pin: tt.pin}
+			if tt.trustCert {
+				opts.RootCAs = relay.RootCAs()
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			conn, info, err := tunnel.Connect(ctx, relay.URL(), testToken, "test", opts)
+			var sess *tunnel.Session
+			if err == nil {
+				defer conn.Close()
+				sess, err = tunnel.WaitForSession(conn, testToken, info, tunnel.SessionOptions{})
+			}
+			if tt.wantErr != nil {
+				if !tt.wantErr(err) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := relay.LastToken(); got != testToken {
+				t.Errorf("relay saw token %q", got)
+			}
+
+			// Both sides derived the same session key
+			sealed, err := tunnel.Seal(sess.Keys, []byte(`{"op":7}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, sealed); err != nil {
+				t.Fatal(err)
+			}
+			deadline := time.Now().Add(5 * time.Second)
+			for len(relay.ReceivedFromAgent()) == 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("relay did not verify the agent's envelope")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package tunneltest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/obs"
+	"github.com/4throck/obs-agent/internal/obstest"
+	"github.com/4throck/obs-agent/internal/tunnel"
+	"github.com/gorilla/websocket"
+)
+
+// Harness runs a real EnvelopeBridge between a fake OBS and a fake relay.
+type Harness struct {
+	OBS   *obstest.OBSTestServer
+	Relay *Relay
+
+	obsConn   *websocket.Conn
+	relayConn *websocket.Conn
+	cancel    context.CancelFunc
+	done      chan error
+}
+
+// Start connects both sides and launches the bridge.
+func Start(ctx context.Context, obsPassword string) (*Harness, error) {
+	return StartWithOptions(ctx, obsPassword, tunnel.BridgeOptions{})
+}
+
+// StartWithOptions is Start with the given bridge options.
+func StartWithOptions(ctx context.Context, obsPassword string, opts tunnel.BridgeOptions) (*Harness, error) {
+	fakeOBS, err := obstest.NewServer(obsPassword)
+	if err != nil {
+		return nil, err
+	}
+	relay := NewRelay(TestToken)
+
+	h := &Harness{OBS: fakeOBS, Relay: relay, done: make(chan error, 1)}
+
+	h.obsConn, err = obs.Connect(ctx, fakeOBS.Addr(), obsPassword)
+	if err != nil {
+		h.Close()
+		return nil, fmt.Errorf("fake OBS: %w", err)
+	}
+
+	var info tunnel.ConnInfo
+	h.relayConn, info, err = tunnel.Connect(ctx, relay.URL(), TestToken, "test", tunnel.ConnectOptions{})
+	if err != nil {
+		h.Close()
+		return nil, fmt.Errorf("fake relay: %w", err)
+	}
+	sess, err := tunnel.WaitForSession(h.relayConn, TestToken, info, tunnel.SessionOptions{})
+	if err != nil {
+		h.Close()
+		return nil, fmt.Errorf("session: %w", err)
+	}
+
+	bctx, cancel := context.WithCancel(ctx)
+	h.cancel = cancel
+	go func() {
+		h.done <- tunnel.EnvelopeBridge(bctx, h.obsConn, h.relayConn, sess, fakeOBS.Addr(), obsPassword, opts)
+	}()
+	return h, nil
+}
+
+// Request sends an op 6 request through the relay and waits for the
+// matching op 7 response. Events arriving in between are skipped.
+func (h *Harness) Request(requestType, requestID string, timeout time.Duration) (json.RawMessage, error) {
+	req, _ := json.Marshal(map[string]interface{}{
+		"op": 6,
+		"d":  map[string]string{"requestType": requestType, "requestId": requestID},
+	})
+	if _, err := h.Relay.Send(req); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		p, err := h.Relay.Receive(time.Until(deadline))
+		if err != nil {
+			return nil, err
+		}
+		var msg struct {
+			Op int `json:"op"`
+			D  struct {
+				RequestID string `json:"requestId"`
+			} `json:"d"`
+		}
+		if json.Unmarshal(p, &msg) == nil && msg.Op == 7 && msg.D.RequestID == requestID {
+			return p, nil
+		}
+	}
+}
+
+// Done delivers the bridge's return value once it exits.
+func (h *Harness) Done() <-chan error {
+	return h.done
+}
+
+// Close tears down the bridge and both fake servers.
+func (h *Harness) Close() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+	if h.obsConn != nil {
+		h.obsConn.Close()
+	}
+	if h.relayConn != nil {
+		h.relayConn.Close()
+	}
+	h.Relay.Close()
+	h.OBS.Close()
+}
@@ -0,0 +1,202 @@
+package tunneltest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/tunnel"
+	"github.com/4throck/obs-agent/internal/tunneltest"
+)
+
+// response is the part of an op 7 the tests look at.
+type response struct {
+	Op int `json:"op"`
+	D  struct {
+		RequestType   string `json:"requestType"`
+		RequestID     string `json:"requestId"`
+		RequestStatus struct {
+			Result bool `json:"result"`
+			Code   int  `json:"code"`
+		} `json:"requestStatus"`
+		ResponseData json.RawMessage `json:"responseData"`
+	} `json:"d"`
+}
+
+func start(t *testing.T, opts tunnel.BridgeOptions) *tunneltest.Harness {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	h, err := tunneltest.StartWithOptions(ctx, "secret", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Close)
+	return h
+}
+
+func request(requestType, requestID string, data interface{}) []byte {
+	d := map[string]interface{}{"requestType": requestType, "requestId": requestID}
+	if data != nil {
+		d["requestData"] = data
+	}
+	b, _ := json.Marshal(map[string]interface{}{"op": 6, "d": d})
+	return b
+}
+
+// obsSaw counts the requests with requestID that reached the fake OBS.
+func obsSaw(h *tunneltest.Harness, requestID string) int {
+	n := 0
+	for _, r := range h.OBS.RecordedRequests() {
+		if r.RequestID == requestID {
+			n++
+		}
+	}
+	return n
+}
+
+func TestHarnessRoundTrip(t *testing.T) {
+	h := start(t, tunnel.BridgeOptions{})
+
+	raw, err := h.Request("GetVersion", "rt-1", 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var resp response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.D.RequestType != "GetVersion" || !resp.D.RequestStatus.Result || len(resp.D.ResponseData) == 0 {
+		t.Errorf("response %s", raw)
+	}
+	if n := obsSaw(h, "rt-1"); n != 1 {
+		t.Errorf("OBS saw the request %d times", n)
+	}
+	if rejected := h.Relay.Rejected(); len(rejected) > 0 {
+		t.Errorf("relay rejected agent envelopes: %v", rejected)
+	}
+}
+
+func TestHarnessReplayRejected(t *testing.T) {
+	stats := &tunnel.BridgeStats{}
+	h := start(t, tunnel.BridgeOptions{Stats: stats})
+
+	sealed, err := h.Relay.Send(request("GetVersion", "once", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Relay.Receive(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Relay.SendRaw(sealed); err != nil {
+		t.Fatal(err)
+	}
+	// A later request proves the replay was read (and dropped) first
+	if _, err := h.Request("GetVersion", "after-replay", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if n := obsSaw(h, "once"); n != 1 {
+		t.Errorf("OBS saw the replayed request %d times", n)
+	}
+	if _, _, rejected := stats.Counts(); rejected != 1 {
+		t.Errorf("bridge rejected %d messages, want 1", rejected)
+	}
+}
+
+func TestHarnessForbiddenRequest(t *testing.T) {
+	stats := &tunnel.BridgeStats{}
+	h := start(t, tunnel.BridgeOptions{Stats: stats})
+
+	if _, err := h.Request("CreateProfile", "forbidden", 500*time.Millisecond); !errors.Is(err, tunneltest.ErrTimeout) {
+		t.Fatalf("forbidden request answered (%v)", err)
+	}
+	if n := obsSaw(h, "forbidden"); n != 0 {
+		t.Errorf("OBS saw the forbidden request %d times", n)
+	}
+	if _, _, rejected := stats.Counts(); rejected != 1 {
+		t.Errorf("bridge rejected %d messages, want 1", rejected)
+	}
+}
+
+func TestHarnessConfigureMonitor(t *testing.T) {
+	h := start(t, tunnel.BridgeOptions{})
+
+	tests := []struct {
+		name       string
+		data       interface{}
+		wantResult bool
+		wantCode   int
+	}{
+		{"valid config", map[string]interface{}{"source": "Camera", "pollIntervalMs": 1000}, true, 100},
+		{"invalid config", map[string]interface{}{"stateMap": map[string]string{"OBS_MEDIA_STATE_ENDED": " "}}, false, 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := "configure-" + tt.name
+			if _, err := h.Relay.Send(request("AgentConfigureMonitor", id, tt.data)); err != nil {
+				t.Fatal(err)
+			}
+			var resp response
+			for resp.D.RequestID != id {
+				raw, err := h.Relay.Receive(5 * time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp = response{}
+				json.Unmarshal(raw, &resp)
+			}
+			if resp.Op != 7 || resp.D.RequestStatus.Result != tt.wantResult || resp.D.RequestStatus.Code != tt.wantCode {
+				t.Errorf("response %+v, want result %v code %d", resp, tt.wantResult, tt.wantCode)
+			}
+			if n := obsSaw(h, id); n != 0 {
+				t.Errorf("AgentConfigureMonitor forwarded to OBS %d times", n)
+			}
+		})
+	}
+}
+
+func TestHarnessPingPong(t *testing.T) {
+	pongs := make(chan struct{}, 16)
+	h := start(t, tunnel.BridgeOptions{
+		PingInterval: 50 * time.Millisecond,
+		OnPong: func() {
+			select {
+			case pongs <- struct{}{}:
+			default:
+			}
+		},
+	})
+
+	select {
+	case <-pongs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no pong from the relay")
+	}
+	if h.Relay.Pings() == 0 {
+		t.Error("relay counted no pings")
+	}
+}
+
+func TestHarnessTeardown(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	h, err := tunneltest.Start(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Request("GetVersion", "before-close", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Close()
+	select {
+	case <-h.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("bridge still running after Close")
+	}
+	if err := h.Relay.SendRaw([]byte(`{}`)); err == nil {
+		t.Error("relay still writing to the agent after Close")
+	}
+}
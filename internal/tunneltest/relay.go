@@ -0,0 +1,194 @@
+// Package tunneltest provides an in-process loopback harness for the
+// envelope bridge: a fake relay that seals/opens envelopes with a known
+// session key, wired to a fake OBS from obstest.
+package tunneltest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/tunnel"
+	"github.com/gorilla/websocket"
+)
+
+// TestToken is a well-formed agent token for harness use.
+const TestToken = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+// ErrTimeout is returned by Receive when nothing arrives in time.
+var ErrTimeout = errors.New("tunneltest: timed out")
+
+// Relay is a fake relay speaking the session handshake and signed envelopes
+// over plain WebSocket. It accepts a single agent connection at a time.
+type Relay struct {
+	token  string
+	nonce  string
+	srv    *httptest.Server
+	cache  *tunnel.NonceCache
+	recvCh chan []byte
+
+	mu        sync.Mutex
+	conn      *websocket.Conn
+	connected chan struct{}
+	sent      [][]byte
+	pings     int
+	rejected  []string
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// NewRelay starts a fake relay. The agent must connect with token.
+func NewRelay(token string) *Relay {
+	n := make([]byte, 16)
+	rand.Read(n)
+	r := &Relay{
+		token:     token,
+		nonce:     hex.EncodeToString(n),
+		cache:     tunnel.NewNonceCache(0, 0),
+		recvCh:    make(chan []byte, 256),
+		connected: make(chan struct{}),
+	}
+	r.srv = httptest.NewServer(http.HandlerFunc(r.handle))
+	return r
+}
+
+// URL is the ws:// address to pass to tunnel.Connect.
+func (r *Relay) URL() string {
+	return "ws" + strings.TrimPrefix(r.srv.URL, "http")
+}
+
+// SessionKeys is the key holder both sides derive for this relay's session nonce.
+func (r *Relay) SessionKeys() *tunnel.SessionKeyHolder {
+	return tunnel.NewSessionKeyHolder(tunnel.DeriveSessionKey(r.token, r.nonce))
+}
+
+// WaitConnected blocks until an agent has completed the handshake.
+func (r *Relay) WaitConnected(timeout time.Duration) error {
+	select {
+	case <-r.connected:
+		return nil
+	case <-time.After(timeout):
+		return ErrTimeout
+	}
+}
+
+// Send seals payload and sends it to the agent. The sealed envelope is
+// returned so tests can replay it with SendRaw.
+func (r *Relay) Send(payload []byte) ([]byte, error) {
+	sealed, err := tunnel.Seal(r.SessionKeys(), payload)
+	if err != nil {
+		return nil, err
+	}
+	return sealed, r.SendRaw(sealed)
+}
+
+// SendRaw writes a pre-built frame (e.g. a replayed or tampered envelope).
+func (r *Relay) SendRaw(frame []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return errors.New("tunneltest: no agent connected")
+	}
+	r.sent = append(r.sent, frame)
+	r.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	return r.conn.WriteMessage(websocket.TextMessage, frame)
+}
+
+// Receive returns the next payload the agent sent, already verified and unwrapped.
+func (r *Relay) Receive(timeout time.Duration) ([]byte, error) {
+	select {
+	case p := <-r.recvCh:
+		return p, nil
+	case <-time.After(timeout):
+		return nil, ErrTimeout
+	}
+}
+
+// Pings is the number of WebSocket pings received from the agent.
+func (r *Relay) Pings() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pings
+}
+
+// Rejected lists reasons for agent envelopes that failed tunnel.Open.
+func (r *Relay) Rejected() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.rejected...)
+}
+
+// CloseAgent closes the agent connection with the given close code.
+func (r *Relay) CloseAgent(code int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil {
+		return
+	}
+	r.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, ""), time.Now().Add(time.Second))
+	r.conn.Close()
+}
+
+// Close shuts the relay down.
+func (r *Relay) Close() {
+	r.CloseAgent(websocket.CloseGoingAway)
+	r.srv.Close()
+}
+
+func (r *Relay) handle(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("X-Agent-Token") != r.token {
+		http.Error(w, "refused", http.StatusUnauthorized)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	conn.SetPingHandler(func(data string) error {
+		r.mu.Lock()
+		r.pings++
+		r.mu.Unlock()
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+
+	r.mu.Lock()
+	r.conn = conn
+	conn.WriteJSON(map[string]string{"type": "session", "nonce": r.nonce})
+	conn.WriteJSON(map[string]string{"type": "connected"})
+	r.mu.Unlock()
+
+	select {
+	case <-r.connected:
+	default:
+		close(r.connected)
+	}
+
+	keys := r.SessionKeys()
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		res := tunnel.Open(keys, data, r.cache)
+		if !res.Valid {
+			r.mu.Lock()
+			r.rejected = append(r.rejected, res.Reason)
+			r.mu.Unlock()
+			continue
+		}
+		select {
+		case r.recvCh <- res.Payload:
+		default:
+		}
+	}
+}
@@ -2,19 +2,77 @@ package ui
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync"
+
+	"github.com/4throck/obs-agent/internal/branding"
+	"github.com/4throck/obs-agent/internal/qr"
+	"golang.org/x/term"
 )
 
+// qrQuietZone is the light border (in modules) around the terminal QR code.
+const qrQuietZone = 2
+
 // CliUI uses stdin/stdout for interaction — fallback for headless environments.
+// A single goroutine reads stdin, so a prompt that is given up on (see
+// ConfirmContext) never leaves a second reader competing for the next line.
 type CliUI struct {
-	reader *bufio.Reader
+	in io.Reader
+
+	once  sync.Once
+	lines chan string // closed at EOF
+
+	mu    sync.Mutex
+	stale bool // a cancelled prompt may have left a line behind
 }
 
 // NewCliUI returns a new CLI-based UI.
 func NewCliUI() *CliUI {
-	return &CliUI{reader: bufio.NewReader(os.Stdin)}
+	return &CliUI{in: os.Stdin}
+}
+
+// readLine returns the next input line, or false at EOF or once ctx is done.
+func (c *CliUI) readLine(ctx context.Context) (string, bool) {
+	c.once.Do(func() {
+		c.lines = make(chan string)
+		go func() {
+			defer close(c.lines)
+			r := bufio.NewReader(c.in)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				c.lines <- line
+			}
+		}()
+	})
+
+	// Drop an answer typed for a prompt that already gave up
+	c.mu.Lock()
+	if c.stale {
+		c.stale = false
+		select {
+		case <-c.lines:
+		default:
+		}
+	}
+	c.mu.Unlock()
+
+	select {
+	case line, ok := <-c.lines:
+		return line, ok
+	case <-ctx.Done():
+		c.mu.Lock()
+		c.stale = true
+		c.mu.Unlock()
+		fmt.Println()
+		return "", false
+	}
 }
 
 func (c *CliUI) Info(title, message string) {
@@ -31,8 +89,8 @@ func (c *CliUI) Entry(title, text, defaultValue string) (string, bool) {
 	} else {
 		fmt.Printf("%s: ", text)
 	}
-	line, err := c.reader.ReadString('\n')
-	if err != nil {
+	line, ok := c.readLine(context.Background())
+	if !ok {
 		return "", false
 	}
 	line = strings.TrimSpace(line)
@@ -44,21 +102,27 @@ func (c *CliUI) Entry(title, text, defaultValue string) (string, bool) {
 
 func (c *CliUI) Password(title, text string) (string, bool) {
 	fmt.Printf("%s: ", text)
-	line, err := c.reader.ReadString('\n')
-	if err != nil {
+	line, ok := c.readLine(context.Background())
+	if !ok {
 		return "", false
 	}
 	return strings.TrimSpace(line), true
 }
 
 func (c *CliUI) Confirm(title, message string) bool {
-	fmt.Printf("%s [Y/n]: ", message)
-	line, err := c.reader.ReadString('\n')
-	if err != nil {
+	return c.ConfirmContext(context.Background(), title, message)
+}
+
+// ConfirmContext defaults to no: only "y" or "yes" approves, so a stray
+// Enter cannot say yes on the user's behalf.
+func (c *CliUI) ConfirmContext(ctx context.Context, title, message string) bool {
+	fmt.Printf("%s [y/N]: ", message)
+	line, ok := c.readLine(ctx)
+	if !ok {
 		return false
 	}
 	line = strings.ToLower(strings.TrimSpace(line))
-	return line == "" || line == "y" || line == "yes"
+	return line == "y" || line == "yes"
 }
 
 func (c *CliUI) Form(title string, fields []FormField) (map[string]string, bool) {
@@ -81,3 +145,75 @@ func (c *CliUI) Form(title string, fields []FormField) (map[string]string, bool)
 	}
 	return result, true
 }
+
+// DisplayAuthPrompt shows the device verification URL and user code.
+// On a capable terminal the URL is also rendered as a QR code (two modules
+// per character cell using half blocks) so it can be scanned from a phone;
+// narrow, non-interactive or TERM=dumb terminals get plain text only.
+func (c *CliUI) DisplayAuthPrompt(url, code string) {
+	w := os.Stdout
+	fmt.Fprintln(w)
+	if width, ok := terminalWidth(w); ok && branding.HasColor() {
+		if sym, err := qr.Encode(url); err == nil && sym.Size+2*qrQuietZone+2 <= width {
+			fmt.Fprintln(w, "  Scan to authorize:")
+			fmt.Fprintln(w)
+			renderQR(w, sym)
+			fmt.Fprintln(w)
+		}
+	}
+	fmt.Fprintf(w, "  Go to: %s\n\n", url)
+	printFramedCode(w, code)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "  Waiting for approval...")
+}
+
+// terminalWidth returns the column count of f if it is a terminal.
+func terminalWidth(f *os.File) (int, bool) {
+	if !term.IsTerminal(int(f.Fd())) {
+		return 0, false
+	}
+	width, _, err := term.GetSize(int(f.Fd()))
+	if err != nil || width <= 0 {
+		return 0, false
+	}
+	return width, true
+}
+
+// renderQR draws sym with explicit white-on-black colours so it scans the
+// same on light and dark terminal themes.
+func renderQR(w io.Writer, sym *qr.Code) {
+	lo, hi := -qrQuietZone, sym.Size+qrQuietZone
+	for y := lo; y < hi; y += 2 {
+		var line strings.Builder
+		line.WriteString("  \033[97;40m")
+		for x := lo; x < hi; x++ {
+			top, bottom := !sym.Dark(x, y), !sym.Dark(x, y+1)
+			if y+1 >= hi {
+				bottom = false
+			}
+			switch {
+			case top && bottom:
+				line.WriteString("█")
+			case top:
+				line.WriteString("▀")
+			case bottom:
+				line.WriteString("▄")
+			default:
+				line.WriteString(" ")
+			}
+		}
+		line.WriteString("\033[0m")
+		fmt.Fprintln(w, line.String())
+	}
+}
+
+// printFramedCode prints the user code spaced out in a branded box.
+func printFramedCode(w io.Writer, code string) {
+	spaced := strings.Join(strings.Split(code, ""), " ")
+	inner := len([]rune(spaced)) + 6
+	bar := strings.Repeat("─", inner)
+	fmt.Fprintln(w, "  Verification code:")
+	fmt.Fprintln(w, "  "+branding.Orange("┌"+bar+"┐"))
+	fmt.Fprintln(w, "  "+branding.Orange("│")+"   "+branding.BoldOrange(spaced)+"   "+branding.Orange("│"))
+	fmt.Fprintln(w, "  "+branding.Orange("└"+bar+"┘"))
+}
@@ -0,0 +1,58 @@
+package ui
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestCliConfirm(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"\n", false},
+		{"n\n", false},
+		{"maybe\n", false},
+		{"y\n", true},
+		{"YES\n", true},
+		{"", false}, // EOF
+	}
+	for _, tt := range tests {
+		pr, pw := io.Pipe()
+		go func() {
+			io.WriteString(pw, tt.input)
+			pw.Close()
+		}()
+		c := &CliUI{in: pr}
+		if got := c.Confirm("t", "Allow?"); got != tt.want {
+			t.Errorf("Confirm(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+// A prompt that timed out must not keep reading: the next prompt gets the
+// next answer, and a line typed for the abandoned prompt is discarded.
+func TestCliConfirmCancelled(t *testing.T) {
+	pr, pw := io.Pipe()
+	c := &CliUI{in: pr}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if c.ConfirmContext(ctx, "t", "Allow?") {
+		t.Fatal("cancelled prompt approved")
+	}
+
+	// Late answer to the first prompt, before the second one is shown
+	go io.WriteString(pw, "y\n")
+	time.Sleep(20 * time.Millisecond)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		io.WriteString(pw, "n\n")
+	}()
+	if c.Confirm("t", "Allow?") {
+		t.Fatal("second prompt consumed the answer meant for the first")
+	}
+}
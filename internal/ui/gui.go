@@ -1,8 +1,10 @@
 package ui
 
 import (
+	"context"
 	"os"
 	"runtime"
+	"sync/atomic"
 
 	"github.com/ncruces/zenity"
 )
@@ -40,7 +42,11 @@ func (g *GuiUI) Password(title, text string) (string, bool) {
 }
 
 func (g *GuiUI) Confirm(title, message string) bool {
-	err := zenity.Question(message, zenity.Title(title), zenity.OKLabel("Yes"), zenity.CancelLabel("No"))
+	return g.ConfirmContext(context.Background(), title, message)
+}
+
+func (g *GuiUI) ConfirmContext(ctx context.Context, title, message string) bool {
+	err := zenity.Question(message, zenity.Title(title), zenity.OKLabel("Yes"), zenity.CancelLabel("No"), zenity.Context(ctx))
 	return err == nil
 }
 
@@ -48,9 +54,22 @@ func (g *GuiUI) Form(title string, fields []FormField) (map[string]string, bool)
 	return showForm(title, fields)
 }
 
+// guiDisabled is set for processes with no desktop to show dialogs on.
+var guiDisabled atomic.Bool
+
+// DisableGui makes IsGuiAvailable report false, e.g. when running as a
+// Windows service in session 0 where dialogs would never be seen.
+func DisableGui() {
+	guiDisabled.Store(true)
+}
+
 // IsGuiAvailable returns true if native GUI dialogs can be shown.
-// Always true on Windows and macOS. On Linux, requires DISPLAY or WAYLAND_DISPLAY.
+// Always true on Windows and macOS unless DisableGui was called. On Linux,
+// requires DISPLAY or WAYLAND_DISPLAY.
 func IsGuiAvailable() bool {
+	if guiDisabled.Load() {
+		return false
+	}
 	switch runtime.GOOS {
 	case "windows", "darwin":
 		return true
@@ -1,5 +1,7 @@
 package ui
 
+import "context"
+
 // FormField describes a single field in a multi-field form dialog.
 type FormField struct {
 	Label    string // Display label shown to the user
@@ -22,6 +24,9 @@ type UI interface {
 	Password(title, text string) (string, bool)
 	// Confirm asks a yes/no question. Returns true for yes.
 	Confirm(title, message string) bool
+	// ConfirmContext is Confirm that gives up (returning false) once ctx is
+	// done, closing the prompt so it cannot answer a later one.
+	ConfirmContext(ctx context.Context, title, message string) bool
 	// Form shows a multi-field dialog in a single window.
 	// Returns field values keyed by FormField.Key, and true if submitted (false if cancelled).
 	Form(title string, fields []FormField) (map[string]string, bool)
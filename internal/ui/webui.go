@@ -3,16 +3,24 @@ package ui
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"mime"
+	"net"
 	"net/http"
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/4throck/obs-agent/internal/agent"
 	"github.com/4throck/obs-agent/internal/device"
+	"github.com/4throck/obs-agent/internal/obs"
+	"github.com/4throck/obs-agent/internal/obsdetect"
+	"github.com/4throck/obs-agent/internal/qr"
 	"github.com/4throck/obs-agent/internal/status"
 	"github.com/gorilla/websocket"
 )
@@ -39,6 +47,41 @@ type WizardConfig struct {
 	OBSDetected   bool
 	SavePath      string
 	ExistingToken string // set when mode is "obs" (re-setup with existing token)
+
+	// ConfigOptions is how the config at SavePath is written (profile,
+	// keyring, permissions)
+	ConfigOptions agent.ConfigOptions
+
+	// MinOBSVersion is the oldest obs-websocket version the OBS test
+	// accepts ("" = obs.DefaultMinVersion)
+	MinOBSVersion string
+
+	// ExistingOBSPass and LegacyPath are set when mode is "update": the
+	// migrated OBS password (kept, never sent to the page) and the
+	// plaintext config it was migrated from
+	ExistingOBSPass string
+	LegacyPath      string
+
+	// DefaultLaunchPath and DefaultLaunchArgs prefill the optional OBS
+	// launch settings (see agent.Config.OBSLaunchPath)
+	DefaultLaunchPath string
+	DefaultLaunchArgs []string
+
+	// UpdateVersion and UpdateURL are the release offered when mode is
+	// "update_available"
+	UpdateVersion string
+	UpdateURL     string
+
+	// DetectedOBSPass is the WebSocket password read from OBS's own
+	// config; the page is only told one exists, and a blank password
+	// entry uses it. OBSServerDisabled is set when that config has the
+	// WebSocket server off
+	DetectedOBSPass   string
+	OBSServerDisabled bool
+
+	// OBSSettingsPath is the OBS file those came from, which the wizard
+	// can edit to turn the WebSocket server on (see handleEnableOBSWS)
+	OBSSettingsPath string
 }
 
 // WizardResult holds the values collected by the setup wizard.
@@ -48,13 +91,35 @@ type WizardResult struct {
 	OBSPort int
 	OBSPass string
 	Saved   bool
+
+	// AgentName is the name the agent was authorized under (device flow only)
+	AgentName string
+
+	// OBSLaunchPath and OBSLaunchArgs start OBS when it is not running
+	// (empty = never)
+	OBSLaunchPath string
+	OBSLaunchArgs []string
+
+	// InstallUpdate is set when the user chose "Download & Update" in the
+	// update_available wizard
+	InstallUpdate bool
 }
 
+// DefaultWizardTimeout is how long a wizard waits for the user before
+// giving up (see SetWizardTimeout).
+const DefaultWizardTimeout = 15 * time.Minute
+
+// ErrWizardTimeout is returned by the wizard runners when the user did
+// not finish within the wizard timeout.
+var ErrWizardTimeout = errors.New("setup wizard timed out")
+
 // WizardRunner can run a full web-based setup wizard.
 type WizardRunner interface {
 	RunDeviceWizard(cfg WizardConfig) (*WizardResult, error)
 	RunManualWizard(cfg WizardConfig) (*WizardResult, error)
 	RunOBSWizard(cfg WizardConfig) (*WizardResult, error)
+	RunUpdateWizard(cfg WizardConfig) (*WizardResult, error)
+	RunUpdateAvailableWizard(version, downloadURL string) (*WizardResult, error)
 }
 
 // WebUI provides a branded web-based setup wizard.
@@ -70,6 +135,9 @@ type WebUI struct {
 	result *WizardResult
 	doneCh chan struct{}
 
+	// timeout bounds each wizard (0 = wait forever)
+	timeout time.Duration
+
 	// Device auth state
 	deviceFlow *device.Flow
 	deviceCode *device.CodeResponse
@@ -77,11 +145,21 @@ type WebUI struct {
 	authToken  string
 	authErr    error
 	pollCancel context.CancelFunc
+	retryMsg   string // set while RequestCode is backing off
 }
 
 // NewWebUI creates a web-based UI with a fallback for non-wizard dialogs.
 func NewWebUI(fallback UI) *WebUI {
-	return &WebUI{fallback: fallback}
+	return &WebUI{fallback: fallback, timeout: DefaultWizardTimeout}
+}
+
+// SetWizardTimeout sets how long each wizard waits for the user before
+// returning ErrWizardTimeout (0 waits forever), so an abandoned browser
+// tab does not keep the agent, and its instance lock, around for good.
+func (w *WebUI) SetWizardTimeout(d time.Duration) {
+	w.mu.Lock()
+	w.timeout = d
+	w.mu.Unlock()
 }
 
 // SetStatusServer wires the WebUI to use the given status server for its API.
@@ -91,11 +169,14 @@ func (w *WebUI) SetStatusServer(s *status.Server) {
 	s.HandleFunc("/api/wizard/state", corsWrap(w.handleState))
 	s.HandleFunc("/api/wizard/name", corsWrap(w.handleName))
 	s.HandleFunc("/api/wizard/poll", corsWrap(w.handlePoll))
+	s.HandleFunc("/api/wizard/cancel-auth", corsWrap(w.handleCancelAuth))
 	s.HandleFunc("/api/wizard/token", corsWrap(w.handleToken))
 	s.HandleFunc("/api/wizard/obs", corsWrap(w.handleOBS))
 	s.HandleFunc("/api/wizard/test-obs", corsWrap(w.handleTestOBS))
+	s.HandleFunc("/api/wizard/enable-obs-ws", corsWrap(requireTrustedOrigin(w.handleEnableOBSWS)))
 	s.HandleFunc("/api/wizard/save", corsWrap(w.handleSave))
 	s.HandleFunc("/api/wizard/done", corsWrap(w.handleDone))
+	s.HandleFunc("/api/wizard/update", corsWrap(w.handleUpdate))
 }
 
 // UI interface delegation — used for non-wizard dialogs (e.g. fatalWait)
@@ -107,6 +188,9 @@ func (w *WebUI) Confirm(title, msg string) bool               { return w.fallbac
 func (w *WebUI) Form(title string, fields []FormField) (map[string]string, bool) {
 	return w.fallback.Form(title, fields)
 }
+func (w *WebUI) ConfirmContext(ctx context.Context, title, msg string) bool {
+	return w.fallback.ConfirmContext(ctx, title, msg)
+}
 
 // --- Wizard runners ---
 
@@ -122,6 +206,41 @@ func (w *WebUI) RunOBSWizard(cfg WizardConfig) (*WizardResult, error) {
 	return w.runWizard("obs", cfg)
 }
 
+// RunUpdateWizard shows what the migration from a legacy plaintext config
+// changed and lets the user test the migrated OBS settings.
+func (w *WebUI) RunUpdateWizard(cfg WizardConfig) (*WizardResult, error) {
+	return w.runWizard("update", cfg)
+}
+
+// RunUpdateAvailableWizard offers the release the relay announced next to
+// the running version. It returns once the user picks "Download & Update"
+// (InstallUpdate set) or "Later"; installing is left to the caller. It
+// fails rather than replace another wizard that is still open.
+func (w *WebUI) RunUpdateAvailableWizard(version, downloadURL string) (*WizardResult, error) {
+	if w.statusSrv == nil {
+		return nil, fmt.Errorf("status server not configured — call SetStatusServer first")
+	}
+	w.mu.Lock()
+	open := w.doneCh != nil
+	if open {
+		select {
+		case <-w.doneCh:
+			open = false
+		default:
+		}
+	}
+	w.mu.Unlock()
+	if open {
+		return nil, fmt.Errorf("another wizard is open")
+	}
+
+	return w.runWizard("update_available", WizardConfig{
+		Version:       w.statusSrv.Snapshot().Version,
+		UpdateVersion: version,
+		UpdateURL:     downloadURL,
+	})
+}
+
 func (w *WebUI) runWizard(mode string, cfg WizardConfig) (*WizardResult, error) {
 	if w.statusSrv == nil {
 		return nil, fmt.Errorf("status server not configured — call SetStatusServer first")
@@ -132,12 +251,17 @@ func (w *WebUI) runWizard(mode string, cfg WizardConfig) (*WizardResult, error)
 	w.wizCfg = cfg
 	w.result = &WizardResult{
 		OBSPort: cfg.DefaultPort,
+		OBSPass: cfg.ExistingOBSPass,
 		Token:   cfg.ExistingToken,
+
+		OBSLaunchPath: cfg.DefaultLaunchPath,
+		OBSLaunchArgs: cfg.DefaultLaunchArgs,
 	}
 	w.doneCh = make(chan struct{})
 	w.authDone = make(chan struct{})
 	w.authToken = ""
 	w.authErr = nil
+	timeout := w.timeout
 	w.mu.Unlock()
 
 	// Open the remote wizard page — it calls our local API via CORS
@@ -150,19 +274,88 @@ func (w *WebUI) runWizard(mode string, cfg WizardConfig) (*WizardResult, error)
 		log.Printf("[wizard] Could not open browser: %v — open %s manually", err, wizardURL)
 	}
 
-	// Block until wizard completes
-	<-w.doneCh
+	// Block until wizard completes or the user has been gone too long
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	var err error
+	select {
+	case <-w.doneCh:
+	case <-expired:
+		log.Printf("[wizard] No response after %v — giving up", timeout)
+		err = ErrWizardTimeout
+		w.finish()
+	}
 
+	w.mu.Lock()
 	if w.pollCancel != nil {
 		w.pollCancel()
 	}
+	w.mu.Unlock()
 
+	if err != nil {
+		return nil, err
+	}
 	return w.result, nil
 }
 
+// finish ends the running wizard, once.
+func (w *WebUI) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	select {
+	case <-w.doneCh:
+	default:
+		close(w.doneCh)
+	}
+}
+
 // --- CORS wrapper ---
 
 // corsWrap wraps a handler with CORS headers so the remote wizard page can call the local API.
+// requireTrustedOrigin guards endpoints that change things outside the
+// agent (such as OBS's own config). POSTs must be JSON, which a cross-site
+// form or text/plain fetch cannot send without a CORS preflight, and a
+// browser Origin must be one of allowedOrigins or the local wizard itself.
+//
+// SECURITY: the local origin only counts when the Host is loopback, so a
+// DNS-rebound page can't pass as the wizard.
+func requireTrustedOrigin(next http.HandlerFunc) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if origin := r.Header.Get("Origin"); origin != "" && !allowedOrigins[origin] && !isLocalOrigin(origin, r.Host) {
+			http.Error(rw, "forbidden origin", http.StatusForbidden)
+			return
+		}
+		if r.Method == "POST" {
+			if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+				http.Error(rw, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+				return
+			}
+		}
+		next(rw, r)
+	}
+}
+
+// isLocalOrigin reports whether origin is the wizard's own page, served
+// from a loopback host.
+func isLocalOrigin(origin, host string) bool {
+	if origin != "http://"+host {
+		return false
+	}
+	h, _, err := net.SplitHostPort(host)
+	if err != nil {
+		h = host
+	}
+	if h == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(h)
+	return ip != nil && ip.IsLoopback()
+}
+
 func corsWrap(next http.HandlerFunc) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("Origin")
@@ -188,10 +381,21 @@ func (w *WebUI) handleState(rw http.ResponseWriter, r *http.Request) {
 	writeJSON(rw, map[string]interface{}{
 		"mode":    w.mode,
 		"version": w.wizCfg.Version,
+		"retry":   w.retryMsg, // device code request backing off ("" = not)
 		"defaults": map[string]interface{}{
-			"host":         w.wizCfg.DefaultHost,
-			"port":         w.wizCfg.DefaultPort,
-			"obs_detected": w.wizCfg.OBSDetected,
+			"host":           w.wizCfg.DefaultHost,
+			"port":           w.wizCfg.DefaultPort,
+			"obs_detected":   w.wizCfg.OBSDetected,
+			"launch_path":    w.wizCfg.DefaultLaunchPath,
+			"launch_args":    strings.Join(w.wizCfg.DefaultLaunchArgs, " "),
+			"config_path":    w.wizCfg.SavePath,
+			"legacy_path":    w.wizCfg.LegacyPath,
+			"update_version": w.wizCfg.UpdateVersion,
+			"update_url":     w.wizCfg.UpdateURL,
+
+			"password_detected": w.wizCfg.DetectedOBSPass != "",
+			"obs_ws_disabled":   w.wizCfg.OBSServerDisabled,
+			"obs_ws_config":     w.wizCfg.OBSSettingsPath,
 		},
 	})
 }
@@ -215,15 +419,36 @@ func (w *WebUI) handleName(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A new name starts over: any earlier request or poll is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
 	w.mu.Lock()
+	w.resetAuth()
+	w.pollCancel = cancel
 	baseURL := relayToHTTPS(w.wizCfg.RelayURL)
 	version := w.wizCfg.Version
 	w.mu.Unlock()
 
 	flow := &device.Flow{BaseURL: baseURL, Version: version}
+	flow.OnRetry = func(attempt int, wait time.Duration, err error) {
+		log.Printf("[wizard] Device code request failed (%v) — retrying in %v", err, wait)
+		w.mu.Lock()
+		w.retryMsg = fmt.Sprintf("Server unreachable — retrying in %ds…", int(wait.Seconds()))
+		w.mu.Unlock()
+	}
+
+	// Retries can outlast the status server's write timeout
+	http.NewResponseController(rw).SetWriteDeadline(time.Time{})
 
 	log.Printf("[wizard] Requesting device authorization for %q...", name)
-	code, err := flow.RequestCode(context.Background(), name)
+	code, err := flow.RequestCode(ctx, name)
+	w.mu.Lock()
+	if ctx.Err() != nil {
+		w.mu.Unlock()
+		writeJSON(rw, map[string]interface{}{"error": "Authorization cancelled"})
+		return
+	}
+	w.retryMsg = ""
+	w.mu.Unlock()
 	if err != nil {
 		writeJSON(rw, map[string]interface{}{"error": fmt.Sprintf("Authorization failed: %v", err)})
 		return
@@ -236,7 +461,16 @@ func (w *WebUI) handleName(rw http.ResponseWriter, r *http.Request) {
 
 	if code.Status == "already_authorized" && code.Token != "" {
 		w.mu.Lock()
+		if ctx.Err() != nil {
+			w.mu.Unlock()
+			writeJSON(rw, map[string]interface{}{"error": "Authorization cancelled"})
+			return
+		}
 		w.result.Token = code.Token
+		w.result.AgentName = code.AgentName
+		if w.result.AgentName == "" {
+			w.result.AgentName = name
+		}
 		close(w.authDone)
 		w.mu.Unlock()
 		log.Printf("[wizard] Machine already authorized as %q", code.AgentName)
@@ -248,47 +482,96 @@ func (w *WebUI) handleName(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	// Start background polling (no browser redirect — user pastes token from dashboard)
-	pollCtx, cancel := context.WithCancel(context.Background())
 	w.mu.Lock()
-	w.pollCancel = cancel
-	w.authDone = make(chan struct{})
+	done := w.authDone
 	w.mu.Unlock()
 
-	go w.pollDeviceAuth(pollCtx, flow, code)
+	go w.pollDeviceAuth(ctx, flow, code, name, done)
 
-	writeJSON(rw, map[string]interface{}{
+	resp := map[string]interface{}{
 		"already_authorized": false,
 		"verification_url":   code.VerificationURL,
 		"user_code":          code.UserCode,
 		"poll_interval":      code.Interval,
-	})
+	}
+	// QR of the verification URL so it can be scanned from a phone
+	if c, err := qr.Encode(code.VerificationURL); err == nil {
+		resp["qr_svg"] = c.SVG()
+	}
+	writeJSON(rw, resp)
 }
 
-func (w *WebUI) pollDeviceAuth(ctx context.Context, flow *device.Flow, code *device.CodeResponse) {
+// pollDeviceAuth waits for the user to approve code and closes done. A
+// poll cancelled by a new name or cancel-auth leaves the state alone: it
+// already belongs to the next attempt.
+func (w *WebUI) pollDeviceAuth(ctx context.Context, flow *device.Flow, code *device.CodeResponse, name string, done chan struct{}) {
 	token, err := flow.PollForToken(ctx, code.DeviceCode, code.Interval)
 
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	if ctx.Err() != nil {
+		return
+	}
 	if err != nil {
 		w.authErr = err
 		log.Printf("[wizard] Device auth failed: %v", err)
 	} else {
 		w.authToken = token
 		w.result.Token = token
+		w.result.AgentName = name
 		log.Println("[wizard] Device authorized!")
 	}
 
 	select {
-	case <-w.authDone:
+	case <-done:
 	default:
-		close(w.authDone)
+		close(done)
+	}
+}
+
+// resetAuth cancels any device-auth request or poll and forgets its
+// outcome. Callers hold w.mu.
+func (w *WebUI) resetAuth() {
+	if w.pollCancel != nil {
+		w.pollCancel()
+		w.pollCancel = nil
+	}
+	w.deviceFlow = nil
+	w.deviceCode = nil
+	w.authDone = make(chan struct{})
+	w.authToken = ""
+	w.authErr = nil
+	w.retryMsg = ""
+	if w.result != nil {
+		w.result.Token = w.wizCfg.ExistingToken
+		w.result.AgentName = ""
+	}
+}
+
+// handleCancelAuth abandons the device authorization in progress (e.g. the
+// user picked the wrong account) so the wizard can start over from the
+// name step.
+func (w *WebUI) handleCancelAuth(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(rw, "POST only", 405)
+		return
 	}
+	w.mu.Lock()
+	w.resetAuth()
+	w.mu.Unlock()
+
+	log.Println("[wizard] Device authorization cancelled")
+	writeJSON(rw, map[string]interface{}{"ok": true})
 }
 
 func (w *WebUI) handlePoll(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	done := w.authDone
+	w.mu.Unlock()
+
 	select {
-	case <-w.authDone:
+	case <-done:
 		w.mu.Lock()
 		defer w.mu.Unlock()
 		if w.authErr != nil {
@@ -304,6 +587,13 @@ func (w *WebUI) handlePoll(rw http.ResponseWriter, r *http.Request) {
 			writeJSON(rw, map[string]string{"status": "complete"})
 		}
 	default:
+		w.mu.Lock()
+		retry := w.retryMsg
+		w.mu.Unlock()
+		if retry != "" {
+			writeJSON(rw, map[string]string{"status": "pending", "retry": retry})
+			return
+		}
 		writeJSON(rw, map[string]string{"status": "pending"})
 	}
 }
@@ -329,6 +619,7 @@ func (w *WebUI) handleToken(rw http.ResponseWriter, r *http.Request) {
 
 	w.mu.Lock()
 	w.result.Token = token
+	w.result.AgentName = ""
 	w.mu.Unlock()
 
 	writeJSON(rw, map[string]interface{}{"valid": true})
@@ -343,6 +634,10 @@ func (w *WebUI) handleOBS(rw http.ResponseWriter, r *http.Request) {
 		Host     string `json:"host"`
 		Port     int    `json:"port"`
 		Password string `json:"password"`
+
+		// Optional; pages that don't send them keep the current values
+		LaunchPath *string `json:"launch_path"`
+		LaunchArgs *string `json:"launch_args"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(rw, map[string]interface{}{"error": "invalid request"})
@@ -354,10 +649,25 @@ func (w *WebUI) handleOBS(rw http.ResponseWriter, r *http.Request) {
 	if port <= 0 || port > 65535 {
 		port = 4455
 	}
+	if req.LaunchPath != nil && strings.TrimSpace(*req.LaunchPath) != "" {
+		if _, err := os.Stat(strings.TrimSpace(*req.LaunchPath)); err != nil {
+			writeJSON(rw, map[string]interface{}{"error": "OBS launch path not found"})
+			return
+		}
+	}
 
 	w.mu.Lock()
 	w.result.OBSPort = port
 	w.result.OBSPass = req.Password
+	if req.Password == "" {
+		w.result.OBSPass = w.wizCfg.DetectedOBSPass
+	}
+	if req.LaunchPath != nil {
+		w.result.OBSLaunchPath = strings.TrimSpace(*req.LaunchPath)
+	}
+	if req.LaunchArgs != nil {
+		w.result.OBSLaunchArgs = strings.Fields(*req.LaunchArgs)
+	}
 	w.mu.Unlock()
 
 	writeJSON(rw, map[string]interface{}{"ok": true})
@@ -372,11 +682,21 @@ func (w *WebUI) handleTestOBS(rw http.ResponseWriter, r *http.Request) {
 		Host     string `json:"host"`
 		Port     int    `json:"port"`
 		Password string `json:"password"`
+
+		// Saved tests the settings the wizard holds (e.g. migrated ones)
+		Saved bool `json:"saved"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(rw, map[string]interface{}{"ok": false, "error": "invalid request"})
 		return
 	}
+	w.mu.Lock()
+	if req.Saved {
+		req.Port, req.Password = w.result.OBSPort, w.result.OBSPass
+	} else if req.Password == "" {
+		req.Password = w.wizCfg.DetectedOBSPass
+	}
+	w.mu.Unlock()
 
 	// OBS host is hardcoded — use the configured default, ignore client value
 	port := req.Port
@@ -384,34 +704,119 @@ func (w *WebUI) handleTestOBS(rw http.ResponseWriter, r *http.Request) {
 		port = 4455
 	}
 
-	addr := fmt.Sprintf("ws://%s:%d", w.wizCfg.DefaultHost, port)
-	dialer := &websocket.Dialer{HandshakeTimeout: 3 * time.Second}
-	ws, _, err := dialer.Dial(addr, nil)
+	// Long enough to tell an obs-websocket 4.x plugin, which sends no
+	// Hello, from a server that hangs
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+	addr := fmt.Sprintf("%s:%d", w.wizCfg.DefaultHost, port)
+	client, err := obs.Dial(ctx, addr, req.Password, 0)
 	if err != nil {
-		writeJSON(rw, map[string]interface{}{"ok": false, "error": "Could not connect to OBS"})
+		writeJSON(rw, map[string]interface{}{"ok": false, "error": testOBSError(err)})
 		return
 	}
-	defer ws.Close()
+	defer client.Close()
 
-	ws.SetReadDeadline(time.Now().Add(3 * time.Second))
-	_, data, err := ws.ReadMessage()
+	version, err := client.GetVersion(ctx)
 	if err != nil {
 		writeJSON(rw, map[string]interface{}{"ok": false, "error": "Connected but OBS did not respond"})
 		return
 	}
+	if err := obs.CheckMinVersion(version.OBSWebSocketVersion, w.wizCfg.MinOBSVersion); err != nil {
+		writeJSON(rw, map[string]interface{}{"ok": false, "error": err.Error()})
+		return
+	}
 
-	var msg struct {
-		Op int `json:"op"`
-		D  struct {
-			ObsWebSocketVersion string `json:"obsWebSocketVersion"`
-		} `json:"d"`
+	writeJSON(rw, map[string]interface{}{"ok": true, "version": version.OBSWebSocketVersion})
+}
+
+// handleEnableOBSWS turns the WebSocket server on in OBS's own settings
+// file, after the user confirmed it on the page. GET reports whether OBS
+// now listens on the WebSocket port, for the page to poll while the user
+// restarts OBS.
+//
+// POST {"restart_ok": false} refuses while OBS is running (or when that
+// cannot be told), since OBS may write its old settings back on exit; the
+// page asks again with restart_ok once the user accepts restarting OBS.
+func (w *WebUI) handleEnableOBSWS(rw http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		w.mu.Lock()
+		addr := net.JoinHostPort(w.wizCfg.DefaultHost, strconv.Itoa(w.wizCfg.DefaultPort))
+		w.mu.Unlock()
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+		}
+		writeJSON(rw, map[string]interface{}{"listening": err == nil})
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(rw, "GET or POST only", 405)
+		return
 	}
-	if err := json.Unmarshal(data, &msg); err != nil || msg.Op != 0 {
-		writeJSON(rw, map[string]interface{}{"ok": false, "error": "Connected but response was not OBS WebSocket"})
+	var req struct {
+		RestartOK bool `json:"restart_ok"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(rw, map[string]interface{}{"ok": false, "error": "invalid request"})
+		return
+	}
+
+	settings, err := obsdetect.Read()
+	if err != nil {
+		writeJSON(rw, map[string]interface{}{"ok": false, "error": "OBS's WebSocket settings were not found"})
+		return
+	}
+	running, err := obsdetect.Running()
+	if (running || err != nil) && !settings.Enabled && !req.RestartOK {
+		writeJSON(rw, map[string]interface{}{
+			"ok":          false,
+			"obs_running": true,
+			"error":       "OBS is running. Close OBS first, or restart it right after the change — otherwise OBS may put its old settings back when it exits.",
+		})
 		return
 	}
 
-	writeJSON(rw, map[string]interface{}{"ok": true, "version": msg.D.ObsWebSocketVersion})
+	resp := map[string]interface{}{"ok": true, "obs_running": running}
+	if !settings.Enabled {
+		if settings, err = obsdetect.Enable(settings); err != nil {
+			log.Printf("[wizard] Could not enable the OBS WebSocket server: %v", err)
+			writeJSON(rw, map[string]interface{}{"ok": false, "error": fmt.Sprintf("Could not change OBS's settings: %v", err)})
+			return
+		}
+		log.Printf("[wizard] Enabled the OBS WebSocket server in %s (backup: %s)", settings.Path, obsdetect.BackupPath(settings.Path))
+		resp["backup"] = obsdetect.BackupPath(settings.Path)
+	}
+
+	w.mu.Lock()
+	w.wizCfg.OBSServerDisabled = false
+	w.wizCfg.DetectedOBSPass = settings.Password
+	w.wizCfg.DefaultPort = settings.Port
+	w.result.OBSPort = settings.Port
+	w.mu.Unlock()
+
+	resp["port"] = settings.Port
+	resp["password_detected"] = settings.Password != ""
+	writeJSON(rw, resp)
+}
+
+// testOBSError turns an obs.Dial error into a message for the wizard.
+func testOBSError(err error) string {
+	var opErr *net.OpError
+	var closeErr *websocket.CloseError
+	var verErr *obs.ErrUnsupportedVersion
+	switch {
+	case errors.As(err, &opErr):
+		return "Could not connect to OBS"
+	case errors.As(err, &closeErr) && closeErr.Code == 4009: // AuthenticationFailed
+		return "OBS rejected the password"
+	case errors.Is(err, obs.ErrWebSocketV4):
+		return "Your obs-websocket plugin is too old — OBS 28+ required"
+	case errors.As(err, &verErr):
+		return err.Error()
+	case errors.Is(err, websocket.ErrBadHandshake):
+		return "Connected but response was not OBS WebSocket"
+	}
+	return "Connected but OBS did not respond"
 }
 
 func (w *WebUI) handleSave(rw http.ResponseWriter, r *http.Request) {
@@ -437,9 +842,23 @@ func (w *WebUI) handleSave(rw http.ResponseWriter, r *http.Request) {
 		OBSHost:  w.wizCfg.DefaultHost,
 		OBSPort:  result.OBSPort,
 		OBSPass:  result.OBSPass,
+
+		AgentName: result.AgentName,
+
+		OBSLaunchPath: result.OBSLaunchPath,
+		OBSLaunchArgs: result.OBSLaunchArgs,
+	}
+
+	// Preserve settings the wizard doesn't edit
+	if existing, err := agent.LoadConfig(savePath, w.wizCfg.ConfigOptions); err == nil {
+		cfg.AllowedExtraRequests = existing.AllowedExtraRequests
+		cfg.Notifications = existing.Notifications
+		if cfg.AgentName == "" && existing.Token == cfg.Token {
+			cfg.AgentName = existing.AgentName
+		}
 	}
 
-	if err := agent.SaveConfig(savePath, cfg); err != nil {
+	if err := agent.SaveConfig(savePath, cfg, w.wizCfg.ConfigOptions); err != nil {
 		writeJSON(rw, map[string]interface{}{"saved": false, "error": err.Error()})
 		return
 	}
@@ -459,17 +878,51 @@ func (w *WebUI) handleDone(rw http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := map[string]interface{}{"ok": true}
-	port := w.statusSrv.Port()
-	resp["status_url"] = remoteBaseURL + "/status?port=" + fmt.Sprintf("%d", port)
+	// Paired, so the dashboard's Reconfigure and Quit buttons work
+	resp["status_url"] = w.statusSrv.DashboardURL()
 	writeJSON(rw, resp)
 
 	go func() {
 		time.Sleep(100 * time.Millisecond)
-		select {
-		case <-w.doneCh:
-		default:
-			close(w.doneCh)
-		}
+		w.finish()
+	}()
+}
+
+// handleUpdate answers the update_available wizard: {"install": true} for
+// "Download & Update", false for "Later". Either ends the wizard.
+func (w *WebUI) handleUpdate(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(rw, "POST only", 405)
+		return
+	}
+	var req struct {
+		Install bool `json:"install"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(rw, map[string]interface{}{"error": "invalid request"})
+		return
+	}
+
+	w.mu.Lock()
+	if w.mode != "update_available" {
+		w.mu.Unlock()
+		writeJSON(rw, map[string]interface{}{"error": "no update is being offered"})
+		return
+	}
+	w.result.InstallUpdate = req.Install
+	version := w.wizCfg.UpdateVersion
+	w.mu.Unlock()
+
+	if req.Install {
+		log.Printf("[wizard] Update to %s accepted", version)
+	} else {
+		log.Printf("[wizard] Update to %s postponed", version)
+	}
+	writeJSON(rw, map[string]interface{}{"ok": true})
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		w.finish()
 	}()
 }
 
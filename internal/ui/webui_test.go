@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireTrustedOrigin(t *testing.T) {
+	tests := []struct {
+		name        string
+		host        string
+		origin      string
+		contentType string
+		want        int
+	}{
+		{"local wizard", "127.0.0.1:8765", "http://127.0.0.1:8765", "application/json", 200},
+		{"hosted wizard", "127.0.0.1:8765", "https://agent.4throck.cloud", "application/json; charset=utf-8", 200},
+		{"no origin", "127.0.0.1:8765", "", "application/json", 200},
+		{"foreign origin", "127.0.0.1:8765", "https://evil.example", "application/json", 403},
+		{"dns rebinding", "evil.example:8765", "http://evil.example:8765", "application/json", 403},
+		{"text/plain", "127.0.0.1:8765", "http://127.0.0.1:8765", "text/plain", 415},
+		{"form", "127.0.0.1:8765", "", "application/x-www-form-urlencoded", 415},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := requireTrustedOrigin(func(rw http.ResponseWriter, r *http.Request) {})
+			req := httptest.NewRequest("POST", "http://"+tt.host+"/api/wizard/enable-obs-ws", strings.NewReader(`{"restart_ok":true}`))
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			h(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleStateRetry(t *testing.T) {
+	w := NewWebUI(nil)
+	for _, retry := range []string{"", "Server unreachable — retrying in 4s…"} {
+		w.mu.Lock()
+		w.retryMsg = retry
+		w.mu.Unlock()
+
+		rec := httptest.NewRecorder()
+		w.handleState(rec, httptest.NewRequest("GET", "/api/wizard/state", nil))
+		var st struct {
+			Retry string `json:"retry"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+			t.Fatal(err)
+		}
+		if st.Retry != retry {
+			t.Errorf("retry = %q, want %q", st.Retry, retry)
+		}
+	}
+}
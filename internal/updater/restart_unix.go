@@ -0,0 +1,15 @@
+//go:build !windows
+
+package updater
+
+import (
+	"os"
+	"syscall"
+)
+
+// Restart replaces the current process with exe, keeping the PID, args and
+// environment (so service managers see the same process). Only returns on
+// error. Release the instance lock and stop listeners first.
+func Restart(exe string) error {
+	return syscall.Exec(exe, os.Args, os.Environ())
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package updater
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Restart starts exe with the current args and environment. Windows has no
+// exec(2); on success the caller must exit so the new process can take the
+// instance lock. Release the lock and stop listeners first.
+func Restart(exe string) error {
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = os.Environ()
+	return cmd.Start()
+}
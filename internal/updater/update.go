@@ -0,0 +1,151 @@
+// Package updater downloads, verifies and installs a new agent binary.
+package updater
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/4throck/obs-agent/internal/integrity"
+)
+
+const (
+	// maxBinarySize caps the download; release binaries are ~10 MB.
+	maxBinarySize   = 256 * 1024 * 1024
+	downloadTimeout = 5 * time.Minute
+)
+
+// Apply downloads the binary at downloadURL, verifies its SHA256 against
+// the release manifest (which must be for version) and, when non-empty,
+// against wantSHA256 from the relay's notice, then atomically replaces
+// the running executable. It returns the executable path to re-exec. On
+// any error the running binary is left untouched.
+//
+// SECURITY: the download URL and hash come from the relay and are not
+// trusted on their own — only a binary whose hash matches a manifest signed
+// by the release key is installed. wantSHA256 only catches a
+// relay/manifest disagreement early.
+func Apply(ctx context.Context, downloadURL, version, wantSHA256, manifestURL string) (string, error) {
+	u, err := url.Parse(downloadURL)
+	if err != nil || u.Scheme != "https" {
+		return "", fmt.Errorf("refusing non-HTTPS download URL %q", downloadURL)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("resolve executable: %w", err)
+	}
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return "", fmt.Errorf("resolve executable: %w", err)
+	}
+
+	// Download next to the executable so the final rename is atomic
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".obs-agent-update-*")
+	if err != nil {
+		return "", fmt.Errorf("create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once renamed
+
+	if err := download(ctx, downloadURL, tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("write update: %w", err)
+	}
+
+	result, err := integrity.VerifyFile(manifestURL, tmpPath)
+	if err != nil {
+		return "", fmt.Errorf("verify update: %w", err)
+	}
+	if err := checkResult(result, version, wantSHA256); err != nil {
+		return "", err
+	}
+
+	if err := os.Chmod(tmpPath, 0755); err != nil {
+		return "", fmt.Errorf("chmod update: %w", err)
+	}
+	if err := replace(exe, tmpPath); err != nil {
+		return "", err
+	}
+	return exe, nil
+}
+
+// checkResult decides whether a verified download may be installed.
+//
+// SECURITY: unlike the startup self-check, an update always needs a signed
+// manifest, whatever -strict-integrity says: TLS trust alone would let
+// whoever serves the manifest choose the binary that replaces this one.
+func checkResult(result *integrity.Result, version, wantSHA256 string) error {
+	if result.Signature != integrity.SignatureVerified {
+		return fmt.Errorf("update manifest is not signed by the release key — refusing")
+	}
+	if !result.Match {
+		return fmt.Errorf("update SHA256 %s does not match manifest %s — refusing", result.Actual, result.Expected)
+	}
+	if wantSHA256 != "" && !strings.EqualFold(wantSHA256, result.Actual) {
+		return fmt.Errorf("update SHA256 %s does not match the announced %s — refusing", result.Actual, wantSHA256)
+	}
+	if result.Version != version {
+		return fmt.Errorf("manifest is for %s, relay announced %s — refusing", result.Version, version)
+	}
+	return nil
+}
+
+func download(ctx context.Context, downloadURL string, w io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("download update: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download update: HTTP %d", resp.StatusCode)
+	}
+	n, err := io.Copy(w, io.LimitReader(resp.Body, maxBinarySize+1))
+	if err != nil {
+		return fmt.Errorf("download update: %w", err)
+	}
+	if n > maxBinarySize {
+		return fmt.Errorf("download update: larger than %d bytes", maxBinarySize)
+	}
+	return nil
+}
+
+// replace swaps newPath into exe. Windows cannot overwrite a running
+// executable but can rename it, so the old binary is moved aside first.
+func replace(exe, newPath string) error {
+	if runtime.GOOS != "windows" {
+		if err := os.Rename(newPath, exe); err != nil {
+			return fmt.Errorf("install update: %w", err)
+		}
+		return nil
+	}
+
+	old := exe + ".old"
+	os.Remove(old) // left over from the previous update
+	if err := os.Rename(exe, old); err != nil {
+		return fmt.Errorf("move old binary aside: %w", err)
+	}
+	if err := os.Rename(newPath, exe); err != nil {
+		os.Rename(old, exe)
+		return fmt.Errorf("install update: %w", err)
+	}
+	return nil
+}
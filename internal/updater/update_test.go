@@ -0,0 +1,117 @@
+package updater
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/4throck/obs-agent/internal/integrity"
+)
+
+func TestCheckResult(t *testing.T) {
+	signed := integrity.Result{Match: true, Actual: "abc", Expected: "abc", Version: "1.2.0", Signature: integrity.SignatureVerified}
+
+	tests := []struct {
+		name    string
+		modify  func(r *integrity.Result)
+		wantSHA string
+		wantErr string
+	}{
+		{name: "signed and matching"},
+		{name: "announced hash matches", wantSHA: "ABC"},
+		{name: "unsigned", modify: func(r *integrity.Result) { r.Signature = integrity.SignatureUnavailable }, wantErr: "not signed"},
+		{name: "no signature state", modify: func(r *integrity.Result) { r.Signature = "" }, wantErr: "not signed"},
+		{name: "hash mismatch", modify: func(r *integrity.Result) { r.Match = false }, wantErr: "does not match manifest"},
+		{name: "announced hash differs", wantSHA: "def", wantErr: "announced"},
+		{name: "other version", modify: func(r *integrity.Result) { r.Version = "1.1.0" }, wantErr: "relay announced"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := signed
+			if tt.modify != nil {
+				tt.modify(&r)
+			}
+			err := checkResult(&r, "1.2.0", tt.wantSHA)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("checkResult: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("checkResult = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// manifestServer serves a manifest listing path's hash for this platform,
+// with a signature under key when key is non-nil (404 otherwise).
+func manifestServer(t *testing.T, path string, key ed25519.PrivateKey) *httptest.Server {
+	t.Helper()
+	hash, err := integrity.FileHash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"version": "1.2.0",
+		"builds":  []map[string]string{{"os": runtime.GOOS, "arch": runtime.GOARCH, "sha256": hash}},
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/manifest.json":
+			w.Write(body)
+		case r.URL.Path == "/manifest.json"+integrity.SignatureSuffix && key != nil:
+			w.Write([]byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, body))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestUnsignedManifestRejected(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := integrity.ManifestPublicKey
+	integrity.ManifestPublicKey = base64.StdEncoding.EncodeToString(pub)
+	t.Cleanup(func() { integrity.ManifestPublicKey = old })
+
+	bin := filepath.Join(t.TempDir(), "obs-agent")
+	if err := os.WriteFile(bin, []byte("new release"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		key     ed25519.PrivateKey
+		wantErr bool
+	}{
+		{name: "signed", key: priv},
+		{name: "no signature", key: nil, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := manifestServer(t, bin, tt.key)
+			result, err := integrity.VerifyFile(srv.URL+"/manifest.json", bin)
+			if err != nil {
+				t.Fatalf("VerifyFile: %v", err)
+			}
+			err = checkResult(result, "1.2.0", "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkResult = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}